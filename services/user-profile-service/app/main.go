@@ -1,11 +1,34 @@
 package main
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
 	"os"
+	"time"
+
+	"github.com/monst/story-craft/services/user-profile-service/bio"
+	"github.com/monst/story-craft/services/user-profile-service/config"
+	"github.com/monst/story-craft/services/user-profile-service/cron"
+	"github.com/monst/story-craft/services/user-profile-service/digest"
+	"github.com/monst/story-craft/services/user-profile-service/genres"
+	"github.com/monst/story-craft/services/user-profile-service/idempotency"
+	"github.com/monst/story-craft/services/user-profile-service/jobs"
+	"github.com/monst/story-craft/services/user-profile-service/lifecycle"
+	"github.com/monst/story-craft/services/user-profile-service/logging"
+	"github.com/monst/story-craft/services/user-profile-service/mail"
+	"github.com/monst/story-craft/services/user-profile-service/metrics"
+	"github.com/monst/story-craft/services/user-profile-service/notifications"
+	"github.com/monst/story-craft/services/user-profile-service/push"
+	"github.com/monst/story-craft/services/user-profile-service/ratelimit"
+	"github.com/monst/story-craft/services/user-profile-service/realtime"
 	"github.com/monst/story-craft/services/user-profile-service/router"
+	"github.com/monst/story-craft/services/user-profile-service/screening"
+	"github.com/monst/story-craft/services/user-profile-service/tracing"
 	"github.com/monst/story-craft/services/user-profile-service/utils"
-	
+	"github.com/redis/go-redis/v9"
+
 	// Импортируем документацию Swagger
 	_ "github.com/monst/story-craft/services/user-profile-service/docs"
 )
@@ -33,32 +56,216 @@ import (
 // @bearerFormat JWT
 
 func main() {
+	// Ключи VAPID создаются, когда конфигурации ещё нет: им она не нужна
+	if len(os.Args) == 3 && os.Args[1] == "push" && os.Args[2] == "keys" {
+		os.Exit(generateVAPIDKeys())
+	}
+
+	// Загрузка конфигурации: при ошибке выводим сразу все недостающие параметры
+	cfg, err := config.Load()
+	if err != nil {
+		slog.Error("Не удалось загрузить конфигурацию", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(cfg, os.Args[1:]))
+	}
+
+	// Все логи сервиса, включая стандартный пакет log, идут через slog
+	slog.SetDefault(logging.New(cfg.Logging, os.Stderr))
+
+	if err := run(cfg); err != nil {
+		slog.Error("Сервер остановлен с ошибкой", slog.Any("error", err))
+		os.Exit(1)
+	}
+}
+
+// run поднимает зависимости и HTTP-сервер и блокируется до сигнала остановки.
+// Остановка идёт в обратном порядке: HTTP-сервер, фоновые задачи, пул БД.
+func run(cfg *config.Config) error {
+	lc := lifecycle.New()
+
+	// Трейсинг останавливается последним, чтобы выгрузить спаны остановки
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		return fmt.Errorf("не удалось настроить трейсинг: %w", err)
+	}
+	lc.OnStop("tracing", shutdownTracing)
+
 	// Инициализация базы данных
-	db, err := utils.SetupDatabase()
+	db, err := utils.SetupDatabase(cfg.Database)
 	if err != nil {
-		log.Fatalf("Не удалось подключиться к базе данных: %v", err)
+		return fmt.Errorf("не удалось подключиться к базе данных: %w", err)
 	}
 
-	// Закрытие соединения с БД после завершения работы программы
+	// Пул соединений закрывается последним, когда запросы и фоновые задачи уже завершены
 	sqlDB, err := db.DB()
 	if err != nil {
-		log.Fatalf("Не удалось получить экземпляр *sql.DB: %v", err)
+		return fmt.Errorf("не удалось получить экземпляр *sql.DB: %w", err)
+	}
+	lc.OnStop("database", func(context.Context) error { return sqlDB.Close() })
+
+	// Метрики запросов к БД и заполненности пула
+	m := metrics.New()
+	if err := db.Use(m.GormPlugin()); err != nil {
+		return fmt.Errorf("не удалось подключить метрики GORM: %w", err)
+	}
+	m.WatchDBPool("primary", sqlDB)
+
+	// Спаны для запросов к БД
+	if err := db.Use(tracing.GormPlugin()); err != nil {
+		return fmt.Errorf("не удалось подключить трейсинг GORM: %w", err)
+	}
+
+	// Фоновая очистка истёкших ключей идемпотентности
+	lc.Go("idempotency-cleanup", idempotency.NewDBStore(db).Cleanup(time.Hour))
+	// Фоновая очистка уведомлений с истёкшим сроком хранения
+	lc.Go("notifications-cleanup", notifications.NewStore(db, nil, nil).Cleanup(cfg.Notifications.CleanupInterval))
+
+	// Отправка писем из очереди; шаблоны проверяются при запуске
+	var emailQueue *mail.Queue
+	if cfg.Email.Enabled {
+		renderer, err := mail.NewRenderer(cfg.Email.DefaultLanguage)
+		if err != nil {
+			return fmt.Errorf("не удалось загрузить шаблоны писем: %w", err)
+		}
+		emailQueue = mail.NewQueue(db, cfg.Email)
+		worker := mail.NewWorker(emailQueue, renderer, mail.NewSMTPTransport(cfg.Email), cfg.Email, m)
+		lc.Go("email-worker", worker.Run)
+	}
+
+	// Отправка push из очереди в браузеры
+	if cfg.WebPush.Enabled {
+		vapid, err := push.NewVAPID(cfg.WebPush.VAPIDPrivateKey, cfg.WebPush.Subject)
+		if err != nil {
+			return fmt.Errorf("не удалось загрузить ключ VAPID: %w", err)
+		}
+		sender := push.NewSender(&http.Client{Timeout: cfg.WebPush.SendTimeout}, vapid, cfg.WebPush.TTL)
+		worker := push.NewWorker(push.NewStore(db, cfg.WebPush), sender, cfg.WebPush, m)
+		lc.Go("push-worker", worker.Run)
+	}
+
+	// Фоновые задачи из очереди в БД: их разбирают все экземпляры сервиса
+	jobQueue := jobs.NewQueue(db, cfg.Jobs)
+	jobWorker := jobs.NewWorker(jobQueue, cfg.Jobs, m)
+	jobs.Register(jobWorker, jobs.KindCleanup, func(ctx context.Context, _ struct{}) error {
+		_, err := jobQueue.Cleanup(ctx)
+		return err
+	})
+
+	// Задачи по расписанию: на всех экземплярах сервиса каждую ставит в очередь только один
+	scheduler := cron.New(sqlDB, m)
+	hourly, _ := cron.Parse("@hourly")
+	jobQueue.Schedule(scheduler, jobs.KindCleanup, hourly, jobs.KindCleanup, nil)
+	if cfg.Digest.Enabled {
+		// Расписание проверено при загрузке конфигурации
+		schedule, _ := cron.Parse(cfg.Digest.Schedule)
+		builder := digest.NewBuilder(db, cfg.Digest, emailQueue, cfg.Notifications.TTL, m)
+		jobs.Register(jobWorker, digest.JobKind, func(ctx context.Context, _ struct{}) error {
+			return builder.Run(ctx)
+		})
+		jobQueue.Schedule(scheduler, "digest", schedule, digest.JobKind, nil)
+	}
+	// HTML описаний, отрисованный прежней версией, обновляется после запуска новой
+	jobs.Register(jobWorker, bio.JobKind, func(ctx context.Context, _ struct{}) error {
+		n, err := bio.Rerender(ctx, db)
+		if n > 0 {
+			slog.Info("Перерисованы описания профилей", slog.Int("count", n))
+		}
+		return err
+	})
+	if _, err := jobQueue.Enqueue(db, bio.JobKind, nil, jobs.Options{UniqueKey: bio.JobKind}); err != nil {
+		return fmt.Errorf("не удалось поставить перерисовку описаний: %w", err)
+	}
+	lc.Go("cron", scheduler.Run)
+	lc.Go("jobs", jobWorker.Run)
+
+	// События для клиентов приходят через LISTEN/NOTIFY от всех экземпляров сервиса
+	hub := realtime.NewHub(cfg.Realtime.ReplayBuffer, cfg.Realtime.MaxConnectionsPerUser)
+	lc.Go("realtime-listener", realtime.Listen(sqlDB, hub))
+
+	// Общее хранилище ограничения частоты для нескольких экземпляров сервиса
+	var rateLimitStore ratelimit.Store
+	if cfg.RateLimit.Enabled && cfg.RateLimit.Backend == "redis" {
+		opts, err := redis.ParseURL(cfg.RateLimit.RedisURL)
+		if err != nil {
+			return fmt.Errorf("неверный REDIS_URL: %w", err)
+		}
+		rdb := redis.NewClient(opts)
+		lc.OnStop("redis", func(context.Context) error { return rdb.Close() })
+		rateLimitStore = ratelimit.NewRedisStore(rdb, "user-profile:ratelimit:")
+	}
+
+	// Внешний классификатор текста профилей, если настроен
+	var classifier screening.Classifier
+	if cfg.Screening.ClassifierURL != "" {
+		classifier = screening.NewHTTPClassifier(cfg.Screening.ClassifierURL, cfg.Screening.ClassifierTimeout)
+	}
+
+	// Справочник жанров для любимых жанров профиля обновляется из story-service
+	catalog := genres.NewCatalog(cfg.Profile)
+	if cfg.Profile.GenresURL != "" {
+		lc.Go("genres-refresh", catalog.Run)
 	}
-	defer sqlDB.Close()
 
 	// Инициализация роутера
-	r := router.SetupRouter(db)
+	r := router.SetupRouter(router.Dependencies{
+		DB:             db,
+		Config:         cfg,
+		Lifecycle:      lc,
+		Metrics:        m,
+		Logger:         slog.Default(),
+		RateLimitStore: rateLimitStore,
+		Classifier:     classifier,
+		Hub:            hub,
+		Genres:         catalog,
+	})
+
+	srv := &http.Server{
+		Addr:              ":" + cfg.HTTP.Port,
+		Handler:           r,
+		ReadTimeout:       cfg.HTTP.ReadTimeout,
+		ReadHeaderTimeout: cfg.HTTP.ReadHeaderTimeout,
+		WriteTimeout:      cfg.HTTP.WriteTimeout,
+		IdleTimeout:       cfg.HTTP.IdleTimeout,
+	}
+	// Потоки событий сами не завершаются: при остановке они закрываются, и клиенты переподключаются к другим экземплярам
+	srv.RegisterOnShutdown(hub.Close)
+
+	port := cfg.HTTP.Port
+	slog.Info("Сервер запускается",
+		slog.String("port", port),
+		slog.String("swagger_ui", "http://localhost:"+port+"/swagger/index.html"),
+		slog.String("schema", "http://localhost:"+port+"/schema"),
+	)
 
-	// Запуск сервера на порту из env или 8080 по умолчанию
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	if err := lc.Run(context.Background(), srv, cfg.HTTP.ShutdownGracePeriod); err != nil {
+		return err
 	}
-	log.Printf("Сервер запускается на порту %s", port)
-	log.Printf("Документация Swagger доступна по адресу http://localhost:%s/swagger/index.html", port)
-	log.Printf("Схема Swagger доступна по адресу http://localhost:%s/schema", port)
-	
-	if err := r.Run(":" + port); err != nil {
-		log.Fatalf("Не удалось запустить сервер: %v", err)
+	slog.Info("Сервер остановлен")
+	return nil
+}
+
+// runCommand выполняет служебные подкоманды вместо запуска сервера.
+// Пока поддерживается только «config print» — вывод итоговой конфигурации без секретов;
+// «push keys» обрабатывается раньше, до загрузки конфигурации.
+func runCommand(cfg *config.Config, args []string) int {
+	if len(args) == 2 && args[0] == "config" && args[1] == "print" {
+		cfg.Print(os.Stdout)
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "Неизвестная команда: %v\nИспользование: main [config print | push keys]\n", args)
+	return 2
+}
+
+// generateVAPIDKeys выводит новую пару ключей VAPID для WEBPUSH_VAPID_PRIVATE_KEY.
+func generateVAPIDKeys() int {
+	privateKey, publicKey, err := push.GenerateKeys()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Не удалось создать ключи VAPID: %v\n", err)
+		return 1
 	}
+	fmt.Printf("WEBPUSH_VAPID_PRIVATE_KEY=%s\n# Открытый ключ для браузеров, его же отдаёт /push/vapid-public-key: %s\n", privateKey, publicKey)
+	return 0
 }
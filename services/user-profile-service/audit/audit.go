@@ -0,0 +1,129 @@
+// Package audit ведёт неизменяемый журнал изменений профилей: кто, когда, с какого
+// адреса и что именно поменял. Записи связаны в цепочку хешей, поэтому правку или
+// удаление записи в обход сервиса можно обнаружить проверкой Verify.
+package audit
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/monst/story-craft/services/user-profile-service/logging"
+	"github.com/monst/story-craft/services/user-profile-service/middleware"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"gorm.io/gorm"
+)
+
+// Действия, которые записываются в журнал.
+const (
+	ActionProfileCreated = "profile.created"
+	ActionProfileUpdated = "profile.updated"
+	ActionProfileDeleted = "profile.deleted"
+
+	ActionPreferencesUpdated = "preferences.updated"
+	ActionEmailBounced       = "email.bounced"
+	ActionEmailUnsubscribed  = "email.unsubscribed"
+	ActionEmailChanged       = "email.changed"
+	ActionEmailVerified      = "email.verified"
+	ActionEmailReverted      = "email.reverted"
+	ActionPushSubscribed     = "push.subscribed"
+	ActionPushRevoked        = "push.revoked"
+	ActionDigestUpdated      = "digest.updated"
+
+	ActionRoleChanged  = "role.changed"
+	ActionSuspended    = "profile.suspended"
+	ActionBanned       = "profile.banned"
+	ActionRestored     = "profile.restored"
+	ActionFieldsReset  = "profile.fields_reset"
+	ActionFlagResolved = "profile.flag_resolved"
+
+	ActionReported        = "profile.reported"
+	ActionReportActioned  = "report.actioned"
+	ActionReportDismissed = "report.dismissed"
+
+	// ActionJobRetried — администратор вернул в очередь фоновую задачу с ошибкой; целевого профиля нет.
+	ActionJobRetried = "job.retried"
+)
+
+// anonymousActor записывается, если запрос пришёл без x-user-object.
+const anonymousActor = "anonymous"
+
+// chainLockID — ключ advisory-блокировки, под которой добавляются записи:
+// следующая запись должна видеть хеш предыдущей. Цепочка одна на весь журнал,
+// поэтому блокировка общая: транзакции с записью в журнал на всех экземплярах
+// сервиса фиксируются по очереди, и блокировка держится до конца транзакции.
+// Для профилей, где запись в журнал сопровождает каждое изменение, это приемлемо,
+// но долгие транзакции с Record задерживают все остальные изменения.
+const chainLockID = 0x61756469745f6c67 // "audit_lg"
+
+// Actor — кто и откуда выполнил действие.
+type Actor struct {
+	ID        string
+	RequestID string
+	IP        string
+}
+
+// ActorFrom собирает сведения об авторе изменения из запроса.
+func ActorFrom(c *gin.Context) Actor {
+	actor := Actor{ID: anonymousActor, RequestID: c.GetString(logging.RequestIDKey), IP: c.ClientIP()}
+	if p, ok := middleware.CurrentPrincipal(c); ok {
+		actor.ID = p.UserID
+	}
+	return actor
+}
+
+// Entry — изменение для записи в журнал. Diff — изменённые поля профиля,
+// Details — дополнительные сведения о действии, сохраняются как JSON.
+type Entry struct {
+	Actor        Actor
+	Action       string
+	TargetUserID string
+	Reason       string
+	Diff         Diff
+	Details      any
+}
+
+// Record добавляет запись в журнал. Вызывается в той же транзакции, что и само
+// изменение, поэтому изменение не может пройти без записи в журнале.
+func Record(tx *gorm.DB, e Entry) error {
+	rec := models.AuditEntry{
+		ActorID:      e.Actor.ID,
+		Action:       e.Action,
+		TargetUserID: e.TargetUserID,
+		Reason:       e.Reason,
+		RequestID:    e.Actor.RequestID,
+		IP:           e.Actor.IP,
+		// Время округляется до микросекунд, как его хранит PostgreSQL, чтобы хеш совпадал при проверке
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+	if len(e.Diff) > 0 {
+		raw, err := json.Marshal(e.Diff)
+		if err != nil {
+			return err
+		}
+		rec.Diff = raw
+	}
+	if e.Details != nil {
+		raw, err := json.Marshal(e.Details)
+		if err != nil {
+			return err
+		}
+		rec.Details = raw
+	}
+
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", chainLockID).Error; err != nil {
+		return err
+	}
+	var prev models.AuditEntry
+	if err := tx.Select("hash").Order("id DESC").Limit(1).Find(&prev).Error; err != nil {
+		return err
+	}
+	rec.PrevHash = prev.Hash
+	hash, err := entryHash(rec)
+	if err != nil {
+		return err
+	}
+	rec.Hash = hash
+
+	return tx.Create(&rec).Error
+}
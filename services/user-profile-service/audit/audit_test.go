@@ -0,0 +1,68 @@
+package audit
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/monst/story-craft/services/user-profile-service/models"
+)
+
+func TestDiffOf(t *testing.T) {
+	now := time.Now()
+	before := models.Profile{Username: "alice", Bio: "старое", Role: models.RoleUser, UpdatedAt: now}
+	after := before
+	after.Bio = "новое"
+	after.UpdatedAt = now.Add(time.Second)
+	after.StatusUntil = &now
+
+	diff := DiffOf(before, after)
+	if len(diff) != 2 {
+		t.Fatalf("ожидали изменения bio и status_until, получили %+v", diff)
+	}
+	if diff["bio"] != (Change{From: "старое", To: "новое"}) {
+		t.Errorf("bio: %+v", diff["bio"])
+	}
+	if c := diff["status_until"]; c.From != nil || !c.To.(time.Time).Equal(now) {
+		t.Errorf("status_until: %+v", c)
+	}
+
+	// Одинаковый момент в разных часовых поясах изменением не считается
+	moved := before
+	moved.LastSeen = &now
+	again := moved
+	inUTC := now.UTC()
+	again.LastSeen = &inUTC
+	if diff := DiffOf(moved, again); len(diff) != 0 {
+		t.Errorf("ожидали пустую разницу, получили %+v", diff)
+	}
+}
+
+func TestEntryHashIgnoresJSONFormatting(t *testing.T) {
+	e := models.AuditEntry{
+		ActorID:      "actor",
+		Action:       ActionProfileUpdated,
+		TargetUserID: "target",
+		Diff:         json.RawMessage(`{"bio":{"from":"a","to":"b"},"avatar_url":{"from":null,"to":"x"}}`),
+		CreatedAt:    time.Date(2030, 1, 1, 12, 0, 0, 123456000, time.UTC),
+	}
+	h1, err := entryHash(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Так jsonb возвращает тот же документ: другой порядок ключей и пробелы
+	e.Diff = json.RawMessage(`{"avatar_url": {"to": "x", "from": null}, "bio": {"to": "b", "from": "a"}}`)
+	h2, err := entryHash(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h1 != h2 {
+		t.Error("хеш не должен зависеть от форматирования JSON")
+	}
+
+	e.PrevHash = "0000"
+	if h3, _ := entryHash(e); h3 == h1 {
+		t.Error("хеш должен зависеть от предыдущей записи")
+	}
+}
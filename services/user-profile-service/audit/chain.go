@@ -0,0 +1,115 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"gorm.io/gorm"
+)
+
+// chainedFields — поля записи, от которых считается хеш, в фиксированном порядке.
+type chainedFields struct {
+	PrevHash     string          `json:"prev_hash"`
+	ActorID      string          `json:"actor_id"`
+	Action       string          `json:"action"`
+	TargetUserID string          `json:"target_user_id"`
+	Reason       string          `json:"reason"`
+	Diff         json.RawMessage `json:"diff"`
+	Details      json.RawMessage `json:"details"`
+	RequestID    string          `json:"request_id"`
+	IP           string          `json:"ip"`
+	CreatedAt    int64           `json:"created_at"`
+}
+
+// entryHash — SHA-256 от полей записи и хеша предыдущей записи.
+func entryHash(e models.AuditEntry) (string, error) {
+	diff, err := canonicalJSON(e.Diff)
+	if err != nil {
+		return "", err
+	}
+	details, err := canonicalJSON(e.Details)
+	if err != nil {
+		return "", err
+	}
+	raw, err := json.Marshal(chainedFields{
+		PrevHash:     e.PrevHash,
+		ActorID:      e.ActorID,
+		Action:       e.Action,
+		TargetUserID: e.TargetUserID,
+		Reason:       e.Reason,
+		Diff:         diff,
+		Details:      details,
+		RequestID:    e.RequestID,
+		IP:           e.IP,
+		CreatedAt:    e.CreatedAt.UnixMicro(),
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// canonicalJSON приводит JSON к единому виду. PostgreSQL хранит jsonb со своим
+// порядком ключей и пробелами, поэтому хешируется не исходный текст, а его
+// нормализованная форма: ключи по алфавиту, без пробелов.
+func canonicalJSON(raw json.RawMessage) (json.RawMessage, error) {
+	if len(raw) == 0 {
+		return json.RawMessage("null"), nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// ErrChainBroken означает, что запись журнала изменена или удалена в обход сервиса.
+var ErrChainBroken = errors.New("цепочка хешей журнала нарушена")
+
+// VerifyResult — итог проверки цепочки.
+type VerifyResult struct {
+	Checked int `json:"checked"`
+	// BrokenAt — первая запись, на которой цепочка не сходится, 0 если всё в порядке.
+	BrokenAt uint64 `json:"broken_at,omitempty"`
+}
+
+// verifyBatch — сколько записей читается за раз при проверке.
+const verifyBatch = 500
+
+// Verify проходит журнал по порядку и пересчитывает хеши. Возвращает ErrChainBroken
+// с номером первой несовпавшей записи, если запись изменили, удалили или вставили в середину.
+func Verify(ctx context.Context, db *gorm.DB) (VerifyResult, error) {
+	var res VerifyResult
+	prevHash := ""
+	var lastID uint64
+	for {
+		var batch []models.AuditEntry
+		if err := db.WithContext(ctx).Where("id > ?", lastID).Order("id").Limit(verifyBatch).Find(&batch).Error; err != nil {
+			return res, err
+		}
+		for _, e := range batch {
+			hash, err := entryHash(e)
+			if err != nil {
+				return res, err
+			}
+			if e.PrevHash != prevHash || e.Hash != hash {
+				res.BrokenAt = e.ID
+				return res, ErrChainBroken
+			}
+			prevHash = e.Hash
+			lastID = e.ID
+			res.Checked++
+		}
+		if len(batch) < verifyBatch {
+			return res, nil
+		}
+	}
+}
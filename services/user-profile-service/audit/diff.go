@@ -0,0 +1,69 @@
+package audit
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Change — значение поля до и после изменения.
+type Change struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// Diff — изменённые поля по их имени в JSON.
+type Diff map[string]Change
+
+// skipFields — служебные поля, которые меняются при любом изменении, и поля, производные
+// от других (HTML описания); в журнал они не попадают.
+var skipFields = map[string]bool{"id": true, "created_at": true, "updated_at": true, "bio_html": true}
+
+// DiffOf сравнивает две версии структуры (например, models.Profile) и возвращает
+// изменившиеся поля. Для создания передаётся пустая структура в before,
+// для удаления — в after. Поля с тегом json:"-" не сравниваются.
+func DiffOf[T any](before, after T) Diff {
+	diff := Diff{}
+	bv, av := reflect.ValueOf(before), reflect.ValueOf(after)
+	t := bv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || name == "-" || name == "" || skipFields[name] {
+			continue
+		}
+		from, to := plain(bv.Field(i)), plain(av.Field(i))
+		if !equal(from, to) {
+			diff[name] = Change{From: from, To: to}
+		}
+	}
+	return diff
+}
+
+// plain разыменовывает указатели и превращает nil в отсутствие значения.
+func plain(v reflect.Value) any {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if t, ok := v.Interface().(time.Time); ok {
+		if t.IsZero() {
+			return nil
+		}
+		return t.UTC()
+	}
+	if v.IsZero() {
+		return nil
+	}
+	return v.Interface()
+}
+
+func equal(a, b any) bool {
+	if ta, ok := a.(time.Time); ok {
+		tb, ok := b.(time.Time)
+		return ok && ta.Equal(tb)
+	}
+	return reflect.DeepEqual(a, b)
+}
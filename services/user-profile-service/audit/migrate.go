@@ -0,0 +1,34 @@
+package audit
+
+import (
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"gorm.io/gorm"
+)
+
+// Migrate создаёт таблицу журнала и запрещает в ней UPDATE, DELETE и TRUNCATE
+// триггерами, чтобы записи не менялись по ошибке в коде или ручным запросом.
+// От роли сервиса это не защищает: она владеет таблицей и может отключить или
+// удалить триггеры, а цепочку хешей — пересчитать. Неизменяемость журнала при
+// доступе к БД от имени сервиса требует передать таблицу другой роли и отозвать
+// у роли сервиса UPDATE, DELETE и TRUNCATE.
+func Migrate(db *gorm.DB) error {
+	if err := db.AutoMigrate(&models.AuditEntry{}); err != nil {
+		return err
+	}
+
+	return db.Exec(`
+		CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+		BEGIN
+			RAISE EXCEPTION 'audit_log: журнал только для добавления записей';
+		END;
+		$$ LANGUAGE plpgsql;
+
+		CREATE OR REPLACE TRIGGER audit_log_no_update
+			BEFORE UPDATE OR DELETE ON audit_log
+			FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+
+		CREATE OR REPLACE TRIGGER audit_log_no_truncate
+			BEFORE TRUNCATE ON audit_log
+			FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
+	`).Error
+}
@@ -0,0 +1,59 @@
+// Package bio — описание профиля в разметке CommonMark и его безопасный HTML.
+// Описание хранится как есть, а HTML сохраняется рядом при изменении и отдаётся
+// клиентам в bio_html без повторной отрисовки.
+package bio
+
+import (
+	"bytes"
+	"html"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// Version — версия отрисовки. Увеличивается при изменении разметки или списка
+// разрешённых тегов, чтобы Rerender обновил сохранённый HTML.
+const Version = 1
+
+// markdown отрисовывает CommonMark с зачёркиванием и автоссылками. Встроенный HTML
+// не выводится: goldmark без WithUnsafe заменяет его комментарием.
+var markdown = goldmark.New(goldmark.WithExtensions(extension.Strikethrough, extension.Linkify))
+
+// policy — разрешённые теги описания. Изображений, таблиц, стилей и скриптов нет;
+// ссылки ведут только на http, https и mailto, открываются в новой вкладке и не
+// передают вес и адрес страницы.
+var policy = newPolicy()
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+	p.AllowElements("p", "br", "hr", "em", "strong", "del", "code", "pre", "blockquote", "ul", "ol", "li",
+		"h1", "h2", "h3", "h4", "h5", "h6")
+	p.AllowAttrs("start").Matching(bluemonday.Integer).OnElements("ol")
+	p.AllowAttrs("href").OnElements("a")
+	p.AllowURLSchemes("http", "https", "mailto")
+	p.RequireParseableURLs(true)
+	p.AllowRelativeURLs(false)
+	p.RequireNoFollowOnLinks(true)
+	p.RequireNoReferrerOnLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+	return p
+}
+
+// Render возвращает безопасный HTML описания source.
+func Render(source string) string {
+	if source == "" {
+		return ""
+	}
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(source), &buf); err != nil {
+		// Запись в bytes.Buffer не завершается ошибкой, но без HTML описание всё равно надо показать
+		return "<p>" + html.EscapeString(source) + "</p>"
+	}
+	return Sanitize(buf.String())
+}
+
+// Sanitize оставляет в HTML только разрешённые теги и атрибуты описания.
+func Sanitize(raw string) string {
+	return policy.Sanitize(raw)
+}
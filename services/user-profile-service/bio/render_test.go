@@ -0,0 +1,119 @@
+package bio
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name, source, want string
+	}{
+		{"пустое", "", ""},
+		{"абзацы", "Пишу *фэнтези* и **хоррор**.\n\nИ ~~детективы~~.",
+			"<p>Пишу <em>фэнтези</em> и <strong>хоррор</strong>.</p>\n<p>И <del>детективы</del>.</p>\n"},
+		{"ссылка", "[Мой сайт](https://alice.example.com)",
+			`<p><a href="https://alice.example.com" rel="nofollow noreferrer noopener" target="_blank">Мой сайт</a></p>` + "\n"},
+		{"автоссылка", "Читайте https://alice.example.com",
+			`<p>Читайте <a href="https://alice.example.com" rel="nofollow noreferrer noopener" target="_blank">https://alice.example.com</a></p>` + "\n"},
+		{"список", "3. три\n4. четыре", "<ol start=\"3\">\n<li>три</li>\n<li>четыре</li>\n</ol>\n"},
+		{"javascript", "[нажми](javascript:alert(1))", "<p>нажми</p>\n"},
+		{"изображение", "![кот](https://example.com/cat.png)", "<p></p>\n"},
+		{"встроенный html", "<script>alert(1)</script>\n\nтекст <b onclick=\"x\">жирный</b>", "\n<p>текст жирный</p>\n"},
+	}
+	for _, tt := range tests {
+		if got := Render(tt.source); got != tt.want {
+			t.Errorf("%s: ожидали %q, получили %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+// allowedAttrs — теги, которые могут остаться после отрисовки, и их атрибуты.
+var allowedAttrs = map[string][]string{
+	"p": nil, "br": nil, "hr": nil, "em": nil, "strong": nil, "del": nil, "code": nil, "pre": nil,
+	"blockquote": nil, "ul": nil, "ol": {"start"}, "li": nil,
+	"h1": nil, "h2": nil, "h3": nil, "h4": nil, "h5": nil, "h6": nil,
+	"a": {"href", "rel", "target"},
+}
+
+// checkSafe разбирает HTML так же, как браузер, и проверяет, что в нём только
+// разрешённые теги и атрибуты, а ссылки ведут на http, https или mailto.
+func checkSafe(t *testing.T, out, source string) {
+	t.Helper()
+	z := html.NewTokenizer(strings.NewReader(out))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return
+		case html.CommentToken, html.DoctypeToken:
+			t.Fatalf("комментарий или doctype в %q из %q", out, source)
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			attrs, ok := allowedAttrs[tok.Data]
+			if !ok {
+				t.Fatalf("тег %s в %q из %q", tok.Data, out, source)
+			}
+			for _, attr := range tok.Attr {
+				if !slices.Contains(attrs, attr.Key) {
+					t.Fatalf("атрибут %s у %s в %q из %q", attr.Key, tok.Data, out, source)
+				}
+				if attr.Key != "href" {
+					continue
+				}
+				u, err := url.Parse(attr.Val)
+				if err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "mailto") {
+					t.Fatalf("ссылка %q в %q из %q", attr.Val, out, source)
+				}
+				if u.Scheme != "mailto" && !strings.Contains(out, "nofollow") {
+					t.Fatalf("ссылка без rel=nofollow в %q", out)
+				}
+			}
+		}
+	}
+}
+
+var seeds = []string{
+	"<script>alert(1)</script>",
+	"<img src=x onerror=alert(1)>",
+	"[x](javascript:alert(1))",
+	"[x](JaVaScRiPt:alert(1))",
+	"[x](data:text/html;base64,PHNjcmlwdD4=)",
+	"[x](&#106;avascript:alert(1))",
+	"![x](javascript:alert(1))",
+	"<a href=\"https://example.com\" onclick=\"alert(1)\">x</a>",
+	"<svg/onload=alert(1)>",
+	"`<script>`",
+	"```\n<script>alert(1)</script>\n```",
+	"<<script>script>alert(1)<</script>/script>",
+	"[x](https://example.com \"title\\\" onmouseover=\\\"alert(1)\")",
+	"<p style=\"background:url(javascript:alert(1))\">x</p>",
+	"<!-- <script>alert(1)</script> -->",
+	"www.example.com/<script>",
+}
+
+func FuzzRender(f *testing.F) {
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, source string) {
+		out := Render(source)
+		checkSafe(t, out, source)
+		// Повторная очистка ничего не меняет: в сохранённом HTML нет того, что политика бы убрала
+		if again := Sanitize(out); again != out {
+			t.Fatalf("очистка не идемпотентна:\n%q\n%q", out, again)
+		}
+	})
+}
+
+func FuzzSanitize(f *testing.F) {
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, raw string) {
+		checkSafe(t, Sanitize(raw), raw)
+	})
+}
@@ -0,0 +1,44 @@
+package bio
+
+import (
+	"context"
+
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// JobKind — задача перерисовки описаний, HTML которых отрисован прежней версией.
+const JobKind = "bio.rerender"
+
+// rerenderBatch — сколько описаний перерисовывается за один запрос.
+const rerenderBatch = 200
+
+// Rerender сохраняет HTML описаний, отрисованных до текущей Version, включая удалённые
+// профили. Описание, изменённое владельцем во время перерисовки, не перезаписывается.
+// Возвращает число обновлённых профилей.
+func Rerender(ctx context.Context, db *gorm.DB) (int, error) {
+	db = db.WithContext(ctx).Clauses(dbresolver.Write).Session(&gorm.Session{})
+	updated := 0
+	var lastID string
+	for {
+		var batch []models.Profile
+		if err := db.Unscoped().Select("id", "bio").
+			Where("bio <> '' AND bio_html_version < ? AND id::text > ?", Version, lastID).
+			Order("id::text").Limit(rerenderBatch).Find(&batch).Error; err != nil {
+			return updated, err
+		}
+		for _, p := range batch {
+			res := db.Unscoped().Model(&models.Profile{}).Where("id = ? AND bio = ?", p.ID, p.Bio).
+				UpdateColumns(map[string]any{"bio_html": Render(p.Bio), "bio_html_version": Version})
+			if res.Error != nil {
+				return updated, res.Error
+			}
+			updated += int(res.RowsAffected)
+		}
+		if len(batch) < rerenderBatch {
+			return updated, nil
+		}
+		lastID = batch[len(batch)-1].ID.String()
+	}
+}
@@ -0,0 +1,43 @@
+package bio
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"github.com/monst/story-craft/services/user-profile-service/testutil"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(testutil.RunWithPostgres(m))
+}
+
+func TestRerender(t *testing.T) {
+	db := testutil.NewDB(t)
+	fx := testutil.LoadFixtures(t, db)
+
+	// Описание Alice отрисовано прежней версией, у Bob — текущей
+	db.Model(&models.Profile{}).Where("user_id = ?", fx.Alice.UserID).
+		UpdateColumns(map[string]any{"bio": "*курсив*", "bio_html": "<p>старый</p>", "bio_html_version": 0})
+	db.Model(&models.Profile{}).Where("user_id = ?", fx.Bob.UserID).
+		UpdateColumns(map[string]any{"bio": "*курсив*", "bio_html": "<p>актуальный</p>", "bio_html_version": Version})
+
+	n, err := Rerender(context.Background(), db)
+	if err != nil || n != 1 {
+		t.Fatalf("ожидали одно обновлённое описание, получили %d, %v", n, err)
+	}
+	var alice, bob models.Profile
+	db.Where("user_id = ?", fx.Alice.UserID).First(&alice)
+	db.Where("user_id = ?", fx.Bob.UserID).First(&bob)
+	if alice.BioHTML != "<p><em>курсив</em></p>\n" || alice.BioHTMLVersion != Version {
+		t.Errorf("ожидали перерисованный HTML: %q, версия %d", alice.BioHTML, alice.BioHTMLVersion)
+	}
+	if bob.BioHTML != "<p>актуальный</p>" {
+		t.Errorf("актуальный HTML не перерисовывается: %q", bob.BioHTML)
+	}
+
+	if n, err := Rerender(context.Background(), db); err != nil || n != 0 {
+		t.Errorf("повторный запуск ничего не меняет: %d, %v", n, err)
+	}
+}
@@ -0,0 +1,659 @@
+// Package config собирает настройки сервиса из значений по умолчанию, YAML-файла,
+// файла .env и переменных окружения (в порядке возрастания приоритета).
+package config
+
+import (
+	"crypto/ecdh"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/monst/story-craft/services/user-profile-service/cron"
+)
+
+// Config — полная конфигурация сервиса профилей.
+//
+// Теги полей:
+//   - env — имя переменной окружения;
+//   - yaml — ключ в YAML-файле (вложенные структуры образуют секции);
+//   - default — значение по умолчанию;
+//   - required — параметр обязателен, пустое значение считается ошибкой;
+//   - secret — значение скрывается при выводе конфигурации.
+type Config struct {
+	HTTP          HTTPConfig          `yaml:"http"`
+	Database      DatabaseConfig      `yaml:"database"`
+	CORS          CORSConfig          `yaml:"cors"`
+	Tracing       TracingConfig       `yaml:"tracing"`
+	Logging       LoggingConfig       `yaml:"logging"`
+	RateLimit     RateLimitConfig     `yaml:"rate_limit"`
+	Idempotency   IdempotencyConfig   `yaml:"idempotency"`
+	Screening     ScreeningConfig     `yaml:"screening"`
+	Notifications NotificationsConfig `yaml:"notifications"`
+	Realtime      RealtimeConfig      `yaml:"realtime"`
+	Email         EmailConfig         `yaml:"email"`
+	WebPush       WebPushConfig       `yaml:"web_push"`
+	Digest        DigestConfig        `yaml:"digest"`
+	Jobs          JobsConfig          `yaml:"jobs"`
+	Profile       ProfileConfig       `yaml:"profile"`
+}
+
+type HTTPConfig struct {
+	Port              string        `env:"PORT" yaml:"port" default:"8080"`
+	ReadTimeout       time.Duration `env:"HTTP_READ_TIMEOUT" yaml:"read_timeout" default:"15s"`
+	ReadHeaderTimeout time.Duration `env:"HTTP_READ_HEADER_TIMEOUT" yaml:"read_header_timeout" default:"5s"`
+	WriteTimeout      time.Duration `env:"HTTP_WRITE_TIMEOUT" yaml:"write_timeout" default:"30s"`
+	IdleTimeout       time.Duration `env:"HTTP_IDLE_TIMEOUT" yaml:"idle_timeout" default:"120s"`
+	// ShutdownGracePeriod — сколько ждать завершения текущих запросов и фоновых задач при остановке.
+	ShutdownGracePeriod time.Duration `env:"SHUTDOWN_GRACE_PERIOD" yaml:"shutdown_grace_period" default:"20s"`
+	// TrustedProxies — адреса прокси (API-шлюза), которым доверяется X-Forwarded-For
+	// при определении IP клиента. По умолчанию — частные сети docker.
+	TrustedProxies []string `env:"HTTP_TRUSTED_PROXIES" yaml:"trusted_proxies" default:"10.0.0.0/8,172.16.0.0/12,192.168.0.0/16,127.0.0.0/8,::1/128"`
+}
+
+type DatabaseConfig struct {
+	Host           string        `env:"DB_HOST" yaml:"host" required:"true"`
+	Port           string        `env:"DB_PORT" yaml:"port" default:"5432"`
+	User           string        `env:"DB_USER" yaml:"user" required:"true"`
+	Password       string        `env:"DB_PASSWORD" yaml:"password" required:"true" secret:"true"`
+	Name           string        `env:"DB_NAME" yaml:"name" required:"true"`
+	SSLMode        string        `env:"DB_SSLMODE" yaml:"sslmode" default:"disable"`
+	ConnectTimeout time.Duration `env:"DB_CONNECT_TIMEOUT" yaml:"connect_timeout" default:"5s"`
+	MaxOpenConns   int           `env:"DB_MAX_OPEN_CONNS" yaml:"max_open_conns" default:"25"`
+	MaxIdleConns   int           `env:"DB_MAX_IDLE_CONNS" yaml:"max_idle_conns" default:"10"`
+	// ConnMaxLifetime и ConnMaxIdleTime ограничивают жизнь соединений в пуле.
+	ConnMaxLifetime time.Duration `env:"DB_CONN_MAX_LIFETIME" yaml:"conn_max_lifetime" default:"30m"`
+	ConnMaxIdleTime time.Duration `env:"DB_CONN_MAX_IDLE_TIME" yaml:"conn_max_idle_time" default:"5m"`
+	// StatementTimeout — серверный statement_timeout для каждого соединения.
+	StatementTimeout time.Duration `env:"DB_STATEMENT_TIMEOUT" yaml:"statement_timeout" default:"10s"`
+	// QueryTimeout — дедлайн на работу с БД в рамках одного HTTP-запроса.
+	QueryTimeout time.Duration `env:"DB_QUERY_TIMEOUT" yaml:"query_timeout" default:"5s"`
+	// ReplicaHosts — реплики для чтения в формате host или host:port, учётные данные общие с основной БД.
+	ReplicaHosts []string `env:"DB_REPLICA_HOSTS" yaml:"replica_hosts"`
+	// ReadYourWritesWindow — сколько после изменения профиля его чтения идут в основную БД,
+	// чтобы пользователь не увидел устаревшие данные из отстающей реплики. Изменения
+	// помнит каждый экземпляр сервиса сам, поэтому при нескольких экземплярах с
+	// репликами нужна привязка запросов пользователя к экземпляру (sticky routing).
+	ReadYourWritesWindow time.Duration `env:"DB_READ_YOUR_WRITES_WINDOW" yaml:"read_your_writes_window" default:"5s"`
+	// SlowQueryThreshold — запросы дольше порога пишутся в лог с уровнем WARN, 0 отключает.
+	SlowQueryThreshold time.Duration `env:"DB_SLOW_QUERY_THRESHOLD" yaml:"slow_query_threshold" default:"200ms"`
+}
+
+// CORSConfig — политика CORS по умолчанию и переопределения для отдельных маршрутов.
+type CORSConfig struct {
+	// AllowedOrigins — разрешённые origin: точные (https://story-craft.io),
+	// с подстановкой поддомена (https://*.story-craft.io) или * для всех.
+	AllowedOrigins []string `env:"CORS_ALLOWED_ORIGINS" yaml:"allowed_origins" default:"*"`
+	// AllowCredentials разрешает cookies и Authorization; несовместим с * в AllowedOrigins.
+	AllowCredentials bool     `env:"CORS_ALLOW_CREDENTIALS" yaml:"allow_credentials" default:"false"`
+	AllowedMethods   []string `env:"CORS_ALLOWED_METHODS" yaml:"allowed_methods" default:"GET,POST,PUT,PATCH,DELETE"`
+	AllowedHeaders   []string `env:"CORS_ALLOWED_HEADERS" yaml:"allowed_headers" default:"Content-Type,Content-Length,Accept,Accept-Encoding,Authorization,Cache-Control,Origin,X-CSRF-Token,X-Requested-With,X-Request-ID,Idempotency-Key"`
+	// ExposedHeaders — заголовки ответа, доступные JavaScript на странице.
+	ExposedHeaders []string `env:"CORS_EXPOSED_HEADERS" yaml:"exposed_headers" default:"ETag,X-Request-ID,X-Trace-Id,Idempotent-Replayed"`
+	// MaxAge — сколько браузер может кешировать ответ на preflight-запрос.
+	MaxAge time.Duration `env:"CORS_MAX_AGE" yaml:"max_age" default:"10m"`
+	// Routes задаются только в YAML: первое переопределение с самым длинным
+	// подходящим префиксом пути заменяет заданные в нём поля политики.
+	Routes []CORSRoute `yaml:"routes"`
+}
+
+// CORSRoute — переопределение политики CORS для маршрутов с префиксом PathPrefix.
+// Незаданные поля наследуются из CORSConfig.
+type CORSRoute struct {
+	PathPrefix       string         `yaml:"path_prefix"`
+	AllowedOrigins   []string       `yaml:"allowed_origins"`
+	AllowCredentials *bool          `yaml:"allow_credentials"`
+	AllowedMethods   []string       `yaml:"allowed_methods"`
+	AllowedHeaders   []string       `yaml:"allowed_headers"`
+	ExposedHeaders   []string       `yaml:"exposed_headers"`
+	MaxAge           *time.Duration `yaml:"max_age"`
+}
+
+type TracingConfig struct {
+	// Exporter — куда отправлять трейсы: otlp, stdout, file или none.
+	Exporter string `env:"TRACING_EXPORTER" yaml:"exporter" default:"none"`
+	// Endpoint — адрес OTLP/HTTP коллектора, например http://otel-collector:4318.
+	Endpoint string `env:"OTEL_EXPORTER_OTLP_ENDPOINT" yaml:"endpoint"`
+	// FilePath — файл для экспортёра file, по одному спану в строке JSON.
+	FilePath    string  `env:"TRACING_FILE" yaml:"file" default:"traces.jsonl"`
+	SampleRatio float64 `env:"TRACING_SAMPLE_RATIO" yaml:"sample_ratio" default:"1"`
+	ServiceName string  `env:"OTEL_SERVICE_NAME" yaml:"service_name" default:"user-profile-service"`
+}
+
+type LoggingConfig struct {
+	// Level — минимальный уровень: debug, info, warn или error.
+	Level string `env:"LOG_LEVEL" yaml:"level" default:"info"`
+	// Format — json для продакшена или text для локальной разработки.
+	Format string `env:"LOG_FORMAT" yaml:"format" default:"json"`
+}
+
+// RateLimitConfig задаёт ограничение частоты запросов к профилям: отдельные
+// корзины для чтения и изменения, у каждого пользователя или IP-адреса свои.
+type RateLimitConfig struct {
+	Enabled bool `env:"RATE_LIMIT_ENABLED" yaml:"enabled" default:"true"`
+	// Backend — memory для одного экземпляра или redis для общего состояния.
+	Backend  string `env:"RATE_LIMIT_BACKEND" yaml:"backend" default:"memory"`
+	RedisURL string `env:"REDIS_URL" yaml:"redis_url" secret:"true"`
+	// ReadRate и WriteRate — запросов в секунду, Burst — сколько можно сделать подряд.
+	ReadRate   float64 `env:"RATE_LIMIT_READ_RATE" yaml:"read_rate" default:"10"`
+	ReadBurst  int     `env:"RATE_LIMIT_READ_BURST" yaml:"read_burst" default:"50"`
+	WriteRate  float64 `env:"RATE_LIMIT_WRITE_RATE" yaml:"write_rate" default:"1"`
+	WriteBurst int     `env:"RATE_LIMIT_WRITE_BURST" yaml:"write_burst" default:"10"`
+	// IPFactor — во сколько раз общая корзина всех пользователей одного IP-адреса больше
+	// корзины одного пользователя; ограничивает перебор userId в x-user-object с одного адреса.
+	IPFactor int `env:"RATE_LIMIT_IP_FACTOR" yaml:"ip_factor" default:"10"`
+}
+
+// IdempotencyConfig задаёт хранение ответов на запросы с Idempotency-Key.
+type IdempotencyConfig struct {
+	// TTL — сколько повтор запроса с тем же ключом получает сохранённый ответ.
+	TTL time.Duration `env:"IDEMPOTENCY_TTL" yaml:"ttl" default:"24h"`
+	// LockTimeout — через сколько незавершённая обработка ключа считается зависшей.
+	// Должен быть больше времени обработки запроса.
+	LockTimeout time.Duration `env:"IDEMPOTENCY_LOCK_TIMEOUT" yaml:"lock_timeout" default:"30s"`
+}
+
+// ScreeningConfig — проверка текста профилей на мат, ссылки и спам.
+// Для каждого правила задаётся действие: off, redact, hold или reject.
+type ScreeningConfig struct {
+	Enabled             bool   `env:"SCREENING_ENABLED" yaml:"enabled" default:"true"`
+	ProfanityAction     string `env:"SCREENING_PROFANITY_ACTION" yaml:"profanity_action" default:"reject"`
+	URLAction           string `env:"SCREENING_URL_ACTION" yaml:"url_action" default:"hold"`
+	SpamAction          string `env:"SCREENING_SPAM_ACTION" yaml:"spam_action" default:"hold"`
+	RepeatedCharsAction string `env:"SCREENING_REPEATED_CHARS_ACTION" yaml:"repeated_chars_action" default:"redact"`
+	// URLFields — поля, в которых ищутся ссылки. Описания нет по умолчанию: ссылки в нём
+	// входят в разметку Markdown и отрисовываются с rel="nofollow noreferrer noopener".
+	URLFields []string `env:"SCREENING_URL_FIELDS" yaml:"url_fields" default:"username,display_name,location,pronouns"`
+	// MaxRepeat — сколько одинаковых символов подряд допустимо.
+	MaxRepeat int `env:"SCREENING_MAX_REPEAT" yaml:"max_repeat" default:"4"`
+	// ClassifierURL — адрес внешнего классификатора; пустой — классификатор не используется.
+	ClassifierURL     string        `env:"SCREENING_CLASSIFIER_URL" yaml:"classifier_url"`
+	ClassifierAction  string        `env:"SCREENING_CLASSIFIER_ACTION" yaml:"classifier_action" default:"hold"`
+	ClassifierTimeout time.Duration `env:"SCREENING_CLASSIFIER_TIMEOUT" yaml:"classifier_timeout" default:"2s"`
+	// ExtraWords дополняют встроенные словари (только в YAML): word, stem* или *root*.
+	ExtraWords []string `yaml:"extra_words"`
+	// SpamPatterns — дополнительные регулярные выражения для спама (только в YAML).
+	SpamPatterns []string `yaml:"spam_patterns"`
+}
+
+// NotificationsConfig — входящие уведомления пользователей.
+type NotificationsConfig struct {
+	// IngestToken — общий секрет внутреннего API создания уведомлений (заголовок X-Internal-Token).
+	// Пустой — внутренний API отключён.
+	IngestToken string `env:"NOTIFICATIONS_INGEST_TOKEN" yaml:"ingest_token" secret:"true"`
+	// TTL — сколько хранится уведомление, если отправитель не указал срок.
+	TTL time.Duration `env:"NOTIFICATIONS_TTL" yaml:"ttl" default:"720h"`
+	// MaxTTL ограничивает срок, который может указать отправитель.
+	MaxTTL time.Duration `env:"NOTIFICATIONS_MAX_TTL" yaml:"max_ttl" default:"2160h"`
+	// CleanupInterval — как часто удаляются истёкшие уведомления.
+	CleanupInterval time.Duration `env:"NOTIFICATIONS_CLEANUP_INTERVAL" yaml:"cleanup_interval" default:"1h"`
+	// MaxRecipients — сколько получателей можно указать в одном запросе.
+	MaxRecipients int `env:"NOTIFICATIONS_MAX_RECIPIENTS" yaml:"max_recipients" default:"1000"`
+}
+
+// RealtimeConfig — потоки событий для клиентов по SSE и WebSocket.
+type RealtimeConfig struct {
+	// Heartbeat — как часто в открытый поток отправляется пустое сообщение, чтобы прокси не закрывали соединение.
+	Heartbeat time.Duration `env:"REALTIME_HEARTBEAT" yaml:"heartbeat" default:"25s"`
+	// MaxConnectionsPerUser — сколько потоков пользователь может держать открытыми на одном экземпляре;
+	// при N экземплярах за балансировщиком всего до N×MaxConnectionsPerUser.
+	MaxConnectionsPerUser int `env:"REALTIME_MAX_CONNECTIONS_PER_USER" yaml:"max_connections_per_user" default:"5"`
+	// ReplayBuffer — сколько последних событий хранится для повтора по Last-Event-ID.
+	ReplayBuffer int `env:"REALTIME_REPLAY_BUFFER" yaml:"replay_buffer" default:"1000"`
+	// WebSocket включает /events/ws для клиентов, которым не подходит SSE.
+	WebSocket bool `env:"REALTIME_WEBSOCKET" yaml:"websocket" default:"false"`
+}
+
+// EmailConfig — отправка писем пользователям через SMTP.
+type EmailConfig struct {
+	// Enabled включает очередь писем и фоновую отправку. Выключено — письма не ставятся в очередь.
+	Enabled bool   `env:"EMAIL_ENABLED" yaml:"enabled" default:"false"`
+	From    string `env:"EMAIL_FROM" yaml:"from" default:"Story Craft <no-reply@story-craft.io>"`
+	// DefaultLanguage — язык писем, если пользователь не выбрал свой: ru или en.
+	DefaultLanguage string `env:"EMAIL_DEFAULT_LANGUAGE" yaml:"default_language" default:"ru"`
+	// AppURL — адрес веб-приложения для ссылок в письмах.
+	AppURL string `env:"EMAIL_APP_URL" yaml:"app_url" default:"http://localhost:3000"`
+	// PublicURL — внешний адрес API сервиса для ссылки отписки в заголовке List-Unsubscribe.
+	PublicURL string `env:"EMAIL_PUBLIC_URL" yaml:"public_url" default:"http://localhost:8080"`
+	// SigningKey подписывает ссылки отписки и подтверждения адреса. Обязателен, если отправка включена.
+	SigningKey string `env:"EMAIL_SIGNING_KEY" yaml:"signing_key" secret:"true"`
+
+	SMTPHost     string `env:"EMAIL_SMTP_HOST" yaml:"smtp_host" default:"localhost"`
+	SMTPPort     int    `env:"EMAIL_SMTP_PORT" yaml:"smtp_port" default:"1025"`
+	SMTPUsername string `env:"EMAIL_SMTP_USERNAME" yaml:"smtp_username"`
+	SMTPPassword string `env:"EMAIL_SMTP_PASSWORD" yaml:"smtp_password" secret:"true"`
+	// SMTPTLS — none (локальный MailHog), starttls или tls (SMTPS, обычно порт 465).
+	SMTPTLS string `env:"EMAIL_SMTP_TLS" yaml:"smtp_tls" default:"none"`
+	// SendTimeout ограничивает отправку одного письма вместе с подключением к серверу.
+	SendTimeout time.Duration `env:"EMAIL_SEND_TIMEOUT" yaml:"send_timeout" default:"30s"`
+
+	// PollInterval — как часто фоновая отправка проверяет очередь.
+	PollInterval time.Duration `env:"EMAIL_POLL_INTERVAL" yaml:"poll_interval" default:"5s"`
+	// BatchSize — сколько писем забирается из очереди за один проход.
+	BatchSize int `env:"EMAIL_BATCH_SIZE" yaml:"batch_size" default:"20"`
+	// MaxAttempts — после стольких временных ошибок письмо считается неотправленным.
+	MaxAttempts int `env:"EMAIL_MAX_ATTEMPTS" yaml:"max_attempts" default:"8"`
+	// RetryBackoff — пауза перед первым повтором, дальше она удваивается до MaxRetryBackoff.
+	RetryBackoff    time.Duration `env:"EMAIL_RETRY_BACKOFF" yaml:"retry_backoff" default:"1m"`
+	MaxRetryBackoff time.Duration `env:"EMAIL_MAX_RETRY_BACKOFF" yaml:"max_retry_backoff" default:"6h"`
+	// DigestDelay — сколько копятся изменения фаз историй перед отправкой одного письма-сводки.
+	DigestDelay time.Duration `env:"EMAIL_DIGEST_DELAY" yaml:"digest_delay" default:"15m"`
+	// SoftBounceLimit — после стольких временных отказов подряд адрес считается недоступным.
+	SoftBounceLimit int `env:"EMAIL_SOFT_BOUNCE_LIMIT" yaml:"soft_bounce_limit" default:"3"`
+
+	// ChangeTTL — сколько действует ссылка подтверждения нового адреса.
+	ChangeTTL time.Duration `env:"EMAIL_CHANGE_TTL" yaml:"change_ttl" default:"24h"`
+	// RevertTTL — сколько действует ссылка отмены смены адреса, отправленная на старый адрес.
+	RevertTTL time.Duration `env:"EMAIL_REVERT_TTL" yaml:"revert_ttl" default:"168h"`
+}
+
+// WebPushConfig — уведомления в браузере через Web Push (RFC 8030) с ключами VAPID (RFC 8292).
+type WebPushConfig struct {
+	// Enabled включает подписки браузеров и фоновую отправку. Выключено — push не ставятся в очередь.
+	Enabled bool `env:"WEBPUSH_ENABLED" yaml:"enabled" default:"false"`
+	// VAPIDPrivateKey — закрытый ключ P-256 в base64url, открытый ключ для браузеров выводится из него.
+	// Создаётся командой «main push keys». После замены ключа браузеры должны подписаться заново.
+	VAPIDPrivateKey string `env:"WEBPUSH_VAPID_PRIVATE_KEY" yaml:"vapid_private_key" secret:"true"`
+	// Subject — контакт для push-сервисов на случай проблем с отправкой: mailto: или https:.
+	Subject string `env:"WEBPUSH_SUBJECT" yaml:"subject" default:"mailto:support@story-craft.io"`
+	// AllowedHosts — push-сервисы, на адреса которых принимаются подписки; *.host разрешает поддомены.
+	// Сервис отправляет запросы на адрес из подписки, поэтому произвольные адреса не принимаются.
+	AllowedHosts []string `env:"WEBPUSH_ALLOWED_HOSTS" yaml:"allowed_hosts" default:"fcm.googleapis.com,updates.push.services.mozilla.com,web.push.apple.com,*.notify.windows.com"`
+	// MaxSubscriptions — сколько устройств пользователя получают push; при подписке нового самая старая подписка удаляется.
+	MaxSubscriptions int `env:"WEBPUSH_MAX_SUBSCRIPTIONS" yaml:"max_subscriptions" default:"10"`
+	// TTL — сколько push-сервис хранит сообщение, пока браузер не в сети.
+	TTL time.Duration `env:"WEBPUSH_TTL" yaml:"ttl" default:"24h"`
+	// SendTimeout ограничивает отправку одного сообщения push-сервису.
+	SendTimeout time.Duration `env:"WEBPUSH_SEND_TIMEOUT" yaml:"send_timeout" default:"10s"`
+
+	// PollInterval — как часто фоновая отправка проверяет очередь.
+	PollInterval time.Duration `env:"WEBPUSH_POLL_INTERVAL" yaml:"poll_interval" default:"5s"`
+	// BatchSize — сколько сообщений забирается из очереди за один проход.
+	BatchSize int `env:"WEBPUSH_BATCH_SIZE" yaml:"batch_size" default:"50"`
+	// MaxAttempts — после стольких временных ошибок сообщение считается неотправленным.
+	MaxAttempts int `env:"WEBPUSH_MAX_ATTEMPTS" yaml:"max_attempts" default:"5"`
+	// RetryBackoff — пауза перед первым повтором, дальше она удваивается до MaxRetryBackoff.
+	RetryBackoff    time.Duration `env:"WEBPUSH_RETRY_BACKOFF" yaml:"retry_backoff" default:"30s"`
+	MaxRetryBackoff time.Duration `env:"WEBPUSH_MAX_RETRY_BACKOFF" yaml:"max_retry_backoff" default:"1h"`
+}
+
+// DigestConfig — сводки непрочитанных уведомлений за день или неделю по расписанию пользователей.
+type DigestConfig struct {
+	// Enabled включает сборку дайджестов. Выключено — расписания пользователей сохраняются, но сводки не приходят.
+	Enabled bool `env:"DIGEST_ENABLED" yaml:"enabled" default:"true"`
+	// Schedule — как часто в формате cron проверяются дайджесты, которым пора: каждый собирается
+	// в первый запуск после часа отправки пользователя, поэтому для поясов со сдвигом на полчаса
+	// нужна проверка чаще раза в час.
+	Schedule string `env:"DIGEST_SCHEDULE" yaml:"schedule" default:"*/5 * * * *"`
+	// BatchSize — сколько дайджестов собирается в одной транзакции.
+	BatchSize int `env:"DIGEST_BATCH_SIZE" yaml:"batch_size" default:"100"`
+	// MaxGroups — сколько групп «история и тип события» попадает в сводку, остальные только считаются.
+	MaxGroups int `env:"DIGEST_MAX_GROUPS" yaml:"max_groups" default:"20"`
+}
+
+// JobsConfig — очередь фоновых задач в PostgreSQL, которую разбирают все экземпляры сервиса.
+type JobsConfig struct {
+	// Concurrency — сколько задач экземпляр выполняет одновременно.
+	Concurrency int `env:"JOBS_CONCURRENCY" yaml:"concurrency" default:"4"`
+	// PollInterval — как часто свободный исполнитель проверяет очередь.
+	PollInterval time.Duration `env:"JOBS_POLL_INTERVAL" yaml:"poll_interval" default:"1s"`
+	// Timeout ограничивает одну попытку. Если экземпляр, забравший задачу, не сохранил
+	// результат за двойной Timeout, задача снова становится доступной.
+	Timeout time.Duration `env:"JOBS_TIMEOUT" yaml:"timeout" default:"5m"`
+	// MaxAttempts — сколько попыток у задачи, если при постановке не указано иное.
+	MaxAttempts int `env:"JOBS_MAX_ATTEMPTS" yaml:"max_attempts" default:"5"`
+	// RetryBackoff — пауза перед первым повтором, дальше она удваивается до MaxRetryBackoff.
+	RetryBackoff    time.Duration `env:"JOBS_RETRY_BACKOFF" yaml:"retry_backoff" default:"10s"`
+	MaxRetryBackoff time.Duration `env:"JOBS_MAX_RETRY_BACKOFF" yaml:"max_retry_backoff" default:"1h"`
+	// DrainTimeout — сколько при остановке ждать выполняемые задачи, прежде чем отменить их
+	// и вернуть в очередь. Должен быть меньше SHUTDOWN_GRACE_PERIOD.
+	DrainTimeout time.Duration `env:"JOBS_DRAIN_TIMEOUT" yaml:"drain_timeout" default:"15s"`
+	// Retention — сколько хранятся завершённые задачи, выполненные и с ошибкой.
+	Retention time.Duration `env:"JOBS_RETENTION" yaml:"retention" default:"336h"`
+}
+
+// ProfileConfig — ограничения расширенных полей профиля.
+type ProfileConfig struct {
+	// MaxLinks — сколько ссылок на соцсети и сайты можно указать в профиле.
+	MaxLinks int `env:"PROFILE_MAX_LINKS" yaml:"max_links" default:"5"`
+	// MaxLanguages — сколько языков можно указать в профиле.
+	MaxLanguages int `env:"PROFILE_MAX_LANGUAGES" yaml:"max_languages" default:"5"`
+	// MaxFavoriteGenres — сколько любимых жанров можно указать в профиле.
+	MaxFavoriteGenres int `env:"PROFILE_MAX_FAVORITE_GENRES" yaml:"max_favorite_genres" default:"10"`
+	// Genres — жанры story-service, из которых выбираются любимые, пока их список не загружен из GenresURL.
+	Genres []string `env:"PROFILE_GENRES" yaml:"genres" default:"Фэнтези,Научная фантастика,Детектив,Приключения,Романтика,Драма,Комедия,Хоррор,Историческая фантастика,Альтернативная история,Фантастика,Ужасы,Мистика,Триллер,Сказка,ЛитРПГ,Киберпанк,Постапокалипсис,Юмор,Спорт,Семейная сага,Приключенческий роман,Эротика"`
+	// GenresURL — адрес GET /genres story-service; пустой — используется только Genres.
+	GenresURL string `env:"PROFILE_GENRES_URL" yaml:"genres_url"`
+	// GenresRefreshInterval — как часто список жанров загружается из GenresURL.
+	GenresRefreshInterval time.Duration `env:"PROFILE_GENRES_REFRESH_INTERVAL" yaml:"genres_refresh_interval" default:"10m"`
+	// GenresTimeout ограничивает запрос списка жанров.
+	GenresTimeout time.Duration `env:"PROFILE_GENRES_TIMEOUT" yaml:"genres_timeout" default:"5s"`
+}
+
+// DSN возвращает строку подключения к PostgreSQL в формате key=value.
+func (c DatabaseConfig) DSN() string {
+	parts := []string{
+		"host=" + quoteDSN(c.Host),
+		"port=" + quoteDSN(c.Port),
+		"user=" + quoteDSN(c.User),
+		"password=" + quoteDSN(c.Password),
+		"dbname=" + quoteDSN(c.Name),
+		"sslmode=" + quoteDSN(c.SSLMode),
+	}
+	if c.ConnectTimeout > 0 {
+		parts = append(parts, fmt.Sprintf("connect_timeout=%d", int(c.ConnectTimeout.Seconds())))
+	}
+	if c.StatementTimeout > 0 {
+		parts = append(parts, fmt.Sprintf("statement_timeout=%d", c.StatementTimeout.Milliseconds()))
+	}
+	return strings.Join(parts, " ")
+}
+
+// ReplicaDSNs возвращает строки подключения к репликам для чтения.
+func (c DatabaseConfig) ReplicaDSNs() []string {
+	dsns := make([]string, 0, len(c.ReplicaHosts))
+	for _, hostPort := range c.ReplicaHosts {
+		replica := c
+		replica.Host, replica.Port = splitHostPort(hostPort, c.Port)
+		dsns = append(dsns, replica.DSN())
+	}
+	return dsns
+}
+
+func splitHostPort(hostPort, defaultPort string) (string, string) {
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return hostPort, defaultPort
+	}
+	return host, port
+}
+
+func quoteDSN(v string) string {
+	if v != "" && !strings.ContainsAny(v, ` '\`) {
+		return v
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+}
+
+var sslModes = map[string]bool{
+	"disable": true, "allow": true, "prefer": true, "require": true, "verify-ca": true, "verify-full": true,
+}
+
+// validate проверяет значения, которые нельзя выразить тегом required.
+func (c *Config) validate() []string {
+	var problems []string
+	if !sslModes[c.Database.SSLMode] {
+		problems = append(problems, fmt.Sprintf("DB_SSLMODE: неизвестный режим %q", c.Database.SSLMode))
+	}
+	if c.HTTP.ShutdownGracePeriod <= 0 {
+		problems = append(problems, "SHUTDOWN_GRACE_PERIOD: значение должно быть положительным")
+	}
+	if c.Database.MaxOpenConns < 0 {
+		problems = append(problems, "DB_MAX_OPEN_CONNS: значение не может быть отрицательным")
+	}
+	if c.Database.MaxIdleConns < 0 {
+		problems = append(problems, "DB_MAX_IDLE_CONNS: значение не может быть отрицательным")
+	}
+	if c.Database.MaxOpenConns > 0 && c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		problems = append(problems, "DB_MAX_IDLE_CONNS: не может превышать DB_MAX_OPEN_CONNS")
+	}
+	if c.Database.QueryTimeout < 0 || c.Database.StatementTimeout < 0 {
+		problems = append(problems, "DB_QUERY_TIMEOUT и DB_STATEMENT_TIMEOUT не могут быть отрицательными")
+	}
+	for _, hostPort := range c.Database.ReplicaHosts {
+		if host, port := splitHostPort(hostPort, c.Database.Port); host == "" || port == "" {
+			problems = append(problems, fmt.Sprintf("DB_REPLICA_HOSTS: неверный адрес реплики %q", hostPort))
+		}
+	}
+	switch c.Tracing.Exporter {
+	case "none", "stdout", "file":
+	case "otlp":
+		if c.Tracing.Endpoint == "" {
+			problems = append(problems, "OTEL_EXPORTER_OTLP_ENDPOINT: обязателен для TRACING_EXPORTER=otlp")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("TRACING_EXPORTER: неизвестный экспортёр %q", c.Tracing.Exporter))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		problems = append(problems, "TRACING_SAMPLE_RATIO: значение должно быть от 0 до 1")
+	}
+	switch c.Logging.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		problems = append(problems, fmt.Sprintf("LOG_LEVEL: неизвестный уровень %q", c.Logging.Level))
+	}
+	if c.Logging.Format != "json" && c.Logging.Format != "text" {
+		problems = append(problems, fmt.Sprintf("LOG_FORMAT: ожидалось json или text, получено %q", c.Logging.Format))
+	}
+	for _, proxy := range c.HTTP.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				problems = append(problems, fmt.Sprintf("HTTP_TRUSTED_PROXIES: неверный адрес или подсеть %q", proxy))
+			}
+		}
+	}
+	if c.RateLimit.Enabled {
+		switch c.RateLimit.Backend {
+		case "memory":
+		case "redis":
+			if c.RateLimit.RedisURL == "" {
+				problems = append(problems, "REDIS_URL: обязателен для RATE_LIMIT_BACKEND=redis")
+			}
+		default:
+			problems = append(problems, fmt.Sprintf("RATE_LIMIT_BACKEND: ожидалось memory или redis, получено %q", c.RateLimit.Backend))
+		}
+		if c.RateLimit.ReadRate <= 0 || c.RateLimit.ReadBurst <= 0 || c.RateLimit.WriteRate <= 0 || c.RateLimit.WriteBurst <= 0 {
+			problems = append(problems, "RATE_LIMIT_*_RATE и RATE_LIMIT_*_BURST должны быть положительными")
+		}
+		if c.RateLimit.IPFactor < 1 {
+			problems = append(problems, "RATE_LIMIT_IP_FACTOR: ожидалось целое число не меньше 1")
+		}
+	}
+	if c.Idempotency.TTL <= 0 || c.Idempotency.LockTimeout <= 0 {
+		problems = append(problems, "IDEMPOTENCY_TTL и IDEMPOTENCY_LOCK_TIMEOUT должны быть положительными")
+	}
+	problems = append(problems, c.Screening.validate()...)
+	if c.Notifications.TTL <= 0 || c.Notifications.MaxTTL < c.Notifications.TTL {
+		problems = append(problems, "NOTIFICATIONS_TTL должен быть положительным и не больше NOTIFICATIONS_MAX_TTL")
+	}
+	if c.Notifications.CleanupInterval <= 0 {
+		problems = append(problems, "NOTIFICATIONS_CLEANUP_INTERVAL: значение должно быть положительным")
+	}
+	if c.Notifications.MaxRecipients <= 0 {
+		problems = append(problems, "NOTIFICATIONS_MAX_RECIPIENTS: значение должно быть положительным")
+	}
+	if c.Realtime.Heartbeat <= 0 || c.Realtime.MaxConnectionsPerUser <= 0 || c.Realtime.ReplayBuffer < 0 {
+		problems = append(problems, "REALTIME_HEARTBEAT и REALTIME_MAX_CONNECTIONS_PER_USER должны быть положительными, REALTIME_REPLAY_BUFFER — неотрицательным")
+	}
+	if c.Email.Enabled {
+		problems = append(problems, c.Email.validate()...)
+	}
+	if c.WebPush.Enabled {
+		problems = append(problems, c.WebPush.validate()...)
+	}
+	if c.Digest.Enabled {
+		if _, err := cron.Parse(c.Digest.Schedule); err != nil {
+			problems = append(problems, "DIGEST_SCHEDULE: "+err.Error())
+		}
+		if c.Digest.BatchSize <= 0 || c.Digest.MaxGroups <= 0 {
+			problems = append(problems, "DIGEST_BATCH_SIZE и DIGEST_MAX_GROUPS должны быть положительными")
+		}
+	}
+	problems = append(problems, c.Jobs.validate(c.HTTP.ShutdownGracePeriod)...)
+	if c.Profile.MaxLinks <= 0 || c.Profile.MaxLanguages <= 0 || c.Profile.MaxFavoriteGenres <= 0 {
+		problems = append(problems, "PROFILE_MAX_LINKS, PROFILE_MAX_LANGUAGES и PROFILE_MAX_FAVORITE_GENRES должны быть положительными")
+	}
+	if c.Profile.GenresURL != "" {
+		if u, err := url.Parse(c.Profile.GenresURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, fmt.Sprintf("PROFILE_GENRES_URL: ожидался адрес http или https, получено %q", c.Profile.GenresURL))
+		}
+		if c.Profile.GenresRefreshInterval <= 0 || c.Profile.GenresTimeout <= 0 {
+			problems = append(problems, "PROFILE_GENRES_REFRESH_INTERVAL и PROFILE_GENRES_TIMEOUT должны быть положительными")
+		}
+	}
+	problems = append(problems, validateOrigins("CORS_ALLOWED_ORIGINS", c.CORS.AllowedOrigins)...)
+	if c.CORS.AllowCredentials && slices.Contains(c.CORS.AllowedOrigins, "*") {
+		problems = append(problems, "CORS_ALLOW_CREDENTIALS: учётные данные нельзя разрешать для * в CORS_ALLOWED_ORIGINS, перечислите origin явно")
+	}
+	if c.CORS.MaxAge < 0 {
+		problems = append(problems, "CORS_MAX_AGE: значение не может быть отрицательным")
+	}
+	for i, route := range c.CORS.Routes {
+		name := fmt.Sprintf("cors.routes[%d]", i)
+		if !strings.HasPrefix(route.PathPrefix, "/") {
+			problems = append(problems, fmt.Sprintf("%s: path_prefix должен начинаться с /, получено %q", name, route.PathPrefix))
+		}
+		problems = append(problems, validateOrigins(name+".allowed_origins", route.AllowedOrigins)...)
+		// Маршрут, не задающий ни origin, ни учётные данные, повторяет уже проверенную общую политику
+		origins, credentials := c.CORS.AllowedOrigins, c.CORS.AllowCredentials
+		if route.AllowedOrigins != nil {
+			origins = route.AllowedOrigins
+		}
+		if route.AllowCredentials != nil {
+			credentials = *route.AllowCredentials
+		}
+		if (route.AllowedOrigins != nil || route.AllowCredentials != nil) && credentials && slices.Contains(origins, "*") {
+			problems = append(problems, fmt.Sprintf("%s: учётные данные нельзя разрешать для *, перечислите origin явно", name))
+		}
+	}
+	return problems
+}
+
+// validateOrigins проверяет, что каждый origin — это *, scheme://host[:port]
+// или scheme://*.host[:port] без пути.
+func validateOrigins(name string, origins []string) []string {
+	var problems []string
+	for _, origin := range origins {
+		if origin == "*" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: неверный origin %q", name, origin))
+			continue
+		}
+		host := strings.TrimPrefix(u.Host, "*.")
+		if u.Scheme == "" || host == "" || strings.Contains(host, "*") || (u.Path != "" && u.Path != "/") {
+			problems = append(problems, fmt.Sprintf("%s: неверный origin %q", name, origin))
+		}
+	}
+	return problems
+}
+
+var screeningActions = map[string]bool{"off": true, "redact": true, "hold": true, "reject": true}
+
+func (c ScreeningConfig) validate() []string {
+	if !c.Enabled {
+		return nil
+	}
+	var problems []string
+	for name, action := range map[string]string{
+		"SCREENING_PROFANITY_ACTION":      c.ProfanityAction,
+		"SCREENING_URL_ACTION":            c.URLAction,
+		"SCREENING_SPAM_ACTION":           c.SpamAction,
+		"SCREENING_REPEATED_CHARS_ACTION": c.RepeatedCharsAction,
+	} {
+		if !screeningActions[action] {
+			problems = append(problems, fmt.Sprintf("%s: ожидалось off, redact, hold или reject, получено %q", name, action))
+		}
+	}
+	if c.ClassifierAction != "off" && c.ClassifierAction != "hold" && c.ClassifierAction != "reject" {
+		problems = append(problems, fmt.Sprintf("SCREENING_CLASSIFIER_ACTION: ожидалось off, hold или reject, получено %q", c.ClassifierAction))
+	}
+	if c.MaxRepeat < 2 {
+		problems = append(problems, "SCREENING_MAX_REPEAT: значение должно быть не меньше 2")
+	}
+	if c.ClassifierURL != "" && c.ClassifierTimeout <= 0 {
+		problems = append(problems, "SCREENING_CLASSIFIER_TIMEOUT: значение должно быть положительным")
+	}
+	for i, p := range c.SpamPatterns {
+		if _, err := regexp.Compile(p); err != nil {
+			problems = append(problems, fmt.Sprintf("screening.spam_patterns[%d]: %v", i, err))
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+var smtpTLSModes = map[string]bool{"none": true, "starttls": true, "tls": true}
+
+func (c EmailConfig) validate() []string {
+	var problems []string
+	if _, err := mail.ParseAddress(c.From); err != nil {
+		problems = append(problems, fmt.Sprintf("EMAIL_FROM: неверный адрес %q", c.From))
+	}
+	if c.DefaultLanguage != "ru" && c.DefaultLanguage != "en" {
+		problems = append(problems, fmt.Sprintf("EMAIL_DEFAULT_LANGUAGE: ожидалось ru или en, получено %q", c.DefaultLanguage))
+	}
+	for name, raw := range map[string]string{"EMAIL_APP_URL": c.AppURL, "EMAIL_PUBLIC_URL": c.PublicURL} {
+		if u, err := url.Parse(raw); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, fmt.Sprintf("%s: ожидался адрес http(s), получено %q", name, raw))
+		}
+	}
+	if c.SigningKey == "" {
+		problems = append(problems, "EMAIL_SIGNING_KEY: обязателен при EMAIL_ENABLED=true")
+	}
+	if c.SMTPHost == "" || c.SMTPPort <= 0 || c.SMTPPort > 65535 {
+		problems = append(problems, "EMAIL_SMTP_HOST и EMAIL_SMTP_PORT: укажите адрес SMTP-сервера")
+	}
+	if !smtpTLSModes[c.SMTPTLS] {
+		problems = append(problems, fmt.Sprintf("EMAIL_SMTP_TLS: ожидалось none, starttls или tls, получено %q", c.SMTPTLS))
+	}
+	if c.SendTimeout <= 0 || c.PollInterval <= 0 || c.RetryBackoff <= 0 || c.MaxRetryBackoff < c.RetryBackoff || c.DigestDelay < 0 {
+		problems = append(problems, "EMAIL_SEND_TIMEOUT, EMAIL_POLL_INTERVAL и EMAIL_RETRY_BACKOFF должны быть положительными, EMAIL_MAX_RETRY_BACKOFF — не меньше EMAIL_RETRY_BACKOFF")
+	}
+	if c.ChangeTTL <= 0 || c.RevertTTL <= 0 {
+		problems = append(problems, "EMAIL_CHANGE_TTL и EMAIL_REVERT_TTL должны быть положительными")
+	}
+	if c.BatchSize <= 0 || c.MaxAttempts <= 0 || c.SoftBounceLimit <= 0 {
+		problems = append(problems, "EMAIL_BATCH_SIZE, EMAIL_MAX_ATTEMPTS и EMAIL_SOFT_BOUNCE_LIMIT должны быть положительными")
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+func (c WebPushConfig) validate() []string {
+	var problems []string
+	if c.VAPIDPrivateKey == "" {
+		problems = append(problems, "WEBPUSH_VAPID_PRIVATE_KEY: обязателен при WEBPUSH_ENABLED=true, создайте ключи командой «main push keys»")
+	} else if key, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(c.VAPIDPrivateKey, "=")); err != nil {
+		problems = append(problems, "WEBPUSH_VAPID_PRIVATE_KEY: ожидался ключ в base64url")
+	} else if _, err := ecdh.P256().NewPrivateKey(key); err != nil {
+		problems = append(problems, "WEBPUSH_VAPID_PRIVATE_KEY: неверный закрытый ключ P-256")
+	}
+	if u, err := url.Parse(c.Subject); err != nil || (u.Scheme != "mailto" && u.Scheme != "https") {
+		problems = append(problems, fmt.Sprintf("WEBPUSH_SUBJECT: ожидался адрес mailto: или https:, получено %q", c.Subject))
+	}
+	if len(c.AllowedHosts) == 0 {
+		problems = append(problems, "WEBPUSH_ALLOWED_HOSTS: укажите хотя бы один push-сервис")
+	}
+	if c.TTL <= 0 || c.SendTimeout <= 0 || c.PollInterval <= 0 || c.RetryBackoff <= 0 || c.MaxRetryBackoff < c.RetryBackoff {
+		problems = append(problems, "WEBPUSH_TTL, WEBPUSH_SEND_TIMEOUT, WEBPUSH_POLL_INTERVAL и WEBPUSH_RETRY_BACKOFF должны быть положительными, WEBPUSH_MAX_RETRY_BACKOFF — не меньше WEBPUSH_RETRY_BACKOFF")
+	}
+	if c.MaxSubscriptions <= 0 || c.BatchSize <= 0 || c.MaxAttempts <= 0 {
+		problems = append(problems, "WEBPUSH_MAX_SUBSCRIPTIONS, WEBPUSH_BATCH_SIZE и WEBPUSH_MAX_ATTEMPTS должны быть положительными")
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+func (c JobsConfig) validate(gracePeriod time.Duration) []string {
+	var problems []string
+	if c.Concurrency <= 0 || c.MaxAttempts <= 0 {
+		problems = append(problems, "JOBS_CONCURRENCY и JOBS_MAX_ATTEMPTS должны быть положительными")
+	}
+	if c.PollInterval <= 0 || c.Timeout <= 0 || c.RetryBackoff <= 0 || c.MaxRetryBackoff < c.RetryBackoff || c.Retention <= 0 {
+		problems = append(problems, "JOBS_POLL_INTERVAL, JOBS_TIMEOUT, JOBS_RETRY_BACKOFF и JOBS_RETENTION должны быть положительными, JOBS_MAX_RETRY_BACKOFF — не меньше JOBS_RETRY_BACKOFF")
+	}
+	if c.DrainTimeout < 0 || c.DrainTimeout >= gracePeriod {
+		problems = append(problems, "JOBS_DRAIN_TIMEOUT должен быть неотрицательным и меньше SHUTDOWN_GRACE_PERIOD")
+	}
+	sort.Strings(problems)
+	return problems
+}
@@ -0,0 +1,398 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func envFrom(m map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := m[key]
+		return v, ok
+	}
+}
+
+var requiredEnv = map[string]string{
+	"DB_HOST":     "postgres",
+	"DB_USER":     "storycraft",
+	"DB_PASSWORD": "secret",
+	"DB_NAME":     "storycraft_db",
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, err := load("", envFrom(requiredEnv))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.HTTP.Port != "8080" {
+		t.Errorf("PORT по умолчанию: %q", cfg.HTTP.Port)
+	}
+	if cfg.Database.Port != "5432" || cfg.Database.SSLMode != "disable" {
+		t.Errorf("настройки БД по умолчанию: %+v", cfg.Database)
+	}
+	if cfg.Database.ConnectTimeout != 5*time.Second {
+		t.Errorf("DB_CONNECT_TIMEOUT по умолчанию: %v", cfg.Database.ConnectTimeout)
+	}
+	if len(cfg.CORS.AllowedOrigins) != 1 || cfg.CORS.AllowedOrigins[0] != "*" {
+		t.Errorf("CORS_ALLOWED_ORIGINS по умолчанию: %v", cfg.CORS.AllowedOrigins)
+	}
+}
+
+func TestLoadReportsAllMissingKeys(t *testing.T) {
+	_, err := load("", envFrom(map[string]string{"DB_HOST": "postgres"}))
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("ожидали ValidationError, получили %v", err)
+	}
+	msg := verr.Error()
+	for _, key := range []string{"DB_USER", "DB_PASSWORD", "DB_NAME"} {
+		if !strings.Contains(msg, key) {
+			t.Errorf("в ошибке нет %s: %s", key, msg)
+		}
+	}
+	if strings.Contains(msg, "DB_HOST") {
+		t.Errorf("DB_HOST задан, но попал в ошибку: %s", msg)
+	}
+}
+
+func TestLoadInvalidValues(t *testing.T) {
+	env := map[string]string{
+		"DB_MAX_OPEN_CONNS":    "много",
+		"DB_SSLMODE":           "sometimes",
+		"HTTP_READ_TIMEOUT":    "15",
+		"CORS_ALLOWED_ORIGINS": "https://story-craft.io, not-an-origin",
+	}
+	for k, v := range requiredEnv {
+		env[k] = v
+	}
+	_, err := load("", envFrom(env))
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("ожидали ValidationError, получили %v", err)
+	}
+	if len(verr.Problems) != 4 {
+		t.Errorf("ожидали 4 проблемы, получили %d: %v", len(verr.Problems), verr.Problems)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := `
+http:
+  port: "9000"
+  read_timeout: 3s
+database:
+  host: yaml-host
+  user: yaml-user
+  password: yaml-password
+  name: yaml-db
+  max_open_conns: 50
+cors:
+  allowed_origins: ["https://story-craft.io"]
+`
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := load(path, envFrom(map[string]string{"DB_HOST": "env-host"}))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Database.Host != "env-host" {
+		t.Errorf("переменная окружения должна перекрывать YAML, получили %q", cfg.Database.Host)
+	}
+	if cfg.HTTP.Port != "9000" || cfg.HTTP.ReadTimeout != 3*time.Second || cfg.Database.MaxOpenConns != 50 {
+		t.Errorf("значения из YAML не применились: %+v %+v", cfg.HTTP, cfg.Database)
+	}
+	if cfg.Database.MaxIdleConns != 10 {
+		t.Errorf("значение по умолчанию должно сохраниться, получили %d", cfg.Database.MaxIdleConns)
+	}
+	if len(cfg.CORS.AllowedOrigins) != 1 || cfg.CORS.AllowedOrigins[0] != "https://story-craft.io" {
+		t.Errorf("CORS из YAML: %v", cfg.CORS.AllowedOrigins)
+	}
+}
+
+func TestPrintRedactsSecrets(t *testing.T) {
+	cfg, err := load("", envFrom(requiredEnv))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	var sb strings.Builder
+	cfg.Print(&sb)
+	out := sb.String()
+	if strings.Contains(out, "secret") {
+		t.Errorf("пароль попал в вывод:\n%s", out)
+	}
+	for _, line := range []string{"DB_PASSWORD=******", "DB_HOST=postgres", "PORT=8080", "HTTP_READ_TIMEOUT=15s"} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("в выводе нет %q:\n%s", line, out)
+		}
+	}
+}
+
+func TestDSNQuotesValues(t *testing.T) {
+	c := DatabaseConfig{Host: "db", Port: "5432", User: "u", Password: "p a'ss", Name: "n", SSLMode: "require"}
+	want := `host=db port=5432 user=u password='p a\'ss' dbname=n sslmode=require`
+	if got := c.DSN(); got != want {
+		t.Errorf("DSN:\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestLoadCORSRoutes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := `
+cors:
+  allowed_origins: ["https://*.story-craft.io"]
+  routes:
+    - path_prefix: /admin
+      allowed_origins: ["https://admin.story-craft.io"]
+      allow_credentials: true
+      max_age: 1m
+    - path_prefix: metrics
+      allowed_origins: ["https://*.*.story-craft.io"]
+`
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := load(path, envFrom(requiredEnv))
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Problems) != 2 {
+		t.Fatalf("ожидали 2 проблемы во втором переопределении, получили %v", err)
+	}
+
+	yaml = yaml[:strings.Index(yaml, "    - path_prefix: metrics")]
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := load(path, envFrom(requiredEnv))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(cfg.CORS.Routes) != 1 {
+		t.Fatalf("ожидали одно переопределение, получили %+v", cfg.CORS.Routes)
+	}
+	route := cfg.CORS.Routes[0]
+	if route.AllowCredentials == nil || !*route.AllowCredentials || route.MaxAge == nil || *route.MaxAge != time.Minute {
+		t.Errorf("поля переопределения не разобраны: %+v", route)
+	}
+	if route.AllowedMethods != nil {
+		t.Errorf("незаданные поля должны наследоваться, получили %v", route.AllowedMethods)
+	}
+
+	var sb strings.Builder
+	cfg.Print(&sb)
+	if strings.Contains(sb.String(), "\n=") || strings.HasPrefix(sb.String(), "=") {
+		t.Errorf("параметры только из YAML не должны выводиться:\n%s", sb.String())
+	}
+}
+
+func TestLoadCORSRejectsWildcardWithCredentials(t *testing.T) {
+	env := map[string]string{"CORS_ALLOW_CREDENTIALS": "true"}
+	for k, v := range requiredEnv {
+		env[k] = v
+	}
+	_, err := load("", envFrom(env))
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Problems) != 1 || !strings.Contains(verr.Problems[0], "CORS_ALLOW_CREDENTIALS") {
+		t.Fatalf("ожидали ошибку * с учётными данными, получили %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := `
+cors:
+  allowed_origins: ["https://story-craft.io"]
+  allow_credentials: true
+  routes:
+    - path_prefix: /public
+      allowed_origins: ["*"]
+    - path_prefix: /stats
+      allowed_origins: ["*"]
+      allow_credentials: false
+`
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err = load(path, envFrom(requiredEnv))
+	if !errors.As(err, &verr) || len(verr.Problems) != 1 || !strings.Contains(verr.Problems[0], "cors.routes[0]") {
+		t.Fatalf("ожидали ошибку только в первом переопределении, получили %v", err)
+	}
+}
+
+func TestLoadRateLimitRequiresRedisURL(t *testing.T) {
+	env := map[string]string{"RATE_LIMIT_BACKEND": "redis", "HTTP_TRUSTED_PROXIES": "10.0.0.0/8,gateway"}
+	for k, v := range requiredEnv {
+		env[k] = v
+	}
+	_, err := load("", envFrom(env))
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Problems) != 2 {
+		t.Fatalf("ожидали ошибки REDIS_URL и HTTP_TRUSTED_PROXIES, получили %v", err)
+	}
+
+	env["HTTP_TRUSTED_PROXIES"] = "10.0.0.0/8"
+	env["RATE_LIMIT_ENABLED"] = "false"
+	if _, err := load("", envFrom(env)); err != nil {
+		t.Errorf("при выключенном ограничении REDIS_URL не нужен: %v", err)
+	}
+}
+
+func TestLoadScreeningValidation(t *testing.T) {
+	env := map[string]string{"SCREENING_URL_ACTION": "block", "SCREENING_CLASSIFIER_ACTION": "redact"}
+	for k, v := range requiredEnv {
+		env[k] = v
+	}
+	_, err := load("", envFrom(env))
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Problems) != 2 {
+		t.Fatalf("ожидали ошибки SCREENING_URL_ACTION и SCREENING_CLASSIFIER_ACTION, получили %v", err)
+	}
+
+	env["SCREENING_ENABLED"] = "false"
+	if _, err := load("", envFrom(env)); err != nil {
+		t.Errorf("при выключенной проверке действия не проверяются: %v", err)
+	}
+}
+
+func TestLoadNotificationsValidation(t *testing.T) {
+	env := map[string]string{"NOTIFICATIONS_TTL": "200h", "NOTIFICATIONS_MAX_TTL": "100h", "NOTIFICATIONS_MAX_RECIPIENTS": "0"}
+	for k, v := range requiredEnv {
+		env[k] = v
+	}
+	_, err := load("", envFrom(env))
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Problems) != 2 {
+		t.Fatalf("ожидали ошибки NOTIFICATIONS_TTL и NOTIFICATIONS_MAX_RECIPIENTS, получили %v", err)
+	}
+}
+
+func TestLoadRealtimeValidation(t *testing.T) {
+	env := map[string]string{"REALTIME_HEARTBEAT": "0s", "REALTIME_REPLAY_BUFFER": "-1"}
+	for k, v := range requiredEnv {
+		env[k] = v
+	}
+	_, err := load("", envFrom(env))
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Problems) != 1 || !strings.Contains(verr.Problems[0], "REALTIME_HEARTBEAT") {
+		t.Fatalf("ожидали ошибку REALTIME_HEARTBEAT, получили %v", err)
+	}
+}
+
+func TestLoadEmailValidation(t *testing.T) {
+	env := map[string]string{"EMAIL_SMTP_TLS": "ssl"}
+	for k, v := range requiredEnv {
+		env[k] = v
+	}
+	// Пока письма выключены, их настройки не проверяются
+	if _, err := load("", envFrom(env)); err != nil {
+		t.Fatalf("письма выключены, ожидали успешную загрузку, получили %v", err)
+	}
+
+	env["EMAIL_ENABLED"] = "true"
+	_, err := load("", envFrom(env))
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Problems) != 2 ||
+		!strings.Contains(verr.Problems[0], "EMAIL_SIGNING_KEY") || !strings.Contains(verr.Problems[1], "EMAIL_SMTP_TLS") {
+		t.Fatalf("ожидали ошибки EMAIL_SIGNING_KEY и EMAIL_SMTP_TLS, получили %v", err)
+	}
+
+	env["EMAIL_SIGNING_KEY"] = "secret"
+	env["EMAIL_SMTP_TLS"] = "starttls"
+	cfg, err := load("", envFrom(env))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Email.SMTPPort != 1025 || cfg.Email.DefaultLanguage != "ru" || cfg.Email.ChangeTTL != 24*time.Hour {
+		t.Errorf("неверные значения по умолчанию: %+v", cfg.Email)
+	}
+
+	env["EMAIL_REVERT_TTL"] = "0s"
+	if _, err := load("", envFrom(env)); !errors.As(err, &verr) || !strings.Contains(verr.Error(), "EMAIL_REVERT_TTL") {
+		t.Errorf("ожидали ошибку EMAIL_REVERT_TTL, получили %v", err)
+	}
+}
+
+func TestLoadWebPushValidation(t *testing.T) {
+	env := map[string]string{"WEBPUSH_ENABLED": "true", "WEBPUSH_SUBJECT": "support@story-craft.io"}
+	for k, v := range requiredEnv {
+		env[k] = v
+	}
+	_, err := load("", envFrom(env))
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Problems) != 2 ||
+		!strings.Contains(verr.Problems[0], "WEBPUSH_SUBJECT") || !strings.Contains(verr.Problems[1], "WEBPUSH_VAPID_PRIVATE_KEY") {
+		t.Fatalf("ожидали ошибки WEBPUSH_SUBJECT и WEBPUSH_VAPID_PRIVATE_KEY, получили %v", err)
+	}
+
+	// Скаляр вне диапазона P-256 не является ключом
+	env["WEBPUSH_SUBJECT"] = "mailto:support@story-craft.io"
+	env["WEBPUSH_VAPID_PRIVATE_KEY"] = "__________________________________________8"
+	if _, err := load("", envFrom(env)); !errors.As(err, &verr) || !strings.Contains(verr.Error(), "неверный закрытый ключ") {
+		t.Fatalf("ожидали ошибку ключа, получили %v", err)
+	}
+
+	env["WEBPUSH_VAPID_PRIVATE_KEY"] = "yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw"
+	cfg, err := load("", envFrom(env))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.WebPush.AllowedHosts) != 4 || cfg.WebPush.TTL != 24*time.Hour {
+		t.Errorf("неверные значения по умолчанию: %+v", cfg.WebPush)
+	}
+}
+
+func TestLoadDigestValidation(t *testing.T) {
+	env := map[string]string{"DIGEST_SCHEDULE": "*/5 * * *", "DIGEST_MAX_GROUPS": "0"}
+	for k, v := range requiredEnv {
+		env[k] = v
+	}
+	_, err := load("", envFrom(env))
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Problems) != 2 || !strings.Contains(verr.Problems[0], "DIGEST_SCHEDULE") {
+		t.Fatalf("ожидали ошибки DIGEST_SCHEDULE и DIGEST_MAX_GROUPS, получили %v", err)
+	}
+
+	// Выключенные дайджесты не проверяются
+	env["DIGEST_ENABLED"] = "false"
+	if _, err := load("", envFrom(env)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadJobsValidation(t *testing.T) {
+	env := map[string]string{"JOBS_DRAIN_TIMEOUT": "30s", "SHUTDOWN_GRACE_PERIOD": "20s"}
+	for k, v := range requiredEnv {
+		env[k] = v
+	}
+	_, err := load("", envFrom(env))
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Problems) != 1 || !strings.Contains(verr.Problems[0], "JOBS_DRAIN_TIMEOUT") {
+		t.Fatalf("ожидали ошибку JOBS_DRAIN_TIMEOUT, получили %v", err)
+	}
+}
+
+func TestLoadProfileValidation(t *testing.T) {
+	env := map[string]string{"PROFILE_MAX_LINKS": "0", "PROFILE_GENRES_URL": "story-service/genres"}
+	for k, v := range requiredEnv {
+		env[k] = v
+	}
+	_, err := load("", envFrom(env))
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Problems) != 2 {
+		t.Fatalf("ожидали две ошибки профиля, получили %v", err)
+	}
+
+	delete(env, "PROFILE_MAX_LINKS")
+	delete(env, "PROFILE_GENRES_URL")
+	cfg, err := load("", envFrom(env))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Profile.Genres) == 0 || cfg.Profile.Genres[1] != "Научная фантастика" {
+		t.Errorf("по умолчанию ожидали жанры story-service, получили %q", cfg.Profile.Genres)
+	}
+}
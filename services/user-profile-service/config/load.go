@@ -0,0 +1,196 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// envConfigFile — переменная с путём к YAML-файлу конфигурации.
+	envConfigFile = "CONFIG_FILE"
+	// defaultConfigFile читается, если CONFIG_FILE не задан и файл существует.
+	defaultConfigFile = "config.yaml"
+)
+
+// ValidationError перечисляет сразу все проблемы конфигурации, чтобы их можно было
+// исправить за один заход.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "некорректная конфигурация:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Load собирает конфигурацию: значения по умолчанию, затем YAML-файл, затем .env
+// и переменные окружения. Возвращает *ValidationError, если конфигурация неполна.
+func Load() (*Config, error) {
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("не удалось прочитать .env: %w", err)
+	}
+
+	path, explicit := os.LookupEnv(envConfigFile)
+	if !explicit {
+		path = defaultConfigFile
+	}
+	if _, err := os.Stat(path); err != nil {
+		if explicit || !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("не удалось открыть файл конфигурации %s: %w", path, err)
+		}
+		path = ""
+	}
+
+	return load(path, os.LookupEnv)
+}
+
+// Default возвращает конфигурацию, заполненную только значениями по умолчанию.
+// Обязательные параметры остаются пустыми.
+func Default() *Config {
+	cfg := &Config{}
+	eachField(reflect.ValueOf(cfg).Elem(), func(f reflect.StructField, v reflect.Value) {
+		if def, ok := f.Tag.Lookup("default"); ok {
+			if err := setValue(v, def); err != nil {
+				panic(fmt.Sprintf("config: неверное значение по умолчанию для %s: %v", f.Name, err))
+			}
+		}
+	})
+	return cfg
+}
+
+func load(path string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	cfg := Default()
+	var problems []string
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось прочитать файл конфигурации %s: %w", path, err)
+		}
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("не удалось разобрать файл конфигурации %s: %w", path, err)
+		}
+	}
+
+	var missing []string
+	eachField(reflect.ValueOf(cfg).Elem(), func(f reflect.StructField, v reflect.Value) {
+		name := f.Tag.Get("env")
+		if name == "" {
+			return
+		}
+		if raw, ok := lookupEnv(name); ok && raw != "" {
+			if err := setValue(v, raw); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+				return
+			}
+		}
+		if f.Tag.Get("required") == "true" && v.IsZero() {
+			missing = append(missing, name)
+		}
+	})
+
+	if len(missing) > 0 {
+		problems = append([]string{"не заданы обязательные параметры: " + strings.Join(missing, ", ")}, problems...)
+	}
+	problems = append(problems, cfg.validate()...)
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	return cfg, nil
+}
+
+// Print выводит итоговую конфигурацию в виде переменных окружения, скрывая секреты.
+// Параметры, которые задаются только в YAML, не выводятся.
+func (c *Config) Print(w io.Writer) {
+	eachField(reflect.ValueOf(c).Elem(), func(f reflect.StructField, v reflect.Value) {
+		if f.Tag.Get("env") == "" {
+			return
+		}
+		value := formatValue(v)
+		if f.Tag.Get("secret") == "true" && value != "" {
+			value = "******"
+		}
+		fmt.Fprintf(w, "%s=%s\n", f.Tag.Get("env"), value)
+	})
+}
+
+// eachField обходит конечные поля конфигурации, спускаясь во вложенные структуры.
+func eachField(v reflect.Value, fn func(reflect.StructField, reflect.Value)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f, fv := t.Field(i), v.Field(i)
+		if f.Type.Kind() == reflect.Struct {
+			eachField(fv, fn)
+			continue
+		}
+		fn(f, fv)
+	}
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func setValue(v reflect.Value, raw string) error {
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("ожидалась длительность (например, 5s): %q", raw)
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(raw)
+	case v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("ожидалось целое число: %q", raw)
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("ожидалось число: %q", raw)
+		}
+		v.SetFloat(f)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("ожидалось true или false: %q", raw)
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("неподдерживаемый тип %s", v.Type())
+	}
+	return nil
+}
+
+func formatValue(v reflect.Value) string {
+	switch {
+	case v.Type() == durationType:
+		return time.Duration(v.Int()).String()
+	case v.Kind() == reflect.Slice:
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = fmt.Sprint(v.Index(i).Interface())
+		}
+		return strings.Join(items, ",")
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
@@ -0,0 +1,143 @@
+// Package cron запускает периодические задачи по расписанию в формате cron.
+// Экземпляров сервиса несколько, поэтому каждый запуск задачи идёт под
+// advisory-блокировкой PostgreSQL: пока задача выполняется на одном экземпляре,
+// остальные пропускают этот запуск.
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule — разобранное расписание из пяти полей: минута, час, день месяца, месяц, день недели.
+// Время расписания — UTC.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// domAny и dowAny — поле начинается с *: день определяется другим полем.
+	domAny, dowAny bool
+}
+
+// field — допустимые значения одного поля расписания.
+type field struct {
+	name     string
+	min, max int
+}
+
+var fields = []field{
+	{"минута", 0, 59},
+	{"час", 0, 23},
+	{"день месяца", 1, 31},
+	{"месяц", 1, 12},
+	{"день недели", 0, 7},
+}
+
+// shortcuts — готовые расписания вместо пяти полей.
+var shortcuts = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// Parse разбирает расписание: пять полей через пробел, в каждом — *, число, диапазон a-b,
+// шаг */n или a-b/n и списки через запятую; или одно из @hourly, @daily, @weekly, @monthly.
+// День недели: 0 или 7 — воскресенье. Если заданы и день месяца, и день недели,
+// подходит любой из них, как в cron.
+func Parse(spec string) (Schedule, error) {
+	if s, ok := shortcuts[strings.TrimSpace(spec)]; ok {
+		spec = s
+	}
+	parts := strings.Fields(spec)
+	if len(parts) != len(fields) {
+		return Schedule{}, fmt.Errorf("расписание %q: ожидалось 5 полей, получено %d", spec, len(parts))
+	}
+	var bits [5]uint64
+	for i, part := range parts {
+		b, err := parseField(part, fields[i])
+		if err != nil {
+			return Schedule{}, fmt.Errorf("расписание %q: %w", spec, err)
+		}
+		bits[i] = b
+	}
+	// Воскресенье можно записать и как 7
+	if bits[4]&(1<<7) != 0 {
+		bits[4] = bits[4]&^(1<<7) | 1
+	}
+	return Schedule{
+		minute: bits[0], hour: bits[1], dom: bits[2], month: bits[3], dow: bits[4],
+		domAny: strings.HasPrefix(parts[2], "*"), dowAny: strings.HasPrefix(parts[4], "*"),
+	}, nil
+}
+
+// parseField возвращает битовую маску значений поля.
+func parseField(spec string, f field) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(spec, ",") {
+		rng, stepSpec, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepSpec)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("%s: неверный шаг %q", f.name, item)
+			}
+			step = n
+		}
+		lo, hi := f.min, f.max
+		if rng != "*" {
+			from, to, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(from); err != nil {
+				return 0, fmt.Errorf("%s: неверное значение %q", f.name, item)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(to); err != nil {
+					return 0, fmt.Errorf("%s: неверное значение %q", f.name, item)
+				}
+			} else if hasStep {
+				// 5/15 — с пятой минуты до конца диапазона
+				hi = f.max
+			}
+		}
+		if lo < f.min || hi > f.max || lo > hi {
+			return 0, fmt.Errorf("%s: значение %q вне диапазона %d-%d", f.name, item, f.min, f.max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+// Next возвращает первый момент расписания строго после after. Если за пять лет
+// подходящего момента нет (например, 30 февраля), возвращает нулевое время.
+func (s Schedule) Next(after time.Time) time.Time {
+	t := after.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (s Schedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domAny || s.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
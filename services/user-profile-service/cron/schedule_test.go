@@ -0,0 +1,63 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	valid := []string{"* * * * *", "*/5 * * * *", "0 9 * * 1-5", "0,30 8-18/2 1 1,6 0", "5/15 * * * *", "0 0 * * 7", "@daily", " @hourly "}
+	for _, spec := range valid {
+		if _, err := Parse(spec); err != nil {
+			t.Errorf("%q: неожиданная ошибка %v", spec, err)
+		}
+	}
+	invalid := []string{"", "* * * *", "* * * * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "a * * * *", "@yearly"}
+	for _, spec := range invalid {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("%q: ожидали ошибку", spec)
+		}
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	at := func(s string) time.Time {
+		t.Helper()
+		v, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	cases := []struct {
+		spec, after, want string
+	}{
+		{"*/5 * * * *", "2026-03-10T10:02:30Z", "2026-03-10T10:05:00Z"},
+		{"*/5 * * * *", "2026-03-10T10:05:00Z", "2026-03-10T10:10:00Z"},
+		{"0 9 * * *", "2026-03-10T09:00:00Z", "2026-03-11T09:00:00Z"},
+		{"30 23 31 12 *", "2026-03-10T00:00:00Z", "2026-12-31T23:30:00Z"},
+		// 2026-03-10 — вторник: ближайшая суббота 14-го
+		{"0 0 * * 6", "2026-03-10T00:00:00Z", "2026-03-14T00:00:00Z"},
+		{"0 0 * * 7", "2026-03-10T00:00:00Z", "2026-03-15T00:00:00Z"},
+		// День месяца и день недели заданы оба: подходит любой
+		{"0 0 13 * 5", "2026-03-10T00:00:00Z", "2026-03-13T00:00:00Z"},
+		{"0 0 1 * 3", "2026-03-10T00:00:00Z", "2026-03-11T00:00:00Z"},
+		{"0 0 29 2 *", "2026-03-10T00:00:00Z", "2028-02-29T00:00:00Z"},
+		// Время не в UTC приводится к UTC
+		{"0 12 * * *", "2026-03-10T14:00:00+03:00", "2026-03-10T12:00:00Z"},
+	}
+	for _, tc := range cases {
+		s, err := Parse(tc.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := s.Next(at(tc.after)); !got.Equal(at(tc.want)) {
+			t.Errorf("%q после %s: ожидали %s, получили %s", tc.spec, tc.after, tc.want, got)
+		}
+	}
+
+	never, _ := Parse("0 0 30 2 *")
+	if got := never.Next(at("2026-03-10T00:00:00Z")); !got.IsZero() {
+		t.Errorf("30 февраля не бывает, получили %s", got)
+	}
+}
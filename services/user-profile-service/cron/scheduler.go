@@ -0,0 +1,146 @@
+package cron
+
+import (
+	"context"
+	"database/sql"
+	"hash/fnv"
+	"log/slog"
+	"time"
+
+	"github.com/monst/story-craft/services/user-profile-service/metrics"
+)
+
+// Результаты запуска задачи для метрик.
+const (
+	ResultOK    = "ok"
+	ResultError = "error"
+	// ResultSkipped — задачу в это время выполняет другой экземпляр сервиса.
+	ResultSkipped = "skipped"
+)
+
+// Job — задача по расписанию. Экземпляры сервиса запускают задачу по своим часам,
+// и если один уже закончил, когда другой только начал, задача выполнится дважды:
+// повторный запуск не должен ничего делать повторно.
+type Job func(ctx context.Context) error
+
+type entry struct {
+	name     string
+	schedule Schedule
+	job      Job
+	lockID   int64
+	next     time.Time
+}
+
+// Scheduler запускает задачи по расписанию. Блокировки берутся на отдельном
+// соединении из пула основной БД и держатся, пока выполняется задача.
+type Scheduler struct {
+	db      *sql.DB
+	metrics *metrics.Metrics
+	entries []*entry
+}
+
+func New(db *sql.DB, m *metrics.Metrics) *Scheduler {
+	return &Scheduler{db: db, metrics: m}
+}
+
+// Add добавляет задачу name. Имя задаёт ключ advisory-блокировки, поэтому
+// у одной задачи на всех экземплярах оно должно совпадать.
+func (s *Scheduler) Add(name string, schedule Schedule, job Job) {
+	s.entries = append(s.entries, &entry{name: name, schedule: schedule, job: job, lockID: lockID(name)})
+}
+
+// Run — фоновая задача для lifecycle.Manager.Go: ждёт ближайшего по расписанию
+// момента и по очереди запускает задачи, которым пора. Остановка дожидается
+// текущей задачи: её контекст отменяется вместе с ctx.
+func (s *Scheduler) Run(ctx context.Context) error {
+	now := time.Now()
+	for _, e := range s.entries {
+		e.next = e.schedule.Next(now)
+	}
+	for {
+		var wake time.Time
+		for _, e := range s.entries {
+			if !e.next.IsZero() && (wake.IsZero() || e.next.Before(wake)) {
+				wake = e.next
+			}
+		}
+		if wake.IsZero() {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		timer := time.NewTimer(time.Until(wake))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		now := time.Now()
+		for _, e := range s.entries {
+			if e.next.IsZero() || e.next.After(now) {
+				continue
+			}
+			s.RunJob(ctx, e.name)
+			e.next = e.schedule.Next(time.Now())
+		}
+	}
+}
+
+// RunJob запускает задачу name под её advisory-блокировкой. Возвращает false,
+// если задача не найдена или её уже выполняет другой экземпляр.
+func (s *Scheduler) RunJob(ctx context.Context, name string) bool {
+	for _, e := range s.entries {
+		if e.name != name {
+			continue
+		}
+		ran, err := s.runLocked(ctx, e)
+		switch {
+		case err != nil:
+			s.metrics.CronRun(e.name, ResultError)
+			if ctx.Err() == nil {
+				slog.Warn("Задача по расписанию завершилась с ошибкой", slog.String("job", e.name), slog.Any("error", err))
+			}
+		case !ran:
+			s.metrics.CronRun(e.name, ResultSkipped)
+			slog.Debug("Задачу по расписанию выполняет другой экземпляр", slog.String("job", e.name))
+		default:
+			s.metrics.CronRun(e.name, ResultOK)
+		}
+		return ran
+	}
+	return false
+}
+
+// runLocked выполняет задачу, если удалось взять её блокировку без ожидания.
+func (s *Scheduler) runLocked(ctx context.Context, e *entry) (bool, error) {
+	// Блокировка уровня сессии принадлежит соединению, поэтому все запросы к ней идут через conn
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", e.lockID).Scan(&locked); err != nil {
+		return false, err
+	}
+	if !locked {
+		return false, nil
+	}
+	defer func() {
+		// Снимаем блокировку и при остановке сервиса, иначе она останется на соединении в пуле
+		if _, err := conn.ExecContext(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", e.lockID); err != nil {
+			slog.Warn("Не удалось снять блокировку задачи по расписанию", slog.String("job", e.name), slog.Any("error", err))
+		}
+	}()
+	return true, e.job(ctx)
+}
+
+// lockID — ключ advisory-блокировки задачи: FNV-1a от имени с префиксом,
+// чтобы не пересекаться с другими блокировками сервиса.
+func lockID(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("cron:" + name))
+	return int64(h.Sum64())
+}
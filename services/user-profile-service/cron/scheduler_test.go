@@ -0,0 +1,68 @@
+package cron_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/monst/story-craft/services/user-profile-service/cron"
+	"github.com/monst/story-craft/services/user-profile-service/metrics"
+	"github.com/monst/story-craft/services/user-profile-service/testutil"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(testutil.RunWithPostgres(m))
+}
+
+func TestRunJobTakesAdvisoryLock(t *testing.T) {
+	db := testutil.NewDB(t)
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	every, _ := cron.Parse("* * * * *")
+
+	// Два экземпляра сервиса с одной задачей: пока первый её выполняет, второй пропускает запуск
+	started, release := make(chan struct{}), make(chan struct{})
+	first := cron.New(sqlDB, metrics.New())
+	first.Add("test-job", every, func(context.Context) error {
+		close(started)
+		<-release
+		return nil
+	})
+	runs := 0
+	second := cron.New(sqlDB, metrics.New())
+	second.Add("test-job", every, func(context.Context) error {
+		runs++
+		return nil
+	})
+	second.Add("failing-job", every, func(context.Context) error {
+		return errors.New("сбой")
+	})
+
+	done := make(chan bool)
+	go func() { done <- first.RunJob(ctx, "test-job") }()
+	<-started
+	if second.RunJob(ctx, "test-job") || runs != 0 {
+		t.Fatalf("задача под блокировкой другого экземпляра не должна выполняться, запусков: %d", runs)
+	}
+	close(release)
+	if !<-done {
+		t.Fatal("первый экземпляр должен был выполнить задачу")
+	}
+	if !second.RunJob(ctx, "test-job") || runs != 1 {
+		t.Fatalf("после снятия блокировки задача должна выполниться, запусков: %d", runs)
+	}
+
+	// Блокировка снимается и после ошибки задачи
+	for range 2 {
+		if !second.RunJob(ctx, "failing-job") {
+			t.Fatal("задача с ошибкой должна запускаться снова")
+		}
+	}
+	if second.RunJob(ctx, "unknown") {
+		t.Error("неизвестная задача не должна запускаться")
+	}
+}
@@ -0,0 +1,180 @@
+package digest
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/monst/story-craft/services/user-profile-service/config"
+	"github.com/monst/story-craft/services/user-profile-service/mail"
+	"github.com/monst/story-craft/services/user-profile-service/metrics"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"github.com/monst/story-craft/services/user-profile-service/notifications"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/plugin/dbresolver"
+)
+
+// JobKind — фоновая задача сборки дайджестов, которую по DIGEST_SCHEDULE ставит cron.Scheduler.
+const JobKind = "digest.send"
+
+// Результаты сборки дайджеста для метрик.
+const (
+	ResultSent = "sent"
+	// ResultEmpty — новых непрочитанных уведомлений не было, дайджест не отправлен.
+	ResultEmpty = "empty"
+)
+
+// Builder собирает дайджесты, которым пора, и ставит их в очередь писем или во входящие.
+type Builder struct {
+	db  *gorm.DB
+	cfg config.DigestConfig
+	// email — очередь писем; nil, если отправка писем выключена, и тогда дайджест приходит во входящие.
+	email *mail.Queue
+	// ttl — срок хранения дайджеста во входящих.
+	ttl     time.Duration
+	metrics *metrics.Metrics
+}
+
+func NewBuilder(db *gorm.DB, cfg config.DigestConfig, email *mail.Queue, ttl time.Duration, m *metrics.Metrics) *Builder {
+	return &Builder{db: db, cfg: cfg, email: email, ttl: ttl, metrics: m}
+}
+
+func (b *Builder) session(ctx context.Context) *gorm.DB {
+	return b.db.WithContext(ctx).Clauses(dbresolver.Write).Session(&gorm.Session{})
+}
+
+// Run — обработчик задачи JobKind: собирает все дайджесты, которым пора, порциями по DIGEST_BATCH_SIZE.
+func (b *Builder) Run(ctx context.Context) error {
+	for {
+		n, err := b.SendDue(ctx, time.Now())
+		if err != nil || n < b.cfg.BatchSize {
+			return err
+		}
+	}
+}
+
+// SendDue собирает до DIGEST_BATCH_SIZE дайджестов со временем отправки не позже now.
+// Дайджест ставится в очередь в одной транзакции со сдвигом расписания на следующий
+// период, поэтому повторный запуск на другом экземпляре не отправит его второй раз,
+// а SKIP LOCKED не даёт двум экземплярам собирать одни и те же дайджесты.
+// Возвращает число обработанных расписаний.
+func (b *Builder) SendDue(ctx context.Context, now time.Time) (int, error) {
+	var due []models.DigestSettings
+	err := b.session(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("frequency <> ? AND next_send_at <= ?", models.DigestOff, now).
+			Order("next_send_at").Limit(b.cfg.BatchSize).Find(&due).Error; err != nil {
+			return err
+		}
+		if len(due) == 0 {
+			return nil
+		}
+
+		userIDs := make([]string, 0, len(due))
+		for _, s := range due {
+			userIDs = append(userIDs, s.UserID)
+		}
+		var existing []string
+		if err := tx.Model(&models.Profile{}).Where("user_id IN ?", userIDs).Pluck("user_id", &existing).Error; err != nil {
+			return err
+		}
+		exists := make(map[string]bool, len(existing))
+		for _, id := range existing {
+			exists[id] = true
+		}
+
+		for _, s := range due {
+			if !exists[s.UserID] {
+				// Профиль удалён: расписание больше не нужно
+				if err := tx.Delete(&models.DigestSettings{}, "user_id = ?", s.UserID).Error; err != nil {
+					return err
+				}
+				continue
+			}
+			if err := b.send(tx, s, now); err != nil {
+				return err
+			}
+			if err := tx.Model(&models.DigestSettings{}).Where("user_id = ?", s.UserID).UpdateColumns(map[string]any{
+				"last_sent_at": now.UTC(),
+				"next_send_at": s.Next(now),
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return len(due), err
+}
+
+// send собирает дайджест пользователя за период до now и ставит его в канал из расписания.
+func (b *Builder) send(tx *gorm.DB, s models.DigestSettings, now time.Time) error {
+	channel := s.Channel
+	if channel == models.ChannelEmail && b.email == nil {
+		channel = models.ChannelInApp
+	}
+	summary, err := b.summarize(tx, s, now)
+	if err != nil {
+		return err
+	}
+	if summary.Total == 0 {
+		b.metrics.Digest(channel, ResultEmpty)
+		return nil
+	}
+
+	// Ключ — время по расписанию: повтор того же дайджеста не создаст второе письмо или уведомление
+	key := "digest:" + strconv.FormatInt(s.NextSendAt.Unix(), 10)
+	if channel == models.ChannelEmail {
+		if _, err := b.email.Enqueue(tx, s.UserID, mail.TemplateDigest, summary, &key); err != nil {
+			return err
+		}
+	} else {
+		payload, err := json.Marshal(summary)
+		if err != nil {
+			return err
+		}
+		note := models.Notification{RecipientID: s.UserID, Type: models.EventDigest, Payload: payload, DedupKey: &key}
+		if _, _, err := notifications.Insert(tx, note, b.ttl); err != nil {
+			return err
+		}
+	}
+	b.metrics.Digest(channel, ResultSent)
+	return nil
+}
+
+// summarize группирует непрочитанные неистёкшие уведомления пользователя по истории
+// и типу, от групп с самыми свежими уведомлениями. Период начинается с предыдущего
+// дайджеста, но не раньше одного периода до now.
+func (b *Builder) summarize(tx *gorm.DB, s models.DigestSettings, now time.Time) (models.DigestSummary, error) {
+	since := now.Add(-s.Period())
+	if s.LastSentAt != nil && s.LastSentAt.After(since) {
+		since = *s.LastSentAt
+	}
+	summary := models.DigestSummary{Frequency: s.Frequency, Since: since.UTC(), Until: now.UTC(), Groups: []models.DigestGroup{}}
+
+	var groups []models.DigestGroup
+	err := tx.Model(&models.Notification{}).
+		Select(`type,
+			COALESCE(payload->>'story_id', '') AS story_id,
+			COALESCE((array_agg(payload->>'story_title' ORDER BY id DESC))[1], '') AS story_title,
+			COUNT(*) AS count,
+			MAX(created_at) AS latest_at`).
+		Where("recipient_id = ? AND read_at IS NULL AND expires_at > now() AND type <> ?", s.UserID, models.EventDigest).
+		Where("created_at > ? AND created_at <= ?", since, now).
+		Group("type, COALESCE(payload->>'story_id', '')").
+		Order("latest_at DESC, type").
+		Scan(&groups).Error
+	if err != nil {
+		return summary, err
+	}
+	for i, g := range groups {
+		summary.Total += g.Count
+		if i < b.cfg.MaxGroups {
+			summary.Groups = append(summary.Groups, g)
+		} else {
+			summary.Omitted += g.Count
+		}
+	}
+	return summary, nil
+}
@@ -0,0 +1,100 @@
+package digest
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/monst/story-craft/services/user-profile-service/config"
+	"github.com/monst/story-craft/services/user-profile-service/mail"
+	"github.com/monst/story-craft/services/user-profile-service/metrics"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"github.com/monst/story-craft/services/user-profile-service/testutil"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(testutil.RunWithPostgres(m))
+}
+
+func TestSendDue(t *testing.T) {
+	db := testutil.NewDB(t)
+	fx := testutil.LoadFixtures(t, db)
+	builder := NewBuilder(db, config.DigestConfig{BatchSize: 10, MaxGroups: 1}, mail.NewQueue(db, config.EmailConfig{}), time.Hour, metrics.New())
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	due := now.Add(-time.Minute)
+
+	schedule := func(userID, channel string) {
+		s := models.DefaultDigestSettings(userID)
+		s.Frequency, s.Channel, s.TimeZone, s.NextSendAt = models.DigestDaily, channel, "UTC", &due
+		if err := db.Create(&s).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	schedule(fx.Alice.UserID, models.ChannelInApp)
+	schedule(fx.Bob.UserID, models.ChannelEmail)
+	schedule(fx.Admin.UserID, models.ChannelInApp)
+	schedule(fx.Deleted.UserID, models.ChannelInApp)
+
+	notify := func(userID, event, payload string, age time.Duration, read bool) {
+		n := models.Notification{RecipientID: userID, Type: event, Payload: []byte(payload), CreatedAt: now.Add(-age), ExpiresAt: now.Add(time.Hour)}
+		if read {
+			n.ReadAt = &now
+		}
+		if err := db.Create(&n).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, userID := range []string{fx.Alice.UserID, fx.Bob.UserID} {
+		for i := range 3 {
+			notify(userID, models.EventNewChapter, `{"story_id":"1","story_title":"Туманный берег"}`, time.Duration(i+1)*time.Minute, false)
+		}
+		notify(userID, models.EventNewFollower, `{}`, time.Hour, false)
+		// Прочитанные и вышедшие за период уведомления в дайджест не попадают
+		notify(userID, models.EventVotingOpened, `{"story_id":"2"}`, time.Minute, true)
+		notify(userID, models.EventVotingOpened, `{"story_id":"2"}`, 48*time.Hour, false)
+	}
+
+	n, err := builder.SendDue(ctx, now)
+	if err != nil || n != 4 {
+		t.Fatalf("ожидали четыре расписания, получили %d, %v", n, err)
+	}
+
+	var digests []models.Notification
+	db.Where("type = ?", models.EventDigest).Find(&digests)
+	if len(digests) != 1 || digests[0].RecipientID != fx.Alice.UserID {
+		t.Fatalf("ожидали дайджест во входящих только у Alice, получили %+v", digests)
+	}
+	var summary models.DigestSummary
+	if err := json.Unmarshal(digests[0].Payload, &summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary.Total != 4 || summary.Omitted != 1 || len(summary.Groups) != 1 ||
+		summary.Groups[0].Count != 3 || summary.Groups[0].StoryTitle != "Туманный берег" {
+		t.Errorf("неверная сводка: %+v", summary)
+	}
+
+	var emails []models.EmailMessage
+	db.Where("template = ?", mail.TemplateDigest).Find(&emails)
+	if len(emails) != 1 || emails[0].RecipientID != fx.Bob.UserID {
+		t.Errorf("ожидали письмо-дайджест для Bob, получили %+v", emails)
+	}
+
+	var settings []models.DigestSettings
+	db.Order("user_id").Find(&settings)
+	if len(settings) != 3 {
+		t.Fatalf("расписание удалённого профиля должно быть удалено, осталось %d", len(settings))
+	}
+	for _, s := range settings {
+		if s.LastSentAt == nil || s.NextSendAt == nil || !s.NextSendAt.After(now) {
+			t.Errorf("расписание %s не сдвинуто: %+v", s.UserID, s)
+		}
+	}
+
+	// Повторный запуск ничего не отправляет
+	if n, err := builder.SendDue(ctx, now); err != nil || n != 0 {
+		t.Errorf("повторный запуск: ожидали 0, получили %d, %v", n, err)
+	}
+}
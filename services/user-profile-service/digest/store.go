@@ -0,0 +1,88 @@
+// Package digest собирает дайджесты: раз в день или неделю в час, выбранный пользователем
+// в его часовом поясе, непрочитанные уведомления группируются по истории и типу и уходят
+// одним письмом или одним уведомлением во входящих. Сборку запускает cron.Scheduler.
+package digest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/monst/story-craft/services/user-profile-service/audit"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/plugin/dbresolver"
+)
+
+// ErrInvalidSettings — неверная частота, канал, час, день недели или часовой пояс.
+var ErrInvalidSettings = errors.New("неверное расписание дайджеста")
+
+// Store хранит расписания дайджестов в основной БД: расписание читается сразу после изменения.
+type Store struct {
+	db *gorm.DB
+}
+
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) session(ctx context.Context) *gorm.DB {
+	return s.db.WithContext(ctx).Clauses(dbresolver.Write).Session(&gorm.Session{})
+}
+
+// Get возвращает расписание дайджеста пользователя или расписание по умолчанию,
+// если пользователь его не менял. Если профиля нет, возвращает gorm.ErrRecordNotFound.
+func (s *Store) Get(ctx context.Context, userID string) (models.DigestSettings, error) {
+	var settings models.DigestSettings
+	err := s.session(ctx).Where("user_id = ?", userID).First(&settings).Error
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return settings, err
+	}
+	if err := s.session(ctx).Select("user_id").Where("user_id = ?", userID).First(&models.Profile{}).Error; err != nil {
+		return settings, err
+	}
+	return models.DefaultDigestSettings(userID), nil
+}
+
+// Update применяет изменение расписания и пересчитывает время следующего дайджеста.
+// Включённый дайджест не захватывает уведомления старше одного периода.
+// Если профиля нет, возвращает gorm.ErrRecordNotFound.
+func (s *Store) Update(ctx context.Context, actor audit.Actor, userID string, patch models.DigestSettingsPatch) (models.DigestSettings, error) {
+	var settings models.DigestSettings
+	err := s.session(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("user_id").
+			Where("user_id = ?", userID).First(&models.Profile{}).Error; err != nil {
+			return err
+		}
+		current := models.DefaultDigestSettings(userID)
+		if err := tx.Where("user_id = ?", userID).Limit(1).Find(&current).Error; err != nil {
+			return err
+		}
+
+		settings = patch.Apply(current)
+		if err := settings.Validate(); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSettings, err)
+		}
+		settings.NextSendAt = settings.Next(time.Now())
+		diff := audit.DiffOf(current, settings)
+		// Время следующего дайджеста выводится из остальных полей
+		delete(diff, "next_send_at")
+		if len(diff) == 0 {
+			settings = current
+			return nil
+		}
+		settings.UpdatedAt = time.Now()
+		if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&settings).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Entry{
+			Actor:        actor,
+			Action:       audit.ActionDigestUpdated,
+			TargetUserID: userID,
+			Diff:         diff,
+		})
+	})
+	return settings, err
+}
@@ -24,40 +24,73 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/profiles": {
-            "post": {
+        "/admin/audit": {
+            "get": {
                 "security": [
                     {
                         "bearerAuth": []
                     }
                 ],
-                "description": "Создает новый профиль пользователя на основе полученных данных",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Записи журнала от новых к старым. Следующая страница запрашивается с before_id, равным id последней записи.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "profiles"
+                    "admin"
                 ],
-                "summary": "Создать профиль пользователя",
+                "summary": "Журнал изменений профилей",
                 "parameters": [
                     {
-                        "description": "Данные профиля",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/InputProfile"
-                        }
+                        "type": "string",
+                        "description": "Кто выполнил действие",
+                        "name": "actor_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Чей профиль изменён",
+                        "name": "target_user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Действие, например profile.updated",
+                        "name": "action",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Начало периода (RFC 3339)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Конец периода, не включая (RFC 3339)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Вернуть записи с id меньше указанного",
+                        "name": "before_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Размер страницы (по умолчанию 50, не больше 100)",
+                        "name": "limit",
+                        "in": "query"
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Созданный профиль",
+                    "200": {
+                        "description": "Записи журнала",
                         "schema": {
-                            "$ref": "#/definitions/InputProfile"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/AuditEntry"
+                            }
                         }
                     },
                     "400": {
@@ -69,8 +102,8 @@ const docTemplate = `{
                             }
                         }
                     },
-                    "409": {
-                        "description": "Конфликт при создании профиля",
+                    "403": {
+                        "description": "Недостаточно прав",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -90,38 +123,104 @@ const docTemplate = `{
                 }
             }
         },
-        "/profiles/{user_id}": {
+        "/admin/audit/verify": {
             "get": {
                 "security": [
                     {
                         "bearerAuth": []
                     }
                 ],
-                "description": "Возвращает профиль пользователя по указанному идентификатору",
-                "consumes": [
+                "description": "Пересчитывает хеши всех записей. 409 означает, что запись broken_at изменена или удалена в обход сервиса.",
+                "produces": [
                     "application/json"
                 ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Проверить цепочку хешей журнала",
+                "responses": {
+                    "200": {
+                        "description": "Журнал не изменён",
+                        "schema": {
+                            "$ref": "#/definitions/audit.VerifyResult"
+                        }
+                    },
+                    "403": {
+                        "description": "Недостаточно прав",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Цепочка нарушена",
+                        "schema": {
+                            "$ref": "#/definitions/audit.VerifyResult"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/jobs": {
+            "get": {
+                "security": [
+                    {
+                        "bearerAuth": []
+                    }
+                ],
+                "description": "Задачи очереди от новых к старым. Следующая страница запрашивается с before_id, равным id последней задачи. Завершённые задачи хранятся JOBS_RETENTION.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "profiles"
+                    "admin"
                 ],
-                "summary": "Получить профиль пользователя",
+                "summary": "Список фоновых задач",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Идентификатор пользователя",
-                        "name": "user_id",
-                        "in": "path",
-                        "required": true
+                        "description": "Состояние: pending, running, done или failed",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Вид задачи, например digest.send",
+                        "name": "kind",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Вернуть задачи с id меньше указанного",
+                        "name": "before_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Размер страницы (по умолчанию 50, не больше 100)",
+                        "name": "limit",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Профиль пользователя",
+                        "description": "Задачи",
                         "schema": {
-                            "$ref": "#/definitions/Profile"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/Job"
+                            }
                         }
                     },
                     "400": {
@@ -133,8 +232,8 @@ const docTemplate = `{
                             }
                         }
                     },
-                    "404": {
-                        "description": "Профиль не найден",
+                    "403": {
+                        "description": "Недостаточно прав",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -152,39 +251,50 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "delete": {
+            }
+        },
+        "/admin/jobs/{id}": {
+            "get": {
                 "security": [
                     {
                         "bearerAuth": []
                     }
                 ],
-                "description": "Удаляет профиль пользователя с указанным идентификатором",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Задача с аргументами, числом попыток и последней ошибкой.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "profiles"
+                    "admin"
                 ],
-                "summary": "Удалить профиль пользователя",
+                "summary": "Получить фоновую задачу",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Идентификатор пользователя",
-                        "name": "user_id",
+                        "type": "integer",
+                        "description": "Идентификатор задачи",
+                        "name": "id",
                         "in": "path",
                         "required": true
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "Профиль успешно удален"
+                    "200": {
+                        "description": "Задача",
+                        "schema": {
+                            "$ref": "#/definitions/Job"
+                        }
                     },
                     "400": {
-                        "description": "Ошибка в запросе",
+                        "description": "Неверный идентификатор",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Недостаточно прав",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -193,7 +303,7 @@ const docTemplate = `{
                         }
                     },
                     "404": {
-                        "description": "Профиль не найден",
+                        "description": "Задача не найдена",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -211,42 +321,50 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "patch": {
+            }
+        },
+        "/admin/jobs/{id}/retry": {
+            "post": {
                 "security": [
                     {
                         "bearerAuth": []
                     }
                 ],
-                "description": "Обновляет существующий профиль пользователя",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Возвращает задачу со статусом failed в очередь с новым набором попыток. Действие записывается в журнал.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "profiles"
+                    "admin"
                 ],
-                "summary": "Обновить профиль пользователя",
+                "summary": "Повторить фоновую задачу",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Идентификатор пользователя",
-                        "name": "user_id",
+                        "type": "integer",
+                        "description": "Идентификатор задачи",
+                        "name": "id",
                         "in": "path",
                         "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Обновленный профиль",
+                        "description": "Задача в очереди",
                         "schema": {
-                            "$ref": "#/definitions/UpdateProfile"
+                            "$ref": "#/definitions/Job"
                         }
                     },
                     "400": {
-                        "description": "Ошибка в запросе",
+                        "description": "Неверный идентификатор",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Недостаточно прав",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -255,7 +373,7 @@ const docTemplate = `{
                         }
                     },
                     "404": {
-                        "description": "Профиль не найден",
+                        "description": "Задача не найдена",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -264,7 +382,7 @@ const docTemplate = `{
                         }
                     },
                     "409": {
-                        "description": "Конфликт при обновлении профиля",
+                        "description": "Задача не завершилась ошибкой или такая же уже в очереди",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -283,70 +401,3714 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "InputProfile": {
-            "type": "object",
-            "required": [
-                "email",
-                "userId",
-                "username"
-            ],
-            "properties": {
-                "avatarUrl": {
-                    "type": "string",
-                    "example": "https://example.com/avatar.jpg"
-                },
-                "email": {
-                    "type": "string",
-                    "example": "user@example.com"
-                },
-                "role": {
-                    "type": "string",
-                    "example": "user"
-                },
-                "userId": {
-                    "type": "string",
-                    "example": "550e8400-e29b-41d4-a716-446655440000"
-                },
-                "username": {
-                    "type": "string",
-                    "example": "user123"
+        },
+        "/admin/profiles/flagged": {
+            "get": {
+                "security": [
+                    {
+                        "bearerAuth": []
+                    }
+                ],
+                "description": "Профили, отмеченные для проверки модератором, начиная с самых давних",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Профили на проверку",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Размер страницы (по умолчанию 20, не больше 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Смещение",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Отмеченные профили",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/Profile"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка в запросе",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Недостаточно прав",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
                 }
             }
         },
-        "Profile": {
-            "type": "object",
-            "properties": {
-                "avatar_url": {
-                    "type": "string"
-                },
-                "bio": {
-                    "type": "string"
-                },
-                "created_at": {
-                    "type": "string"
-                },
-                "display_name": {
-                    "type": "string"
-                },
-                "email": {
-                    "type": "string"
-                },
-                "id": {
-                    "type": "string"
-                },
-                "last_seen": {
-                    "type": "string"
-                },
-                "role": {
-                    "type": "string"
-                },
-                "updated_at": {
-                    "type": "string"
-                },
-                "user_id": {
+        "/admin/profiles/{user_id}/ban": {
+            "post": {
+                "security": [
+                    {
+                        "bearerAuth": []
+                    }
+                ],
+                "description": "Банит пользователя до указанного момента или бессрочно, если until не передан. Причина обязательна.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Забанить пользователя",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Идентификатор пользователя",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Причина и необязательный срок бана",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/Moderation"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Обновлённый профиль",
+                        "schema": {
+                            "$ref": "#/definitions/Profile"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка в запросе",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Недостаточно прав",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Профиль не найден",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/profiles/{user_id}/flag": {
+            "delete": {
+                "security": [
+                    {
+                        "bearerAuth": []
+                    }
+                ],
+                "description": "Убирает профиль из списка отмеченных для проверки. Поля, задержанные проверкой текста, становятся видны всем.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Снять отметку о проверке",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Идентификатор пользователя",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Комментарий модератора",
+                        "name": "reason",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Обновлённый профиль",
+                        "schema": {
+                            "$ref": "#/definitions/Profile"
+                        }
+                    },
+                    "403": {
+                        "description": "Недостаточно прав",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Профиль не найден",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/profiles/{user_id}/reset": {
+            "post": {
+                "security": [
+                    {
+                        "bearerAuth": []
+                    }
+                ],
+                "description": "Очищает display_name, bio, avatar_url, links, location и/или pronouns. Прежние значения сохраняются в журнале.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Сбросить поля профиля",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Идентификатор пользователя",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Поля и причина",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/ResetFields"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Обновлённый профиль",
+                        "schema": {
+                            "$ref": "#/definitions/Profile"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка в запросе",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Недостаточно прав",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Профиль не найден",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/profiles/{user_id}/restore": {
+            "post": {
+                "security": [
+                    {
+                        "bearerAuth": []
+                    }
+                ],
+                "description": "Возвращает профилю состояние ACTIVE. Причина обязательна.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Снять блокировку",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Идентификатор пользователя",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Причина снятия блокировки",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/Moderation"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Обновлённый профиль",
+                        "schema": {
+                            "$ref": "#/definitions/Profile"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка в запросе",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Недостаточно прав",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Профиль не найден",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/profiles/{user_id}/role": {
+            "put": {
+                "security": [
+                    {
+                        "bearerAuth": []
+                    }
+                ],
+                "description": "Назначает роль USER, AUTHOR или ADMIN. Действие записывается в журнал.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Сменить роль пользователя",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Идентификатор пользователя",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Новая роль",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/ChangeRole"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Обновлённый профиль",
+                        "schema": {
+                            "$ref": "#/definitions/Profile"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка в запросе",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Недостаточно прав",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Профиль не найден",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/profiles/{user_id}/suspend": {
+            "post": {
+                "security": [
+                    {
+                        "bearerAuth": []
+                    }
+                ],
+                "description": "Блокирует профиль до указанного момента: пользователь не может его изменять. Причина и срок обязательны.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Заблокировать пользователя на время",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Идентификатор пользователя",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Причина и срок блокировки",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/Moderation"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Обновлённый профиль",
+                        "schema": {
+                            "$ref": "#/definitions/Profile"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка в запросе",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Недостаточно прав",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Профиль не найден",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/reports": {
+            "get": {
+                "security": [
+                    {
+                        "bearerAuth": []
+                    }
+                ],
+                "description": "Жалобы в порядке поступления. По умолчанию только нерассмотренные (OPEN и TRIAGED).",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Очередь жалоб",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Состояния через запятую: OPEN, TRIAGED, ACTIONED, DISMISSED",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Категория жалобы",
+                        "name": "category",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "На чей профиль жалоба",
+                        "name": "target_user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Размер страницы (по умолчанию 20, не больше 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Смещение",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Жалобы",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/Report"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка в запросе",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Недостаточно прав",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/reports/{id}/action": {
+            "post": {
+                "security": [
+                    {
+                        "bearerAuth": []
+                    }
+                ],
+                "description": "Закрывает жалобу как ACTIONED и скрывает указанные поля профиля. Скрытые поля видит только владелец; изменённое им поле снова становится видимым. Автор жалобы получает уведомление report_resolved с outcome.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Принять меры по жалобе",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Идентификатор жалобы",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Решение и поля для скрытия",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/ReportDecision"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Рассмотренная жалоба",
+                        "schema": {
+                            "$ref": "#/definitions/Report"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка в запросе",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Недостаточно прав",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Жалоба или профиль не найдены",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Жалоба уже рассмотрена",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/reports/{id}/dismiss": {
+            "post": {
+                "security": [
+                    {
+                        "bearerAuth": []
+                    }
+                ],
+                "description": "Закрывает жалобу как DISMISSED без изменения профиля. Автор жалобы получает уведомление report_resolved с outcome.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Отклонить жалобу",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Идентификатор жалобы",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Решение для автора жалобы",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/ReportDecision"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Рассмотренная жалоба",
+                        "schema": {
+                            "$ref": "#/definitions/Report"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка в запросе",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Недостаточно прав",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Жалоба не найдена",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Жалоба уже рассмотрена",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/reports/{id}/triage": {
+            "post": {
+                "security": [
+                    {
+                        "bearerAuth": []
+                    }
+                ],
+                "description": "Переводит жалобу из OPEN в TRIAGED и закрепляет её за модератором",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Взять жалобу в работу",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Идентификатор жалобы",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Жалоба",
+                        "schema": {
+                            "$ref": "#/definitions/Report"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка в запросе",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Недостаточно прав",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Жалоба не найдена",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Жалоба уже в работе или рассмотрена",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/email/confirm": {
+            "post": {
+                "description": "Применяет смену адреса или подтверждает текущий адрес по ссылке из письма. После смены на прежний адрес приходит письмо со ссылкой отмены. Вход в приложение не нужен: ссылка подписана и действует EMAIL_CHANGE_TTL.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "email"
+                ],
+                "summary": "Подтвердить email по ссылке",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Токен из ссылки подтверждения",
+                        "name": "token",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Профиль с подтверждённым адресом",
+                        "schema": {
+                            "$ref": "#/definitions/Profile"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверная ссылка",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Ссылка уже использована, заменена новой или адрес занят",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "410": {
+                        "description": "Срок ссылки истёк",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Превышен лимит запросов",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/email/revert": {
+            "post": {
+                "description": "Возвращает адрес, который был до смены, по ссылке из письма на него. Ссылка действует EMAIL_REVERT_TTL и только пока адрес в профиле не меняли снова.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "email"
+                ],
+                "summary": "Отменить смену email",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Токен из ссылки отмены",
+                        "name": "token",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Профиль с прежним адресом",
+                        "schema": {
+                            "$ref": "#/definitions/Profile"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверная ссылка",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Ссылка уже использована или прежний адрес занят",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "410": {
+                        "description": "Срок ссылки истёк",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Превышен лимит запросов",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/email/unsubscribe": {
+            "post": {
+                "description": "Отписка по подписанной ссылке из письма без входа в приложение. Со scope=all перестают приходить все письма, кроме служебных, иначе в настройках уведомлений выключается почта для событий этого письма. Этот же адрес указан в заголовке List-Unsubscribe для отписки в один клик (RFC 8058). Повторная отписка ничего не меняет.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "email"
+                ],
+                "summary": "Отписаться от писем",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Токен из ссылки отписки",
+                        "name": "token",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "scope — от каких писем отписан пользователь",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Неверная ссылка отписки",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Профиль не найден",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Превышен лимит запросов",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/events": {
+            "get": {
+                "security": [
+                    {
+                        "bearerAuth": []
+                    }
+                ],
+                "description": "Server-Sent Events с новыми уведомлениями (event: notification) и изменениями профиля (event: profile). После разрыва браузер переподключается с Last-Event-ID и получает пропущенные события; если их уже нет в буфере, приходит event: resync — данные нужно перечитать. Раз в REALTIME_HEARTBEAT отправляется комментарий-пинг.",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "events"
+                ],
+                "summary": "Поток событий (SSE)",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Идентификатор последнего полученного события",
+                        "name": "Last-Event-ID",
+                        "in": "header"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "То же, что Last-Event-ID, для клиентов без доступа к заголовкам",
+                        "name": "last_event_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Поток событий",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный Last-Event-ID",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Требуется авторизация",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Открыто слишком много потоков",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "503": {
+                        "description": "Сервис останавливается",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/events/ws": {
+            "get": {
+                "security": [
+                    {
+                        "bearerAuth": []
+                    }
+                ],
+                "description": "Те же события, что и в /events, сообщениями JSON {\"id\",\"type\",\"data\"}. Для продолжения после разрыва передайте last_event_id. Раз в REALTIME_HEARTBEAT приходит {\"type\":\"heartbeat\"}. Доступен, если включён REALTIME_WEBSOCKET; origin проверяется по политике CORS.",
+                "tags": [
+                    "events"
+                ],
+                "summary": "Поток событий (WebSocket)",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Идентификатор последнего полученного события",
+                        "name": "last_event_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "101": {
+                        "description": "Соединение WebSocket",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный last_event_id",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Требуется авторизация",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Origin не разрешён",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "429": {
+                        "description": "Открыто слишком много потоков",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "503": {
+                        "description": "Сервис останавливается",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/health/live": {
+            "get": {
+                "description": "Не обращается к зависимостям, отвечает 200, пока процесс обрабатывает запросы",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Проверка живости",
+                "responses": {
+                    "200": {
+                        "description": "Сервис жив",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/health/ready": {
+            "get": {
+                "description": "Проверяет доступность PostgreSQL, версию схемы и состояние фоновых задач. Результат кэшируется на несколько секунд",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Проверка готовности",
+                "responses": {
+                    "200": {
+                        "description": "Все зависимости доступны",
+                        "schema": {
+                            "$ref": "#/definitions/health.Report"
+                        }
+                    },
+                    "503": {
+                        "description": "Хотя бы одна зависимость недоступна",
+                        "schema": {
+                            "$ref": "#/definitions/health.Report"
+                        }
+                    }
+                }
+            }
+        },
+        "/internal/email/bounces": {
+            "post": {
+                "description": "Вызывается обработчиком уведомлений почтового провайдера. После жёсткого отказа или нескольких временных письма на адрес больше не отправляются, пока пользователь не сменит email; жалоба на спам отписывает от всех писем, кроме служебных. Вызывается с заголовком X-Internal-Token, через API-шлюз не публикуется.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "internal"
+                ],
+                "summary": "Сообщить об отказе доставки (внутренний API)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Общий секрет внутреннего API",
+                        "name": "X-Internal-Token",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Адрес и вид отказа: hard, soft или complaint",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/EmailBounce"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "status: recorded",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка в запросе",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Неверный внутренний токен",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Профиль с таким адресом не найден",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "503": {
+                        "description": "Внутренний API не настроен",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/internal/notifications": {
+            "post": {
+                "description": "Создаёт уведомление для каждого получателя, у которого включены уведомления этого типа в приложении. Повтор с тем же dedup_key для получателя не создаёт дубликат. Вызывается другими сервисами с заголовком X-Internal-Token, через API-шлюз не публикуется.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "internal"
+                ],
+                "summary": "Создать уведомления (внутренний API)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Общий секрет внутреннего API",
+                        "name": "X-Internal-Token",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Уведомление и получатели",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/IngestNotification"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "created — созданные уведомления, results — результат по получателям: created, duplicate или muted",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка в запросе",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Неверный внутренний токен",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "503": {
+                        "description": "Внутренний API не настроен",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/internal/profiles/{user_id}/email": {
+            "get": {
+                "description": "Для сервисов, которым нужно знать, подтверждён ли адрес пользователя и доставляются ли на него письма. Вызывается с заголовком X-Internal-Token, через API-шлюз не публикуется.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "internal"
+                ],
+                "summary": "Адрес и подтверждение email (внутренний API)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Общий секрет внутреннего API",
+                        "name": "X-Internal-Token",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Идентификатор пользователя",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Адрес и его подтверждение",
+                        "schema": {
+                            "$ref": "#/definitions/EmailStatus"
+                        }
+                    },
+                    "401": {
+                        "description": "Неверный внутренний токен",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Профиль не найден",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "503": {
+                        "description": "Внутренний API не настроен",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/notifications": {
+            "get": {
+                "security": [
+                    {
+                        "bearerAuth": []
+                    }
+                ],
+                "description": "Уведомления текущего пользователя от новых к старым. Следующая страница запрашивается с cursor из next_cursor; unread_count — общее число непрочитанных.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "notifications"
+                ],
+                "summary": "Входящие уведомления",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Курсор из next_cursor предыдущей страницы",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Размер страницы (по умолчанию 20, не больше 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Только непрочитанные",
+                        "name": "unread",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Страница уведомлений",
+                        "schema": {
+                            "$ref": "#/definitions/NotificationPage"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный курсор или limit",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Требуется авторизация",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Превышен лимит запросов",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/notifications/read-all": {
+            "post": {
+                "security": [
+                    {
+                        "bearerAuth": []
+                    }
+                ],
+                "description": "Отмечает непрочитанные уведомления пользователя. С up_to отмечаются только уведомления с id не больше указанного, чтобы не задеть пришедшие после загрузки списка.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "notifications"
+                ],
+                "summary": "Отметить все уведомления прочитанными",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Наибольший отмечаемый идентификатор",
+                        "name": "up_to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "updated — сколько уведомлений отмечено",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "integer"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный up_to",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Требуется авторизация",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Профиль заблокирован модератором",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Превышен лимит запросов",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/notifications/unread-count": {
+            "get": {
+                "security": [
+                    {
+                        "bearerAuth": []
+                    }
+                ],
+                "description": "Для счётчика на иконке уведомлений без загрузки списка",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "notifications"
+                ],
+                "summary": "Число непрочитанных уведомлений",
+                "responses": {
+                    "200": {
+                        "description": "unread_count",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "integer"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Требуется авторизация",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Превышен лимит запросов",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/notifications/{id}/read": {
+            "post": {
+                "security": [
+                    {
+                        "bearerAuth": []
+                    }
+                ],
+                "description": "Повторная отметка не меняет время прочтения",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "notifications"
+                ],
+                "summary": "Отметить уведомление прочитанным",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Идентификатор уведомления",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Уведомление",
+                        "schema": {
+                            "$ref": "#/definitions/Notification"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный идентификатор",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Требуется авторизация",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Профиль заблокирован модератором",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Уведомление не найдено",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Превышен лимит запросов",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/profiles": {
+            "get": {
+                "security": [
+                    {
+                        "bearerAuth": []
+                    }
+                ],
+                "description": "Пакетная выборка профилей по user_ids (через запятую, не больше 100) или поиск по началу username и отображаемого имени",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "profiles"
+                ],
+                "summary": "Найти профили пользователей",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Идентификаторы пользователей через запятую",
+                        "name": "user_ids",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Начало username или отображаемого имени",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Максимум результатов поиска (по умолчанию 20, не больше 100)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Найденные профили",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/Profile"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка в запросе",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Превышен лимит запросов",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "bearerAuth": []
+                    }
+                ],
+                "description": "Создает новый профиль пользователя на основе полученных данных. Имя проверяется на мат, ссылки и спам: в зависимости от настроек оно отклоняется или задерживается до проверки модератором (held_fields).",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "profiles"
+                ],
+                "summary": "Создать профиль пользователя",
+                "parameters": [
+                    {
+                        "description": "Данные профиля",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/InputProfile"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Ключ идемпотентности: повтор с тем же ключом вернёт исходный ответ",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Созданный профиль",
+                        "schema": {
+                            "$ref": "#/definitions/InputProfile"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка в запросе",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Попытка назначить роль ADMIN",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Конфликт при создании профиля или запрос с тем же ключом ещё выполняется",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "422": {
+                        "description": "Имя не прошло проверку текста (violations) или Idempotency-Key уже использован с другим запросом",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "429": {
+                        "description": "Превышен лимит запросов",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/profiles/{user_id}": {
+            "get": {
+                "security": [
+                    {
+                        "bearerAuth": []
+                    }
+                ],
+                "description": "Возвращает профиль пользователя по указанному идентификатору",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "profiles"
+                ],
+                "summary": "Получить профиль пользователя",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Идентификатор пользователя",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Профиль пользователя",
+                        "schema": {
+                            "$ref": "#/definitions/Profile"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка в запросе",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Профиль не найден",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Превышен лимит запросов",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "bearerAuth": []
+                    }
+                ],
+                "description": "Удаляет профиль пользователя с указанным идентификатором. Доступно только владельцу профиля.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "profiles"
+                ],
+                "summary": "Удалить профиль пользователя",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Идентификатор пользователя",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Профиль успешно удален"
+                    },
+                    "400": {
+                        "description": "Ошибка в запросе",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Требуется авторизация",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Чужой профиль или профиль заблокирован модератором",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Профиль не найден",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Превышен лимит запросов",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "bearerAuth": []
+                    }
+                ],
+                "description": "Обновляет существующий профиль пользователя. Расширенные поля (links, location, pronouns, languages, favoriteGenres) меняются, только если переданы; fieldVisibility задаёт, кому они видны: public, registered или private. Описание (bio) принимается в разметке CommonMark, безопасный HTML для показа возвращается в bio_html. Изменённые имя, отображаемое имя, описание, местоположение и местоимения проходят проверку текста: фрагменты могут быть замаскированы, поле задержано до проверки модератором (held_fields) или изменение отклонено (422). Новый email вступает в силу после перехода по ссылке из письма на него: до этого он возвращается в pending_email, а в email остаётся прежний адрес; если отправка писем выключена, смена email отклоняется (409). Доступно только владельцу профиля.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "profiles"
+                ],
+                "summary": "Обновить профиль пользователя",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Идентификатор пользователя",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Обновленный профиль",
+                        "schema": {
+                            "$ref": "#/definitions/UpdateProfile"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка в запросе",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Требуется авторизация",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Чужой профиль, профиль заблокирован или попытка сменить роль",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Профиль не найден",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Конфликт при обновлении профиля или смена email при выключенной отправке писем",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "422": {
+                        "description": "Текст не прошёл проверку: в violations поля и сработавшие правила",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "429": {
+                        "description": "Превышен лимит запросов",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/profiles/{user_id}/digest": {
+            "get": {
+                "security": [
+                    {
+                        "bearerAuth": []
+                    }
+                ],
+                "description": "Частота (off, daily, weekly), канал (email или in_app), час отправки и день недели в часовом поясе пользователя, время следующего дайджеста. Если пользователь ничего не менял, возвращается расписание по умолчанию: дайджест выключен. Доступно только владельцу профиля.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "profiles"
+                ],
+                "summary": "Получить расписание дайджеста",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Идентификатор пользователя",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Расписание дайджеста",
+                        "schema": {
+                            "$ref": "#/definitions/DigestSettings"
+                        }
+                    },
+                    "401": {
+                        "description": "Требуется авторизация",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Чужой профиль",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Профиль не найден",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Превышен лимит запросов",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "bearerAuth": []
+                    }
+                ],
+                "description": "Меняет переданные поля расписания. Дайджест собирает непрочитанные уведомления с предыдущего дайджеста, сгруппированные по истории и типу, и приходит в час send_hour (0–23) по часовому поясу IANA time_zone; еженедельный — в день weekday (0 — воскресенье). Если новых уведомлений нет, дайджест не приходит. Доступно только владельцу профиля.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "profiles"
+                ],
+                "summary": "Изменить расписание дайджеста",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Идентификатор пользователя",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Изменяемые поля расписания",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/DigestSettingsPatch"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Сохранённое расписание",
+                        "schema": {
+                            "$ref": "#/definitions/DigestSettings"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверная частота, канал, час, день недели или часовой пояс",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Требуется авторизация",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Чужой профиль или профиль заблокирован модератором",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Профиль не найден",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Превышен лимит запросов",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/profiles/{user_id}/email/verify": {
+            "post": {
+                "security": [
+                    {
+                        "bearerAuth": []
+                    }
+                ],
+                "description": "Отправляет на текущий адрес профиля письмо со ссылкой подтверждения. Новая ссылка заменяет отправленные раньше. Доступно только владельцу профиля.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "profiles"
+                ],
+                "summary": "Подтвердить текущий email",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Идентификатор пользователя",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "status: sent",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Требуется авторизация",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Чужой профиль или профиль заблокирован модератором",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Профиль не найден",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Адрес уже подтверждён",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Превышен лимит запросов",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/profiles/{user_id}/notification-preferences": {
+            "get": {
+                "security": [
+                    {
+                        "bearerAuth": []
+                    }
+                ],
+                "description": "Возвращает, по каким каналам (in_app, email, web_push) приходят уведомления о событиях, и тихие часы. Расписание дайджеста задаётся отдельно: /profiles/{user_id}/digest. Если пользователь ничего не менял, возвращаются настройки по умолчанию. Доступно только владельцу профиля.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "profiles"
+                ],
+                "summary": "Получить настройки уведомлений",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Идентификатор пользователя",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Настройки уведомлений",
+                        "schema": {
+                            "$ref": "#/definitions/NotificationPreferences"
+                        }
+                    },
+                    "401": {
+                        "description": "Требуется авторизация",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Чужой профиль",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Профиль не найден",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Превышен лимит запросов",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "bearerAuth": []
+                    }
+                ],
+                "description": "Заменяет настройки уведомлений целиком. События, которых нет в запросе, получают значения по умолчанию. Время тихих часов задаётся как HH:MM в часовом поясе IANA (например, Europe/Moscow), интервал может переходить через полночь. Доступно только владельцу профиля.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "profiles"
+                ],
+                "summary": "Изменить настройки уведомлений",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Идентификатор пользователя",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Настройки уведомлений",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/NotificationPreferences"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Сохранённые настройки",
+                        "schema": {
+                            "$ref": "#/definitions/NotificationPreferences"
+                        }
+                    },
+                    "400": {
+                        "description": "Неизвестное событие, неверное время или часовой пояс",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Требуется авторизация",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Чужой профиль или профиль заблокирован модератором",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Профиль не найден",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Превышен лимит запросов",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/profiles/{user_id}/push-subscriptions": {
+            "get": {
+                "security": [
+                    {
+                        "bearerAuth": []
+                    }
+                ],
+                "description": "Подписки браузеров пользователя от новых к старым. Доступно только владельцу профиля.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "push"
+                ],
+                "summary": "Подписанные устройства",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Идентификатор пользователя",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Подписки",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/PushSubscription"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Требуется авторизация",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Чужой профиль",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Превышен лимит запросов",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "bearerAuth": []
+                    }
+                ],
+                "description": "Принимает PushSubscription.toJSON() браузера и необязательную подпись устройства. Повторная подписка того же браузера обновляет ключи. При первой подписке включаются push о начале голосования и победе продолжения, если пользователь не включал push сам. Доступно только владельцу профиля.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "push"
+                ],
+                "summary": "Подписать устройство на push",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Идентификатор пользователя",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Подписка браузера",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/PushSubscriptionInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Подписка обновлена",
+                        "schema": {
+                            "$ref": "#/definitions/PushSubscription"
+                        }
+                    },
+                    "201": {
+                        "description": "Новая подписка",
+                        "schema": {
+                            "$ref": "#/definitions/PushSubscription"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный адрес push-сервиса или ключи",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Требуется авторизация",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Чужой профиль или профиль заблокирован модератором",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Профиль не найден",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Превышен лимит запросов",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/profiles/{user_id}/push-subscriptions/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "bearerAuth": []
+                    }
+                ],
+                "description": "Удаляет подписку вместе с неотправленными на неё сообщениями. Доступно только владельцу профиля.",
+                "tags": [
+                    "push"
+                ],
+                "summary": "Отписать устройство от push",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Идентификатор пользователя",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Идентификатор подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Подписка удалена"
+                    },
+                    "400": {
+                        "description": "Неверный идентификатор",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Требуется авторизация",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Чужой профиль или профиль заблокирован модератором",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка не найдена",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Превышен лимит запросов",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/profiles/{user_id}/reports": {
+            "post": {
+                "security": [
+                    {
+                        "bearerAuth": []
+                    }
+                ],
+                "description": "Жалоба на имя, описание или аватар пользователя. Пока жалоба не рассмотрена, повторная жалоба на тот же профиль возвращает существующую (200).",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reports"
+                ],
+                "summary": "Пожаловаться на профиль",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Идентификатор пользователя, на которого жалуются",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Категория и суть жалобы",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/CreateReport"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Жалоба уже подана и ждёт решения",
+                        "schema": {
+                            "$ref": "#/definitions/Report"
+                        }
+                    },
+                    "201": {
+                        "description": "Жалоба создана",
+                        "schema": {
+                            "$ref": "#/definitions/Report"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка в запросе",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Требуется авторизация",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Профиль заблокирован модератором",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Профиль не найден",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Превышен лимит запросов",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/push/vapid-public-key": {
+            "get": {
+                "description": "Ключ applicationServerKey для pushManager.subscribe в браузере. Если ключ отличается от того, с которым браузер подписан, подписку нужно оформить заново.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "push"
+                ],
+                "summary": "Открытый ключ VAPID",
+                "responses": {
+                    "200": {
+                        "description": "public_key — ключ в base64url",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/reports": {
+            "get": {
+                "security": [
+                    {
+                        "bearerAuth": []
+                    }
+                ],
+                "description": "Жалобы, поданные текущим пользователем, с состоянием и решением модератора, от новых к старым",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reports"
+                ],
+                "summary": "Мои жалобы",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Размер страницы (по умолчанию 20, не больше 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Смещение",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Жалобы",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/Report"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка в запросе",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Требуется авторизация",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "AuditEntry": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string"
+                },
+                "actor_id": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "details": {
+                    "type": "object"
+                },
+                "diff": {
+                    "type": "object"
+                },
+                "hash": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "ip": {
+                    "type": "string"
+                },
+                "prev_hash": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "request_id": {
+                    "type": "string"
+                },
+                "target_user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "ChangeRole": {
+            "type": "object",
+            "required": [
+                "role"
+            ],
+            "properties": {
+                "reason": {
+                    "type": "string",
+                    "example": "Подтверждён статус автора"
+                },
+                "role": {
+                    "type": "string",
+                    "example": "AUTHOR"
+                }
+            }
+        },
+        "CreateReport": {
+            "type": "object",
+            "required": [
+                "category"
+            ],
+            "properties": {
+                "category": {
+                    "type": "string",
+                    "example": "SPAM"
+                },
+                "comment": {
+                    "type": "string",
+                    "example": "Реклама казино в описании"
+                },
+                "fields": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "bio"
+                    ]
+                }
+            }
+        },
+        "DigestSettings": {
+            "type": "object",
+            "properties": {
+                "channel": {
+                    "description": "Channel — email или in_app.",
+                    "type": "string",
+                    "example": "email"
+                },
+                "frequency": {
+                    "type": "string",
+                    "example": "daily"
+                },
+                "last_sent_at": {
+                    "description": "LastSentAt — когда собран предыдущий дайджест: следующий включает уведомления после него.",
+                    "type": "string"
+                },
+                "next_send_at": {
+                    "description": "NextSendAt — когда будет собран следующий дайджест; пусто, если дайджест выключен.",
+                    "type": "string"
+                },
+                "send_hour": {
+                    "description": "SendHour — час отправки от 0 до 23 по часовому поясу TimeZone.",
+                    "type": "integer",
+                    "example": 9
+                },
+                "time_zone": {
+                    "type": "string",
+                    "example": "Europe/Moscow"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "weekday": {
+                    "description": "Weekday — день недели еженедельного дайджеста: 0 — воскресенье, 1 — понедельник.",
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "DigestSettingsPatch": {
+            "type": "object",
+            "properties": {
+                "channel": {
+                    "type": "string",
+                    "example": "in_app"
+                },
+                "frequency": {
+                    "type": "string",
+                    "example": "weekly"
+                },
+                "send_hour": {
+                    "type": "integer",
+                    "example": 20
+                },
+                "time_zone": {
+                    "type": "string",
+                    "example": "Asia/Kolkata"
+                },
+                "weekday": {
+                    "type": "integer",
+                    "example": 5
+                }
+            }
+        },
+        "EmailBounce": {
+            "type": "object",
+            "required": [
+                "address",
+                "type"
+            ],
+            "properties": {
+                "address": {
+                    "type": "string",
+                    "example": "user@example.com"
+                },
+                "reason": {
+                    "type": "string",
+                    "example": "550 5.1.1 user unknown"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "hard"
+                }
+            }
+        },
+        "EmailStatus": {
+            "type": "object",
+            "properties": {
+                "deliverable": {
+                    "description": "Deliverable — письма на адрес доставляются: нет жёсткого отказа.",
+                    "type": "boolean"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "email_verified_at": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                },
+                "verified": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "EventChannels": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "boolean"
+                },
+                "in_app": {
+                    "type": "boolean"
+                },
+                "web_push": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "IngestNotification": {
+            "type": "object",
+            "required": [
+                "recipient_ids",
+                "type"
+            ],
+            "properties": {
+                "dedup_key": {
+                    "description": "DedupKey — идентификатор события у отправителя, уникален для каждого получателя.",
+                    "type": "string",
+                    "example": "story-42:voting-3"
+                },
+                "payload": {
+                    "type": "object"
+                },
+                "recipient_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "550e8400-e29b-41d4-a716-446655440000"
+                    ]
+                },
+                "ttl": {
+                    "description": "TTL — срок хранения, например 72h; по умолчанию берётся из конфигурации.",
+                    "type": "string",
+                    "example": "72h"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "voting_opened"
+                }
+            }
+        },
+        "InputProfile": {
+            "type": "object",
+            "required": [
+                "email",
+                "userId",
+                "username"
+            ],
+            "properties": {
+                "avatarUrl": {
+                    "type": "string",
+                    "example": "https://example.com/avatar.jpg"
+                },
+                "email": {
+                    "type": "string",
+                    "example": "user@example.com"
+                },
+                "role": {
+                    "type": "string",
+                    "example": "USER"
+                },
+                "userId": {
+                    "type": "string",
+                    "example": "550e8400-e29b-41d4-a716-446655440000"
+                },
+                "username": {
+                    "type": "string",
+                    "example": "user123"
+                }
+            }
+        },
+        "Job": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "finished_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "kind": {
+                    "type": "string",
+                    "example": "digest.send"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "max_attempts": {
+                    "description": "MaxAttempts — после стольких ошибок задача получает статус failed.",
+                    "type": "integer"
+                },
+                "payload": {
+                    "type": "object"
+                },
+                "run_at": {
+                    "description": "RunAt — когда задачу можно забрать: время запуска или повтора, у выполняемой — конец аренды.",
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "unique_key": {
+                    "description": "UniqueKey — пока задача с этим ключом ждёт или выполняется, такая же не ставится.",
+                    "type": "string"
+                }
+            }
+        },
+        "Moderation": {
+            "type": "object",
+            "required": [
+                "reason"
+            ],
+            "properties": {
+                "reason": {
+                    "type": "string",
+                    "example": "Спам в описании профиля"
+                },
+                "until": {
+                    "type": "string",
+                    "example": "2030-01-01T00:00:00Z"
+                }
+            }
+        },
+        "Notification": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "description": "ExpiresAt — после этого момента уведомление не показывается и удаляется фоновой очисткой.",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "payload": {
+                    "type": "object"
+                },
+                "read_at": {
+                    "type": "string"
+                },
+                "recipient_id": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "voting_opened"
+                }
+            }
+        },
+        "NotificationPage": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/Notification"
+                    }
+                },
+                "next_cursor": {
+                    "type": "string"
+                },
+                "unread_count": {
+                    "type": "integer"
+                }
+            }
+        },
+        "NotificationPreferences": {
+            "type": "object",
+            "properties": {
+                "events": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/EventChannels"
+                    }
+                },
+                "language": {
+                    "description": "Language — язык писем: ru или en; пустой — язык сервиса по умолчанию.",
+                    "type": "string",
+                    "example": "ru"
+                },
+                "quiet_hours": {
+                    "$ref": "#/definitions/QuietHours"
+                },
+                "version": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "Profile": {
+            "type": "object",
+            "properties": {
+                "avatar_url": {
+                    "type": "string"
+                },
+                "bio": {
+                    "type": "string"
+                },
+                "bio_html": {
+                    "description": "BioHTML — Bio, отрисованное из CommonMark в безопасный HTML; обновляется вместе с Bio.",
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "display_name": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "email_bounced_at": {
+                    "description": "EmailBouncedAt — когда письма на Email перестали доставляться; пока адрес не изменён, писем нет.",
+                    "type": "string"
+                },
+                "email_unsubscribed_at": {
+                    "description": "EmailUnsubscribedAt — когда пользователь отписался от всех писем, кроме служебных.\nСбрасывается, если он снова включает письма в настройках уведомлений.",
+                    "type": "string"
+                },
+                "email_verified_at": {
+                    "description": "EmailVerifiedAt — когда владелец подтвердил Email по ссылке из письма; NULL — адрес не подтверждён.",
+                    "type": "string"
+                },
+                "favorite_genres": {
+                    "description": "FavoriteGenres — любимые жанры, названия жанров story-service.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "field_visibility": {
+                    "description": "FieldVisibility — кому видны расширенные поля; видна только владельцу.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "flag_reason": {
+                    "type": "string"
+                },
+                "flagged_at": {
+                    "description": "FlaggedAt — когда профиль отмечен для проверки модератором.",
+                    "type": "string"
+                },
+                "held_fields": {
+                    "description": "HeldFields — поля, задержанные автоматической проверкой текста до решения модератора.\nВидны только владельцу; снимаются, когда модератор снимает отметку о проверке.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "hidden_fields": {
+                    "description": "HiddenFields — поля, скрытые модератором по жалобе. Владелец видит их и может исправить:\nизменённое поле снова становится видимым.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "languages": {
+                    "description": "Languages — языки, на которых пишет автор, в виде тегов BCP 47.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "last_seen": {
+                    "type": "string"
+                },
+                "links": {
+                    "description": "Links — ссылки на соцсети и сайты, не больше PROFILE_MAX_LINKS.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/SocialLink"
+                    }
+                },
+                "location": {
+                    "type": "string"
+                },
+                "pending_email": {
+                    "description": "PendingEmail — новый адрес, который ждёт подтверждения; виден только владельцу.",
+                    "type": "string"
+                },
+                "pronouns": {
+                    "type": "string"
+                },
+                "role": {
+                    "type": "string"
+                },
+                "status": {
+                    "description": "Status — состояние модерации: ACTIVE, SUSPENDED или BANNED.\nОграничение действует до StatusUntil, без срока — бессрочно.",
+                    "type": "string"
+                },
+                "status_reason": {
+                    "type": "string"
+                },
+                "status_until": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "user_id": {
                     "type": "string"
                 },
                 "username": {
@@ -354,6 +4116,218 @@ const docTemplate = `{
                 }
             }
         },
+        "PushSubscription": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "device_name": {
+                    "description": "DeviceName — подпись устройства для списка подписок, например «Рабочий ноутбук».",
+                    "type": "string",
+                    "example": "Firefox на ноутбуке"
+                },
+                "endpoint": {
+                    "type": "string",
+                    "example": "https://fcm.googleapis.com/fcm/send/dGVzdA"
+                },
+                "expires_at": {
+                    "description": "ExpiresAt — срок подписки, если его сообщил браузер (expirationTime).",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "last_success_at": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "user_agent": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "PushSubscriptionInput": {
+            "type": "object",
+            "required": [
+                "endpoint",
+                "keys"
+            ],
+            "properties": {
+                "device_name": {
+                    "type": "string",
+                    "example": "Firefox на ноутбуке"
+                },
+                "endpoint": {
+                    "type": "string",
+                    "example": "https://fcm.googleapis.com/fcm/send/dGVzdA"
+                },
+                "expirationTime": {
+                    "description": "ExpirationTime — срок подписки в миллисекундах Unix или null.",
+                    "type": "integer",
+                    "example": 1767225600000
+                },
+                "keys": {
+                    "$ref": "#/definitions/PushSubscriptionKeys"
+                }
+            }
+        },
+        "PushSubscriptionKeys": {
+            "type": "object",
+            "required": [
+                "auth",
+                "p256dh"
+            ],
+            "properties": {
+                "auth": {
+                    "type": "string",
+                    "example": "BTBZMqHH6r4Tts7J_aSIgg"
+                },
+                "p256dh": {
+                    "type": "string",
+                    "example": "BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4"
+                }
+            }
+        },
+        "QuietHours": {
+            "type": "object",
+            "properties": {
+                "enabled": {
+                    "type": "boolean"
+                },
+                "end": {
+                    "type": "string",
+                    "example": "08:00"
+                },
+                "start": {
+                    "type": "string",
+                    "example": "22:00"
+                },
+                "time_zone": {
+                    "type": "string",
+                    "example": "Europe/Moscow"
+                }
+            }
+        },
+        "Report": {
+            "type": "object",
+            "properties": {
+                "category": {
+                    "type": "string"
+                },
+                "comment": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "fields": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "hidden_fields": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "moderator_id": {
+                    "description": "ModeratorID не показывается автору жалобы.",
+                    "type": "string"
+                },
+                "outcome": {
+                    "description": "Outcome — сообщение автору жалобы о принятом решении.",
+                    "type": "string"
+                },
+                "reporter_id": {
+                    "type": "string"
+                },
+                "resolved_at": {
+                    "type": "string"
+                },
+                "snapshot": {
+                    "description": "Snapshot — значения полей профиля на момент жалобы, чтобы модератор видел их и после правки или скрытия.",
+                    "type": "object"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "target_user_id": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "ReportDecision": {
+            "type": "object",
+            "required": [
+                "outcome"
+            ],
+            "properties": {
+                "hide_fields": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "bio"
+                    ]
+                },
+                "outcome": {
+                    "type": "string",
+                    "example": "Описание профиля скрыто"
+                }
+            }
+        },
+        "ResetFields": {
+            "type": "object",
+            "required": [
+                "fields",
+                "reason"
+            ],
+            "properties": {
+                "fields": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "display_name",
+                        "bio",
+                        "avatar_url"
+                    ]
+                },
+                "reason": {
+                    "type": "string",
+                    "example": "Оскорбительное содержимое"
+                }
+            }
+        },
+        "SocialLink": {
+            "type": "object",
+            "properties": {
+                "type": {
+                    "type": "string",
+                    "example": "github"
+                },
+                "url": {
+                    "type": "string",
+                    "example": "https://github.com/alice"
+                }
+            }
+        },
         "UpdateProfile": {
             "type": "object",
             "required": [
@@ -365,7 +4339,8 @@ const docTemplate = `{
                     "type": "string"
                 },
                 "bio": {
-                    "type": "string"
+                    "type": "string",
+                    "example": "Пишу *фэнтези*"
                 },
                 "displayName": {
                     "type": "string"
@@ -374,6 +4349,50 @@ const docTemplate = `{
                     "type": "string",
                     "example": "email@mail.ru"
                 },
+                "favoriteGenres": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "Фэнтези"
+                    ]
+                },
+                "fieldVisibility": {
+                    "description": "FieldVisibility меняет видимость перечисленных полей: public, registered или private.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "languages": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "ru",
+                        "en-US"
+                    ]
+                },
+                "links": {
+                    "description": "Расширенные поля меняются, только если переданы; пустое значение очищает поле.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "https://github.com/alice"
+                    ]
+                },
+                "location": {
+                    "type": "string",
+                    "example": "Москва"
+                },
+                "pronouns": {
+                    "type": "string",
+                    "example": "она/её"
+                },
                 "role": {
                     "type": "string"
                 },
@@ -385,6 +4404,61 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "audit.VerifyResult": {
+            "type": "object",
+            "properties": {
+                "broken_at": {
+                    "description": "BrokenAt — первая запись, на которой цепочка не сходится, 0 если всё в порядке.",
+                    "type": "integer"
+                },
+                "checked": {
+                    "type": "integer"
+                }
+            }
+        },
+        "health.CheckResult": {
+            "type": "object",
+            "properties": {
+                "details": {},
+                "error": {
+                    "type": "string"
+                },
+                "latency_ms": {
+                    "type": "number"
+                },
+                "status": {
+                    "$ref": "#/definitions/health.Status"
+                }
+            }
+        },
+        "health.Report": {
+            "type": "object",
+            "properties": {
+                "checked_at": {
+                    "type": "string"
+                },
+                "checks": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/health.CheckResult"
+                    }
+                },
+                "status": {
+                    "$ref": "#/definitions/health.Status"
+                }
+            }
+        },
+        "health.Status": {
+            "type": "string",
+            "enum": [
+                "ok",
+                "fail"
+            ],
+            "x-enum-varnames": [
+                "StatusOK",
+                "StatusFail"
+            ]
         }
     }
 }`
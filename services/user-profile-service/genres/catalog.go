@@ -0,0 +1,109 @@
+// Package genres — справочник жанров story-service, из которого выбираются любимые
+// жанры профиля.
+package genres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/monst/story-craft/services/user-profile-service/config"
+)
+
+// Catalog хранит названия жанров. Пока список не загружен из PROFILE_GENRES_URL,
+// используются жанры из PROFILE_GENRES.
+type Catalog struct {
+	url      string
+	interval time.Duration
+	client   *http.Client
+
+	mu sync.RWMutex
+	// names — название в нижнем регистре → название как в story-service.
+	names map[string]string
+}
+
+func NewCatalog(cfg config.ProfileConfig) *Catalog {
+	c := &Catalog{url: cfg.GenresURL, interval: cfg.GenresRefreshInterval, client: &http.Client{Timeout: cfg.GenresTimeout}}
+	c.set(cfg.Genres)
+	return c
+}
+
+// Canonical возвращает название жанра как в story-service без учёта регистра
+// и пробелов по краям; ok == false, если такого жанра нет.
+func (c *Catalog) Canonical(name string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	canonical, ok := c.names[strings.ToLower(strings.TrimSpace(name))]
+	return canonical, ok
+}
+
+func (c *Catalog) set(names []string) {
+	index := make(map[string]string, len(names))
+	for _, name := range names {
+		index[strings.ToLower(name)] = name
+	}
+	c.mu.Lock()
+	c.names = index
+	c.mu.Unlock()
+}
+
+// Refresh загружает жанры из GET /genres story-service. При ошибке или пустом
+// ответе остаётся прежний список.
+func (c *Catalog) Refresh(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("story-service ответил %s", resp.Status)
+	}
+	var body struct {
+		Genres []struct {
+			Name string `json:"name"`
+		} `json:"genres"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("не удалось разобрать список жанров: %w", err)
+	}
+	names := make([]string, 0, len(body.Genres))
+	for _, genre := range body.Genres {
+		if name := strings.TrimSpace(genre.Name); name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return errors.New("story-service вернул пустой список жанров")
+	}
+	c.set(names)
+	return nil
+}
+
+// Run — фоновая задача для lifecycle.Manager.Go: загружает жанры при запуске
+// и затем раз в PROFILE_GENRES_REFRESH_INTERVAL.
+func (c *Catalog) Run(ctx context.Context) error {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		if err := c.Refresh(ctx); err != nil && ctx.Err() == nil {
+			slog.Warn("Не удалось обновить список жанров", slog.Any("error", err))
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
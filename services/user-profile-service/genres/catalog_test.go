@@ -0,0 +1,44 @@
+package genres
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/monst/story-craft/services/user-profile-service/config"
+)
+
+func TestCatalogRefresh(t *testing.T) {
+	body := `{"count": 2, "genres": [{"id": "1", "name": "Киберпанк"}, {"id": "2", "name": "Космоопера"}]}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	c := NewCatalog(config.ProfileConfig{Genres: []string{"Фэнтези"}, GenresURL: srv.URL, GenresTimeout: time.Second})
+	if name, ok := c.Canonical(" фэнтези "); !ok || name != "Фэнтези" {
+		t.Errorf("до загрузки ожидали жанры из конфигурации, получили %q, %v", name, ok)
+	}
+
+	if err := c.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if name, ok := c.Canonical("КОСМООПЕРА"); !ok || name != "Космоопера" {
+		t.Errorf("ожидали жанр из story-service, получили %q, %v", name, ok)
+	}
+	if _, ok := c.Canonical("Фэнтези"); ok {
+		t.Error("жанр, которого нет в story-service, не должен приниматься")
+	}
+
+	// Пустой ответ не стирает загруженный список
+	body = `{"count": 0, "genres": []}`
+	if err := c.Refresh(context.Background()); err == nil {
+		t.Error("ожидали ошибку для пустого списка")
+	}
+	if _, ok := c.Canonical("Киберпанк"); !ok {
+		t.Error("после ошибки должен остаться прежний список")
+	}
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/monst/story-craft/services/user-profile-service/middleware"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// requireOwner пропускает только владельца профиля user_id. Им проверяются все
+// маршруты, которыми пользователь читает или меняет свои данные: профиль, почту,
+// настройки уведомлений, дайджест и push-подписки.
+func requireOwner(c *gin.Context, userID string) bool {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "Требуется авторизация")
+		return false
+	}
+	if principal.UserID != userID {
+		respondError(c, http.StatusForbidden, "Доступно только владельцу профиля")
+		return false
+	}
+	return true
+}
+
+// RestrictionGuard не пускает пользователей с действующей блокировкой или баном
+// к маршрутам, которыми они что-то меняют от своего имени.
+type RestrictionGuard struct {
+	db *gorm.DB
+}
+
+func NewRestrictionGuard(db *gorm.DB) *RestrictionGuard {
+	return &RestrictionGuard{db: db}
+}
+
+// RequireActive проверяет профиль автора запроса в основной БД, чтобы только что
+// наложенная блокировка действовала сразу. Запросы без авторизации и от пользователей
+// без профиля пропускаются: их отклоняет сам обработчик, а ограничивать ещё нечего.
+func (g RestrictionGuard) RequireActive(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		c.Next()
+		return
+	}
+
+	var profile models.Profile
+	err := g.db.WithContext(c.Request.Context()).Clauses(dbresolver.Write).
+		Select("status", "status_until").Where("user_id = ?", principal.UserID).Take(&profile).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		respondDBError(c, err, "Ошибка при проверке состояния профиля")
+		c.Abort()
+		return
+	}
+	if err == nil && profile.IsRestricted(time.Now()) {
+		respondError(c, http.StatusForbidden, "Профиль заблокирован модератором")
+		c.Abort()
+		return
+	}
+	c.Next()
+}
@@ -0,0 +1,424 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/monst/story-craft/services/user-profile-service/audit"
+	"github.com/monst/story-craft/services/user-profile-service/metrics"
+	"github.com/monst/story-craft/services/user-profile-service/middleware"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"github.com/monst/story-craft/services/user-profile-service/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/plugin/dbresolver"
+)
+
+// resettableFields — поля профиля, которые администратор может принудительно очистить.
+var resettableFields = []string{"display_name", "bio", "avatar_url", "links", "location", "pronouns"}
+
+type AdminHandler struct {
+	db           *gorm.DB
+	recentWrites *utils.RecentWrites
+	metrics      *metrics.Metrics
+}
+
+func NewAdminHandler(db *gorm.DB, recentWrites *utils.RecentWrites, m *metrics.Metrics) *AdminHandler {
+	return &AdminHandler{db: db, recentWrites: recentWrites, metrics: m}
+}
+
+// writeDB возвращает сессию основной БД: права и состояние профилей проверяются без отставания реплик.
+func (h AdminHandler) writeDB(c *gin.Context) *gorm.DB {
+	return h.db.WithContext(c.Request.Context()).Clauses(dbresolver.Write).Session(&gorm.Session{})
+}
+
+// RequireAdmin пропускает только пользователей с ролью ADMIN и без действующей блокировки.
+// Роль берётся из профиля в БД, а не из заголовка x-user-object.
+func (h AdminHandler) RequireAdmin(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "Требуется авторизация")
+		c.Abort()
+		return
+	}
+
+	var admin models.Profile
+	err := h.writeDB(c).Where("user_id = ?", principal.UserID).First(&admin).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		respondDBError(c, err, "Ошибка при проверке прав администратора")
+		c.Abort()
+		return
+	}
+	if err != nil || admin.Role != models.RoleAdmin || admin.IsRestricted(time.Now()) {
+		respondError(c, http.StatusForbidden, "Недостаточно прав")
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+// ChangeRole меняет роль пользователя
+// @Summary Сменить роль пользователя
+// @Description Назначает роль USER, AUTHOR или ADMIN. Действие записывается в журнал.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security bearerAuth
+// @Param user_id path string true "Идентификатор пользователя"
+// @Param request body models.ChangeRole true "Новая роль"
+// @Success 200 {object} models.Profile "Обновлённый профиль"
+// @Failure 400 {object} map[string]string "Ошибка в запросе"
+// @Failure 403 {object} map[string]string "Недостаточно прав"
+// @Failure 404 {object} map[string]string "Профиль не найден"
+// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
+// @Router /admin/profiles/{user_id}/role [put]
+func (h AdminHandler) ChangeRole(c *gin.Context) {
+	var input models.ChangeRole
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, "Ошибка в формате JSON: проверьте правильность данных")
+		return
+	}
+	role, ok := models.NormalizeRole(input.Role)
+	if !ok {
+		respondError(c, http.StatusBadRequest, "Ошибка: роль должна быть USER, AUTHOR или ADMIN")
+		return
+	}
+
+	h.moderate(c, audit.ActionRoleChanged, input.Reason, func(p *models.Profile) (map[string]any, any) {
+		return map[string]any{"role": role}, gin.H{"from": p.Role, "to": role}
+	})
+}
+
+// SuspendProfile временно блокирует пользователя
+// @Summary Заблокировать пользователя на время
+// @Description Блокирует профиль до указанного момента: пользователь не может его изменять. Причина и срок обязательны.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security bearerAuth
+// @Param user_id path string true "Идентификатор пользователя"
+// @Param request body models.Moderation true "Причина и срок блокировки"
+// @Success 200 {object} models.Profile "Обновлённый профиль"
+// @Failure 400 {object} map[string]string "Ошибка в запросе"
+// @Failure 403 {object} map[string]string "Недостаточно прав"
+// @Failure 404 {object} map[string]string "Профиль не найден"
+// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
+// @Router /admin/profiles/{user_id}/suspend [post]
+func (h AdminHandler) SuspendProfile(c *gin.Context) {
+	input, ok := bindModeration(c)
+	if !ok {
+		return
+	}
+	if input.Until == nil {
+		respondError(c, http.StatusBadRequest, "Ошибка: для блокировки нужен срок until")
+		return
+	}
+	h.setStatus(c, audit.ActionSuspended, models.StatusSuspended, input)
+}
+
+// BanProfile банит пользователя
+// @Summary Забанить пользователя
+// @Description Банит пользователя до указанного момента или бессрочно, если until не передан. Причина обязательна.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security bearerAuth
+// @Param user_id path string true "Идентификатор пользователя"
+// @Param request body models.Moderation true "Причина и необязательный срок бана"
+// @Success 200 {object} models.Profile "Обновлённый профиль"
+// @Failure 400 {object} map[string]string "Ошибка в запросе"
+// @Failure 403 {object} map[string]string "Недостаточно прав"
+// @Failure 404 {object} map[string]string "Профиль не найден"
+// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
+// @Router /admin/profiles/{user_id}/ban [post]
+func (h AdminHandler) BanProfile(c *gin.Context) {
+	input, ok := bindModeration(c)
+	if !ok {
+		return
+	}
+	h.setStatus(c, audit.ActionBanned, models.StatusBanned, input)
+}
+
+// RestoreProfile снимает блокировку или бан
+// @Summary Снять блокировку
+// @Description Возвращает профилю состояние ACTIVE. Причина обязательна.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security bearerAuth
+// @Param user_id path string true "Идентификатор пользователя"
+// @Param request body models.Moderation true "Причина снятия блокировки"
+// @Success 200 {object} models.Profile "Обновлённый профиль"
+// @Failure 400 {object} map[string]string "Ошибка в запросе"
+// @Failure 403 {object} map[string]string "Недостаточно прав"
+// @Failure 404 {object} map[string]string "Профиль не найден"
+// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
+// @Router /admin/profiles/{user_id}/restore [post]
+func (h AdminHandler) RestoreProfile(c *gin.Context) {
+	input, ok := bindModeration(c)
+	if !ok {
+		return
+	}
+	input.Until = nil
+	h.setStatus(c, audit.ActionRestored, models.StatusActive, input)
+}
+
+// ResetProfileFields очищает поля профиля
+// @Summary Сбросить поля профиля
+// @Description Очищает display_name, bio, avatar_url, links, location и/или pronouns. Прежние значения сохраняются в журнале.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security bearerAuth
+// @Param user_id path string true "Идентификатор пользователя"
+// @Param request body models.ResetFields true "Поля и причина"
+// @Success 200 {object} models.Profile "Обновлённый профиль"
+// @Failure 400 {object} map[string]string "Ошибка в запросе"
+// @Failure 403 {object} map[string]string "Недостаточно прав"
+// @Failure 404 {object} map[string]string "Профиль не найден"
+// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
+// @Router /admin/profiles/{user_id}/reset [post]
+func (h AdminHandler) ResetProfileFields(c *gin.Context) {
+	var input models.ResetFields
+	if err := c.ShouldBindJSON(&input); err != nil || len(input.Fields) == 0 {
+		respondError(c, http.StatusBadRequest, "Ошибка: передайте fields и reason")
+		return
+	}
+	for _, field := range input.Fields {
+		if !slices.Contains(resettableFields, field) {
+			respondError(c, http.StatusBadRequest, "Ошибка: поле "+field+" нельзя сбросить, допустимы "+strings.Join(resettableFields, ", "))
+			return
+		}
+	}
+
+	h.moderate(c, audit.ActionFieldsReset, input.Reason, func(p *models.Profile) (map[string]any, any) {
+		current := map[string]any{"display_name": p.DisplayName, "bio": p.Bio, "avatar_url": p.AvatarURL,
+			"links": p.Links, "location": p.Location, "pronouns": p.Pronouns}
+		updates := make(map[string]any, len(input.Fields))
+		previous := make(map[string]any, len(input.Fields))
+		for _, field := range input.Fields {
+			updates[field] = ""
+			previous[field] = current[field]
+		}
+		// Ссылки хранятся в jsonb, пустой список — NULL
+		if _, ok := updates["links"]; ok {
+			updates["links"] = models.SocialLinks(nil)
+		}
+		if _, ok := updates["bio"]; ok {
+			updates["bio_html"] = ""
+		}
+		return updates, gin.H{"previous": previous}
+	})
+}
+
+// ResolveFlag снимает отметку о проверке
+// @Summary Снять отметку о проверке
+// @Description Убирает профиль из списка отмеченных для проверки. Поля, задержанные проверкой текста, становятся видны всем.
+// @Tags admin
+// @Produce json
+// @Security bearerAuth
+// @Param user_id path string true "Идентификатор пользователя"
+// @Param reason query string false "Комментарий модератора"
+// @Success 200 {object} models.Profile "Обновлённый профиль"
+// @Failure 403 {object} map[string]string "Недостаточно прав"
+// @Failure 404 {object} map[string]string "Профиль не найден"
+// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
+// @Router /admin/profiles/{user_id}/flag [delete]
+func (h AdminHandler) ResolveFlag(c *gin.Context) {
+	h.moderate(c, audit.ActionFlagResolved, c.Query("reason"), func(p *models.Profile) (map[string]any, any) {
+		return map[string]any{"flagged_at": nil, "flag_reason": "", "held_fields": nil}, gin.H{"flag_reason": p.FlagReason, "held_fields": p.HeldFields}
+	})
+}
+
+// ListFlaggedProfiles возвращает профили, отмеченные для проверки
+// @Summary Профили на проверку
+// @Description Профили, отмеченные для проверки модератором, начиная с самых давних
+// @Tags admin
+// @Produce json
+// @Security bearerAuth
+// @Param limit query int false "Размер страницы (по умолчанию 20, не больше 100)"
+// @Param offset query int false "Смещение"
+// @Success 200 {array} models.Profile "Отмеченные профили"
+// @Failure 400 {object} map[string]string "Ошибка в запросе"
+// @Failure 403 {object} map[string]string "Недостаточно прав"
+// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
+// @Router /admin/profiles/flagged [get]
+func (h AdminHandler) ListFlaggedProfiles(c *gin.Context) {
+	limit, offset, ok := bindPage(c)
+	if !ok {
+		return
+	}
+
+	profiles := []models.Profile{}
+	if err := h.writeDB(c).
+		Where("flagged_at IS NOT NULL").
+		Order("flagged_at").
+		Limit(limit).
+		Offset(offset).
+		Find(&profiles).Error; err != nil {
+		respondDBError(c, err, "Ошибка при получении отмеченных профилей")
+		return
+	}
+	c.JSON(http.StatusOK, profiles)
+}
+
+func bindModeration(c *gin.Context) (models.Moderation, bool) {
+	var input models.Moderation
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, "Ошибка: передайте причину reason")
+		return input, false
+	}
+	if input.Until != nil && !input.Until.After(time.Now()) {
+		respondError(c, http.StatusBadRequest, "Ошибка: срок until должен быть в будущем")
+		return input, false
+	}
+	return input, true
+}
+
+func (h AdminHandler) setStatus(c *gin.Context, action, status string, input models.Moderation) {
+	h.moderate(c, action, input.Reason, func(p *models.Profile) (map[string]any, any) {
+		updates := map[string]any{"status": status, "status_reason": input.Reason, "status_until": input.Until}
+		if status == models.StatusActive {
+			updates["status_reason"] = ""
+		}
+		return updates, gin.H{"from": p.Status, "to": status, "until": input.Until}
+	})
+}
+
+// moderate применяет действие администратора к профилю из пути запроса: в одной
+// транзакции блокирует строку профиля, обновляет её и пишет в журнал запись с изменёнными полями.
+// change получает текущий профиль и возвращает изменения и подробности для журнала.
+func (h AdminHandler) moderate(c *gin.Context, action, reason string, change func(*models.Profile) (map[string]any, any)) {
+	principal, _ := middleware.CurrentPrincipal(c)
+	userID := c.Params.ByName("user_id")
+	if userID == principal.UserID {
+		respondError(c, http.StatusBadRequest, "Нельзя применять действия администратора к своему профилю")
+		return
+	}
+
+	var profile models.Profile
+	err := h.writeDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", userID).First(&profile).Error; err != nil {
+			return err
+		}
+		before := profile
+		updates, details := change(&profile)
+		if err := tx.Model(&profile).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).First(&profile).Error; err != nil {
+			return err
+		}
+		if err := publishProfile(tx, action, userID, &profile); err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Entry{
+			Actor:        audit.ActorFrom(c),
+			Action:       action,
+			TargetUserID: userID,
+			Reason:       reason,
+			Diff:         audit.DiffOf(before, profile),
+			Details:      details,
+		})
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			respondError(c, http.StatusNotFound, "Профиль не найден")
+		} else {
+			respondDBError(c, err, "Ошибка при изменении профиля")
+		}
+		return
+	}
+	h.recentWrites.Mark(userID)
+	h.metrics.ProfileEvent(metrics.EventUpdated)
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// ListAuditLog возвращает записи журнала изменений
+// @Summary Журнал изменений профилей
+// @Description Записи журнала от новых к старым. Следующая страница запрашивается с before_id, равным id последней записи.
+// @Tags admin
+// @Produce json
+// @Security bearerAuth
+// @Param actor_id query string false "Кто выполнил действие"
+// @Param target_user_id query string false "Чей профиль изменён"
+// @Param action query string false "Действие, например profile.updated"
+// @Param from query string false "Начало периода (RFC 3339)"
+// @Param to query string false "Конец периода, не включая (RFC 3339)"
+// @Param before_id query int false "Вернуть записи с id меньше указанного"
+// @Param limit query int false "Размер страницы (по умолчанию 50, не больше 100)"
+// @Success 200 {array} models.AuditEntry "Записи журнала"
+// @Failure 400 {object} map[string]string "Ошибка в запросе"
+// @Failure 403 {object} map[string]string "Недостаточно прав"
+// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
+// @Router /admin/audit [get]
+func (h AdminHandler) ListAuditLog(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > maxBatchSize {
+		respondError(c, http.StatusBadRequest, "Ошибка: limit должен быть от 1 до "+strconv.Itoa(maxBatchSize))
+		return
+	}
+
+	query := h.writeDB(c).Order("id DESC").Limit(limit)
+	for param, column := range map[string]string{"actor_id": "actor_id", "target_user_id": "target_user_id", "action": "action"} {
+		if v := c.Query(param); v != "" {
+			query = query.Where(column+" = ?", v)
+		}
+	}
+	for param, cond := range map[string]string{"from": "created_at >= ?", "to": "created_at < ?"} {
+		v := c.Query(param)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Ошибка: "+param+" должен быть в формате RFC 3339")
+			return
+		}
+		query = query.Where(cond, t.UTC())
+	}
+	if v := c.Query("before_id"); v != "" {
+		beforeID, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Ошибка: before_id должен быть положительным числом")
+			return
+		}
+		query = query.Where("id < ?", beforeID)
+	}
+
+	entries := []models.AuditEntry{}
+	if err := query.Find(&entries).Error; err != nil {
+		respondDBError(c, err, "Ошибка при чтении журнала")
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
+
+// VerifyAuditLog проверяет целостность журнала
+// @Summary Проверить цепочку хешей журнала
+// @Description Пересчитывает хеши всех записей. 409 означает, что запись broken_at изменена или удалена в обход сервиса.
+// @Tags admin
+// @Produce json
+// @Security bearerAuth
+// @Success 200 {object} audit.VerifyResult "Журнал не изменён"
+// @Failure 403 {object} map[string]string "Недостаточно прав"
+// @Failure 409 {object} audit.VerifyResult "Цепочка нарушена"
+// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
+// @Router /admin/audit/verify [get]
+func (h AdminHandler) VerifyAuditLog(c *gin.Context) {
+	res, err := audit.Verify(c.Request.Context(), h.writeDB(c))
+	switch {
+	case errors.Is(err, audit.ErrChainBroken):
+		c.JSON(http.StatusConflict, res)
+	case err != nil:
+		respondDBError(c, err, "Ошибка при проверке журнала")
+	default:
+		c.JSON(http.StatusOK, res)
+	}
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/monst/story-craft/services/user-profile-service/audit"
+	"github.com/monst/story-craft/services/user-profile-service/digest"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type DigestHandler struct {
+	store *digest.Store
+}
+
+func NewDigestHandler(store *digest.Store) *DigestHandler {
+	return &DigestHandler{store: store}
+}
+
+// GetDigestSettings возвращает расписание дайджеста пользователя
+// @Summary Получить расписание дайджеста
+// @Description Частота (off, daily, weekly), канал (email или in_app), час отправки и день недели в часовом поясе пользователя, время следующего дайджеста. Если пользователь ничего не менял, возвращается расписание по умолчанию: дайджест выключен. Доступно только владельцу профиля.
+// @Tags profiles
+// @Produce json
+// @Security bearerAuth
+// @Param user_id path string true "Идентификатор пользователя"
+// @Success 200 {object} models.DigestSettings "Расписание дайджеста"
+// @Failure 401 {object} map[string]string "Требуется авторизация"
+// @Failure 403 {object} map[string]string "Чужой профиль"
+// @Failure 404 {object} map[string]string "Профиль не найден"
+// @Failure 429 {object} map[string]string "Превышен лимит запросов"
+// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
+// @Router /profiles/{user_id}/digest [get]
+func (h DigestHandler) GetDigestSettings(c *gin.Context) {
+	userID := c.Params.ByName("user_id")
+	if !requireOwner(c, userID) {
+		return
+	}
+	settings, err := h.store.Get(c.Request.Context(), userID)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		respondError(c, http.StatusNotFound, "Профиль не найден")
+	case err != nil:
+		respondDBError(c, err, "Ошибка при получении расписания дайджеста")
+	default:
+		c.JSON(http.StatusOK, settings)
+	}
+}
+
+// UpdateDigestSettings изменяет расписание дайджеста пользователя
+// @Summary Изменить расписание дайджеста
+// @Description Меняет переданные поля расписания. Дайджест собирает непрочитанные уведомления с предыдущего дайджеста, сгруппированные по истории и типу, и приходит в час send_hour (0–23) по часовому поясу IANA time_zone; еженедельный — в день weekday (0 — воскресенье). Если новых уведомлений нет, дайджест не приходит. Доступно только владельцу профиля.
+// @Tags profiles
+// @Accept json
+// @Produce json
+// @Security bearerAuth
+// @Param user_id path string true "Идентификатор пользователя"
+// @Param request body models.DigestSettingsPatch true "Изменяемые поля расписания"
+// @Success 200 {object} models.DigestSettings "Сохранённое расписание"
+// @Failure 400 {object} map[string]string "Неверная частота, канал, час, день недели или часовой пояс"
+// @Failure 401 {object} map[string]string "Требуется авторизация"
+// @Failure 403 {object} map[string]string "Чужой профиль или профиль заблокирован модератором"
+// @Failure 404 {object} map[string]string "Профиль не найден"
+// @Failure 429 {object} map[string]string "Превышен лимит запросов"
+// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
+// @Router /profiles/{user_id}/digest [patch]
+func (h DigestHandler) UpdateDigestSettings(c *gin.Context) {
+	userID := c.Params.ByName("user_id")
+	if !requireOwner(c, userID) {
+		return
+	}
+	var patch models.DigestSettingsPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		respondError(c, http.StatusBadRequest, "Ошибка в формате JSON: проверьте правильность данных")
+		return
+	}
+	settings, err := h.store.Update(c.Request.Context(), audit.ActorFrom(c), userID, patch)
+	switch {
+	case errors.Is(err, digest.ErrInvalidSettings):
+		respondError(c, http.StatusBadRequest, "Ошибка: "+err.Error())
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		respondError(c, http.StatusNotFound, "Профиль не найден")
+	case err != nil:
+		respondDBError(c, err, "Ошибка при сохранении расписания дайджеста")
+	default:
+		c.JSON(http.StatusOK, settings)
+	}
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/monst/story-craft/services/user-profile-service/audit"
+	"github.com/monst/story-craft/services/user-profile-service/config"
+	"github.com/monst/story-craft/services/user-profile-service/mail"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"github.com/monst/story-craft/services/user-profile-service/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type EmailHandler struct {
+	queue        *mail.Queue
+	cfg          config.EmailConfig
+	recentWrites *utils.RecentWrites
+}
+
+func NewEmailHandler(queue *mail.Queue, cfg config.EmailConfig, recentWrites *utils.RecentWrites) *EmailHandler {
+	return &EmailHandler{queue: queue, cfg: cfg, recentWrites: recentWrites}
+}
+
+// Unsubscribe отписывает пользователя по ссылке из письма
+// @Summary Отписаться от писем
+// @Description Отписка по подписанной ссылке из письма без входа в приложение. Со scope=all перестают приходить все письма, кроме служебных, иначе в настройках уведомлений выключается почта для событий этого письма. Этот же адрес указан в заголовке List-Unsubscribe для отписки в один клик (RFC 8058). Повторная отписка ничего не меняет.
+// @Tags email
+// @Produce json
+// @Param token query string true "Токен из ссылки отписки"
+// @Success 200 {object} map[string]string "scope — от каких писем отписан пользователь"
+// @Failure 400 {object} map[string]string "Неверная ссылка отписки"
+// @Failure 404 {object} map[string]string "Профиль не найден"
+// @Failure 429 {object} map[string]string "Превышен лимит запросов"
+// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
+// @Router /email/unsubscribe [post]
+func (h EmailHandler) Unsubscribe(c *gin.Context) {
+	userID, scope, err := mail.ParseUnsubscribeToken(h.cfg.SigningKey, c.Query("token"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Ошибка: неверная ссылка отписки")
+		return
+	}
+	// Ссылку получил только владелец адреса, поэтому отписка записывается от его имени
+	actor := audit.ActorFrom(c)
+	actor.ID = userID
+	if err := h.queue.Unsubscribe(c.Request.Context(), actor, userID, scope); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			respondError(c, http.StatusNotFound, "Профиль не найден")
+		} else {
+			respondDBError(c, err, "Ошибка при отписке от писем")
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"scope": scope})
+}
+
+// RecordBounce сохраняет отказ доставки письма
+// @Summary Сообщить об отказе доставки (внутренний API)
+// @Description Вызывается обработчиком уведомлений почтового провайдера. После жёсткого отказа или нескольких временных письма на адрес больше не отправляются, пока пользователь не сменит email; жалоба на спам отписывает от всех писем, кроме служебных. Вызывается с заголовком X-Internal-Token, через API-шлюз не публикуется.
+// @Tags internal
+// @Accept json
+// @Produce json
+// @Param X-Internal-Token header string true "Общий секрет внутреннего API"
+// @Param request body models.EmailBounce true "Адрес и вид отказа: hard, soft или complaint"
+// @Success 200 {object} map[string]string "status: recorded"
+// @Failure 400 {object} map[string]string "Ошибка в запросе"
+// @Failure 401 {object} map[string]string "Неверный внутренний токен"
+// @Failure 404 {object} map[string]string "Профиль с таким адресом не найден"
+// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
+// @Failure 503 {object} map[string]string "Внутренний API не настроен"
+// @Router /internal/email/bounces [post]
+func (h EmailHandler) RecordBounce(c *gin.Context) {
+	var input models.EmailBounce
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, "Ошибка в формате JSON: проверьте правильность данных")
+		return
+	}
+	found, err := h.queue.RecordBounce(c.Request.Context(), audit.ActorFrom(c), input)
+	switch {
+	case errors.Is(err, mail.ErrUnknownBounce):
+		respondError(c, http.StatusBadRequest, "Ошибка: "+err.Error())
+	case err != nil:
+		respondDBError(c, err, "Ошибка при сохранении отказа доставки")
+	case !found:
+		respondError(c, http.StatusNotFound, "Профиль с таким адресом не найден")
+	default:
+		c.JSON(http.StatusOK, gin.H{"status": "recorded"})
+	}
+}
+
+// RequestVerification отправляет ссылку подтверждения текущего адреса
+// @Summary Подтвердить текущий email
+// @Description Отправляет на текущий адрес профиля письмо со ссылкой подтверждения. Новая ссылка заменяет отправленные раньше. Доступно только владельцу профиля.
+// @Tags profiles
+// @Produce json
+// @Security bearerAuth
+// @Param user_id path string true "Идентификатор пользователя"
+// @Success 202 {object} map[string]string "status: sent"
+// @Failure 401 {object} map[string]string "Требуется авторизация"
+// @Failure 403 {object} map[string]string "Чужой профиль или профиль заблокирован модератором"
+// @Failure 404 {object} map[string]string "Профиль не найден"
+// @Failure 409 {object} map[string]string "Адрес уже подтверждён"
+// @Failure 429 {object} map[string]string "Превышен лимит запросов"
+// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
+// @Router /profiles/{user_id}/email/verify [post]
+func (h EmailHandler) RequestVerification(c *gin.Context) {
+	userID := c.Params.ByName("user_id")
+	if !requireOwner(c, userID) {
+		return
+	}
+	err := h.queue.RequestVerification(c.Request.Context(), userID)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		respondError(c, http.StatusNotFound, "Профиль не найден")
+	case errors.Is(err, mail.ErrAlreadyVerified):
+		respondError(c, http.StatusConflict, "Адрес уже подтверждён")
+	case errors.Is(err, mail.ErrEmailTaken):
+		respondError(c, http.StatusConflict, "Адрес уже используется другим профилем")
+	case err != nil:
+		respondDBError(c, err, "Ошибка при отправке ссылки подтверждения")
+	default:
+		h.recentWrites.Mark(userID)
+		c.JSON(http.StatusAccepted, gin.H{"status": "sent"})
+	}
+}
+
+// ConfirmEmail подтверждает адрес по ссылке из письма
+// @Summary Подтвердить email по ссылке
+// @Description Применяет смену адреса или подтверждает текущий адрес по ссылке из письма. После смены на прежний адрес приходит письмо со ссылкой отмены. Вход в приложение не нужен: ссылка подписана и действует EMAIL_CHANGE_TTL.
+// @Tags email
+// @Produce json
+// @Param token query string true "Токен из ссылки подтверждения"
+// @Success 200 {object} models.Profile "Профиль с подтверждённым адресом"
+// @Failure 400 {object} map[string]string "Неверная ссылка"
+// @Failure 409 {object} map[string]string "Ссылка уже использована, заменена новой или адрес занят"
+// @Failure 410 {object} map[string]string "Срок ссылки истёк"
+// @Failure 429 {object} map[string]string "Превышен лимит запросов"
+// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
+// @Router /email/confirm [post]
+func (h EmailHandler) ConfirmEmail(c *gin.Context) {
+	profile, err := h.queue.ConfirmChange(c.Request.Context(), audit.ActorFrom(c), c.Query("token"))
+	h.respondChange(c, profile, err, "Ошибка при подтверждении адреса")
+}
+
+// RevertEmail возвращает прежний адрес по ссылке из письма
+// @Summary Отменить смену email
+// @Description Возвращает адрес, который был до смены, по ссылке из письма на него. Ссылка действует EMAIL_REVERT_TTL и только пока адрес в профиле не меняли снова.
+// @Tags email
+// @Produce json
+// @Param token query string true "Токен из ссылки отмены"
+// @Success 200 {object} models.Profile "Профиль с прежним адресом"
+// @Failure 400 {object} map[string]string "Неверная ссылка"
+// @Failure 409 {object} map[string]string "Ссылка уже использована или прежний адрес занят"
+// @Failure 410 {object} map[string]string "Срок ссылки истёк"
+// @Failure 429 {object} map[string]string "Превышен лимит запросов"
+// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
+// @Router /email/revert [post]
+func (h EmailHandler) RevertEmail(c *gin.Context) {
+	profile, err := h.queue.RevertChange(c.Request.Context(), audit.ActorFrom(c), c.Query("token"))
+	h.respondChange(c, profile, err, "Ошибка при возврате прежнего адреса")
+}
+
+func (h EmailHandler) respondChange(c *gin.Context, profile models.Profile, err error, message string) {
+	switch {
+	case errors.Is(err, mail.ErrInvalidToken):
+		respondError(c, http.StatusBadRequest, "Ошибка: неверная ссылка")
+	case errors.Is(err, mail.ErrTokenExpired):
+		respondError(c, http.StatusGone, "Срок ссылки истёк, запросите новую")
+	case errors.Is(err, mail.ErrChangeUsed):
+		respondError(c, http.StatusConflict, "Ссылка уже использована или больше не действует")
+	case errors.Is(err, mail.ErrEmailTaken):
+		respondError(c, http.StatusConflict, "Адрес уже используется другим профилем")
+	case err != nil:
+		respondDBError(c, err, message)
+	default:
+		h.recentWrites.Mark(profile.UserID)
+		c.JSON(http.StatusOK, profile)
+	}
+}
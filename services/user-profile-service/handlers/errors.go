@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/monst/story-craft/services/user-profile-service/logging"
+	"github.com/monst/story-craft/services/user-profile-service/middleware"
+)
+
+// respondError отвечает ошибкой в общем формате {"error": ..., "trace_id": ...}.
+// По trace_id запрос можно найти в трейсах и логах.
+func respondError(c *gin.Context, status int, message string) {
+	respondErrorWith(c, status, message, nil)
+}
+
+// respondErrorWith дополняет ответ с ошибкой полями extra, например списком нарушений.
+func respondErrorWith(c *gin.Context, status int, message string, extra gin.H) {
+	c.JSON(status, middleware.ErrorBody(c, message, extra))
+}
+
+// respondDBError отвечает 504, если работа с БД не уложилась в дедлайн запроса, и 500 в остальных случаях.
+// Исходная ошибка пишется в лог запроса, клиенту она не показывается.
+func respondDBError(c *gin.Context, err error, message string) {
+	logging.FromContext(c.Request.Context()).ErrorContext(c.Request.Context(), message, slog.Any("error", err))
+	if errors.Is(err, context.DeadlineExceeded) {
+		respondError(c, http.StatusGatewayTimeout, "Превышено время ожидания ответа базы данных")
+		return
+	}
+	respondError(c, http.StatusInternalServerError, message)
+}
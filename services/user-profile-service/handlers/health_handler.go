@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/monst/story-craft/services/user-profile-service/health"
+)
+
+type HealthHandler struct {
+	checker *health.Checker
+}
+
+func NewHealthHandler(checker *health.Checker) *HealthHandler {
+	return &HealthHandler{checker: checker}
+}
+
+// Live сообщает, что процесс жив и обрабатывает запросы
+// @Summary Проверка живости
+// @Description Не обращается к зависимостям, отвечает 200, пока процесс обрабатывает запросы
+// @Tags health
+// @Produce json
+// @Success 200 {object} map[string]string "Сервис жив"
+// @Router /health/live [get]
+func (h HealthHandler) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": health.StatusOK})
+}
+
+// Ready проверяет готовность сервиса принимать трафик
+// @Summary Проверка готовности
+// @Description Проверяет доступность PostgreSQL, версию схемы и состояние фоновых задач. Результат кэшируется на несколько секунд
+// @Tags health
+// @Produce json
+// @Success 200 {object} health.Report "Все зависимости доступны"
+// @Failure 503 {object} health.Report "Хотя бы одна зависимость недоступна"
+// @Router /health/ready [get]
+func (h HealthHandler) Ready(c *gin.Context) {
+	report := h.checker.Check(c.Request.Context())
+	status := http.StatusOK
+	if report.Status != health.StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
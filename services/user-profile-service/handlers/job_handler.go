@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/monst/story-craft/services/user-profile-service/audit"
+	"github.com/monst/story-craft/services/user-profile-service/jobs"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+)
+
+type JobHandler struct {
+	queue *jobs.Queue
+}
+
+func NewJobHandler(queue *jobs.Queue) *JobHandler {
+	return &JobHandler{queue: queue}
+}
+
+// ListJobs возвращает фоновые задачи
+// @Summary Список фоновых задач
+// @Description Задачи очереди от новых к старым. Следующая страница запрашивается с before_id, равным id последней задачи. Завершённые задачи хранятся JOBS_RETENTION.
+// @Tags admin
+// @Produce json
+// @Security bearerAuth
+// @Param status query string false "Состояние: pending, running, done или failed"
+// @Param kind query string false "Вид задачи, например digest.send"
+// @Param before_id query int false "Вернуть задачи с id меньше указанного"
+// @Param limit query int false "Размер страницы (по умолчанию 50, не больше 100)"
+// @Success 200 {array} models.Job "Задачи"
+// @Failure 400 {object} map[string]string "Ошибка в запросе"
+// @Failure 403 {object} map[string]string "Недостаточно прав"
+// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
+// @Router /admin/jobs [get]
+func (h JobHandler) ListJobs(c *gin.Context) {
+	filter := jobs.Filter{Status: c.Query("status"), Kind: c.Query("kind")}
+	var err error
+	if filter.Limit, err = strconv.Atoi(c.DefaultQuery("limit", "50")); err != nil || filter.Limit < 1 || filter.Limit > maxBatchSize {
+		respondError(c, http.StatusBadRequest, "Ошибка: limit должен быть от 1 до "+strconv.Itoa(maxBatchSize))
+		return
+	}
+	if filter.Status != "" && !slices.Contains(models.JobStatuses, filter.Status) {
+		respondError(c, http.StatusBadRequest, "Ошибка: status должен быть одним из "+strings.Join(models.JobStatuses, ", "))
+		return
+	}
+	if v := c.Query("before_id"); v != "" {
+		if filter.BeforeID, err = strconv.ParseInt(v, 10, 64); err != nil || filter.BeforeID <= 0 {
+			respondError(c, http.StatusBadRequest, "Ошибка: before_id должен быть положительным числом")
+			return
+		}
+	}
+
+	list, err := h.queue.List(c.Request.Context(), filter)
+	if err != nil {
+		respondDBError(c, err, "Ошибка при чтении очереди задач")
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// GetJob возвращает фоновую задачу
+// @Summary Получить фоновую задачу
+// @Description Задача с аргументами, числом попыток и последней ошибкой.
+// @Tags admin
+// @Produce json
+// @Security bearerAuth
+// @Param id path int true "Идентификатор задачи"
+// @Success 200 {object} models.Job "Задача"
+// @Failure 400 {object} map[string]string "Неверный идентификатор"
+// @Failure 403 {object} map[string]string "Недостаточно прав"
+// @Failure 404 {object} map[string]string "Задача не найдена"
+// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
+// @Router /admin/jobs/{id} [get]
+func (h JobHandler) GetJob(c *gin.Context) {
+	id, ok := jobID(c)
+	if !ok {
+		return
+	}
+	job, err := h.queue.Get(c.Request.Context(), id)
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		respondError(c, http.StatusNotFound, "Задача не найдена")
+	case err != nil:
+		respondDBError(c, err, "Ошибка при чтении задачи")
+	default:
+		c.JSON(http.StatusOK, job)
+	}
+}
+
+// RetryJob возвращает задачу с ошибкой в очередь
+// @Summary Повторить фоновую задачу
+// @Description Возвращает задачу со статусом failed в очередь с новым набором попыток. Действие записывается в журнал.
+// @Tags admin
+// @Produce json
+// @Security bearerAuth
+// @Param id path int true "Идентификатор задачи"
+// @Success 200 {object} models.Job "Задача в очереди"
+// @Failure 400 {object} map[string]string "Неверный идентификатор"
+// @Failure 403 {object} map[string]string "Недостаточно прав"
+// @Failure 404 {object} map[string]string "Задача не найдена"
+// @Failure 409 {object} map[string]string "Задача не завершилась ошибкой или такая же уже в очереди"
+// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
+// @Router /admin/jobs/{id}/retry [post]
+func (h JobHandler) RetryJob(c *gin.Context) {
+	id, ok := jobID(c)
+	if !ok {
+		return
+	}
+	job, err := h.queue.Retry(c.Request.Context(), audit.ActorFrom(c), id)
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		respondError(c, http.StatusNotFound, "Задача не найдена")
+	case errors.Is(err, jobs.ErrNotFailed), errors.Is(err, jobs.ErrDuplicate):
+		respondError(c, http.StatusConflict, "Ошибка: "+err.Error())
+	case err != nil:
+		respondDBError(c, err, "Ошибка при повторе задачи")
+	default:
+		c.JSON(http.StatusOK, job)
+	}
+}
+
+func jobID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Params.ByName("id"), 10, 64)
+	if err != nil || id <= 0 {
+		respondError(c, http.StatusBadRequest, "Ошибка: неверный идентификатор задачи")
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monst/story-craft/services/user-profile-service/config"
+	"github.com/monst/story-craft/services/user-profile-service/metrics"
+	"github.com/monst/story-craft/services/user-profile-service/middleware"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"github.com/monst/story-craft/services/user-profile-service/notifications"
+
+	"github.com/gin-gonic/gin"
+)
+
+type NotificationHandler struct {
+	store   *notifications.Store
+	cfg     config.NotificationsConfig
+	metrics *metrics.Metrics
+}
+
+func NewNotificationHandler(store *notifications.Store, cfg config.NotificationsConfig, m *metrics.Metrics) *NotificationHandler {
+	return &NotificationHandler{store: store, cfg: cfg, metrics: m}
+}
+
+// recipient возвращает пользователя запроса или отвечает 401.
+func recipient(c *gin.Context) (string, bool) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "Требуется авторизация")
+		return "", false
+	}
+	return principal.UserID, true
+}
+
+// ListNotifications возвращает входящие уведомления пользователя
+// @Summary Входящие уведомления
+// @Description Уведомления текущего пользователя от новых к старым. Следующая страница запрашивается с cursor из next_cursor; unread_count — общее число непрочитанных.
+// @Tags notifications
+// @Produce json
+// @Security bearerAuth
+// @Param cursor query string false "Курсор из next_cursor предыдущей страницы"
+// @Param limit query int false "Размер страницы (по умолчанию 20, не больше 100)"
+// @Param unread query bool false "Только непрочитанные"
+// @Success 200 {object} models.NotificationPage "Страница уведомлений"
+// @Failure 400 {object} map[string]string "Неверный курсор или limit"
+// @Failure 401 {object} map[string]string "Требуется авторизация"
+// @Failure 429 {object} map[string]string "Превышен лимит запросов"
+// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
+// @Router /notifications [get]
+func (h NotificationHandler) ListNotifications(c *gin.Context) {
+	userID, ok := recipient(c)
+	if !ok {
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > maxBatchSize {
+		respondError(c, http.StatusBadRequest, "Ошибка: limit должен быть от 1 до "+strconv.Itoa(maxBatchSize))
+		return
+	}
+	opts := notifications.ListOptions{Limit: limit, UnreadOnly: c.Query("unread") == "true"}
+	if cursor := c.Query("cursor"); cursor != "" {
+		if opts.Before, err = notifications.DecodeCursor(cursor); err != nil {
+			respondError(c, http.StatusBadRequest, "Ошибка: неверный курсор")
+			return
+		}
+	}
+
+	// Запрашивается на одну запись больше, чтобы узнать, есть ли следующая страница
+	opts.Limit++
+	items, err := h.store.List(c.Request.Context(), userID, opts)
+	if err != nil {
+		respondDBError(c, err, "Ошибка при получении уведомлений")
+		return
+	}
+	page := models.NotificationPage{Items: items}
+	if len(items) > limit {
+		page.Items = items[:limit]
+		page.NextCursor = notifications.EncodeCursor(page.Items[limit-1].ID)
+	}
+	if page.UnreadCount, err = h.store.UnreadCount(c.Request.Context(), userID); err != nil {
+		respondDBError(c, err, "Ошибка при подсчёте непрочитанных уведомлений")
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// UnreadCount возвращает число непрочитанных уведомлений
+// @Summary Число непрочитанных уведомлений
+// @Description Для счётчика на иконке уведомлений без загрузки списка
+// @Tags notifications
+// @Produce json
+// @Security bearerAuth
+// @Success 200 {object} map[string]int64 "unread_count"
+// @Failure 401 {object} map[string]string "Требуется авторизация"
+// @Failure 429 {object} map[string]string "Превышен лимит запросов"
+// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
+// @Router /notifications/unread-count [get]
+func (h NotificationHandler) UnreadCount(c *gin.Context) {
+	userID, ok := recipient(c)
+	if !ok {
+		return
+	}
+	count, err := h.store.UnreadCount(c.Request.Context(), userID)
+	if err != nil {
+		respondDBError(c, err, "Ошибка при подсчёте непрочитанных уведомлений")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"unread_count": count})
+}
+
+// MarkRead отмечает уведомление прочитанным
+// @Summary Отметить уведомление прочитанным
+// @Description Повторная отметка не меняет время прочтения
+// @Tags notifications
+// @Produce json
+// @Security bearerAuth
+// @Param id path int true "Идентификатор уведомления"
+// @Success 200 {object} models.Notification "Уведомление"
+// @Failure 400 {object} map[string]string "Неверный идентификатор"
+// @Failure 401 {object} map[string]string "Требуется авторизация"
+// @Failure 403 {object} map[string]string "Профиль заблокирован модератором"
+// @Failure 404 {object} map[string]string "Уведомление не найдено"
+// @Failure 429 {object} map[string]string "Превышен лимит запросов"
+// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
+// @Router /notifications/{id}/read [post]
+func (h NotificationHandler) MarkRead(c *gin.Context) {
+	userID, ok := recipient(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		respondError(c, http.StatusBadRequest, "Ошибка: неверный идентификатор уведомления")
+		return
+	}
+	note, err := h.store.MarkRead(c.Request.Context(), userID, id)
+	if err != nil {
+		if errors.Is(err, notifications.ErrNotFound) {
+			respondError(c, http.StatusNotFound, "Уведомление не найдено")
+		} else {
+			respondDBError(c, err, "Ошибка при отметке уведомления")
+		}
+		return
+	}
+	c.JSON(http.StatusOK, note)
+}
+
+// MarkAllRead отмечает прочитанными все уведомления
+// @Summary Отметить все уведомления прочитанными
+// @Description Отмечает непрочитанные уведомления пользователя. С up_to отмечаются только уведомления с id не больше указанного, чтобы не задеть пришедшие после загрузки списка.
+// @Tags notifications
+// @Produce json
+// @Security bearerAuth
+// @Param up_to query int false "Наибольший отмечаемый идентификатор"
+// @Success 200 {object} map[string]int64 "updated — сколько уведомлений отмечено"
+// @Failure 400 {object} map[string]string "Неверный up_to"
+// @Failure 401 {object} map[string]string "Требуется авторизация"
+// @Failure 403 {object} map[string]string "Профиль заблокирован модератором"
+// @Failure 429 {object} map[string]string "Превышен лимит запросов"
+// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
+// @Router /notifications/read-all [post]
+func (h NotificationHandler) MarkAllRead(c *gin.Context) {
+	userID, ok := recipient(c)
+	if !ok {
+		return
+	}
+	var upTo int64
+	if raw := c.Query("up_to"); raw != "" {
+		var err error
+		if upTo, err = strconv.ParseInt(raw, 10, 64); err != nil || upTo <= 0 {
+			respondError(c, http.StatusBadRequest, "Ошибка: up_to должен быть положительным числом")
+			return
+		}
+	}
+	updated, err := h.store.MarkAllRead(c.Request.Context(), userID, upTo)
+	if err != nil {
+		respondDBError(c, err, "Ошибка при отметке уведомлений")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"updated": updated})
+}
+
+// IngestNotifications создаёт уведомления от других сервисов
+// @Summary Создать уведомления (внутренний API)
+// @Description Создаёт уведомление для каждого получателя, у которого включены уведомления этого типа в приложении. Повтор с тем же dedup_key для получателя не создаёт дубликат. Вызывается другими сервисами с заголовком X-Internal-Token, через API-шлюз не публикуется.
+// @Tags internal
+// @Accept json
+// @Produce json
+// @Param X-Internal-Token header string true "Общий секрет внутреннего API"
+// @Param request body models.IngestNotification true "Уведомление и получатели"
+// @Success 200 {object} map[string]interface{} "created — созданные уведомления, results — результат по получателям: created, duplicate или muted"
+// @Failure 400 {object} map[string]string "Ошибка в запросе"
+// @Failure 401 {object} map[string]string "Неверный внутренний токен"
+// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
+// @Failure 503 {object} map[string]string "Внутренний API не настроен"
+// @Router /internal/notifications [post]
+func (h NotificationHandler) IngestNotifications(c *gin.Context) {
+	var input models.IngestNotification
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, "Ошибка в формате JSON: проверьте правильность данных")
+		return
+	}
+	if !slices.Contains(models.NotificationEvents, input.Type) {
+		respondError(c, http.StatusBadRequest, "Ошибка: неизвестный тип уведомления "+strconv.Quote(input.Type))
+		return
+	}
+	if len(input.RecipientIDs) == 0 || len(input.RecipientIDs) > h.cfg.MaxRecipients {
+		respondError(c, http.StatusBadRequest, "Ошибка: получателей должно быть от 1 до "+strconv.Itoa(h.cfg.MaxRecipients))
+		return
+	}
+	for _, id := range input.RecipientIDs {
+		if _, err := uuid.Parse(id); err != nil {
+			respondError(c, http.StatusBadRequest, "Ошибка: неверный идентификатор получателя "+strconv.Quote(id))
+			return
+		}
+	}
+	ttl := h.cfg.TTL
+	if input.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(input.TTL); err != nil || ttl <= 0 || ttl > h.cfg.MaxTTL {
+			respondError(c, http.StatusBadRequest, "Ошибка: ttl должен быть положительной длительностью не больше "+h.cfg.MaxTTL.String())
+			return
+		}
+	}
+
+	note := models.Notification{Type: input.Type, Payload: input.Payload}
+	if input.DedupKey != "" {
+		note.DedupKey = &input.DedupKey
+	}
+	created, results, err := h.store.Ingest(c.Request.Context(), note, input.RecipientIDs, ttl)
+	if err != nil {
+		respondDBError(c, err, "Ошибка при создании уведомлений")
+		return
+	}
+	for _, result := range results {
+		h.metrics.Notification(input.Type, result)
+	}
+	if created == nil {
+		created = []models.Notification{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"created": created, "results": results})
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/monst/story-craft/services/user-profile-service/audit"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GetNotificationPreferences возвращает настройки уведомлений пользователя
+// @Summary Получить настройки уведомлений
+// @Description Возвращает, по каким каналам (in_app, email, web_push) приходят уведомления о событиях, и тихие часы. Расписание дайджеста задаётся отдельно: /profiles/{user_id}/digest. Если пользователь ничего не менял, возвращаются настройки по умолчанию. Доступно только владельцу профиля.
+// @Tags profiles
+// @Produce json
+// @Security bearerAuth
+// @Param user_id path string true "Идентификатор пользователя"
+// @Success 200 {object} models.NotificationPreferences "Настройки уведомлений"
+// @Failure 401 {object} map[string]string "Требуется авторизация"
+// @Failure 403 {object} map[string]string "Чужой профиль"
+// @Failure 404 {object} map[string]string "Профиль не найден"
+// @Failure 429 {object} map[string]string "Превышен лимит запросов"
+// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
+// @Router /profiles/{user_id}/notification-preferences [get]
+func (h ProfileHandler) GetNotificationPreferences(c *gin.Context) {
+	userID := c.Params.ByName("user_id")
+	if !requireOwner(c, userID) {
+		return
+	}
+
+	var profile models.Profile
+	err := h.readDB(c, userID).Select("user_id", "notification_preferences").
+		Where("user_id = ?", userID).First(&profile).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, "Профиль не найден")
+		} else {
+			respondDBError(c, err, "Ошибка при получении настроек уведомлений")
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, profile.NotificationPreferences)
+}
+
+// UpdateNotificationPreferences заменяет настройки уведомлений пользователя
+// @Summary Изменить настройки уведомлений
+// @Description Заменяет настройки уведомлений целиком. События, которых нет в запросе, получают значения по умолчанию. Время тихих часов задаётся как HH:MM в часовом поясе IANA (например, Europe/Moscow), интервал может переходить через полночь. Доступно только владельцу профиля.
+// @Tags profiles
+// @Accept json
+// @Produce json
+// @Security bearerAuth
+// @Param user_id path string true "Идентификатор пользователя"
+// @Param request body models.NotificationPreferences true "Настройки уведомлений"
+// @Success 200 {object} models.NotificationPreferences "Сохранённые настройки"
+// @Failure 400 {object} map[string]string "Неизвестное событие, неверное время или часовой пояс"
+// @Failure 401 {object} map[string]string "Требуется авторизация"
+// @Failure 403 {object} map[string]string "Чужой профиль или профиль заблокирован модератором"
+// @Failure 404 {object} map[string]string "Профиль не найден"
+// @Failure 429 {object} map[string]string "Превышен лимит запросов"
+// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
+// @Router /profiles/{user_id}/notification-preferences [put]
+func (h ProfileHandler) UpdateNotificationPreferences(c *gin.Context) {
+	userID := c.Params.ByName("user_id")
+	if !requireOwner(c, userID) {
+		return
+	}
+
+	var input models.NotificationPreferences
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, "Ошибка в формате JSON: проверьте правильность данных")
+		return
+	}
+	// Версию задаёт сервис: клиент присылает настройки в текущем формате
+	prefs := input.Normalize()
+	if err := prefs.Validate(); err != nil {
+		respondError(c, http.StatusBadRequest, "Неверные настройки уведомлений: "+err.Error())
+		return
+	}
+
+	err := h.writeDB(c).Transaction(func(tx *gorm.DB) error {
+		var profile models.Profile
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ?", userID).First(&profile).Error; err != nil {
+			return err
+		}
+		diff := audit.DiffOf(profile.NotificationPreferences, prefs)
+		// Включая письма в настройках, пользователь отменяет отписку от всех писем
+		resubscribe := profile.EmailUnsubscribedAt != nil && prefs.EmailEnabled()
+		if resubscribe {
+			diff["email_unsubscribed_at"] = audit.Change{From: profile.EmailUnsubscribedAt.UTC()}
+		}
+		if len(diff) == 0 {
+			return nil
+		}
+		updates := map[string]any{"notification_preferences": prefs}
+		if resubscribe {
+			updates["email_unsubscribed_at"] = nil
+		}
+		if err := tx.Model(&profile).UpdateColumns(updates).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Entry{
+			Actor:        audit.ActorFrom(c),
+			Action:       audit.ActionPreferencesUpdated,
+			TargetUserID: userID,
+			Diff:         diff,
+		})
+	})
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, "Профиль не найден")
+		} else {
+			respondDBError(c, err, "Ошибка при сохранении настроек уведомлений")
+		}
+		return
+	}
+	h.recentWrites.Mark(userID)
+
+	c.JSON(http.StatusOK, prefs)
+}
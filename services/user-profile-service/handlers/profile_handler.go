@@ -1,87 +1,148 @@
 package handlers
 
 import (
-	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/monst/story-craft/services/user-profile-service/audit"
+	"github.com/monst/story-craft/services/user-profile-service/bio"
+	"github.com/monst/story-craft/services/user-profile-service/config"
+	"github.com/monst/story-craft/services/user-profile-service/genres"
+	"github.com/monst/story-craft/services/user-profile-service/mail"
+	"github.com/monst/story-craft/services/user-profile-service/metrics"
+	"github.com/monst/story-craft/services/user-profile-service/middleware"
 	"github.com/monst/story-craft/services/user-profile-service/models"
+	"github.com/monst/story-craft/services/user-profile-service/realtime"
+	"github.com/monst/story-craft/services/user-profile-service/screening"
+	"github.com/monst/story-craft/services/user-profile-service/utils"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
 )
 
+// maxBatchSize ограничивает число профилей в одном пакетном запросе и в выдаче поиска.
+const maxBatchSize = 100
+
 type ProfileHandler struct {
-	db *gorm.DB
+	db           *gorm.DB
+	recentWrites *utils.RecentWrites
+	metrics      *metrics.Metrics
+	screener     *screening.Screener
+	// email — очередь писем; nil, если отправка писем выключена.
+	email *mail.Queue
+	// limits — ограничения расширенных полей профиля.
+	limits config.ProfileConfig
+	genres *genres.Catalog
 }
 
-func NewProfileHandler(db *gorm.DB) *ProfileHandler {
-	return &ProfileHandler{db: db}
+func NewProfileHandler(db *gorm.DB, recentWrites *utils.RecentWrites, m *metrics.Metrics, screener *screening.Screener, email *mail.Queue,
+	limits config.ProfileConfig, catalog *genres.Catalog) *ProfileHandler {
+	return &ProfileHandler{db: db, recentWrites: recentWrites, metrics: m, screener: screener, email: email, limits: limits, genres: catalog}
+}
+
+// readDB возвращает сессию для чтения профилей userIDs. Обычно это реплика,
+// но недавно изменённые профили читаются из основной БД.
+func (h ProfileHandler) readDB(c *gin.Context, userIDs ...string) *gorm.DB {
+	db := h.db.WithContext(c.Request.Context())
+	if h.recentWrites.Active(userIDs...) {
+		return db.Clauses(dbresolver.Write).Session(&gorm.Session{})
+	}
+	return db
+}
+
+// writeDB возвращает сессию основной БД для изменения данных.
+func (h ProfileHandler) writeDB(c *gin.Context) *gorm.DB {
+	return h.db.WithContext(c.Request.Context()).Clauses(dbresolver.Write).Session(&gorm.Session{})
 }
 
 // CreateProfile создает новый профиль пользователя
 // @Summary Создать профиль пользователя
-// @Description Создает новый профиль пользователя на основе полученных данных
+// @Description Создает новый профиль пользователя на основе полученных данных. Имя проверяется на мат, ссылки и спам: в зависимости от настроек оно отклоняется или задерживается до проверки модератором (held_fields).
 // @Tags profiles
 // @Accept json
 // @Produce json
 // @Param request body models.InputProfile true "Данные профиля"
+// @Param Idempotency-Key header string false "Ключ идемпотентности: повтор с тем же ключом вернёт исходный ответ"
 // @Security bearerAuth
 // @Success 201 {object} models.InputProfile "Созданный профиль"
 // @Failure 400 {object} map[string]string "Ошибка в запросе"
-// @Failure 409 {object} map[string]string "Конфликт при создании профиля"
+// @Failure 403 {object} map[string]string "Попытка назначить роль ADMIN"
+// @Failure 409 {object} map[string]string "Конфликт при создании профиля или запрос с тем же ключом ещё выполняется"
+// @Failure 422 {object} map[string]interface{} "Имя не прошло проверку текста (violations) или Idempotency-Key уже использован с другим запросом"
+// @Failure 429 {object} map[string]string "Превышен лимит запросов"
 // @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
 // @Router /profiles [post]
 func (h ProfileHandler) CreateProfile(c *gin.Context) {
 	var input models.InputProfile
 
-	reqData := c.Request.Header.Get("x-user-object")
-
-	var data map[string]interface{}
-	err := json.Unmarshal([]byte(reqData), &data)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Ошибка в формате JSON: проверьте правильность данных"})
-	}
-
-	userID, ok := data["userId"].(string)
+	principal, ok := middleware.CurrentPrincipal(c)
 	if !ok {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Ошибка: не удалось извлечь userId из заголовка"})
+		respondError(c, http.StatusBadRequest, "Ошибка: не удалось извлечь userId из заголовка")
 		return
 	}
+	userID := principal.UserID
 	input.UserID = userID
 
 	body := c.Request.Body
 	defer body.Close()
 
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Ошибка в формате JSON: проверьте правильность данных"})
+		respondError(c, http.StatusBadRequest, "Ошибка в формате JSON: проверьте правильность данных")
 		return
 	}
 
 	userUUID, err := uuid.Parse(userID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Ошибка: неверный формат user_id: " + userID})
+		respondError(c, http.StatusBadRequest, "Ошибка: неверный формат user_id: "+userID)
 		return
 	}
 
 	if input.Username == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Ошибка: не передали username"})
+		respondError(c, http.StatusBadRequest, "Ошибка: не передали username")
 		return
 	}
 
 	if input.Email == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Ошибка: не передали email"})
+		respondError(c, http.StatusBadRequest, "Ошибка: не передали email")
+		return
+	}
+
+	role := models.RoleUser
+	if input.Role != "" {
+		normalized, ok := models.NormalizeRole(input.Role)
+		if !ok {
+			respondError(c, http.StatusBadRequest, "Ошибка: неизвестная роль "+input.Role)
+			return
+		}
+		if normalized == models.RoleAdmin {
+			respondError(c, http.StatusForbidden, "Роль ADMIN назначается только администратором")
+			return
+		}
+		role = normalized
+	}
+
+	held, flagReason, ok := screenText(c, h.screener, map[string]*string{"username": &input.Username})
+	if !ok {
 		return
 	}
 
 	// Проверка на существование пользователя с таким username
+	db := h.writeDB(c)
+
 	var existingProfile models.Profile
-	if err := h.db.Where("user_id = ?", userUUID).First(&existingProfile).Error; err == nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "Пользователь с таким username или email уже существует"})
+	if err := db.Where("user_id = ?", userUUID).First(&existingProfile).Error; err == nil {
+		h.metrics.ProfileConflict("create")
+		respondError(c, http.StatusConflict, "Пользователь с таким username или email уже существует")
 		return
 	} else if err != gorm.ErrRecordNotFound {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при проверке существующих пользователей"})
+		respondDBError(c, err, "Ошибка при проверке существующих пользователей")
 		return
 	}
 
@@ -90,17 +151,43 @@ func (h ProfileHandler) CreateProfile(c *gin.Context) {
 		Username:  input.Username,
 		Email:     input.Email,
 		AvatarURL: input.AvatarURL,
-		Role:      input.Role,
+		Role:      role,
+	}
+	// Задержанное проверкой имя видно только владельцу, пока модератор не снимет отметку
+	if len(held) > 0 {
+		now := time.Now()
+		profile.HeldFields, profile.FlaggedAt, profile.FlagReason = held, &now, flagReason
 	}
 
-	if err := h.db.Create(&profile).Error; err != nil {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&profile).Error; err != nil {
+			return err
+		}
+		if h.email != nil {
+			// Ключ защищает от второго приветствия, если профиль создадут заново после удаления
+			key := mail.TemplateWelcome
+			if _, err := h.email.Enqueue(tx, userID, mail.TemplateWelcome, nil, &key); err != nil {
+				return err
+			}
+		}
+		return audit.Record(tx, audit.Entry{
+			Actor:        audit.ActorFrom(c),
+			Action:       audit.ActionProfileCreated,
+			TargetUserID: userID,
+			Diff:         audit.DiffOf(models.Profile{}, profile),
+		})
+	})
+	if err != nil {
 		if strings.Contains(err.Error(), "unique constraint") {
-			c.JSON(http.StatusConflict, gin.H{"error": "Пользователь с такими данными уже существует"})
+			h.metrics.ProfileConflict("create")
+			respondError(c, http.StatusConflict, "Пользователь с такими данными уже существует")
 		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось создать профиль пользователя"})
+			respondDBError(c, err, "Не удалось создать профиль пользователя")
 		}
 		return
 	}
+	h.recentWrites.Mark(userID)
+	h.metrics.ProfileEvent(metrics.EventCreated)
 
 	c.JSON(http.StatusCreated, profile)
 }
@@ -116,27 +203,92 @@ func (h ProfileHandler) CreateProfile(c *gin.Context) {
 // @Success 200 {object} models.Profile "Профиль пользователя"
 // @Failure 400 {object} map[string]string "Ошибка в запросе"
 // @Failure 404 {object} map[string]string "Профиль не найден"
+// @Failure 429 {object} map[string]string "Превышен лимит запросов"
 // @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
 // @Router /profiles/{user_id} [get]
 func (h ProfileHandler) GetProfile(c *gin.Context) {
 	userID := c.Params.ByName("user_id")
 
 	var profile models.Profile
-	if err := h.db.Where("user_id = ?", userID).First(&profile).Error; err != nil {
+	if err := h.readDB(c, userID).Where("user_id = ?", userID).First(&profile).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Профиль не найден"})
+			respondError(c, http.StatusNotFound, "Профиль не найден")
 		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении данных профиля"})
+			respondDBError(c, err, "Ошибка при получении данных профиля")
 		}
 		return
 	}
 
-	c.JSON(http.StatusOK, profile)
+	c.JSON(http.StatusOK, profile.VisibleTo(viewerID(c)))
+}
+
+// ListProfiles возвращает профили по списку идентификаторов или ищет их по имени
+// @Summary Найти профили пользователей
+// @Description Пакетная выборка профилей по user_ids (через запятую, не больше 100) или поиск по началу username и отображаемого имени
+// @Tags profiles
+// @Accept json
+// @Produce json
+// @Security bearerAuth
+// @Param user_ids query string false "Идентификаторы пользователей через запятую"
+// @Param q query string false "Начало username или отображаемого имени"
+// @Param limit query int false "Максимум результатов поиска (по умолчанию 20, не больше 100)"
+// @Success 200 {array} models.Profile "Найденные профили"
+// @Failure 400 {object} map[string]string "Ошибка в запросе"
+// @Failure 429 {object} map[string]string "Превышен лимит запросов"
+// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
+// @Router /profiles [get]
+func (h ProfileHandler) ListProfiles(c *gin.Context) {
+	profiles := []models.Profile{}
+
+	if rawIDs := c.Query("user_ids"); rawIDs != "" {
+		userIDs := strings.Split(rawIDs, ",")
+		if len(userIDs) > maxBatchSize {
+			respondError(c, http.StatusBadRequest, "Ошибка: слишком много user_ids, максимум "+strconv.Itoa(maxBatchSize))
+			return
+		}
+		for i, id := range userIDs {
+			userIDs[i] = strings.TrimSpace(id)
+			if _, err := uuid.Parse(userIDs[i]); err != nil {
+				respondError(c, http.StatusBadRequest, "Ошибка: неверный формат user_id: "+userIDs[i])
+				return
+			}
+		}
+
+		if err := h.readDB(c, userIDs...).Where("user_id IN ?", userIDs).Find(&profiles).Error; err != nil {
+			respondDBError(c, err, "Ошибка при получении данных профилей")
+			return
+		}
+		c.JSON(http.StatusOK, visibleTo(profiles, viewerID(c)))
+		return
+	}
+
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		respondError(c, http.StatusBadRequest, "Ошибка: передайте user_ids или q")
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > maxBatchSize {
+		respondError(c, http.StatusBadRequest, "Ошибка: limit должен быть от 1 до "+strconv.Itoa(maxBatchSize))
+		return
+	}
+
+	pattern := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(query) + "%"
+	if err := h.readDB(c).
+		Where("username ILIKE ? OR display_name ILIKE ?", pattern, pattern).
+		Order("username").
+		Limit(limit).
+		Find(&profiles).Error; err != nil {
+		respondDBError(c, err, "Ошибка при поиске профилей")
+		return
+	}
+
+	c.JSON(http.StatusOK, visibleTo(profiles, viewerID(c)))
 }
 
 // UpdateProfile обновляет профиль пользователя
 // @Summary Обновить профиль пользователя
-// @Description Обновляет существующий профиль пользователя
+// @Description Обновляет существующий профиль пользователя. Расширенные поля (links, location, pronouns, languages, favoriteGenres) меняются, только если переданы; fieldVisibility задаёт, кому они видны: public, registered или private. Описание (bio) принимается в разметке CommonMark, безопасный HTML для показа возвращается в bio_html. Изменённые имя, отображаемое имя, описание, местоположение и местоимения проходят проверку текста: фрагменты могут быть замаскированы, поле задержано до проверки модератором (held_fields) или изменение отклонено (422). Новый email вступает в силу после перехода по ссылке из письма на него: до этого он возвращается в pending_email, а в email остаётся прежний адрес; если отправка писем выключена, смена email отклоняется (409). Доступно только владельцу профиля.
 // @Tags profiles
 // @Accept json
 // @Produce json
@@ -144,55 +296,180 @@ func (h ProfileHandler) GetProfile(c *gin.Context) {
 // @Param user_id path string true "Идентификатор пользователя"
 // @Success 200 {object} models.UpdateProfile "Обновленный профиль"
 // @Failure 400 {object} map[string]string "Ошибка в запросе"
+// @Failure 401 {object} map[string]string "Требуется авторизация"
+// @Failure 403 {object} map[string]string "Чужой профиль, профиль заблокирован или попытка сменить роль"
 // @Failure 404 {object} map[string]string "Профиль не найден"
-// @Failure 409 {object} map[string]string "Конфликт при обновлении профиля"
+// @Failure 409 {object} map[string]string "Конфликт при обновлении профиля или смена email при выключенной отправке писем"
+// @Failure 422 {object} map[string]interface{} "Текст не прошёл проверку: в violations поля и сработавшие правила"
+// @Failure 429 {object} map[string]string "Превышен лимит запросов"
 // @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
 // @Router /profiles/{user_id} [patch]
 func (h ProfileHandler) UpdateProfile(c *gin.Context) {
 	var input models.UpdateProfile
 
 	userID := c.Params.ByName("user_id")
+	if !requireOwner(c, userID) {
+		return
+	}
 	input.UserID = userID
 
 	body := c.Request.Body
 	defer body.Close()
 
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Ошибка в формате JSON: проверьте правильность данных"})
+		respondError(c, http.StatusBadRequest, "Ошибка в формате JSON: проверьте правильность данных")
 		return
 	}
 
+	db := h.writeDB(c)
+
 	var profile models.Profile
-	if err := h.db.Where("user_id = ?", userID).First(&profile).Error; err != nil {
+	if err := db.Where("user_id = ?", userID).First(&profile).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Профиль не найден"})
+			respondError(c, http.StatusNotFound, "Профиль не найден")
 		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при поиске профиля"})
+			respondDBError(c, err, "Ошибка при поиске профиля")
 		}
 		return
 	}
 
-	if err := h.db.Model(&profile).Updates(input).Error; err != nil {
-		if strings.Contains(err.Error(), "unique constraint") {
-			c.JSON(http.StatusConflict, gin.H{"error": "Нарушение уникальности данных при обновлении"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при обновлении профиля"})
+	// Роль меняется только через /admin, чтобы смена попадала в журнал действий
+	if input.Role != "" {
+		if role, ok := models.NormalizeRole(input.Role); !ok || role != profile.Role {
+			respondError(c, http.StatusForbidden, "Роль меняется только администратором")
+			return
 		}
+		input.Role = ""
+	}
+
+	extended, err := h.extendedUpdates(&input, profile.FieldVisibility)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Ошибка: "+err.Error())
 		return
 	}
 
-	// Получаем обновленный профиль
-	if err := h.db.Where("user_id = ?", userID).First(&profile).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Профиль успешно обновлен, но возникла ошибка при получении обновленных данных"})
+	// Проверяются только изменённые текстовые поля: сохранённый текст уже проверен
+	current := map[string]string{"username": profile.Username, "display_name": profile.DisplayName, "bio": profile.Bio,
+		"location": profile.Location, "pronouns": profile.Pronouns}
+	fields := map[string]*string{"username": &input.Username, "display_name": &input.DisplayName, "bio": &input.Bio}
+	if input.Location != nil {
+		fields["location"] = input.Location
+	}
+	if input.Pronouns != nil {
+		fields["pronouns"] = input.Pronouns
+	}
+	changed := map[string]*string{}
+	for name, value := range fields {
+		if *value != "" && *value != current[name] {
+			changed[name] = value
+		}
+	}
+	held, flagReason, ok := screenText(c, h.screener, changed)
+	if !ok {
 		return
 	}
+	// Описание отрисовывается после маскировки проверкой текста, как и сохраняется
+	if input.Bio != "" {
+		extended["bio_html"] = bio.Render(input.Bio)
+		extended["bio_html_version"] = bio.Version
+	}
+	// Местоположение и местоимения сохраняются после маскировки проверкой текста
+	if input.Location != nil {
+		extended["location"] = *input.Location
+	}
+	if input.Pronouns != nil {
+		extended["pronouns"] = *input.Pronouns
+	}
+
+	// Новый адрес вступает в силу только после перехода по ссылке из письма на него;
+	// без отправки писем подтвердить адрес нечем, и смена отклоняется
+	var newEmail string
+	if input.Email != "" && !strings.EqualFold(input.Email, profile.Email) {
+		if h.email == nil {
+			respondError(c, http.StatusConflict, "Смена email недоступна: отправка писем для подтверждения адреса выключена")
+			return
+		}
+		if !strings.EqualFold(input.Email, profile.PendingEmail) {
+			newEmail = input.Email
+		}
+	}
+	input.Email = ""
 
-	c.JSON(http.StatusOK, profile)
+	// Обновлённый профиль перечитывается в той же транзакции, чтобы журнал получил точную разницу
+	before := profile
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&profile).Updates(input).Error; err != nil {
+			return err
+		}
+		if len(extended) > 0 {
+			if err := tx.Model(&profile).Updates(extended).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("user_id = ?", userID).First(&profile).Error; err != nil {
+			return err
+		}
+		diff := audit.DiffOf(before, profile)
+
+		// Исправленное владельцем поле снова видно всем, если прошло проверку текста
+		updates := map[string]any{}
+		if visible := unhideChanged(profile.HiddenFields, diff); !slices.Equal(visible, profile.HiddenFields) {
+			updates["hidden_fields"] = visible
+		}
+		if merged := mergeHeld(profile.HeldFields, diff, held); !slices.Equal(merged, profile.HeldFields) {
+			updates["held_fields"] = merged
+		}
+		if len(held) > 0 && profile.FlaggedAt == nil {
+			updates["flagged_at"] = time.Now()
+			updates["flag_reason"] = flagReason
+		}
+		if len(updates) > 0 {
+			if err := tx.Model(&profile).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		if newEmail != "" {
+			if err := h.email.RequestChange(tx, &profile, newEmail); err != nil {
+				return err
+			}
+		}
+		if len(updates) > 0 || newEmail != "" {
+			if err := tx.Where("user_id = ?", userID).First(&profile).Error; err != nil {
+				return err
+			}
+			diff = audit.DiffOf(before, profile)
+		}
+		if len(diff) == 0 {
+			return nil
+		}
+		if err := publishProfile(tx, audit.ActionProfileUpdated, userID, &profile); err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Entry{
+			Actor:        audit.ActorFrom(c),
+			Action:       audit.ActionProfileUpdated,
+			TargetUserID: userID,
+			Diff:         diff,
+		})
+	})
+	if err != nil {
+		if errors.Is(err, mail.ErrEmailTaken) || strings.Contains(err.Error(), "unique constraint") {
+			h.metrics.ProfileConflict("update")
+			respondError(c, http.StatusConflict, "Нарушение уникальности данных при обновлении")
+		} else {
+			respondDBError(c, err, "Ошибка при обновлении профиля")
+		}
+		return
+	}
+	h.recentWrites.Mark(userID)
+	h.metrics.ProfileEvent(metrics.EventUpdated)
+
+	c.JSON(http.StatusOK, profile.VisibleTo(viewerID(c)))
 }
 
 // DeleteProfile удаляет профиль пользователя
 // @Summary Удалить профиль пользователя
-// @Description Удаляет профиль пользователя с указанным идентификатором
+// @Description Удаляет профиль пользователя с указанным идентификатором. Доступно только владельцу профиля.
 // @Tags profiles
 // @Accept json
 // @Produce json
@@ -200,27 +477,202 @@ func (h ProfileHandler) UpdateProfile(c *gin.Context) {
 // @Param user_id path string true "Идентификатор пользователя"
 // @Success 204 "Профиль успешно удален"
 // @Failure 400 {object} map[string]string "Ошибка в запросе"
+// @Failure 401 {object} map[string]string "Требуется авторизация"
+// @Failure 403 {object} map[string]string "Чужой профиль или профиль заблокирован модератором"
 // @Failure 404 {object} map[string]string "Профиль не найден"
+// @Failure 429 {object} map[string]string "Превышен лимит запросов"
 // @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
 // @Router /profiles/{user_id} [delete]
 func (h ProfileHandler) DeleteProfile(c *gin.Context) {
 	userID := c.Params.ByName("user_id")
+	if !requireOwner(c, userID) {
+		return
+	}
 
 	// Проверка существования профиля перед удалением
+	db := h.writeDB(c)
+
 	var profile models.Profile
-	if err := h.db.Where("user_id = ?", userID).First(&profile).Error; err != nil {
+	if err := db.Where("user_id = ?", userID).First(&profile).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Профиль для удаления не найден"})
+			respondError(c, http.StatusNotFound, "Профиль для удаления не найден")
 		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при поиске профиля для удаления"})
+			respondDBError(c, err, "Ошибка при поиске профиля для удаления")
 		}
 		return
 	}
 
-	if err := h.db.Delete(&profile).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при удалении профиля"})
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&profile).Error; err != nil {
+			return err
+		}
+		// Подписки браузеров удаляются сразу: push удалённому пользователю не отправляются
+		if err := tx.Where("user_id = ?", userID).Delete(&models.PushSubscription{}).Error; err != nil {
+			return err
+		}
+		// Расписание дайджеста тоже: сводки удалённому пользователю не собираются
+		if err := tx.Where("user_id = ?", userID).Delete(&models.DigestSettings{}).Error; err != nil {
+			return err
+		}
+		if err := publishProfile(tx, audit.ActionProfileDeleted, userID, nil); err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Entry{
+			Actor:        audit.ActorFrom(c),
+			Action:       audit.ActionProfileDeleted,
+			TargetUserID: userID,
+			Diff:         audit.DiffOf(profile, models.Profile{}),
+		})
+	})
+	if err != nil {
+		respondDBError(c, err, "Ошибка при удалении профиля")
 		return
 	}
+	h.recentWrites.Mark(userID)
+	h.metrics.ProfileEvent(metrics.EventDeleted)
 
 	c.JSON(http.StatusNoContent, "Профиль успешно удалён")
 }
+
+// GetEmailStatus возвращает адрес пользователя и его подтверждение
+// @Summary Адрес и подтверждение email (внутренний API)
+// @Description Для сервисов, которым нужно знать, подтверждён ли адрес пользователя и доставляются ли на него письма. Вызывается с заголовком X-Internal-Token, через API-шлюз не публикуется.
+// @Tags internal
+// @Produce json
+// @Param X-Internal-Token header string true "Общий секрет внутреннего API"
+// @Param user_id path string true "Идентификатор пользователя"
+// @Success 200 {object} models.EmailStatus "Адрес и его подтверждение"
+// @Failure 401 {object} map[string]string "Неверный внутренний токен"
+// @Failure 404 {object} map[string]string "Профиль не найден"
+// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
+// @Failure 503 {object} map[string]string "Внутренний API не настроен"
+// @Router /internal/profiles/{user_id}/email [get]
+func (h ProfileHandler) GetEmailStatus(c *gin.Context) {
+	userID := c.Params.ByName("user_id")
+	var profile models.Profile
+	if err := h.readDB(c, userID).Where("user_id = ?", userID).First(&profile).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, "Профиль не найден")
+		} else {
+			respondDBError(c, err, "Ошибка при поиске профиля")
+		}
+		return
+	}
+	c.JSON(http.StatusOK, models.EmailStatus{
+		UserID:          profile.UserID,
+		Email:           profile.Email,
+		Verified:        profile.EmailVerifiedAt != nil,
+		EmailVerifiedAt: profile.EmailVerifiedAt,
+		Deliverable:     profile.EmailBouncedAt == nil,
+	})
+}
+
+// publishProfile отправляет владельцу профиля событие об изменении в его потоки событий,
+// например на другие открытые вкладки. profile равен nil после удаления.
+func publishProfile(tx *gorm.DB, action, userID string, profile *models.Profile) error {
+	change := realtime.ProfileChange{Action: action}
+	if profile != nil {
+		change.Profile = profile
+	}
+	return realtime.Publish(tx, userID, realtime.EventProfile, change)
+}
+
+// viewerID возвращает идентификатор пользователя, выполняющего запрос, или пустую строку для анонимного.
+func viewerID(c *gin.Context) string {
+	principal, _ := middleware.CurrentPrincipal(c)
+	return principal.UserID
+}
+
+// visibleTo скрывает в профилях поля, скрытые модератором, если смотрит не владелец.
+func visibleTo(profiles []models.Profile, viewerID string) []models.Profile {
+	for i := range profiles {
+		profiles[i] = profiles[i].VisibleTo(viewerID)
+	}
+	return profiles
+}
+
+// unhideChanged убирает из скрытых поля, которые изменились в diff.
+func unhideChanged(hidden models.StringList, diff audit.Diff) models.StringList {
+	var visible models.StringList
+	for _, field := range hidden {
+		if _, changed := diff[field]; !changed {
+			visible = append(visible, field)
+		}
+	}
+	return visible
+}
+
+// extendedUpdates проверяет переданные расширенные поля и возвращает изменения колонок.
+// Местоположение и местоимения обрезаются по краям в самом запросе: их ещё проверяет screenText.
+func (h ProfileHandler) extendedUpdates(input *models.UpdateProfile, visibility models.FieldVisibility) (map[string]any, error) {
+	updates := map[string]any{}
+	if input.Links != nil {
+		if len(*input.Links) > h.limits.MaxLinks {
+			return nil, fmt.Errorf("ссылок не может быть больше %d", h.limits.MaxLinks)
+		}
+		var links models.SocialLinks
+		for _, raw := range *input.Links {
+			link, err := models.ParseSocialLink(raw)
+			if err != nil {
+				return nil, err
+			}
+			if !slices.ContainsFunc(links, func(l models.SocialLink) bool { return l.URL == link.URL }) {
+				links = append(links, link)
+			}
+		}
+		updates["links"] = links
+	}
+	for _, field := range []struct {
+		name  string
+		value *string
+		limit int
+	}{{"location", input.Location, models.MaxLocationLength}, {"pronouns", input.Pronouns, models.MaxPronounsLength}} {
+		if field.value == nil {
+			continue
+		}
+		*field.value = strings.TrimSpace(*field.value)
+		if err := models.ValidateProfileText(field.name, *field.value, field.limit); err != nil {
+			return nil, err
+		}
+	}
+	if input.Languages != nil {
+		if len(*input.Languages) > h.limits.MaxLanguages {
+			return nil, fmt.Errorf("языков не может быть больше %d", h.limits.MaxLanguages)
+		}
+		var languages models.StringList
+		for _, tag := range *input.Languages {
+			canonical, err := models.CanonicalLanguage(tag)
+			if err != nil {
+				return nil, err
+			}
+			if !languages.Contains(canonical) {
+				languages = append(languages, canonical)
+			}
+		}
+		updates["languages"] = languages
+	}
+	if input.FavoriteGenres != nil {
+		if len(*input.FavoriteGenres) > h.limits.MaxFavoriteGenres {
+			return nil, fmt.Errorf("любимых жанров не может быть больше %d", h.limits.MaxFavoriteGenres)
+		}
+		var favorite models.StringList
+		for _, name := range *input.FavoriteGenres {
+			genre, ok := h.genres.Canonical(name)
+			if !ok {
+				return nil, fmt.Errorf("жанр %q не найден", name)
+			}
+			if !favorite.Contains(genre) {
+				favorite = append(favorite, genre)
+			}
+		}
+		updates["favorite_genres"] = favorite
+	}
+	if input.FieldVisibility != nil {
+		merged, err := visibility.Merge(input.FieldVisibility)
+		if err != nil {
+			return nil, err
+		}
+		updates["field_visibility"] = merged
+	}
+	return updates, nil
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/monst/story-craft/services/user-profile-service/audit"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"github.com/monst/story-craft/services/user-profile-service/push"
+	"github.com/monst/story-craft/services/user-profile-service/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type PushHandler struct {
+	store        *push.Store
+	vapid        *push.VAPID
+	recentWrites *utils.RecentWrites
+}
+
+func NewPushHandler(store *push.Store, vapid *push.VAPID, recentWrites *utils.RecentWrites) *PushHandler {
+	return &PushHandler{store: store, vapid: vapid, recentWrites: recentWrites}
+}
+
+// GetVAPIDPublicKey возвращает открытый ключ сервера для подписки браузера
+// @Summary Открытый ключ VAPID
+// @Description Ключ applicationServerKey для pushManager.subscribe в браузере. Если ключ отличается от того, с которым браузер подписан, подписку нужно оформить заново.
+// @Tags push
+// @Produce json
+// @Success 200 {object} map[string]string "public_key — ключ в base64url"
+// @Router /push/vapid-public-key [get]
+func (h PushHandler) GetVAPIDPublicKey(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"public_key": h.vapid.PublicKey()})
+}
+
+// Subscribe сохраняет подписку браузера на push
+// @Summary Подписать устройство на push
+// @Description Принимает PushSubscription.toJSON() браузера и необязательную подпись устройства. Повторная подписка того же браузера обновляет ключи. При первой подписке включаются push о начале голосования и победе продолжения, если пользователь не включал push сам. Доступно только владельцу профиля.
+// @Tags push
+// @Accept json
+// @Produce json
+// @Security bearerAuth
+// @Param user_id path string true "Идентификатор пользователя"
+// @Param request body models.PushSubscriptionInput true "Подписка браузера"
+// @Success 201 {object} models.PushSubscription "Новая подписка"
+// @Success 200 {object} models.PushSubscription "Подписка обновлена"
+// @Failure 400 {object} map[string]string "Неверный адрес push-сервиса или ключи"
+// @Failure 401 {object} map[string]string "Требуется авторизация"
+// @Failure 403 {object} map[string]string "Чужой профиль или профиль заблокирован модератором"
+// @Failure 404 {object} map[string]string "Профиль не найден"
+// @Failure 429 {object} map[string]string "Превышен лимит запросов"
+// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
+// @Router /profiles/{user_id}/push-subscriptions [post]
+func (h PushHandler) Subscribe(c *gin.Context) {
+	userID := c.Params.ByName("user_id")
+	if !requireOwner(c, userID) {
+		return
+	}
+	var input models.PushSubscriptionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, "Ошибка в формате JSON: проверьте правильность данных")
+		return
+	}
+	sub, created, err := h.store.Subscribe(c.Request.Context(), audit.ActorFrom(c), userID, input, c.Request.UserAgent())
+	switch {
+	case errors.Is(err, push.ErrInvalidEndpoint), errors.Is(err, push.ErrInvalidKeys):
+		respondError(c, http.StatusBadRequest, "Ошибка: "+err.Error())
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		respondError(c, http.StatusNotFound, "Профиль не найден")
+	case err != nil:
+		respondDBError(c, err, "Ошибка при сохранении подписки")
+	default:
+		// Подписка могла включить push в настройках уведомлений
+		h.recentWrites.Mark(userID)
+		status := http.StatusOK
+		if created {
+			status = http.StatusCreated
+		}
+		c.JSON(status, sub)
+	}
+}
+
+// ListSubscriptions возвращает устройства, подписанные на push
+// @Summary Подписанные устройства
+// @Description Подписки браузеров пользователя от новых к старым. Доступно только владельцу профиля.
+// @Tags push
+// @Produce json
+// @Security bearerAuth
+// @Param user_id path string true "Идентификатор пользователя"
+// @Success 200 {array} models.PushSubscription "Подписки"
+// @Failure 401 {object} map[string]string "Требуется авторизация"
+// @Failure 403 {object} map[string]string "Чужой профиль"
+// @Failure 429 {object} map[string]string "Превышен лимит запросов"
+// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
+// @Router /profiles/{user_id}/push-subscriptions [get]
+func (h PushHandler) ListSubscriptions(c *gin.Context) {
+	userID := c.Params.ByName("user_id")
+	if !requireOwner(c, userID) {
+		return
+	}
+	subs, err := h.store.List(c.Request.Context(), userID)
+	if err != nil {
+		respondDBError(c, err, "Ошибка при получении подписок")
+		return
+	}
+	c.JSON(http.StatusOK, subs)
+}
+
+// RevokeSubscription отписывает устройство от push
+// @Summary Отписать устройство от push
+// @Description Удаляет подписку вместе с неотправленными на неё сообщениями. Доступно только владельцу профиля.
+// @Tags push
+// @Security bearerAuth
+// @Param user_id path string true "Идентификатор пользователя"
+// @Param id path int true "Идентификатор подписки"
+// @Success 204 "Подписка удалена"
+// @Failure 400 {object} map[string]string "Неверный идентификатор"
+// @Failure 401 {object} map[string]string "Требуется авторизация"
+// @Failure 403 {object} map[string]string "Чужой профиль или профиль заблокирован модератором"
+// @Failure 404 {object} map[string]string "Подписка не найдена"
+// @Failure 429 {object} map[string]string "Превышен лимит запросов"
+// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
+// @Router /profiles/{user_id}/push-subscriptions/{id} [delete]
+func (h PushHandler) RevokeSubscription(c *gin.Context) {
+	userID := c.Params.ByName("user_id")
+	if !requireOwner(c, userID) {
+		return
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		respondError(c, http.StatusBadRequest, "Ошибка: неверный идентификатор подписки")
+		return
+	}
+	err = h.store.Revoke(c.Request.Context(), audit.ActorFrom(c), userID, id)
+	switch {
+	case errors.Is(err, push.ErrNotFound):
+		respondError(c, http.StatusNotFound, "Подписка не найдена")
+	case err != nil:
+		respondDBError(c, err, "Ошибка при удалении подписки")
+	default:
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,546 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/monst/story-craft/services/user-profile-service/audit"
+	"github.com/monst/story-craft/services/user-profile-service/metrics"
+	"github.com/monst/story-craft/services/user-profile-service/middleware"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"github.com/monst/story-craft/services/user-profile-service/notifications"
+	"github.com/monst/story-craft/services/user-profile-service/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/plugin/dbresolver"
+)
+
+// maxReportComment ограничивает длину комментария к жалобе.
+const maxReportComment = 2000
+
+var (
+	errReportResolved   = errors.New("жалоба уже рассмотрена")
+	errOwnProfileReport = errors.New("жалоба на собственный профиль")
+)
+
+type ReportHandler struct {
+	db           *gorm.DB
+	recentWrites *utils.RecentWrites
+	metrics      *metrics.Metrics
+	// notificationTTL — срок хранения уведомления автору жалобы о решении.
+	notificationTTL time.Duration
+}
+
+func NewReportHandler(db *gorm.DB, recentWrites *utils.RecentWrites, m *metrics.Metrics, notificationTTL time.Duration) *ReportHandler {
+	return &ReportHandler{db: db, recentWrites: recentWrites, metrics: m, notificationTTL: notificationTTL}
+}
+
+// writeDB возвращает сессию основной БД: очередь модерации читается без отставания реплик.
+func (h ReportHandler) writeDB(c *gin.Context) *gorm.DB {
+	return h.db.WithContext(c.Request.Context()).Clauses(dbresolver.Write).Session(&gorm.Session{})
+}
+
+// CreateReport создаёт жалобу на профиль
+// @Summary Пожаловаться на профиль
+// @Description Жалоба на имя, описание или аватар пользователя. Пока жалоба не рассмотрена, повторная жалоба на тот же профиль возвращает существующую (200).
+// @Tags reports
+// @Accept json
+// @Produce json
+// @Security bearerAuth
+// @Param user_id path string true "Идентификатор пользователя, на которого жалуются"
+// @Param request body models.CreateReport true "Категория и суть жалобы"
+// @Success 201 {object} models.Report "Жалоба создана"
+// @Success 200 {object} models.Report "Жалоба уже подана и ждёт решения"
+// @Failure 400 {object} map[string]string "Ошибка в запросе"
+// @Failure 401 {object} map[string]string "Требуется авторизация"
+// @Failure 403 {object} map[string]string "Профиль заблокирован модератором"
+// @Failure 404 {object} map[string]string "Профиль не найден"
+// @Failure 429 {object} map[string]string "Превышен лимит запросов"
+// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
+// @Router /profiles/{user_id}/reports [post]
+func (h ReportHandler) CreateReport(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "Требуется авторизация")
+		return
+	}
+	targetID := c.Params.ByName("user_id")
+
+	var input models.CreateReport
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, "Ошибка в формате JSON: проверьте правильность данных")
+		return
+	}
+	category := strings.ToUpper(strings.TrimSpace(input.Category))
+	if !slices.Contains(models.ReportCategories, category) {
+		respondError(c, http.StatusBadRequest, "Ошибка: категория должна быть одной из "+strings.Join(models.ReportCategories, ", "))
+		return
+	}
+	if !validHideableFields(c, input.Fields) {
+		return
+	}
+	if len([]rune(input.Comment)) > maxReportComment {
+		respondError(c, http.StatusBadRequest, "Ошибка: комментарий длиннее "+strconv.Itoa(maxReportComment)+" символов")
+		return
+	}
+	if targetID == principal.UserID {
+		respondError(c, http.StatusBadRequest, "Нельзя пожаловаться на собственный профиль")
+		return
+	}
+
+	report := models.Report{
+		ReporterID:   principal.UserID,
+		TargetUserID: targetID,
+		Category:     category,
+		Fields:       models.StringList(input.Fields),
+		Comment:      strings.TrimSpace(input.Comment),
+		Status:       models.ReportOpen,
+	}
+	created := false
+	flagged := false
+	err := h.writeDB(c).Transaction(func(tx *gorm.DB) error {
+		var profile models.Profile
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", targetID).First(&profile).Error; err != nil {
+			return err
+		}
+		snapshot, err := reportSnapshot(profile, input.Fields)
+		if err != nil {
+			return err
+		}
+		report.Snapshot = snapshot
+
+		// Дубликат определяется частичным уникальным индексом по нерассмотренным жалобам
+		res := tx.Clauses(clause.OnConflict{
+			Columns:     []clause.Column{{Name: "reporter_id"}, {Name: "target_user_id"}},
+			TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "resolved_at IS NULL"}}},
+			DoNothing:   true,
+		}).Create(&report)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return tx.Where("reporter_id = ? AND target_user_id = ? AND resolved_at IS NULL", principal.UserID, targetID).First(&report).Error
+		}
+		created = true
+
+		// Первая жалоба ставит профиль в список на проверку
+		if profile.FlaggedAt != nil {
+			return nil
+		}
+		before := profile
+		now := time.Now()
+		if err := tx.Model(&profile).Updates(map[string]any{"flagged_at": now, "flag_reason": category}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", targetID).First(&profile).Error; err != nil {
+			return err
+		}
+		flagged = true
+		return audit.Record(tx, audit.Entry{
+			Actor:        audit.ActorFrom(c),
+			Action:       audit.ActionReported,
+			TargetUserID: targetID,
+			Reason:       report.Comment,
+			Diff:         audit.DiffOf(before, profile),
+			Details:      gin.H{"report_id": report.ID, "category": category},
+		})
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			respondError(c, http.StatusNotFound, "Профиль не найден")
+		} else {
+			respondDBError(c, err, "Ошибка при создании жалобы")
+		}
+		return
+	}
+	if flagged {
+		h.recentWrites.Mark(targetID)
+	}
+
+	report.ModeratorID = ""
+	if created {
+		c.JSON(http.StatusCreated, report)
+	} else {
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// ListMyReports возвращает жалобы текущего пользователя
+// @Summary Мои жалобы
+// @Description Жалобы, поданные текущим пользователем, с состоянием и решением модератора, от новых к старым
+// @Tags reports
+// @Produce json
+// @Security bearerAuth
+// @Param limit query int false "Размер страницы (по умолчанию 20, не больше 100)"
+// @Param offset query int false "Смещение"
+// @Success 200 {array} models.Report "Жалобы"
+// @Failure 400 {object} map[string]string "Ошибка в запросе"
+// @Failure 401 {object} map[string]string "Требуется авторизация"
+// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
+// @Router /reports [get]
+func (h ReportHandler) ListMyReports(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "Требуется авторизация")
+		return
+	}
+	limit, offset, ok := bindPage(c)
+	if !ok {
+		return
+	}
+
+	reports := []models.Report{}
+	if err := h.writeDB(c).
+		Where("reporter_id = ?", principal.UserID).
+		Order("id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&reports).Error; err != nil {
+		respondDBError(c, err, "Ошибка при получении жалоб")
+		return
+	}
+	// Автор жалобы видит решение, но не того, кто его принял
+	for i := range reports {
+		reports[i].ModeratorID = ""
+	}
+	c.JSON(http.StatusOK, reports)
+}
+
+// ListReports возвращает очередь модерации
+// @Summary Очередь жалоб
+// @Description Жалобы в порядке поступления. По умолчанию только нерассмотренные (OPEN и TRIAGED).
+// @Tags admin
+// @Produce json
+// @Security bearerAuth
+// @Param status query string false "Состояния через запятую: OPEN, TRIAGED, ACTIONED, DISMISSED"
+// @Param category query string false "Категория жалобы"
+// @Param target_user_id query string false "На чей профиль жалоба"
+// @Param limit query int false "Размер страницы (по умолчанию 20, не больше 100)"
+// @Param offset query int false "Смещение"
+// @Success 200 {array} models.Report "Жалобы"
+// @Failure 400 {object} map[string]string "Ошибка в запросе"
+// @Failure 403 {object} map[string]string "Недостаточно прав"
+// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
+// @Router /admin/reports [get]
+func (h ReportHandler) ListReports(c *gin.Context) {
+	limit, offset, ok := bindPage(c)
+	if !ok {
+		return
+	}
+	statuses := []string{models.ReportOpen, models.ReportTriaged}
+	if raw := c.Query("status"); raw != "" {
+		statuses = strings.Split(strings.ToUpper(raw), ",")
+		for _, s := range statuses {
+			if !slices.Contains([]string{models.ReportOpen, models.ReportTriaged, models.ReportActioned, models.ReportDismissed}, s) {
+				respondError(c, http.StatusBadRequest, "Ошибка: неизвестное состояние жалобы "+s)
+				return
+			}
+		}
+	}
+
+	query := h.writeDB(c).Where("status IN ?", statuses)
+	if category := c.Query("category"); category != "" {
+		query = query.Where("category = ?", strings.ToUpper(category))
+	}
+	if target := c.Query("target_user_id"); target != "" {
+		query = query.Where("target_user_id = ?", target)
+	}
+
+	reports := []models.Report{}
+	if err := query.Order("id").Limit(limit).Offset(offset).Find(&reports).Error; err != nil {
+		respondDBError(c, err, "Ошибка при получении очереди жалоб")
+		return
+	}
+	c.JSON(http.StatusOK, reports)
+}
+
+// TriageReport берёт жалобу в работу
+// @Summary Взять жалобу в работу
+// @Description Переводит жалобу из OPEN в TRIAGED и закрепляет её за модератором
+// @Tags admin
+// @Produce json
+// @Security bearerAuth
+// @Param id path int true "Идентификатор жалобы"
+// @Success 200 {object} models.Report "Жалоба"
+// @Failure 400 {object} map[string]string "Ошибка в запросе"
+// @Failure 403 {object} map[string]string "Недостаточно прав"
+// @Failure 404 {object} map[string]string "Жалоба не найдена"
+// @Failure 409 {object} map[string]string "Жалоба уже в работе или рассмотрена"
+// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
+// @Router /admin/reports/{id}/triage [post]
+func (h ReportHandler) TriageReport(c *gin.Context) {
+	principal, _ := middleware.CurrentPrincipal(c)
+	id, ok := reportID(c)
+	if !ok {
+		return
+	}
+
+	// Условие на состояние не даёт двум модераторам взять одну жалобу
+	res := h.writeDB(c).Model(&models.Report{}).
+		Where("id = ? AND status = ?", id, models.ReportOpen).
+		Updates(map[string]any{"status": models.ReportTriaged, "moderator_id": principal.UserID})
+	if res.Error != nil {
+		respondDBError(c, res.Error, "Ошибка при изменении жалобы")
+		return
+	}
+
+	var report models.Report
+	if err := h.writeDB(c).First(&report, id).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		respondError(c, http.StatusNotFound, "Жалоба не найдена")
+		return
+	} else if err != nil {
+		respondDBError(c, err, "Ошибка при изменении жалобы")
+		return
+	}
+	if res.RowsAffected == 0 {
+		respondError(c, http.StatusConflict, "Жалоба уже в работе или рассмотрена")
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// ActionReport принимает меры по жалобе
+// @Summary Принять меры по жалобе
+// @Description Закрывает жалобу как ACTIONED и скрывает указанные поля профиля. Скрытые поля видит только владелец; изменённое им поле снова становится видимым. Автор жалобы получает уведомление report_resolved с outcome.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security bearerAuth
+// @Param id path int true "Идентификатор жалобы"
+// @Param request body models.ReportDecision true "Решение и поля для скрытия"
+// @Success 200 {object} models.Report "Рассмотренная жалоба"
+// @Failure 400 {object} map[string]string "Ошибка в запросе"
+// @Failure 403 {object} map[string]string "Недостаточно прав"
+// @Failure 404 {object} map[string]string "Жалоба или профиль не найдены"
+// @Failure 409 {object} map[string]string "Жалоба уже рассмотрена"
+// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
+// @Router /admin/reports/{id}/action [post]
+func (h ReportHandler) ActionReport(c *gin.Context) {
+	var input models.ReportDecision
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, "Ошибка: передайте outcome — решение для автора жалобы")
+		return
+	}
+	if !validHideableFields(c, input.HideFields) {
+		return
+	}
+	h.resolve(c, models.ReportActioned, input)
+}
+
+// DismissReport отклоняет жалобу
+// @Summary Отклонить жалобу
+// @Description Закрывает жалобу как DISMISSED без изменения профиля. Автор жалобы получает уведомление report_resolved с outcome.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security bearerAuth
+// @Param id path int true "Идентификатор жалобы"
+// @Param request body models.ReportDecision true "Решение для автора жалобы"
+// @Success 200 {object} models.Report "Рассмотренная жалоба"
+// @Failure 400 {object} map[string]string "Ошибка в запросе"
+// @Failure 403 {object} map[string]string "Недостаточно прав"
+// @Failure 404 {object} map[string]string "Жалоба не найдена"
+// @Failure 409 {object} map[string]string "Жалоба уже рассмотрена"
+// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
+// @Router /admin/reports/{id}/dismiss [post]
+func (h ReportHandler) DismissReport(c *gin.Context) {
+	var input models.ReportDecision
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, "Ошибка: передайте outcome — решение для автора жалобы")
+		return
+	}
+	input.HideFields = nil
+	h.resolve(c, models.ReportDismissed, input)
+}
+
+// resolve закрывает жалобу решением модератора. В одной транзакции скрывает поля
+// профиля, снимает отметку о проверке, если других нерассмотренных жалоб не осталось,
+// уведомляет автора жалобы о решении и пишет решение в журнал.
+func (h ReportHandler) resolve(c *gin.Context, status string, input models.ReportDecision) {
+	principal, _ := middleware.CurrentPrincipal(c)
+	id, ok := reportID(c)
+	if !ok {
+		return
+	}
+
+	var report models.Report
+	profileChanged := false
+	err := h.writeDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&report, id).Error; err != nil {
+			return err
+		}
+		if !report.IsOpen() {
+			return errReportResolved
+		}
+		if report.TargetUserID == principal.UserID {
+			return errOwnProfileReport
+		}
+
+		// Профиль мог быть удалён после жалобы: тогда её можно только закрыть, скрывать нечего
+		var profile models.Profile
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", report.TargetUserID).First(&profile).Error
+		if err != nil && (!errors.Is(err, gorm.ErrRecordNotFound) || len(input.HideFields) > 0) {
+			return err
+		}
+		before := profile
+
+		updates := map[string]any{}
+		if len(input.HideFields) > 0 {
+			hidden := slices.Clone(profile.HiddenFields)
+			for _, field := range input.HideFields {
+				if !hidden.Contains(field) {
+					hidden = append(hidden, field)
+				}
+			}
+			updates["hidden_fields"] = hidden
+		}
+		// Задержанные проверкой текста поля по-прежнему ждут модератора, отметка остаётся
+		if profile.FlaggedAt != nil && len(profile.HeldFields) == 0 {
+			var pending int64
+			if err := tx.Model(&models.Report{}).
+				Where("target_user_id = ? AND id <> ? AND resolved_at IS NULL", report.TargetUserID, report.ID).
+				Count(&pending).Error; err != nil {
+				return err
+			}
+			if pending == 0 {
+				updates["flagged_at"] = nil
+				updates["flag_reason"] = ""
+			}
+		}
+		if len(updates) > 0 {
+			if err := tx.Model(&profile).Updates(updates).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("user_id = ?", report.TargetUserID).First(&profile).Error; err != nil {
+				return err
+			}
+			profileChanged = true
+		}
+
+		if err := tx.Model(&report).Updates(map[string]any{
+			"status":        status,
+			"moderator_id":  principal.UserID,
+			"outcome":       strings.TrimSpace(input.Outcome),
+			"hidden_fields": models.StringList(input.HideFields),
+			"resolved_at":   time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&report, id).Error; err != nil {
+			return err
+		}
+
+		action := audit.ActionReportDismissed
+		if status == models.ReportActioned {
+			action = audit.ActionReportActioned
+		}
+		if profileChanged {
+			if err := publishProfile(tx, action, report.TargetUserID, &profile); err != nil {
+				return err
+			}
+		}
+		if err := notifyReporter(tx, report, h.notificationTTL); err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Entry{
+			Actor:        audit.ActorFrom(c),
+			Action:       action,
+			TargetUserID: report.TargetUserID,
+			Reason:       report.Outcome,
+			Diff:         audit.DiffOf(before, profile),
+			Details:      gin.H{"report_id": report.ID, "category": report.Category, "hidden_fields": input.HideFields},
+		})
+	})
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		respondError(c, http.StatusNotFound, "Жалоба или профиль не найдены")
+		return
+	case errors.Is(err, errReportResolved):
+		respondError(c, http.StatusConflict, "Жалоба уже рассмотрена")
+		return
+	case errors.Is(err, errOwnProfileReport):
+		respondError(c, http.StatusBadRequest, "Нельзя рассматривать жалобы на свой профиль")
+		return
+	case err != nil:
+		respondDBError(c, err, "Ошибка при рассмотрении жалобы")
+		return
+	}
+	if profileChanged {
+		h.recentWrites.Mark(report.TargetUserID)
+		h.metrics.ProfileEvent(metrics.EventUpdated)
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// notifyReporter отправляет автору жалобы решение модератора. Модератор в уведомлении
+// не указывается, как и в GET /reports.
+func notifyReporter(tx *gorm.DB, report models.Report, ttl time.Duration) error {
+	payload, err := json.Marshal(models.ReportResolution{
+		ReportID:     report.ID,
+		TargetUserID: report.TargetUserID,
+		Status:       report.Status,
+		Outcome:      report.Outcome,
+	})
+	if err != nil {
+		return err
+	}
+	key := "report:" + strconv.FormatUint(report.ID, 10)
+	note := models.Notification{RecipientID: report.ReporterID, Type: models.EventReportResolved, Payload: payload, DedupKey: &key}
+	_, _, err = notifications.Insert(tx, note, ttl)
+	return err
+}
+
+// reportSnapshot сохраняет значения полей, на которые жалуются, а без списка полей — всех доступных для жалобы.
+func reportSnapshot(p models.Profile, fields []string) (json.RawMessage, error) {
+	if len(fields) == 0 {
+		fields = models.HideableFields
+	}
+	values := map[string]string{"username": p.Username, "display_name": p.DisplayName, "bio": p.Bio, "avatar_url": p.AvatarURL,
+		"links": strings.Join(p.Links.URLs(), "\n"), "location": p.Location, "pronouns": p.Pronouns}
+	snapshot := make(map[string]string, len(fields))
+	for _, field := range fields {
+		snapshot[field] = values[field]
+	}
+	return json.Marshal(snapshot)
+}
+
+func validHideableFields(c *gin.Context, fields []string) bool {
+	for _, field := range fields {
+		if !slices.Contains(models.HideableFields, field) {
+			respondError(c, http.StatusBadRequest, "Ошибка: поле "+field+" не поддерживается, допустимы "+strings.Join(models.HideableFields, ", "))
+			return false
+		}
+	}
+	return true
+}
+
+func reportID(c *gin.Context) (uint64, bool) {
+	id, err := strconv.ParseUint(c.Params.ByName("id"), 10, 64)
+	if err != nil || id == 0 {
+		respondError(c, http.StatusBadRequest, "Ошибка: неверный идентификатор жалобы")
+		return 0, false
+	}
+	return id, true
+}
+
+// bindPage разбирает параметры limit и offset постраничной выдачи.
+func bindPage(c *gin.Context) (limit, offset int, ok bool) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > maxBatchSize {
+		respondError(c, http.StatusBadRequest, "Ошибка: limit должен быть от 1 до "+strconv.Itoa(maxBatchSize))
+		return 0, 0, false
+	}
+	offset, err = strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		respondError(c, http.StatusBadRequest, "Ошибка: offset должен быть неотрицательным")
+		return 0, 0, false
+	}
+	return limit, offset, true
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/monst/story-craft/services/user-profile-service/audit"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"github.com/monst/story-craft/services/user-profile-service/screening"
+)
+
+// Violation — поле, не прошедшее проверку текста, и сработавшие правила.
+type Violation struct {
+	Field string   `json:"field"`
+	Rules []string `json:"rules"`
+}
+
+// screenText проверяет текстовые поля профиля (имя поля → значение из запроса).
+// Замаскированный текст записывается обратно в запрос. Возвращает задержанные до
+// проверки модератором поля и сработавшие для них правила; ok == false означает,
+// что изменение отклонено и ответ 422 уже отправлен.
+func screenText(c *gin.Context, screener *screening.Screener, fields map[string]*string) (held models.StringList, reason string, ok bool) {
+	texts := make(map[string]string, len(fields))
+	for name, value := range fields {
+		texts[name] = *value
+	}
+	verdict := screener.Screen(c.Request.Context(), texts)
+
+	// Имя пользователя уникально и служит адресом профиля, поэтому маскировать его нельзя
+	if fv, found := verdict.Fields["username"]; found && fv.Action == screening.ActionRedact {
+		fv.Action = screening.ActionReject
+		verdict.Fields["username"] = fv
+	}
+
+	var violations []Violation
+	for _, name := range verdict.FieldsWith(screening.ActionReject) {
+		violations = append(violations, Violation{Field: name, Rules: verdict.Fields[name].Rules})
+	}
+	if len(violations) > 0 {
+		respondErrorWith(c, http.StatusUnprocessableEntity, "Текст профиля не прошёл проверку", gin.H{"violations": violations})
+		return nil, "", false
+	}
+
+	for _, name := range verdict.FieldsWith(screening.ActionRedact) {
+		*fields[name] = verdict.Fields[name].Text
+	}
+	held = models.StringList(verdict.FieldsWith(screening.ActionHold))
+	if len(held) > 0 {
+		reason = "SCREENING: " + strings.Join(verdict.RulesFor(screening.ActionHold), ", ")
+	}
+	return held, reason, true
+}
+
+// mergeHeld убирает из задержанных поля, изменённые в запросе, и добавляет задержанные проверкой сейчас.
+func mergeHeld(current models.StringList, diff audit.Diff, held models.StringList) models.StringList {
+	var merged models.StringList
+	for _, field := range current {
+		if _, changed := diff[field]; !changed {
+			merged = append(merged, field)
+		}
+	}
+	for _, field := range held {
+		if !merged.Contains(field) {
+			merged = append(merged, field)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/monst/story-craft/services/user-profile-service/config"
+	"github.com/monst/story-craft/services/user-profile-service/logging"
+	"github.com/monst/story-craft/services/user-profile-service/metrics"
+	"github.com/monst/story-craft/services/user-profile-service/realtime"
+	"golang.org/x/net/websocket"
+)
+
+// sseRetry — через сколько миллисекунд браузер переподключается к потоку после разрыва.
+const sseRetry = 3000
+
+// wsWriteTimeout ограничивает отправку одного сообщения по WebSocket: клиент, который
+// не читает, не должен держать обработчик.
+const wsWriteTimeout = 10 * time.Second
+
+type StreamHandler struct {
+	hub           *realtime.Hub
+	cfg           config.RealtimeConfig
+	metrics       *metrics.Metrics
+	originAllowed func(origin string) bool
+}
+
+func NewStreamHandler(hub *realtime.Hub, cfg config.RealtimeConfig, originAllowed func(string) bool, m *metrics.Metrics) *StreamHandler {
+	return &StreamHandler{hub: hub, cfg: cfg, metrics: m, originAllowed: originAllowed}
+}
+
+// subscribe подписывает пользователя запроса на события с учётом Last-Event-ID
+// (заголовок или параметр last_event_id для клиентов, которые не могут задать заголовок).
+func (h StreamHandler) subscribe(c *gin.Context) (*realtime.Subscription, []realtime.Event, bool) {
+	userID, ok := recipient(c)
+	if !ok {
+		return nil, nil, false
+	}
+	raw := c.GetHeader("Last-Event-ID")
+	if raw == "" {
+		raw = c.Query("last_event_id")
+	}
+	var lastID int64
+	if raw != "" {
+		var err error
+		if lastID, err = strconv.ParseInt(raw, 10, 64); err != nil || lastID < 0 {
+			respondError(c, http.StatusBadRequest, "Ошибка: неверный Last-Event-ID")
+			return nil, nil, false
+		}
+	}
+	sub, replay, err := h.hub.Subscribe(userID, lastID, raw != "")
+	switch {
+	case errors.Is(err, realtime.ErrTooManyConnections):
+		respondError(c, http.StatusTooManyRequests, "Открыто слишком много потоков событий")
+		return nil, nil, false
+	case errors.Is(err, realtime.ErrClosed):
+		respondError(c, http.StatusServiceUnavailable, "Сервис останавливается, переподключитесь позже")
+		return nil, nil, false
+	}
+	return sub, replay, true
+}
+
+// Stream отдаёт события пользователя по SSE
+// @Summary Поток событий (SSE)
+// @Description Server-Sent Events с новыми уведомлениями (event: notification) и изменениями профиля (event: profile). После разрыва браузер переподключается с Last-Event-ID и получает пропущенные события; если их уже нет в буфере, приходит event: resync — данные нужно перечитать. Раз в REALTIME_HEARTBEAT отправляется комментарий-пинг.
+// @Tags events
+// @Produce text/event-stream
+// @Security bearerAuth
+// @Param Last-Event-ID header int false "Идентификатор последнего полученного события"
+// @Param last_event_id query int false "То же, что Last-Event-ID, для клиентов без доступа к заголовкам"
+// @Success 200 {string} string "Поток событий"
+// @Failure 400 {object} map[string]string "Неверный Last-Event-ID"
+// @Failure 401 {object} map[string]string "Требуется авторизация"
+// @Failure 429 {object} map[string]string "Открыто слишком много потоков"
+// @Failure 503 {object} map[string]string "Сервис останавливается"
+// @Router /events [get]
+func (h StreamHandler) Stream(c *gin.Context) {
+	sub, replay, ok := h.subscribe(c)
+	if !ok {
+		return
+	}
+	defer sub.Close()
+	defer h.metrics.StreamOpened("sse")()
+
+	// Поток живёт дольше HTTP_WRITE_TIMEOUT сервера
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		logging.FromContext(c.Request.Context()).Warn("Не удалось снять таймаут записи для потока событий", slog.Any("error", err))
+	}
+	header := c.Writer.Header()
+	header.Set("Content-Type", sse.ContentType)
+	header.Set("Cache-Control", "no-cache")
+	header.Set("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	fmt.Fprintf(c.Writer, "retry:%d\n\n", sseRetry)
+	for _, e := range replay {
+		writeSSE(c.Writer, e)
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(h.cfg.Heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case e, open := <-sub.Events():
+			if !open {
+				// Клиент не успевал читать: после переподключения он получит пропущенное из буфера
+				return
+			}
+			writeSSE(c.Writer, e)
+		case <-heartbeat.C:
+			io.WriteString(c.Writer, ":ping\n\n")
+		}
+		c.Writer.Flush()
+	}
+}
+
+func writeSSE(w io.Writer, e realtime.Event) {
+	event := sse.Event{Event: e.Type, Data: e.Data}
+	if e.ID > 0 {
+		event.Id = strconv.FormatInt(e.ID, 10)
+	}
+	if len(e.Data) == 0 {
+		event.Data = "{}"
+	}
+	sse.Encode(w, event)
+}
+
+// WebSocket отдаёт события пользователя по WebSocket
+// @Summary Поток событий (WebSocket)
+// @Description Те же события, что и в /events, сообщениями JSON {"id","type","data"}. Для продолжения после разрыва передайте last_event_id. Раз в REALTIME_HEARTBEAT приходит {"type":"heartbeat"}. Доступен, если включён REALTIME_WEBSOCKET; origin проверяется по политике CORS.
+// @Tags events
+// @Security bearerAuth
+// @Param last_event_id query int false "Идентификатор последнего полученного события"
+// @Success 101 {string} string "Соединение WebSocket"
+// @Failure 400 {object} map[string]string "Неверный last_event_id"
+// @Failure 401 {object} map[string]string "Требуется авторизация"
+// @Failure 403 {string} string "Origin не разрешён"
+// @Failure 429 {object} map[string]string "Открыто слишком много потоков"
+// @Failure 503 {object} map[string]string "Сервис останавливается"
+// @Router /events/ws [get]
+func (h StreamHandler) WebSocket(c *gin.Context) {
+	sub, replay, ok := h.subscribe(c)
+	if !ok {
+		return
+	}
+	defer sub.Close()
+
+	server := websocket.Server{
+		Handshake: func(cfg *websocket.Config, r *http.Request) error {
+			if cfg.Origin != nil && !h.originAllowed(cfg.Origin.Scheme+"://"+cfg.Origin.Host) {
+				return errors.New("origin не разрешён")
+			}
+			return nil
+		},
+		Handler: func(ws *websocket.Conn) {
+			defer h.metrics.StreamOpened("websocket")()
+			h.serveWebSocket(c.Request.Context(), ws, sub, replay)
+		},
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+func (h StreamHandler) serveWebSocket(ctx context.Context, ws *websocket.Conn, sub *realtime.Subscription, replay []realtime.Event) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer ws.Close()
+
+	// Клиент ничего не отправляет; чтение нужно, чтобы заметить закрытие соединения
+	go func() {
+		defer cancel()
+		var discard []byte
+		for websocket.Message.Receive(ws, &discard) == nil {
+		}
+	}()
+
+	send := func(e realtime.Event) bool {
+		e.UserID = ""
+		ws.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		return websocket.JSON.Send(ws, e) == nil
+	}
+	for _, e := range replay {
+		if !send(e) {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(h.cfg.Heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e, open := <-sub.Events():
+			if !open || !send(e) {
+				return
+			}
+		case <-heartbeat.C:
+			if !send(realtime.Event{Type: realtime.EventHeartbeat}) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package health
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/monst/story-craft/services/user-profile-service/lifecycle"
+	"github.com/monst/story-craft/services/user-profile-service/utils"
+	"gorm.io/gorm"
+)
+
+// Database проверяет, что основная БД отвечает на ping.
+func Database(db *gorm.DB) CheckFunc {
+	return func(ctx context.Context) (any, error) {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
+		if err := sqlDB.PingContext(ctx); err != nil {
+			return nil, err
+		}
+		stats := sqlDB.Stats()
+		return map[string]int{
+			"open_connections": stats.OpenConnections,
+			"in_use":           stats.InUse,
+			"idle":             stats.Idle,
+		}, nil
+	}
+}
+
+// Migrations проверяет, что схема БД не старее той, которую ожидает сборка.
+func Migrations(db *gorm.DB) CheckFunc {
+	return func(ctx context.Context) (any, error) {
+		version, err := utils.CurrentSchemaVersion(ctx, db)
+		if err != nil {
+			return nil, err
+		}
+		details := map[string]int{"current": version, "expected": utils.SchemaVersion}
+		if version < utils.SchemaVersion {
+			return details, fmt.Errorf("схема БД устарела: версия %d, ожидается %d", version, utils.SchemaVersion)
+		}
+		return details, nil
+	}
+}
+
+// Workers проверяет, что ни одна фоновая задача не завершилась с ошибкой.
+func Workers(lc *lifecycle.Manager) CheckFunc {
+	return func(context.Context) (any, error) {
+		workers := lc.Workers()
+		for _, w := range workers {
+			if w.State == lifecycle.StateFailed {
+				return workers, fmt.Errorf("фоновая задача %s завершилась с ошибкой", w.Name)
+			}
+		}
+		return workers, nil
+	}
+}
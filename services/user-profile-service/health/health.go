@@ -0,0 +1,111 @@
+// Package health выполняет проверки зависимостей сервиса для эндпоинтов готовности.
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusOK   Status = "ok"
+	StatusFail Status = "fail"
+)
+
+// CheckFunc проверяет зависимость. Возвращаемые details попадают в ответ как есть.
+type CheckFunc func(ctx context.Context) (details any, err error)
+
+// CheckResult — результат проверки одной зависимости.
+type CheckResult struct {
+	Status    Status  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+	Details   any     `json:"details,omitempty"`
+}
+
+// Report — сводный результат всех проверок.
+type Report struct {
+	Status    Status                 `json:"status"`
+	Checks    map[string]CheckResult `json:"checks"`
+	CheckedAt time.Time              `json:"checked_at"`
+}
+
+type check struct {
+	name string
+	fn   CheckFunc
+}
+
+// Checker выполняет проверки параллельно с общим таймаутом и кэширует результат
+// на cacheTTL, чтобы частый опрос не нагружал базу.
+type Checker struct {
+	timeout  time.Duration
+	cacheTTL time.Duration
+	checks   []check
+
+	mu   sync.Mutex
+	last *Report
+}
+
+func NewChecker(timeout, cacheTTL time.Duration) *Checker {
+	return &Checker{timeout: timeout, cacheTTL: cacheTTL}
+}
+
+// Add регистрирует проверку зависимости name.
+func (c *Checker) Add(name string, fn CheckFunc) {
+	c.checks = append(c.checks, check{name: name, fn: fn})
+}
+
+// Check возвращает результат проверок, при необходимости выполняя их заново.
+// Одновременные вызовы ждут одну и ту же проверку.
+func (c *Checker) Check(ctx context.Context) Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.last != nil && time.Since(c.last.CheckedAt) < c.cacheTTL {
+		return *c.last
+	}
+
+	// Результат кэшируется для всех, поэтому отмена запроса одного клиента не должна его портить
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.timeout)
+	defer cancel()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]CheckResult, len(c.checks)), CheckedAt: time.Now()}
+	results := make([]CheckResult, len(c.checks))
+
+	var wg sync.WaitGroup
+	for i, ch := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = run(ctx, ch.fn)
+		}()
+	}
+	wg.Wait()
+
+	for i, ch := range c.checks {
+		report.Checks[ch.name] = results[i]
+		if results[i].Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+
+	c.last = &report
+	return report
+}
+
+func run(ctx context.Context, fn CheckFunc) CheckResult {
+	start := time.Now()
+	details, err := fn(ctx)
+	result := CheckResult{
+		Status:    StatusOK,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		Details:   details,
+	}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
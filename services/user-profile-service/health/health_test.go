@@ -0,0 +1,59 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCheckerAggregatesResults(t *testing.T) {
+	c := NewChecker(time.Second, 0)
+	c.Add("database", func(context.Context) (any, error) { return map[string]int{"idle": 1}, nil })
+	c.Add("cache", func(context.Context) (any, error) { return nil, errors.New("нет соединения") })
+
+	report := c.Check(context.Background())
+	if report.Status != StatusFail {
+		t.Fatalf("одна упавшая проверка должна давать fail, получили %s", report.Status)
+	}
+	if report.Checks["database"].Status != StatusOK {
+		t.Errorf("database: %+v", report.Checks["database"])
+	}
+	if got := report.Checks["cache"]; got.Status != StatusFail || got.Error != "нет соединения" {
+		t.Errorf("cache: %+v", got)
+	}
+}
+
+func TestCheckerCachesResults(t *testing.T) {
+	var calls atomic.Int32
+	c := NewChecker(time.Second, time.Minute)
+	c.Add("database", func(context.Context) (any, error) {
+		calls.Add(1)
+		return nil, nil
+	})
+
+	for range 5 {
+		c.Check(context.Background())
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("в пределах cacheTTL проверка должна выполняться один раз, выполнено %d", calls.Load())
+	}
+}
+
+func TestCheckerTimeout(t *testing.T) {
+	c := NewChecker(50*time.Millisecond, 0)
+	c.Add("slow", func(ctx context.Context) (any, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	start := time.Now()
+	report := c.Check(context.Background())
+	if report.Status != StatusFail {
+		t.Fatal("зависшая проверка должна завершаться по таймауту с ошибкой")
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("таймаут проверок не соблюдается")
+	}
+}
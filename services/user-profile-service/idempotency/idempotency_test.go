@@ -0,0 +1,241 @@
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/monst/story-craft/services/user-profile-service/middleware"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"github.com/monst/story-craft/services/user-profile-service/testutil"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	os.Exit(testutil.RunWithPostgres(m))
+}
+
+// memoryStore — Store в памяти с той же семантикой, что у DBStore, для проверки middleware без БД.
+type memoryStore struct {
+	mu   sync.Mutex
+	recs map[string]*models.IdempotencyKey
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{recs: make(map[string]*models.IdempotencyKey)}
+}
+
+func (s *memoryStore) Acquire(_ context.Context, userID, key, hash string, lockTimeout, ttl time.Duration) (*models.IdempotencyKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	rec, ok := s.recs[userID+"/"+key]
+	if !ok || rec.ExpiresAt.Before(now) {
+		s.recs[userID+"/"+key] = &models.IdempotencyKey{UserID: userID, Key: key, RequestHash: hash, LockedAt: now, ExpiresAt: now.Add(ttl)}
+		return nil, nil
+	}
+	if !rec.Completed && rec.RequestHash == hash && rec.LockedAt.Add(lockTimeout).Before(now) {
+		rec.LockedAt = now
+		return nil, nil
+	}
+	copied := *rec
+	return &copied, nil
+}
+
+func (s *memoryStore) Complete(_ context.Context, userID, key string, status int, contentType string, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec := s.recs[userID+"/"+key]
+	rec.Completed, rec.StatusCode, rec.ContentType, rec.ResponseBody = true, status, contentType, body
+	return nil
+}
+
+func (s *memoryStore) Release(_ context.Context, userID, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.recs, userID+"/"+key)
+	return nil
+}
+
+type testServer struct {
+	router *gin.Engine
+	calls  atomic.Int32
+	status atomic.Int32
+	delay  time.Duration
+}
+
+func newTestServer(store Store) *testServer {
+	s := &testServer{}
+	s.status.Store(http.StatusCreated)
+	s.router = gin.New()
+	s.router.Use(middleware.Authenticate())
+	s.router.POST("/profiles/", Middleware(store, Options{TTL: time.Hour, LockTimeout: time.Minute}), func(c *gin.Context) {
+		n := s.calls.Add(1)
+		time.Sleep(s.delay)
+		c.JSON(int(s.status.Load()), gin.H{"call": n})
+	})
+	return s
+}
+
+func (s *testServer) post(userID, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/profiles/", strings.NewReader(body))
+	req.Header.Set(middleware.UserObjectHeader, `{"userId":"`+userID+`"}`)
+	if key != "" {
+		req.Header.Set(Header, key)
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestReplayReturnsOriginalResponse(t *testing.T) {
+	s := newTestServer(newMemoryStore())
+
+	first := s.post("alice", "k1", `{"username":"alice"}`)
+	second := s.post("alice", "k1", `{"username":"alice"}`)
+
+	if first.Code != http.StatusCreated || second.Code != http.StatusCreated {
+		t.Fatalf("ожидали 201 на оба запроса, получили %d и %d", first.Code, second.Code)
+	}
+	if second.Body.String() != first.Body.String() {
+		t.Errorf("повтор должен вернуть исходный ответ: %s != %s", second.Body.String(), first.Body.String())
+	}
+	if second.Header().Get(ReplayedHeader) != "true" || first.Header().Get(ReplayedHeader) != "" {
+		t.Error("Idempotent-Replayed должен быть только у повтора")
+	}
+	if s.calls.Load() != 1 {
+		t.Errorf("обработчик должен выполниться один раз, выполнился %d", s.calls.Load())
+	}
+}
+
+func TestKeyIsScopedToPrincipalAndRequest(t *testing.T) {
+	s := newTestServer(newMemoryStore())
+	s.post("alice", "k1", `{"username":"alice"}`)
+
+	if w := s.post("alice", "k1", `{"username":"mallory"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("ключ с другим телом: ожидали 422, получили %d", w.Code)
+	}
+	if w := s.post("bob", "k1", `{"username":"mallory"}`); w.Code != http.StatusCreated || w.Header().Get(ReplayedHeader) != "" {
+		t.Errorf("ключи разных пользователей независимы, получили %d", w.Code)
+	}
+	if w := s.post("alice", "", `{"username":"alice"}`); w.Header().Get(ReplayedHeader) != "" {
+		t.Error("запрос без ключа не должен получать сохранённый ответ")
+	}
+	if w := s.post("alice", strings.Repeat("k", 256), `{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("слишком длинный ключ: ожидали 400, получили %d", w.Code)
+	}
+}
+
+func TestServerErrorsAreNotStored(t *testing.T) {
+	s := newTestServer(newMemoryStore())
+	s.status.Store(http.StatusInternalServerError)
+	s.post("alice", "k1", `{}`)
+
+	s.status.Store(http.StatusCreated)
+	if w := s.post("alice", "k1", `{}`); w.Code != http.StatusCreated || w.Header().Get(ReplayedHeader) != "" {
+		t.Errorf("после 500 запрос с тем же ключом должен выполниться заново, получили %d", w.Code)
+	}
+	if s.calls.Load() != 2 {
+		t.Errorf("ожидали 2 вызова обработчика, получили %d", s.calls.Load())
+	}
+}
+
+func TestConcurrentDuplicatesAreSerialized(t *testing.T) {
+	s := newTestServer(newMemoryStore())
+	s.delay = 200 * time.Millisecond
+
+	var wg sync.WaitGroup
+	responses := make([]*httptest.ResponseRecorder, 5)
+	for i := range responses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			responses[i] = s.post("alice", "k1", `{"username":"alice"}`)
+		}(i)
+	}
+	wg.Wait()
+
+	if s.calls.Load() != 1 {
+		t.Fatalf("параллельные дубликаты должны выполниться один раз, выполнились %d", s.calls.Load())
+	}
+	for _, w := range responses {
+		if w.Code != http.StatusCreated || w.Body.String() != responses[0].Body.String() {
+			t.Errorf("все дубликаты должны получить исходный ответ: %d %s", w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestDuplicateGivesUpAtDeadline(t *testing.T) {
+	s := newTestServer(newMemoryStore())
+	s.delay = 300 * time.Millisecond
+
+	go s.post("alice", "k1", `{}`)
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodPost, "/profiles/", strings.NewReader(`{}`)).WithContext(ctx)
+	req.Header.Set(middleware.UserObjectHeader, `{"userId":"alice"}`)
+	req.Header.Set(Header, "k1")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusConflict || w.Header().Get("Retry-After") == "" {
+		t.Errorf("ожидали 409 с Retry-After, получили %d %v", w.Code, w.Header())
+	}
+}
+
+func TestDBStore(t *testing.T) {
+	db := testutil.NewDB(t)
+	store := NewDBStore(db)
+	ctx := context.Background()
+	userID := uuid.NewString()
+
+	rec, err := store.Acquire(ctx, userID, "k1", "hash", time.Minute, time.Hour)
+	if err != nil || rec != nil {
+		t.Fatalf("свободный ключ должен захватываться: %v %v", rec, err)
+	}
+	rec, err = store.Acquire(ctx, userID, "k1", "hash", time.Minute, time.Hour)
+	if err != nil || rec == nil || rec.Completed {
+		t.Fatalf("занятый ключ должен возвращать незавершённую запись: %+v %v", rec, err)
+	}
+
+	if err := store.Complete(ctx, userID, "k1", http.StatusCreated, "application/json", []byte(`{"ok":true}`)); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	rec, err = store.Acquire(ctx, userID, "k1", "hash", time.Minute, time.Hour)
+	if err != nil || rec == nil || !rec.Completed || rec.StatusCode != http.StatusCreated || string(rec.ResponseBody) != `{"ok":true}` {
+		t.Fatalf("ожидали сохранённый ответ: %+v %v", rec, err)
+	}
+
+	// Зависшая обработка забирается после lockTimeout
+	if rec, _ := store.Acquire(ctx, userID, "k2", "hash", time.Minute, time.Hour); rec != nil {
+		t.Fatal("k2 должен быть свободен")
+	}
+	db.Exec(`UPDATE idempotency_keys SET locked_at = now() - interval '2 minutes' WHERE key = 'k2'`)
+	if rec, err := store.Acquire(ctx, userID, "k2", "hash", time.Minute, time.Hour); err != nil || rec != nil {
+		t.Fatalf("зависший ключ должен захватываться: %+v %v", rec, err)
+	}
+
+	// Истёкший ключ освобождается
+	db.Exec(`UPDATE idempotency_keys SET expires_at = now() - interval '1 second' WHERE key = 'k1'`)
+	if rec, err := store.Acquire(ctx, userID, "k1", "other", time.Minute, time.Hour); err != nil || rec != nil {
+		t.Fatalf("истёкший ключ должен захватываться заново: %+v %v", rec, err)
+	}
+
+	if err := store.Release(ctx, userID, "k1"); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	db.Exec(`UPDATE idempotency_keys SET expires_at = now() - interval '1 second'`)
+	if n, err := store.DeleteExpired(ctx); err != nil || n != 1 {
+		t.Errorf("DeleteExpired: ожидали удалить 1 ключ, удалено %d: %v", n, err)
+	}
+}
@@ -0,0 +1,146 @@
+// Package idempotency реализует заголовок Idempotency-Key: повтор запроса с тем же
+// ключом возвращает сохранённый ответ вместо повторного выполнения.
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/monst/story-craft/services/user-profile-service/logging"
+	"github.com/monst/story-craft/services/user-profile-service/middleware"
+)
+
+const (
+	// Header — заголовок с ключом идемпотентности от клиента.
+	Header = "Idempotency-Key"
+	// ReplayedHeader выставляется в ответах, взятых из сохранённого результата.
+	ReplayedHeader = "Idempotent-Replayed"
+
+	maxKeyLength = 255
+	pollInterval = 50 * time.Millisecond
+)
+
+// Options — параметры обработки ключей.
+type Options struct {
+	// TTL — сколько хранится ответ на запрос с ключом.
+	TTL time.Duration
+	// LockTimeout — через сколько незавершённая обработка считается зависшей
+	// и ключ может забрать другой запрос.
+	LockTimeout time.Duration
+}
+
+// Middleware делает запросы с заголовком Idempotency-Key идемпотентными.
+// Ключ действует в пределах пользователя, запросы без ключа или без пользователя
+// обрабатываются как обычно.
+//
+//   - повтор с тем же телом получает сохранённый ответ с Idempotent-Replayed: true;
+//   - тот же ключ с другим телом или маршрутом отклоняется с 422;
+//   - параллельный дубликат ждёт завершения первого запроса, а если ожидание
+//     превысило дедлайн запроса, получает 409 и Retry-After.
+//
+// Ответы 5xx не сохраняются, чтобы после сбоя запрос можно было повторить.
+func Middleware(store Store, opts Options) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(Header)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxKeyLength {
+			middleware.AbortWithError(c, http.StatusBadRequest, "Idempotency-Key не может быть длиннее 255 символов")
+			return
+		}
+		principal, ok := middleware.CurrentPrincipal(c)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			middleware.AbortWithError(c, http.StatusBadRequest, "Не удалось прочитать тело запроса")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		hash := requestHash(c.Request.Method, c.FullPath(), body)
+
+		ctx := c.Request.Context()
+		log := logging.FromContext(ctx)
+		for {
+			rec, err := store.Acquire(ctx, principal.UserID, key, hash, opts.LockTimeout, opts.TTL)
+			if err != nil {
+				log.Error("Не удалось проверить Idempotency-Key", slog.Any("error", err))
+				middleware.AbortWithError(c, http.StatusInternalServerError, "Не удалось проверить Idempotency-Key")
+				return
+			}
+			if rec == nil {
+				break
+			}
+			if rec.RequestHash != hash {
+				middleware.AbortWithError(c, http.StatusUnprocessableEntity, "Idempotency-Key уже использован с другим запросом")
+				return
+			}
+			if rec.Completed {
+				c.Header(ReplayedHeader, "true")
+				c.Data(rec.StatusCode, rec.ContentType, rec.ResponseBody)
+				c.Abort()
+				return
+			}
+
+			// Первый запрос ещё выполняется — ждём его результата
+			select {
+			case <-ctx.Done():
+				c.Header("Retry-After", "1")
+				middleware.AbortWithError(c, http.StatusConflict, "Запрос с этим Idempotency-Key ещё выполняется")
+				return
+			case <-time.After(pollInterval):
+			}
+		}
+
+		w := &captureWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+
+		// Результат сохраняется, даже если клиент уже отключился
+		saveCtx := context.WithoutCancel(ctx)
+		if status := w.Status(); status >= http.StatusInternalServerError {
+			err = store.Release(saveCtx, principal.UserID, key)
+		} else {
+			err = store.Complete(saveCtx, principal.UserID, key, status, w.Header().Get("Content-Type"), w.body.Bytes())
+		}
+		if err != nil {
+			log.Error("Не удалось сохранить результат для Idempotency-Key", slog.Any("error", err))
+		}
+	}
+}
+
+// requestHash связывает ключ с конкретным запросом: маршрутом и телом.
+func requestHash(method, route string, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, method+" "+route+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// captureWriter копирует тело ответа, чтобы сохранить его для повторов.
+type captureWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
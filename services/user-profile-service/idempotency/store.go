@@ -0,0 +1,126 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// Store хранит ключи идемпотентности.
+type Store interface {
+	// Acquire пытается начать обработку запроса с ключом key. Если ключ свободен,
+	// истёк или предыдущая обработка не завершилась за lockTimeout, возвращает nil:
+	// запрос можно выполнять. Иначе возвращает существующую запись.
+	Acquire(ctx context.Context, userID, key, hash string, lockTimeout, ttl time.Duration) (*models.IdempotencyKey, error)
+	// Complete сохраняет ответ, который будет возвращаться на повторы.
+	Complete(ctx context.Context, userID, key string, status int, contentType string, body []byte) error
+	// Release освобождает ключ без сохранения ответа, чтобы запрос можно было повторить.
+	Release(ctx context.Context, userID, key string) error
+}
+
+// DBStore хранит ключи в PostgreSQL, поэтому повторы распознаются любым экземпляром сервиса.
+// Время берётся из now() базы, чтобы часы экземпляров не влияли на TTL и блокировки.
+type DBStore struct {
+	db *gorm.DB
+}
+
+func NewDBStore(db *gorm.DB) *DBStore {
+	return &DBStore{db: db}
+}
+
+func (s *DBStore) session(ctx context.Context) *gorm.DB {
+	return s.db.WithContext(ctx).Clauses(dbresolver.Write).Session(&gorm.Session{})
+}
+
+func (s *DBStore) Acquire(ctx context.Context, userID, key, hash string, lockTimeout, ttl time.Duration) (*models.IdempotencyKey, error) {
+	db := s.session(ctx)
+
+	// Истёкший ключ считается свободным
+	if err := db.Where("user_id = ? AND key = ? AND expires_at < now()", userID, key).
+		Delete(&models.IdempotencyKey{}).Error; err != nil {
+		return nil, err
+	}
+
+	res := db.Exec(`INSERT INTO idempotency_keys (user_id, key, request_hash, completed, locked_at, created_at, expires_at)
+		VALUES (?, ?, ?, false, now(), now(), now() + make_interval(secs => ?))
+		ON CONFLICT DO NOTHING`, userID, key, hash, ttl.Seconds())
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 1 {
+		return nil, nil
+	}
+
+	var rec models.IdempotencyKey
+	if err := db.Where("user_id = ? AND key = ?", userID, key).First(&rec).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Запись удалили между вставкой и чтением — пусть клиент повторит запрос
+			return nil, errors.New("ключ идемпотентности освобождён во время проверки")
+		}
+		return nil, err
+	}
+	if rec.Completed || rec.RequestHash != hash {
+		return &rec, nil
+	}
+
+	// Обработка зависла (например, экземпляр упал) — забираем ключ себе
+	res = db.Model(&models.IdempotencyKey{}).
+		Where("user_id = ? AND key = ? AND completed = false AND locked_at < now() - make_interval(secs => ?)", userID, key, lockTimeout.Seconds()).
+		Update("locked_at", gorm.Expr("now()"))
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 1 {
+		return nil, nil
+	}
+	return &rec, nil
+}
+
+func (s *DBStore) Complete(ctx context.Context, userID, key string, status int, contentType string, body []byte) error {
+	return s.session(ctx).Model(&models.IdempotencyKey{}).
+		Where("user_id = ? AND key = ?", userID, key).
+		Updates(map[string]any{
+			"completed":     true,
+			"status_code":   status,
+			"content_type":  contentType,
+			"response_body": body,
+		}).Error
+}
+
+func (s *DBStore) Release(ctx context.Context, userID, key string) error {
+	return s.session(ctx).
+		Where("user_id = ? AND key = ? AND completed = false", userID, key).
+		Delete(&models.IdempotencyKey{}).Error
+}
+
+// DeleteExpired удаляет ключи с истёкшим TTL.
+func (s *DBStore) DeleteExpired(ctx context.Context) (int64, error) {
+	res := s.session(ctx).Where("expires_at < now()").Delete(&models.IdempotencyKey{})
+	return res.RowsAffected, res.Error
+}
+
+// Cleanup возвращает фоновую задачу для lifecycle.Manager.Go, которая раз в interval
+// удаляет истёкшие ключи.
+func (s *DBStore) Cleanup(interval time.Duration) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ticker.C:
+				if n, err := s.DeleteExpired(ctx); err != nil && ctx.Err() == nil {
+					slog.Warn("Не удалось удалить истёкшие ключи идемпотентности", slog.Any("error", err))
+				} else if n > 0 {
+					slog.Debug("Удалены истёкшие ключи идемпотентности", slog.Int64("count", n))
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,247 @@
+// Package jobs — очередь фоновых задач в PostgreSQL. Задачи ставятся в транзакции
+// изменения, выполняются на любом экземпляре сервиса с повторами после ошибок,
+// а задачи по расписанию ставит cron.Scheduler.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/monst/story-craft/services/user-profile-service/audit"
+	"github.com/monst/story-craft/services/user-profile-service/config"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/plugin/dbresolver"
+)
+
+var (
+	ErrNotFound = errors.New("задача не найдена")
+	// ErrNotFailed — вручную повторяется только задача со статусом failed.
+	ErrNotFailed = errors.New("повторить можно только задачу, завершившуюся ошибкой")
+	// ErrDuplicate — задача с тем же ключом уникальности уже ждёт или выполняется.
+	ErrDuplicate = errors.New("задача с тем же ключом уже в очереди")
+)
+
+// Options — необязательные параметры постановки задачи.
+type Options struct {
+	// UniqueKey — пока задача с этим ключом ждёт или выполняется, такая же не ставится.
+	UniqueKey string
+	// RunAt — задача выполняется не раньше этого момента; по умолчанию сразу.
+	RunAt time.Time
+	// MaxAttempts — по умолчанию JOBS_MAX_ATTEMPTS.
+	MaxAttempts int
+}
+
+// Filter — условия списка задач для администратора.
+type Filter struct {
+	Status string
+	Kind   string
+	// BeforeID — вернуть задачи с id меньше указанного, 0 — с самой новой.
+	BeforeID int64
+	Limit    int
+}
+
+// Queue хранит задачи в таблице jobs.
+type Queue struct {
+	db  *gorm.DB
+	cfg config.JobsConfig
+}
+
+func NewQueue(db *gorm.DB, cfg config.JobsConfig) *Queue {
+	return &Queue{db: db, cfg: cfg}
+}
+
+func (q *Queue) session(ctx context.Context) *gorm.DB {
+	return q.db.WithContext(ctx).Clauses(dbresolver.Write).Session(&gorm.Session{})
+}
+
+// Enqueue ставит задачу kind с аргументами args, которые получит обработчик.
+// Вызывается в транзакции изменения, как mail.Queue.Enqueue: при откате задача
+// не появится. Возвращает false, если задача с тем же UniqueKey уже ждёт или выполняется.
+func (q *Queue) Enqueue(tx *gorm.DB, kind string, args any, opts Options) (bool, error) {
+	var raw any
+	if args != nil {
+		encoded, err := json.Marshal(args)
+		if err != nil {
+			return false, err
+		}
+		raw = string(encoded)
+	}
+	var uniqueKey *string
+	if opts.UniqueKey != "" {
+		uniqueKey = &opts.UniqueKey
+	}
+	var runAt *time.Time
+	if !opts.RunAt.IsZero() {
+		at := opts.RunAt.UTC()
+		runAt = &at
+	}
+	maxAttempts := opts.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = q.cfg.MaxAttempts
+	}
+
+	var id int64
+	err := tx.Raw(`INSERT INTO jobs (kind, unique_key, payload, status, max_attempts, run_at, created_at)
+		VALUES (?, ?, ?, ?, ?, COALESCE(?, now()), now())
+		ON CONFLICT (unique_key) WHERE unique_key IS NOT NULL AND (status = 'pending' OR status = 'running') DO NOTHING
+		RETURNING id`,
+		kind, uniqueKey, raw, models.JobPending, maxAttempts, runAt).Scan(&id).Error
+	return id != 0, err
+}
+
+// Claim забирает до limit задач видов kinds, срок которых наступил; задачи других
+// видов остаются экземплярам, которые их знают, например при обновлении сервиса.
+// Забранные задачи получают статус running до истечения аренды — двойного JOBS_TIMEOUT,
+// чтобы исполнитель успел сохранить результат попытки, прерванной по таймауту. Если
+// экземпляр сервиса остановится, не отметив результат, задачи снова попадут в выдачу.
+// SKIP LOCKED не даёт двум экземплярам забрать одну задачу.
+func (q *Queue) Claim(ctx context.Context, kinds []string, limit int) ([]models.Job, error) {
+	lease := 2 * q.cfg.Timeout
+	var claimed []models.Job
+	err := q.session(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status IN ? AND run_at <= now() AND kind IN ?", []string{models.JobPending, models.JobRunning}, kinds).
+			Order("run_at").Limit(limit).Find(&claimed).Error; err != nil {
+			return err
+		}
+		if len(claimed) == 0 {
+			return nil
+		}
+		ids := make([]int64, 0, len(claimed))
+		for _, j := range claimed {
+			ids = append(ids, j.ID)
+		}
+		return tx.Model(&models.Job{}).Where("id IN ?", ids).Updates(map[string]any{
+			"status":   models.JobRunning,
+			"attempts": gorm.Expr("attempts + 1"),
+			"run_at":   gorm.Expr("now() + make_interval(secs => ?)", lease.Seconds()),
+		}).Error
+	})
+	for i := range claimed {
+		claimed[i].Status = models.JobRunning
+		claimed[i].Attempts++
+	}
+	return claimed, err
+}
+
+// markDone отмечает задачу выполненной.
+func (q *Queue) markDone(ctx context.Context, id int64) error {
+	return q.finish(ctx, id, map[string]any{
+		"status":      models.JobDone,
+		"finished_at": gorm.Expr("now()"),
+		"last_error":  "",
+	})
+}
+
+// markRetry возвращает задачу в очередь через delay после ошибки.
+func (q *Queue) markRetry(ctx context.Context, id int64, delay time.Duration, reason string) error {
+	return q.finish(ctx, id, map[string]any{
+		"status":     models.JobPending,
+		"run_at":     gorm.Expr("now() + make_interval(secs => ?)", delay.Seconds()),
+		"last_error": reason,
+	})
+}
+
+// markReleased возвращает задачу, прерванную остановкой сервиса, в очередь без учёта попытки.
+func (q *Queue) markReleased(ctx context.Context, id int64) error {
+	return q.finish(ctx, id, map[string]any{
+		"status":   models.JobPending,
+		"attempts": gorm.Expr("GREATEST(attempts - 1, 0)"),
+		"run_at":   gorm.Expr("now()"),
+	})
+}
+
+// markFailed завершает задачу с ошибкой: попытки закончились или ошибка постоянная.
+func (q *Queue) markFailed(ctx context.Context, id int64, reason string) error {
+	return q.finish(ctx, id, map[string]any{
+		"status":      models.JobFailed,
+		"finished_at": gorm.Expr("now()"),
+		"last_error":  reason,
+	})
+}
+
+func (q *Queue) finish(ctx context.Context, id int64, updates map[string]any) error {
+	return q.session(ctx).Model(&models.Job{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// List возвращает задачи от новых к старым.
+func (q *Queue) List(ctx context.Context, f Filter) ([]models.Job, error) {
+	query := q.session(ctx).Order("id DESC").Limit(f.Limit)
+	if f.Status != "" {
+		query = query.Where("status = ?", f.Status)
+	}
+	if f.Kind != "" {
+		query = query.Where("kind = ?", f.Kind)
+	}
+	if f.BeforeID > 0 {
+		query = query.Where("id < ?", f.BeforeID)
+	}
+	list := []models.Job{}
+	err := query.Find(&list).Error
+	return list, err
+}
+
+// Get возвращает задачу по id или ErrNotFound.
+func (q *Queue) Get(ctx context.Context, id int64) (models.Job, error) {
+	var job models.Job
+	err := q.session(ctx).Where("id = ?", id).First(&job).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return job, ErrNotFound
+	}
+	return job, err
+}
+
+// Retry возвращает задачу со статусом failed в очередь с новым набором попыток
+// и записывает это в журнал. Задачу с ключом уникальности нельзя повторить,
+// пока такая же ждёт или выполняется.
+func (q *Queue) Retry(ctx context.Context, actor audit.Actor, id int64) (models.Job, error) {
+	var job models.Job
+	err := q.session(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&job).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNotFound
+			}
+			return err
+		}
+		if job.Status != models.JobFailed {
+			return ErrNotFailed
+		}
+		if job.UniqueKey != nil {
+			var active int64
+			if err := tx.Model(&models.Job{}).Where("unique_key = ? AND status IN ?", *job.UniqueKey,
+				[]string{models.JobPending, models.JobRunning}).Count(&active).Error; err != nil {
+				return err
+			}
+			if active > 0 {
+				return ErrDuplicate
+			}
+		}
+
+		if err := tx.Model(&job).Clauses(clause.Returning{}).Updates(map[string]any{
+			"status":      models.JobPending,
+			"attempts":    0,
+			"run_at":      gorm.Expr("now()"),
+			"finished_at": nil,
+		}).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Entry{
+			Actor:   actor,
+			Action:  audit.ActionJobRetried,
+			Details: map[string]any{"job_id": job.ID, "kind": job.Kind, "last_error": job.LastError},
+		})
+	})
+	return job, err
+}
+
+// Cleanup удаляет завершённые задачи старше JOBS_RETENTION. Возвращает число удалённых.
+func (q *Queue) Cleanup(ctx context.Context) (int64, error) {
+	res := q.session(ctx).
+		Where("status IN ? AND finished_at < ?", []string{models.JobDone, models.JobFailed}, time.Now().UTC().Add(-q.cfg.Retention)).
+		Delete(&models.Job{})
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,206 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/monst/story-craft/services/user-profile-service/config"
+	"github.com/monst/story-craft/services/user-profile-service/cron"
+	"github.com/monst/story-craft/services/user-profile-service/metrics"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+)
+
+// Результаты попытки задачи для метрик.
+const (
+	ResultDone   = "done"
+	ResultRetry  = "retry"
+	ResultFailed = "failed"
+	// ResultInterrupted — сервис остановился раньше, чем задача завершилась; она вернулась в очередь.
+	ResultInterrupted = "interrupted"
+)
+
+// KindCleanup — задача удаления завершённых задач старше JOBS_RETENTION.
+const KindCleanup = "jobs.cleanup"
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent помечает ошибку обработчика постоянной: задача сразу получает статус
+// failed без повторов, например если её данные больше не существуют.
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+// IsPermanent сообщает, что повтор задачи не поможет.
+func IsPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
+
+type handler func(ctx context.Context, payload json.RawMessage) error
+
+// Worker выполняет задачи из очереди.
+type Worker struct {
+	queue    *Queue
+	cfg      config.JobsConfig
+	metrics  *metrics.Metrics
+	handlers map[string]handler
+	kinds    []string
+}
+
+func NewWorker(queue *Queue, cfg config.JobsConfig, m *metrics.Metrics) *Worker {
+	return &Worker{queue: queue, cfg: cfg, metrics: m, handlers: map[string]handler{}}
+}
+
+// Register добавляет обработчик задач kind с аргументами типа T: аргументы,
+// переданные в Queue.Enqueue, разбираются из JSON. Вызывается до Run.
+// Задача выполнится повторно, если экземпляр сервиса остановится раньше, чем
+// сохранит её результат, поэтому обработчик должен быть идемпотентным.
+func Register[T any](w *Worker, kind string, handle func(ctx context.Context, args T) error) {
+	if _, ok := w.handlers[kind]; !ok {
+		w.kinds = append(w.kinds, kind)
+		sort.Strings(w.kinds)
+	}
+	w.handlers[kind] = func(ctx context.Context, payload json.RawMessage) error {
+		var args T
+		if len(payload) > 0 {
+			if err := json.Unmarshal(payload, &args); err != nil {
+				return Permanent(fmt.Errorf("неверные аргументы задачи: %w", err))
+			}
+		}
+		return handle(ctx, args)
+	}
+}
+
+// Schedule добавляет в планировщик задачу name, которая по расписанию ставит в очередь
+// задачу kind с аргументами args. Ключ уникальности не даёт поставить следующий запуск,
+// пока предыдущий ещё ждёт или выполняется.
+func (q *Queue) Schedule(s *cron.Scheduler, name string, schedule cron.Schedule, kind string, args any) {
+	s.Add(name, schedule, func(ctx context.Context) error {
+		_, err := q.Enqueue(q.session(ctx), kind, args, Options{UniqueKey: "cron:" + name})
+		return err
+	})
+}
+
+// Run — фоновая задача для lifecycle.Manager.Go: забирает задачи, пока есть свободные
+// из JOBS_CONCURRENCY исполнителей, и проверяет очередь раз в JOBS_POLL_INTERVAL.
+// При остановке новые задачи не забираются, а выполняемые получают JOBS_DRAIN_TIMEOUT
+// на завершение; после этого их контекст отменяется, и они возвращаются в очередь.
+func (w *Worker) Run(ctx context.Context) error {
+	// Задачи выполняются в своём контексте, чтобы остановка не прерывала их сразу
+	jobCtx, cancelJobs := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelJobs()
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, w.cfg.Concurrency)
+	finished := make(chan struct{}, 1)
+	ticker := time.NewTicker(w.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for ctx.Err() == nil {
+		free := cap(slots) - len(slots)
+		if free > 0 && len(w.kinds) > 0 {
+			claimed, err := w.queue.Claim(ctx, w.kinds, free)
+			if err != nil && ctx.Err() == nil {
+				slog.Warn("Не удалось забрать задачи из очереди", slog.Any("error", err))
+			}
+			for _, job := range claimed {
+				slots <- struct{}{}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					w.execute(jobCtx, job)
+					<-slots
+					select {
+					case finished <- struct{}{}:
+					default:
+					}
+				}()
+			}
+			// Забрали всё, что могли: в очереди могут быть ещё задачи
+			if err == nil && len(claimed) == free {
+				continue
+			}
+		}
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		case <-finished:
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	timer := time.NewTimer(w.cfg.DrainTimeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+		slog.Warn("Фоновые задачи не завершились за JOBS_DRAIN_TIMEOUT и возвращаются в очередь")
+		cancelJobs()
+		<-done
+	}
+	return ctx.Err()
+}
+
+// execute выполняет одну попытку задачи и сохраняет результат. Результат сохраняется
+// и после отмены ctx при остановке.
+func (w *Worker) execute(ctx context.Context, job models.Job) {
+	start := time.Now()
+	runCtx, cancel := context.WithTimeout(ctx, w.cfg.Timeout)
+	err := w.call(runCtx, job)
+	cancel()
+
+	storeCtx := context.WithoutCancel(ctx)
+	var result string
+	var storeErr error
+	switch {
+	case err == nil:
+		result, storeErr = ResultDone, w.queue.markDone(storeCtx, job.ID)
+	case ctx.Err() != nil:
+		result, storeErr = ResultInterrupted, w.queue.markReleased(storeCtx, job.ID)
+	case IsPermanent(err) || job.Attempts >= job.MaxAttempts:
+		slog.Warn("Фоновая задача завершилась ошибкой", slog.Int64("job_id", job.ID), slog.String("kind", job.Kind),
+			slog.Int("attempts", job.Attempts), slog.Any("error", err))
+		result, storeErr = ResultFailed, w.queue.markFailed(storeCtx, job.ID, err.Error())
+	default:
+		result, storeErr = ResultRetry, w.queue.markRetry(storeCtx, job.ID, w.backoff(job.Attempts), err.Error())
+	}
+	w.metrics.Job(job.Kind, result, time.Since(start))
+	if storeErr != nil {
+		slog.Warn("Не удалось сохранить результат фоновой задачи", slog.Int64("job_id", job.ID), slog.Any("error", storeErr))
+	}
+}
+
+// call вызывает обработчик задачи; паника обработчика считается ошибкой попытки.
+func (w *Worker) call(ctx context.Context, job models.Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("паника в обработчике: %v", r)
+		}
+	}()
+	return w.handlers[job.Kind](ctx, job.Payload)
+}
+
+// backoff — пауза перед повтором после attempt неудачных попыток: удваивается от
+// JOBS_RETRY_BACKOFF до JOBS_MAX_RETRY_BACKOFF.
+func (w *Worker) backoff(attempt int) time.Duration {
+	delay := w.cfg.RetryBackoff
+	for i := 1; i < attempt && delay < w.cfg.MaxRetryBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, w.cfg.MaxRetryBackoff)
+}
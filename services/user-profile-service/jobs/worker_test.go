@@ -0,0 +1,267 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/monst/story-craft/services/user-profile-service/audit"
+	"github.com/monst/story-craft/services/user-profile-service/config"
+	"github.com/monst/story-craft/services/user-profile-service/metrics"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"github.com/monst/story-craft/services/user-profile-service/testutil"
+	"gorm.io/gorm"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(testutil.RunWithPostgres(m))
+}
+
+func testJobsConfig() config.JobsConfig {
+	return config.JobsConfig{
+		Concurrency:     2,
+		PollInterval:    10 * time.Millisecond,
+		Timeout:         time.Minute,
+		MaxAttempts:     2,
+		RetryBackoff:    time.Millisecond,
+		MaxRetryBackoff: time.Millisecond,
+		DrainTimeout:    time.Second,
+		Retention:       time.Hour,
+	}
+}
+
+func jobStatus(t *testing.T, db *gorm.DB, id int64) models.Job {
+	t.Helper()
+	var job models.Job
+	if err := db.First(&job, id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+func TestBackoff(t *testing.T) {
+	w := NewWorker(nil, config.JobsConfig{RetryBackoff: 10 * time.Second, MaxRetryBackoff: time.Minute}, metrics.New())
+	want := []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second, time.Minute, time.Minute}
+	for i, d := range want {
+		if got := w.backoff(i + 1); got != d {
+			t.Errorf("попытка %d: ожидали %s, получили %s", i+1, d, got)
+		}
+	}
+}
+
+func TestPermanent(t *testing.T) {
+	base := errors.New("профиль удалён")
+	err := fmt.Errorf("обработка: %w", Permanent(base))
+	if !IsPermanent(err) || !errors.Is(err, base) {
+		t.Errorf("обёрнутая постоянная ошибка должна распознаваться: %v", err)
+	}
+	if IsPermanent(base) {
+		t.Error("обычная ошибка не постоянная")
+	}
+}
+
+func TestWorkerExecute(t *testing.T) {
+	db := testutil.NewDB(t)
+	cfg := testJobsConfig()
+	queue := NewQueue(db, cfg)
+	worker := NewWorker(queue, cfg, metrics.New())
+	ctx := context.Background()
+
+	type greeting struct {
+		Name string `json:"name"`
+	}
+	var got []string
+	Register(worker, "greet", func(_ context.Context, args greeting) error {
+		got = append(got, args.Name)
+		return nil
+	})
+	calls := 0
+	Register(worker, "flaky", func(context.Context, struct{}) error {
+		calls++
+		return errors.New("временная ошибка")
+	})
+	Register(worker, "broken", func(context.Context, struct{}) error {
+		return Permanent(errors.New("данных больше нет"))
+	})
+	Register(worker, "panics", func(context.Context, struct{}) error {
+		panic("сбой")
+	})
+
+	enqueue := func(kind string, args any) int64 {
+		t.Helper()
+		if ok, err := queue.Enqueue(db, kind, args, Options{}); err != nil || !ok {
+			t.Fatalf("%s: не удалось поставить задачу: %v", kind, err)
+		}
+		var job models.Job
+		db.Where("kind = ?", kind).Order("id DESC").First(&job)
+		return job.ID
+	}
+	greet := enqueue("greet", greeting{Name: "Alice"})
+	flaky := enqueue("flaky", nil)
+	broken := enqueue("broken", nil)
+	panics := enqueue("panics", nil)
+	// Задачи незнакомого вида остаются другим экземплярам
+	unknown := enqueue("unknown", nil)
+
+	// Задачи со сроком в будущем не забираются
+	if _, err := queue.Enqueue(db, "greet", greeting{Name: "Bob"}, Options{RunAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func() int {
+		t.Helper()
+		claimed, err := queue.Claim(ctx, worker.kinds, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, job := range claimed {
+			worker.execute(ctx, job)
+		}
+		return len(claimed)
+	}
+	if n := run(); n != 4 {
+		t.Fatalf("ожидали четыре задачи, забрано %d", n)
+	}
+	if len(got) != 1 || got[0] != "Alice" || jobStatus(t, db, greet).Status != models.JobDone {
+		t.Errorf("обработчик должен получить аргументы: %v, %+v", got, jobStatus(t, db, greet))
+	}
+	if job := jobStatus(t, db, flaky); job.Status != models.JobPending || job.Attempts != 1 || job.LastError == "" {
+		t.Errorf("после первой ошибки задача должна ждать повтора: %+v", job)
+	}
+	if job := jobStatus(t, db, broken); job.Status != models.JobFailed || job.Attempts != 1 {
+		t.Errorf("постоянная ошибка не повторяется: %+v", job)
+	}
+	if job := jobStatus(t, db, panics); job.Status != models.JobPending || job.LastError == "" {
+		t.Errorf("паника считается ошибкой попытки: %+v", job)
+	}
+	if job := jobStatus(t, db, unknown); job.Status != models.JobPending || job.Attempts != 0 {
+		t.Errorf("задачу незнакомого вида нельзя забирать: %+v", job)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	run()
+	if job := jobStatus(t, db, flaky); job.Status != models.JobFailed || job.Attempts != 2 || job.FinishedAt == nil || calls != 2 {
+		t.Errorf("после JOBS_MAX_ATTEMPTS попыток задача завершается ошибкой: %+v, вызовов %d", job, calls)
+	}
+}
+
+func TestEnqueueUnique(t *testing.T) {
+	db := testutil.NewDB(t)
+	queue := NewQueue(db, testJobsConfig())
+
+	opts := Options{UniqueKey: "cron:digest"}
+	for i, want := range []bool{true, false} {
+		if ok, err := queue.Enqueue(db, "digest.send", nil, opts); err != nil || ok != want {
+			t.Fatalf("постановка %d: ожидали %v, получили %v, %v", i+1, want, ok, err)
+		}
+	}
+	// После завершения задачи ключ снова свободен
+	if err := db.Model(&models.Job{}).Where("unique_key = ?", opts.UniqueKey).Update("status", models.JobDone).Error; err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := queue.Enqueue(db, "digest.send", nil, opts); err != nil || !ok {
+		t.Fatalf("после завершения ожидали новую задачу: %v, %v", ok, err)
+	}
+
+	// Задача, поставленная в откатившейся транзакции, не появляется
+	db.Transaction(func(tx *gorm.DB) error {
+		queue.Enqueue(tx, "rolled-back", nil, Options{})
+		return errors.New("откат")
+	})
+	var n int64
+	db.Model(&models.Job{}).Where("kind = ?", "rolled-back").Count(&n)
+	if n != 0 {
+		t.Error("задача из откатившейся транзакции попала в очередь")
+	}
+}
+
+func TestRunDrainsOnStop(t *testing.T) {
+	db := testutil.NewDB(t)
+	cfg := testJobsConfig()
+	queue := NewQueue(db, cfg)
+
+	started := make(chan struct{}, 2)
+	// Первая задача успевает завершиться за время ожидания, вторая — нет и возвращается в очередь
+	run := func(cfg config.JobsConfig, kind string, wait time.Duration) models.Job {
+		t.Helper()
+		worker := NewWorker(queue, cfg, metrics.New())
+		Register(worker, kind, func(ctx context.Context, _ struct{}) error {
+			started <- struct{}{}
+			select {
+			case <-time.After(wait):
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if _, err := queue.Enqueue(db, kind, nil, Options{}); err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() { done <- worker.Run(ctx) }()
+		<-started
+		cancel()
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Fatalf("ожидали context.Canceled, получили %v", err)
+		}
+		var job models.Job
+		db.Where("kind = ?", kind).First(&job)
+		return job
+	}
+
+	if job := run(cfg, "short", 50*time.Millisecond); job.Status != models.JobDone {
+		t.Errorf("задача должна завершиться во время остановки: %+v", job)
+	}
+	cfg.DrainTimeout = 10 * time.Millisecond
+	if job := run(cfg, "long", time.Hour); job.Status != models.JobPending || job.Attempts != 0 {
+		t.Errorf("прерванная задача должна вернуться в очередь без учёта попытки: %+v", job)
+	}
+}
+
+func TestRetryAndCleanup(t *testing.T) {
+	db := testutil.NewDB(t)
+	queue := NewQueue(db, testJobsConfig())
+	ctx := context.Background()
+	admin := audit.Actor{ID: "admin"}
+
+	key := "reconcile"
+	failed := models.Job{Kind: "reconcile", UniqueKey: &key, Status: models.JobFailed, Attempts: 2, MaxAttempts: 2, LastError: "сбой"}
+	done := models.Job{Kind: "reconcile", Status: models.JobDone, MaxAttempts: 2}
+	for _, job := range []*models.Job{&failed, &done} {
+		if err := db.Create(job).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Завершились раньше JOBS_RETENTION
+	db.Model(&models.Job{}).Where("id IN ?", []int64{failed.ID, done.ID}).Update("finished_at", time.Now().UTC().Add(-2*time.Hour))
+
+	if _, err := queue.Retry(ctx, admin, done.ID); !errors.Is(err, ErrNotFailed) {
+		t.Errorf("выполненную задачу нельзя повторить: %v", err)
+	}
+	if _, err := queue.Retry(ctx, admin, 1_000_000); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ожидали ErrNotFound, получили %v", err)
+	}
+	job, err := queue.Retry(ctx, admin, failed.ID)
+	if err != nil || job.Status != models.JobPending || job.Attempts != 0 || job.FinishedAt != nil {
+		t.Fatalf("задача должна вернуться в очередь: %+v, %v", job, err)
+	}
+
+	// Пока повтор ждёт в очереди, вторая задача с тем же ключом не повторяется
+	second := models.Job{Kind: "reconcile", UniqueKey: &key, Status: models.JobFailed, MaxAttempts: 2}
+	db.Create(&second)
+	if _, err := queue.Retry(ctx, admin, second.ID); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("ожидали ErrDuplicate, получили %v", err)
+	}
+
+	n, err := queue.Cleanup(ctx)
+	if err != nil || n != 1 {
+		t.Errorf("ожидали удаление одной выполненной задачи, удалено %d, %v", n, err)
+	}
+	if _, err := queue.Get(ctx, failed.ID); err != nil {
+		t.Errorf("повторённая задача не должна удаляться: %v", err)
+	}
+}
@@ -0,0 +1,195 @@
+// Package lifecycle управляет запуском и упорядоченной остановкой сервиса:
+// HTTP-сервером, фоновыми задачами и ресурсами вроде пула соединений с БД.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// State — состояние фоновой задачи.
+type State string
+
+const (
+	StateRunning  State = "running"
+	StateStopping State = "stopping"
+	StateStopped  State = "stopped"
+	StateFailed   State = "failed"
+)
+
+// WorkerStatus — снимок состояния фоновой задачи.
+type WorkerStatus struct {
+	Name      string    `json:"name"`
+	State     State     `json:"state"`
+	Error     string    `json:"error,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+type worker struct {
+	status WorkerStatus
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+type hook struct {
+	name string
+	stop func(context.Context) error
+}
+
+// Manager хранит зарегистрированные фоновые задачи и обработчики остановки.
+// При остановке они вызываются в порядке, обратном регистрации: то, что
+// зарегистрировано первым (например, пул БД), закрывается последним.
+type Manager struct {
+	mu      sync.Mutex
+	hooks   []hook
+	workers []*worker
+	stopped bool
+}
+
+func New() *Manager {
+	return &Manager{}
+}
+
+// OnStop регистрирует функцию, которая будет вызвана при остановке сервиса.
+func (m *Manager) OnStop(name string, stop func(context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hooks = append(m.hooks, hook{name: name, stop: stop})
+}
+
+// Go запускает фоновую задачу. При остановке её контекст отменяется, и Manager
+// ждёт завершения run, но не дольше оставшегося времени на остановку.
+func (m *Manager) Go(name string, run func(ctx context.Context) error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &worker{
+		status: WorkerStatus{Name: name, State: StateRunning, StartedAt: time.Now()},
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	m.mu.Lock()
+	m.workers = append(m.workers, w)
+	m.mu.Unlock()
+
+	go func() {
+		defer close(w.done)
+		err := run(ctx)
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if err != nil && !errors.Is(err, context.Canceled) {
+			w.status.State = StateFailed
+			w.status.Error = err.Error()
+			slog.Error("Фоновая задача завершилась с ошибкой", slog.String("worker", name), slog.Any("error", err))
+			return
+		}
+		w.status.State = StateStopped
+	}()
+
+	m.OnStop(name, func(stopCtx context.Context) error {
+		m.setState(w, StateStopping)
+		cancel()
+		select {
+		case <-w.done:
+			return nil
+		case <-stopCtx.Done():
+			return fmt.Errorf("задача не завершилась вовремя: %w", stopCtx.Err())
+		}
+	})
+}
+
+func (m *Manager) setState(w *worker, state State) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if w.status.State == StateRunning {
+		w.status.State = state
+	}
+}
+
+// Workers возвращает состояние всех фоновых задач.
+func (m *Manager) Workers() []WorkerStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	statuses := make([]WorkerStatus, len(m.workers))
+	for i, w := range m.workers {
+		statuses[i] = w.status
+	}
+	return statuses
+}
+
+// Shutdown вызывает обработчики остановки в обратном порядке. Повторные вызовы ничего не делают.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	if m.stopped {
+		m.mu.Unlock()
+		return nil
+	}
+	m.stopped = true
+	hooks := m.hooks
+	m.mu.Unlock()
+
+	var errs []error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		h := hooks[i]
+		if err := h.stop(ctx); err != nil {
+			slog.Error("Ошибка при остановке", slog.String("component", h.name), slog.Any("error", err))
+			errs = append(errs, fmt.Errorf("%s: %w", h.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Run запускает HTTP-сервер на адресе srv.Addr и блокируется до SIGINT/SIGTERM
+// или отмены ctx. Затем сервер перестаёт принимать новые соединения и дожидается
+// текущих запросов, после чего останавливается всё остальное. На всю остановку
+// отводится grace.
+func (m *Manager) Run(ctx context.Context, srv *http.Server, grace time.Duration) error {
+	ln, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		return err
+	}
+	return m.Serve(ctx, srv, ln, grace)
+}
+
+// Serve — то же, что Run, но на уже открытом ln.
+func (m *Manager) Serve(ctx context.Context, srv *http.Server, ln net.Listener, grace time.Duration) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// HTTP-сервер регистрируется последним, поэтому останавливается первым:
+	// пока идут запросы, фоновые задачи и пул БД ещё доступны
+	m.OnStop("http", srv.Shutdown)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.Serve(ln)
+	}()
+
+	var runErr error
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			runErr = err
+		}
+	case <-ctx.Done():
+		slog.Info("Получен сигнал остановки, завершаем обработку запросов", slog.Duration("grace_period", grace))
+	}
+	// Повторный сигнал завершит процесс сразу, не дожидаясь корректной остановки
+	stop()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+	if err := m.Shutdown(shutdownCtx); err != nil {
+		return errors.Join(runErr, err)
+	}
+	return runErr
+}
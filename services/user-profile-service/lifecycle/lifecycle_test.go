@@ -0,0 +1,163 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// startServer запускает Manager.Serve с обработчиком /slow, который отвечает
+// только после закрытия release. Возвращает адрес сервера и канал с результатом Serve.
+func startServer(t *testing.T, m *Manager, started chan<- struct{}, release <-chan struct{}, grace time.Duration) (string, <-chan error) {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		io.WriteString(w, "done")
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: mux}
+
+	result := make(chan error, 1)
+	go func() { result <- m.Serve(context.Background(), srv, ln, grace) }()
+	return "http://" + ln.Addr().String(), result
+}
+
+func TestSignalDrainsInFlightRequests(t *testing.T) {
+	m := New()
+
+	var mu sync.Mutex
+	var order []string
+	record := func(name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, name)
+	}
+
+	m.OnStop("database", func(context.Context) error {
+		record("database")
+		return nil
+	})
+	m.Go("worker", func(ctx context.Context) error {
+		<-ctx.Done()
+		record("worker")
+		return ctx.Err()
+	})
+
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	addr, result := startServer(t, m, started, release, 5*time.Second)
+
+	type response struct {
+		body string
+		err  error
+	}
+	inFlight := make(chan response, 1)
+	go func() {
+		resp, err := http.Get(addr + "/slow")
+		if err != nil {
+			inFlight <- response{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		inFlight <- response{body: string(body), err: err}
+	}()
+
+	<-started
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+
+	// Пока запрос не завершён, сервер не должен останавливаться
+	select {
+	case err := <-result:
+		t.Fatalf("Serve завершился до окончания запроса: %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// Новые соединения уже не принимаются
+	client := &http.Client{Timeout: time.Second}
+	if resp, err := client.Get(addr + "/slow"); err == nil {
+		resp.Body.Close()
+		t.Error("после сигнала сервер не должен принимать новые запросы")
+	}
+
+	close(release)
+	got := <-inFlight
+	if got.err != nil || got.body != "done" {
+		t.Fatalf("запрос в процессе должен завершиться успешно: %+v", got)
+	}
+
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("Serve вернул ошибку: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve не завершился после сигнала")
+	}
+
+	if want := []string{"worker", "database"}; !slices.Equal(order, want) {
+		t.Errorf("порядок остановки: ожидали %v, получили %v", want, order)
+	}
+	if statuses := m.Workers(); statuses[0].State != StateStopped {
+		t.Errorf("задача должна быть остановлена, состояние %s", statuses[0].State)
+	}
+}
+
+func TestGracePeriodExpires(t *testing.T) {
+	m := New()
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	defer close(release)
+
+	addr, result := startServer(t, m, started, release, 100*time.Millisecond)
+	go http.Get(addr + "/slow")
+	<-started
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGINT); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("ожидали ошибку по истечении grace, получили %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve не завершился по истечении grace")
+	}
+}
+
+func TestWorkerFailureIsReported(t *testing.T) {
+	m := New()
+	m.Go("broken", func(context.Context) error { return errors.New("нет соединения") })
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if s := m.Workers()[0]; s.State == StateFailed {
+			if s.Error != "нет соединения" {
+				t.Errorf("текст ошибки: %q", s.Error)
+			}
+			if err := m.Shutdown(context.Background()); err != nil {
+				t.Errorf("остановка упавшей задачи не должна возвращать ошибку: %v", err)
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("состояние задачи не стало failed")
+}
@@ -0,0 +1,80 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// GormLogger передаёт сообщения GORM в slog. Запросы дольше slowThreshold
+// пишутся с уровнем WARN, ошибки — ERROR (кроме отсутствия записи), остальные — DEBUG.
+// Если в контексте запроса есть логгер, используется он, чтобы запись получила request_id.
+type GormLogger struct {
+	base          *slog.Logger
+	slowThreshold time.Duration
+	level         gormlogger.LogLevel
+}
+
+func NewGormLogger(base *slog.Logger, slowThreshold time.Duration) *GormLogger {
+	return &GormLogger{base: base, slowThreshold: slowThreshold, level: gormlogger.Info}
+}
+
+func (l *GormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	clone := *l
+	clone.level = level
+	return &clone
+}
+
+func (l *GormLogger) logger(ctx context.Context) *slog.Logger {
+	if ctx != nil {
+		if cl, ok := ctx.Value(ctxKey{}).(*slog.Logger); ok {
+			return cl
+		}
+	}
+	return l.base
+}
+
+func (l *GormLogger) Info(ctx context.Context, msg string, args ...any) {
+	if l.level >= gormlogger.Info {
+		l.logger(ctx).InfoContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *GormLogger) Warn(ctx context.Context, msg string, args ...any) {
+	if l.level >= gormlogger.Warn {
+		l.logger(ctx).WarnContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *GormLogger) Error(ctx context.Context, msg string, args ...any) {
+	if l.level >= gormlogger.Error {
+		l.logger(ctx).ErrorContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level <= gormlogger.Silent {
+		return
+	}
+	elapsed := time.Since(begin)
+	log := l.logger(ctx)
+
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && l.level >= gormlogger.Error:
+		sql, rows := fc()
+		log.ErrorContext(ctx, "Ошибка запроса к БД",
+			slog.String("sql", sql), slog.Int64("rows", rows), slog.Duration("elapsed", elapsed), slog.Any("error", err))
+	case l.slowThreshold > 0 && elapsed > l.slowThreshold && l.level >= gormlogger.Warn:
+		sql, rows := fc()
+		log.WarnContext(ctx, "Медленный запрос к БД",
+			slog.String("sql", sql), slog.Int64("rows", rows), slog.Duration("elapsed", elapsed), slog.Duration("threshold", l.slowThreshold))
+	case log.Enabled(ctx, slog.LevelDebug):
+		sql, rows := fc()
+		log.DebugContext(ctx, "Запрос к БД", slog.String("sql", sql), slog.Int64("rows", rows), slog.Duration("elapsed", elapsed))
+	}
+}
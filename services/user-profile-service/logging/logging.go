@@ -0,0 +1,55 @@
+// Package logging настраивает структурированное логирование на log/slog:
+// уровень и формат из конфигурации, маскирование персональных данных,
+// логгер запроса в контексте и адаптер логгера для GORM.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/monst/story-craft/services/user-profile-service/config"
+)
+
+// New создаёт логгер по настройкам. Все записи проходят через маскирование
+// email и токенов.
+func New(cfg config.LoggingConfig, w io.Writer) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: parseLevel(cfg.Level)}
+
+	var h slog.Handler
+	if cfg.Format == "text" {
+		h = slog.NewTextHandler(w, opts)
+	} else {
+		h = slog.NewJSONHandler(w, opts)
+	}
+	return slog.New(NewRedactHandler(h))
+}
+
+func parseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+type ctxKey struct{}
+
+// WithLogger кладёт логгер в контекст.
+func WithLogger(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// FromContext возвращает логгер запроса (с request_id и trace_id) или логгер по умолчанию.
+func FromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(ctxKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return slog.Default()
+}
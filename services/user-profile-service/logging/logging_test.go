@@ -0,0 +1,167 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/monst/story-craft/services/user-profile-service/config"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func newTestLogger(level string) (*slog.Logger, *bytes.Buffer) {
+	var buf bytes.Buffer
+	return New(config.LoggingConfig{Level: level, Format: "json"}, &buf), &buf
+}
+
+func decodeLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var rec map[string]any
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("строка лога не является JSON: %q: %v", line, err)
+		}
+		records = append(records, rec)
+	}
+	return records
+}
+
+func TestRedact(t *testing.T) {
+	cases := map[string]string{
+		"письмо для alice@example.com":             "письмо для a***@example.com",
+		"Authorization: Bearer abc.def-ghi":        "Authorization: Bearer [REDACTED]",
+		"token eyJhbGciOi.eyJzdWIiOi.c2lnbmF0dXJl": "token [REDACTED]",
+		"обычный текст без данных":                 "обычный текст без данных",
+	}
+	for in, want := range cases {
+		if got := Redact(in); got != want {
+			t.Errorf("Redact(%q) = %q, ожидалось %q", in, got, want)
+		}
+	}
+}
+
+func TestLoggerRedactsAttributes(t *testing.T) {
+	log, buf := newTestLogger("info")
+
+	log.With(slog.String("email", "bob@example.com")).Info("профиль создан для bob@example.com",
+		slog.String("password", "hunter2"),
+		slog.Group("request", slog.String("authorization", "Bearer secret")),
+		slog.Any("error", errors.New("duplicate key: carol@example.com")),
+	)
+
+	out := buf.String()
+	for _, leaked := range []string{"bob@example.com", "hunter2", "secret", "carol@example.com"} {
+		if strings.Contains(out, leaked) {
+			t.Errorf("в лог попало %q: %s", leaked, out)
+		}
+	}
+	rec := decodeLines(t, buf)[0]
+	if rec["email"] != "b***@example.com" {
+		t.Errorf("email должен быть замаскирован, получили %v", rec["email"])
+	}
+	if rec["password"] != "[REDACTED]" {
+		t.Errorf("пароль должен быть скрыт, получили %v", rec["password"])
+	}
+}
+
+func TestLoggerLevel(t *testing.T) {
+	log, buf := newTestLogger("warn")
+	log.Info("не должно попасть")
+	log.Warn("должно попасть")
+
+	records := decodeLines(t, buf)
+	if len(records) != 1 || records[0]["msg"] != "должно попасть" {
+		t.Errorf("уровень warn должен отсекать info, получили %v", records)
+	}
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	log, buf := newTestLogger("info")
+
+	r := gin.New()
+	r.Use(RequestID(log), AccessLog())
+	r.GET("/profiles/:user_id", func(c *gin.Context) {
+		FromContext(c.Request.Context()).Info("внутри обработчика")
+		c.Status(http.StatusNotFound)
+	})
+
+	t.Run("honours incoming header", func(t *testing.T) {
+		buf.Reset()
+		req := httptest.NewRequest(http.MethodGet, "/profiles/42", nil)
+		req.Header.Set(RequestIDHeader, "req-123")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if got := w.Header().Get(RequestIDHeader); got != "req-123" {
+			t.Errorf("ожидался X-Request-ID req-123, получили %q", got)
+		}
+		records := decodeLines(t, buf)
+		if len(records) != 2 {
+			t.Fatalf("ожидалось 2 записи (обработчик и access log), получили %d", len(records))
+		}
+		for _, rec := range records {
+			if rec["request_id"] != "req-123" {
+				t.Errorf("запись без request_id: %v", rec)
+			}
+		}
+		access := records[1]
+		if access["route"] != "/profiles/:user_id" || access["status"] != float64(404) || access["level"] != "WARN" {
+			t.Errorf("неожиданная запись access log: %v", access)
+		}
+	})
+
+	t.Run("generates id for invalid header", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/profiles/42", nil)
+		req.Header.Set(RequestIDHeader, "bad id\nwith newline")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		got := w.Header().Get(RequestIDHeader)
+		if got == "" || strings.Contains(got, " ") {
+			t.Errorf("ожидался сгенерированный X-Request-ID, получили %q", got)
+		}
+	})
+}
+
+func TestGormLoggerSlowQuery(t *testing.T) {
+	log, buf := newTestLogger("info")
+	gl := NewGormLogger(log, 100*time.Millisecond)
+
+	fc := func() (string, int64) { return `SELECT * FROM "user_profiles" WHERE email = 'dave@example.com'`, 1 }
+
+	gl.Trace(context.Background(), time.Now(), fc, nil)
+	if buf.Len() != 0 {
+		t.Errorf("быстрый запрос не должен попадать в лог на уровне info: %s", buf.String())
+	}
+
+	gl.Trace(context.Background(), time.Now().Add(-time.Second), fc, nil)
+	records := decodeLines(t, buf)
+	if len(records) != 1 || records[0]["level"] != "WARN" {
+		t.Fatalf("медленный запрос должен логироваться с уровнем WARN, получили %v", records)
+	}
+	if strings.Contains(buf.String(), "dave@example.com") {
+		t.Errorf("email в тексте запроса должен быть замаскирован: %s", buf.String())
+	}
+
+	buf.Reset()
+	gl.LogMode(gormlogger.Silent).Trace(context.Background(), time.Now().Add(-time.Second), fc, errors.New("boom"))
+	if buf.Len() != 0 {
+		t.Errorf("в режиме Silent лог должен быть пустым: %s", buf.String())
+	}
+}
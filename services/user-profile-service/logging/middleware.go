@@ -0,0 +1,75 @@
+package logging
+
+import (
+	"log/slog"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/monst/story-craft/services/user-profile-service/tracing"
+)
+
+const (
+	// RequestIDHeader — заголовок с идентификатором запроса.
+	RequestIDHeader = "X-Request-ID"
+	// RequestIDKey — ключ gin-контекста с идентификатором запроса.
+	RequestIDKey = "request_id"
+)
+
+// validRequestID ограничивает входящий X-Request-ID, чтобы в логи не попадал произвольный текст.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._\-]{1,128}$`)
+
+// RequestID берёт идентификатор запроса из X-Request-ID или генерирует новый,
+// возвращает его в ответе и кладёт в контекст логгер запроса с request_id и trace_id.
+// Подключается после tracing.Middleware, чтобы trace_id уже был известен.
+func RequestID(base *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = uuid.NewString()
+		}
+		c.Set(RequestIDKey, requestID)
+		c.Header(RequestIDHeader, requestID)
+
+		l := base.With(slog.String("request_id", requestID))
+		if traceID := tracing.TraceID(c.Request.Context()); traceID != "" {
+			l = l.With(slog.String("trace_id", traceID))
+		}
+		c.Request = c.Request.WithContext(WithLogger(c.Request.Context(), l))
+
+		c.Next()
+	}
+}
+
+// AccessLog пишет по одной записи на запрос: маршрут, статус, длительность.
+// Ответы 5xx пишутся с уровнем ERROR, 4xx — WARN.
+func AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("route", c.FullPath()),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+			slog.Int("bytes", c.Writer.Size()),
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("errors", c.Errors.String()))
+		}
+		FromContext(c.Request.Context()).LogAttrs(c.Request.Context(), level, "HTTP-запрос", attrs...)
+	}
+}
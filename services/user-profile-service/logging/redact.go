@@ -0,0 +1,105 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+var (
+	emailPattern  = regexp.MustCompile(`([A-Za-z0-9._%+\-])[A-Za-z0-9._%+\-]*@([A-Za-z0-9.\-]+\.[A-Za-z]{2,})`)
+	bearerPattern = regexp.MustCompile(`(?i)\b(bearer)\s+[A-Za-z0-9\-._~+/]+=*`)
+	jwtPattern    = regexp.MustCompile(`\beyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+`)
+)
+
+// sensitiveKeys — атрибуты, значения которых скрываются целиком.
+var sensitiveKeys = []string{"password", "token", "secret", "authorization", "cookie", "api_key", "apikey"}
+
+// Redact маскирует email (остаётся первая буква и домен), bearer-токены и JWT в строке.
+func Redact(s string) string {
+	s = jwtPattern.ReplaceAllString(s, redacted)
+	s = bearerPattern.ReplaceAllString(s, "$1 "+redacted)
+	return emailPattern.ReplaceAllString(s, "$1***@$2")
+}
+
+// MaskEmail маскирует адрес для логов: user@example.com -> u***@example.com.
+func MaskEmail(email string) string {
+	return Redact(email)
+}
+
+// RedactHandler маскирует персональные данные в сообщении и атрибутах перед
+// передачей записи во внутренний обработчик.
+type RedactHandler struct {
+	inner slog.Handler
+}
+
+func NewRedactHandler(inner slog.Handler) *RedactHandler {
+	return &RedactHandler{inner: inner}
+}
+
+func (h *RedactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h *RedactHandler) Handle(ctx context.Context, r slog.Record) error {
+	out := slog.NewRecord(r.Time, r.Level, Redact(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(redactAttr(a))
+		return true
+	})
+	return h.inner.Handle(ctx, out)
+}
+
+func (h *RedactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clean := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		clean[i] = redactAttr(a)
+	}
+	return &RedactHandler{inner: h.inner.WithAttrs(clean)}
+}
+
+func (h *RedactHandler) WithGroup(name string) slog.Handler {
+	return &RedactHandler{inner: h.inner.WithGroup(name)}
+}
+
+func redactAttr(a slog.Attr) slog.Attr {
+	if isSensitiveKey(a.Key) {
+		return slog.String(a.Key, redacted)
+	}
+
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, Redact(v.String()))
+	case slog.KindGroup:
+		group := v.Group()
+		clean := make([]any, len(group))
+		for i, ga := range group {
+			clean[i] = redactAttr(ga)
+		}
+		return slog.Group(a.Key, clean...)
+	case slog.KindAny:
+		// Ошибки и произвольные значения печатаются строкой, поэтому проверяем её
+		if err, ok := v.Any().(error); ok {
+			return slog.String(a.Key, Redact(err.Error()))
+		}
+		if s := fmt.Sprintf("%+v", v.Any()); Redact(s) != s {
+			return slog.String(a.Key, Redact(s))
+		}
+	}
+	return slog.Attr{Key: a.Key, Value: v}
+}
+
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, k := range sensitiveKeys {
+		if strings.Contains(key, k) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,296 @@
+package mail
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/monst/story-craft/services/user-profile-service/audit"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"github.com/monst/story-craft/services/user-profile-service/realtime"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrTokenExpired — срок ссылки подтверждения или отмены истёк.
+	ErrTokenExpired = errors.New("срок ссылки истёк")
+	// ErrChangeUsed — ссылка уже использована, заменена более новым запросом
+	// или адрес в профиле с тех пор изменился.
+	ErrChangeUsed = errors.New("ссылка уже использована или больше не действует")
+	// ErrEmailTaken — адрес уже указан в другом профиле.
+	ErrEmailTaken = errors.New("адрес уже используется другим профилем")
+	// ErrAlreadyVerified — текущий адрес уже подтверждён.
+	ErrAlreadyVerified = errors.New("адрес уже подтверждён")
+)
+
+// ConfirmToken подписывает ссылку подтверждения адреса из запроса changeID, действующую до expires.
+func ConfirmToken(key string, changeID int64, expires time.Time) string {
+	return changeToken(key, purposeConfirm, changeID, expires)
+}
+
+// RevertToken подписывает ссылку отмены смены адреса, действующую до expires.
+func RevertToken(key string, changeID int64, expires time.Time) string {
+	return changeToken(key, purposeRevert, changeID, expires)
+}
+
+func changeToken(key, purpose string, changeID int64, expires time.Time) string {
+	raw := strconv.FormatInt(changeID, 10) + ":" + strconv.FormatInt(expires.Unix(), 10)
+	payload := base64.RawURLEncoding.EncodeToString([]byte(raw))
+	return payload + "." + sign(key, purpose, payload)
+}
+
+// parseChangeToken проверяет подпись и срок ссылки и возвращает идентификатор запроса.
+// Срок проверяется до обращения к базе: по истёкшей ссылке запрос не ищется.
+func parseChangeToken(key, purpose, token string, now time.Time) (int64, error) {
+	raw, err := verify(key, purpose, token)
+	if err != nil {
+		return 0, err
+	}
+	idPart, expiresPart, ok := strings.Cut(raw, ":")
+	id, idErr := strconv.ParseInt(idPart, 10, 64)
+	expires, expErr := strconv.ParseInt(expiresPart, 10, 64)
+	if !ok || idErr != nil || expErr != nil || id <= 0 {
+		return 0, ErrInvalidToken
+	}
+	if now.Unix() >= expires {
+		return 0, ErrTokenExpired
+	}
+	return id, nil
+}
+
+// changeData — данные письма о смене адреса: ссылка собирается при отправке,
+// чтобы подписанный токен не хранился в очереди.
+type changeData struct {
+	ChangeID int64 `json:"change_id"`
+	// Address — куда отправить письмо: новый адрес для подтверждения, старый — для уведомления о смене.
+	Address   string    `json:"address"`
+	NewEmail  string    `json:"new_email"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// RequestChange запрашивает подтверждение адреса newEmail для профиля: адрес в профиле
+// пока не меняется, новый сохраняется в PendingEmail, а на него ставится письмо со ссылкой.
+// Если newEmail совпадает с текущим адресом, это подтверждение текущего адреса.
+// Прежние неподтверждённые запросы пользователя отменяются. Вызывается в транзакции изменения профиля.
+func (q *Queue) RequestChange(tx *gorm.DB, profile *models.Profile, newEmail string) error {
+	if err := q.checkAvailable(tx, newEmail, profile.UserID); err != nil {
+		return err
+	}
+	if err := cancelChanges(tx, profile.UserID); err != nil {
+		return err
+	}
+
+	change := models.EmailChange{
+		UserID:    profile.UserID,
+		OldEmail:  profile.Email,
+		NewEmail:  newEmail,
+		ExpiresAt: time.Now().Add(q.cfg.ChangeTTL).Truncate(time.Second),
+	}
+	if err := tx.Create(&change).Error; err != nil {
+		return err
+	}
+	pending := newEmail
+	if strings.EqualFold(newEmail, profile.Email) {
+		pending = ""
+	}
+	if profile.PendingEmail != pending {
+		if err := tx.Model(profile).UpdateColumn("pending_email", pending).Error; err != nil {
+			return err
+		}
+		profile.PendingEmail = pending
+	}
+	key := "email-change:" + strconv.FormatInt(change.ID, 10)
+	_, err := q.Enqueue(tx, profile.UserID, TemplateEmailConfirm, changeData{
+		ChangeID: change.ID, Address: newEmail, NewEmail: newEmail, ExpiresAt: change.ExpiresAt,
+	}, &key)
+	return err
+}
+
+// RequestVerification отправляет ссылку подтверждения текущего адреса пользователя.
+// Если профиля нет, возвращает gorm.ErrRecordNotFound.
+func (q *Queue) RequestVerification(ctx context.Context, userID string) error {
+	return q.session(ctx).Transaction(func(tx *gorm.DB) error {
+		var profile models.Profile
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ?", userID).First(&profile).Error; err != nil {
+			return err
+		}
+		if profile.EmailVerifiedAt != nil {
+			return ErrAlreadyVerified
+		}
+		return q.RequestChange(tx, &profile, profile.Email)
+	})
+}
+
+// ConfirmChange применяет запрос по ссылке подтверждения: адрес в профиле заменяется
+// новым и отмечается подтверждённым, на старый адрес уходит письмо со ссылкой отмены.
+// Повторный переход по той же ссылке возвращает ErrChangeUsed.
+func (q *Queue) ConfirmChange(ctx context.Context, actor audit.Actor, token string) (models.Profile, error) {
+	id, err := parseChangeToken(q.cfg.SigningKey, purposeConfirm, token, time.Now())
+	if err != nil {
+		return models.Profile{}, err
+	}
+	var profile models.Profile
+	err = q.session(ctx).Transaction(func(tx *gorm.DB) error {
+		change, err := lockChange(tx, id)
+		if err != nil {
+			return err
+		}
+		if change.ConfirmedAt != nil || change.CancelledAt != nil {
+			return ErrChangeUsed
+		}
+		if !change.ExpiresAt.After(time.Now()) {
+			return ErrTokenExpired
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ?", change.UserID).First(&profile).Error; err != nil {
+			return err
+		}
+		// Адрес успели изменить другим путём: подтверждать уже нечего
+		if profile.Email != change.OldEmail {
+			return ErrChangeUsed
+		}
+
+		now := time.Now()
+		updates := map[string]any{"email_verified_at": now, "pending_email": ""}
+		action := audit.ActionEmailVerified
+		changed := profile.Email != change.NewEmail
+		if changed {
+			action = audit.ActionEmailChanged
+			if err := q.checkAvailable(tx, change.NewEmail, change.UserID); err != nil {
+				return err
+			}
+			updates["email"] = change.NewEmail
+			updates["email_bounced_at"] = nil
+			updates["email_soft_bounces"] = 0
+		}
+		changeUpdates := map[string]any{"confirmed_at": now}
+		revertExpires := now.Add(q.cfg.RevertTTL).Truncate(time.Second)
+		if changed {
+			changeUpdates["revert_expires_at"] = revertExpires
+		}
+		if err := tx.Model(&change).Updates(changeUpdates).Error; err != nil {
+			return err
+		}
+		if err := q.applyEmail(tx, actor, &profile, action, updates, change.ID); err != nil {
+			return err
+		}
+		if !changed {
+			return nil
+		}
+		key := "email-revert:" + strconv.FormatInt(change.ID, 10)
+		_, err = q.Enqueue(tx, change.UserID, TemplateEmailChanged, changeData{
+			ChangeID: change.ID, Address: change.OldEmail, NewEmail: change.NewEmail, ExpiresAt: revertExpires,
+		}, &key)
+		return err
+	})
+	return profile, err
+}
+
+// RevertChange возвращает прежний адрес по ссылке из письма на старый адрес, например
+// если адрес сменил не владелец. Прежний адрес считается подтверждённым: ссылка пришла на него.
+// Неподтверждённые запросы смены отменяются.
+func (q *Queue) RevertChange(ctx context.Context, actor audit.Actor, token string) (models.Profile, error) {
+	id, err := parseChangeToken(q.cfg.SigningKey, purposeRevert, token, time.Now())
+	if err != nil {
+		return models.Profile{}, err
+	}
+	var profile models.Profile
+	err = q.session(ctx).Transaction(func(tx *gorm.DB) error {
+		change, err := lockChange(tx, id)
+		if err != nil {
+			return err
+		}
+		if change.ConfirmedAt == nil || change.RevertExpiresAt == nil || change.RevertedAt != nil {
+			return ErrChangeUsed
+		}
+		if !change.RevertExpiresAt.After(time.Now()) {
+			return ErrTokenExpired
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ?", change.UserID).First(&profile).Error; err != nil {
+			return err
+		}
+		if profile.Email != change.NewEmail {
+			return ErrChangeUsed
+		}
+		if err := q.checkAvailable(tx, change.OldEmail, change.UserID); err != nil {
+			return err
+		}
+		if err := cancelChanges(tx, change.UserID); err != nil {
+			return err
+		}
+		if err := tx.Model(&change).Update("reverted_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return q.applyEmail(tx, actor, &profile, audit.ActionEmailReverted, map[string]any{
+			"email":              change.OldEmail,
+			"email_verified_at":  time.Now(),
+			"pending_email":      "",
+			"email_bounced_at":   nil,
+			"email_soft_bounces": 0,
+		}, change.ID)
+	})
+	return profile, err
+}
+
+// applyEmail сохраняет изменения адреса в профиле, записывает их в журнал
+// и отправляет владельцу событие об изменении профиля. Ссылку получил только
+// владелец адреса, поэтому изменение записывается от его имени, как и отписка.
+func (q *Queue) applyEmail(tx *gorm.DB, actor audit.Actor, profile *models.Profile, action string, updates map[string]any, changeID int64) error {
+	actor.ID = profile.UserID
+	before := *profile
+	if err := tx.Model(profile).UpdateColumns(updates).Error; err != nil {
+		return err
+	}
+	if err := tx.First(profile, "id = ?", profile.ID).Error; err != nil {
+		return err
+	}
+	if err := realtime.Publish(tx, profile.UserID, realtime.EventProfile, realtime.ProfileChange{Action: action, Profile: profile}); err != nil {
+		return err
+	}
+	return audit.Record(tx, audit.Entry{
+		Actor:        actor,
+		Action:       action,
+		TargetUserID: profile.UserID,
+		Diff:         audit.DiffOf(before, *profile),
+		Details:      map[string]int64{"change_id": changeID},
+	})
+}
+
+// checkAvailable проверяет, что адрес не занят другим профилем, в том числе удалённым:
+// уникальный индекс действует и на них.
+func (q *Queue) checkAvailable(tx *gorm.DB, email, userID string) error {
+	var taken int64
+	if err := tx.Unscoped().Model(&models.Profile{}).
+		Where("LOWER(email) = LOWER(?) AND user_id <> ?", email, userID).Count(&taken).Error; err != nil {
+		return err
+	}
+	if taken > 0 {
+		return ErrEmailTaken
+	}
+	return nil
+}
+
+func lockChange(tx *gorm.DB, id int64) (models.EmailChange, error) {
+	var change models.EmailChange
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&change, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return change, ErrChangeUsed
+	}
+	return change, err
+}
+
+// cancelChanges отменяет неподтверждённые запросы пользователя и их письма.
+func cancelChanges(tx *gorm.DB, userID string) error {
+	if err := tx.Model(&models.EmailChange{}).
+		Where("user_id = ? AND confirmed_at IS NULL AND cancelled_at IS NULL", userID).
+		Update("cancelled_at", time.Now()).Error; err != nil {
+		return err
+	}
+	return cancelPending(tx, userID, []string{TemplateEmailConfirm}, "запрос смены адреса заменён")
+}
@@ -0,0 +1,91 @@
+package mail
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monst/story-craft/services/user-profile-service/audit"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"github.com/monst/story-craft/services/user-profile-service/testutil"
+	"gorm.io/gorm"
+)
+
+func TestChangeToken(t *testing.T) {
+	const key = "secret"
+	now := time.Now()
+	expires := now.Add(time.Hour)
+
+	id, err := parseChangeToken(key, purposeConfirm, ConfirmToken(key, 42, expires), now)
+	if err != nil || id != 42 {
+		t.Fatalf("ожидали 42, получили %d, %v", id, err)
+	}
+	if _, err := parseChangeToken(key, purposeConfirm, ConfirmToken(key, 42, expires), expires); err != ErrTokenExpired {
+		t.Errorf("истёкшая ссылка: ожидали ErrTokenExpired, получили %v", err)
+	}
+	bad := map[string]string{
+		"ссылка отмены":   RevertToken(key, 42, expires),
+		"ссылка отписки":  UnsubscribeToken(key, "42", ScopeAll),
+		"другой ключ":     ConfirmToken("other", 42, expires),
+		"без подписи":     strings.Split(ConfirmToken(key, 42, expires), ".")[0],
+		"изменённый срок": changeToken(key, purposeConfirm, 42, expires)[:4] + "x",
+	}
+	for name, token := range bad {
+		if _, err := parseChangeToken(key, purposeConfirm, token, now); err != ErrInvalidToken {
+			t.Errorf("%s: ожидали ErrInvalidToken, получили %v", name, err)
+		}
+	}
+}
+
+func TestWorkerSendsChangeConfirmation(t *testing.T) {
+	db := testutil.NewDB(t)
+	fx := testutil.LoadFixtures(t, db)
+	server := startFakeSMTP(t)
+	cfg := testEmailConfig(server)
+	cfg.ChangeTTL = time.Hour
+	cfg.RevertTTL = 24 * time.Hour
+	queue, worker := newTestWorker(t, db, cfg)
+	ctx := context.Background()
+
+	// Старый адрес не принимает почту, но письмо подтверждения идёт на новый
+	server.reject["bob@example.com"] = true
+	db.Model(&fx.Bob).Update("email_bounced_at", time.Now())
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return queue.RequestChange(tx, &fx.Bob, "bob@new.example.com")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := worker.ProcessBatch(ctx); err != nil {
+		t.Fatal(err)
+	}
+	got := server.messages()
+	if len(got) != 1 || got[0].To != "bob@new.example.com" {
+		t.Fatalf("ожидали письмо на новый адрес, получили %+v", got)
+	}
+	data := string(got[0].Data)
+	if !strings.Contains(data, "/email/confirm?token=3D") || strings.Contains(data, "List-Unsubscribe") {
+		t.Errorf("в письме должна быть ссылка подтверждения и не должно быть отписки:\n%s", data)
+	}
+
+	// После подтверждения уведомление уходит на старый адрес; его отказ не отмечает новый адрес недоступным
+	var change models.EmailChange
+	db.Where("user_id = ?", fx.Bob.UserID).First(&change)
+	profile, err := queue.ConfirmChange(ctx, audit.Actor{}, ConfirmToken(cfg.SigningKey, change.ID, change.ExpiresAt))
+	if err != nil || profile.Email != "bob@new.example.com" || profile.EmailBouncedAt != nil {
+		t.Fatalf("адрес не подтверждён: %+v, %v", profile, err)
+	}
+	if _, err := worker.ProcessBatch(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var notice models.EmailMessage
+	db.Where("template = ?", TemplateEmailChanged).First(&notice)
+	if notice.Status != models.EmailFailed {
+		t.Errorf("письмо на отклонённый адрес: ожидали failed, получили %s", notice.Status)
+	}
+	db.First(&profile, "user_id = ?", fx.Bob.UserID)
+	if profile.EmailBouncedAt != nil {
+		t.Error("отказ старого адреса не должен отмечаться в профиле")
+	}
+}
@@ -0,0 +1,94 @@
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Message — письмо для отправки. Headers добавляются к стандартным заголовкам,
+// например List-Unsubscribe.
+type Message struct {
+	From      string
+	To        string
+	MessageID string
+	Date      time.Time
+	Content   Content
+	Headers   map[string]string
+}
+
+// Bytes собирает письмо в формате RFC 5322: multipart/alternative с текстовой
+// и HTML-версией в quoted-printable, тема кодируется по RFC 2047.
+func (m Message) Bytes() ([]byte, error) {
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return nil, fmt.Errorf("неверный адрес отправителя: %w", err)
+	}
+	to, err := mail.ParseAddress(m.To)
+	if err != nil {
+		return nil, fmt.Errorf("неверный адрес получателя: %w", err)
+	}
+
+	var buf bytes.Buffer
+	body := multipart.NewWriter(&buf)
+	headers := []struct{ name, value string }{
+		{"From", from.String()},
+		{"To", to.String()},
+		{"Subject", mime.QEncoding.Encode("utf-8", m.Content.Subject)},
+		{"Date", m.Date.Format(time.RFC1123Z)},
+		{"Message-ID", m.MessageID},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", `multipart/alternative; boundary="` + body.Boundary() + `"`},
+	}
+	var head bytes.Buffer
+	for _, h := range headers {
+		if h.value != "" {
+			fmt.Fprintf(&head, "%s: %s\r\n", h.name, h.value)
+		}
+	}
+	for name, value := range m.Headers {
+		fmt.Fprintf(&head, "%s: %s\r\n", textproto.CanonicalMIMEHeaderKey(name), strings.NewReplacer("\r", "", "\n", "").Replace(value))
+	}
+	head.WriteString("\r\n")
+
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", m.Content.Text},
+		{"text/html; charset=utf-8", m.Content.HTML},
+	} {
+		w, err := body.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := body.Close(); err != nil {
+		return nil, err
+	}
+	return append(head.Bytes(), buf.Bytes()...), nil
+}
+
+// messageID создаёт идентификатор письма в домене отправителя.
+func messageID(from string, id int64, now time.Time) string {
+	domain := "localhost"
+	if addr, err := mail.ParseAddress(from); err == nil {
+		if _, d, ok := strings.Cut(addr.Address, "@"); ok {
+			domain = d
+		}
+	}
+	return fmt.Sprintf("<%d.%d@%s>", id, now.UnixNano(), domain)
+}
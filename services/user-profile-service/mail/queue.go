@@ -0,0 +1,314 @@
+package mail
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/monst/story-craft/services/user-profile-service/audit"
+	"github.com/monst/story-craft/services/user-profile-service/config"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/plugin/dbresolver"
+)
+
+// ErrUnknownBounce — неизвестный вид отказа доставки.
+var ErrUnknownBounce = errors.New("неизвестный вид отказа: ожидалось hard, soft или complaint")
+
+// Queue — очередь писем в таблице email_messages и обратная связь от почты в профилях.
+// Все запросы идут в основную БД: очередь меняется при каждом чтении.
+type Queue struct {
+	db  *gorm.DB
+	cfg config.EmailConfig
+}
+
+func NewQueue(db *gorm.DB, cfg config.EmailConfig) *Queue {
+	return &Queue{db: db, cfg: cfg}
+}
+
+func (q *Queue) session(ctx context.Context) *gorm.DB {
+	return q.db.WithContext(ctx).Clauses(dbresolver.Write).Session(&gorm.Session{})
+}
+
+// Enqueue ставит письмо template получателю recipientID. Вызывается в транзакции
+// изменения, как realtime.Publish: при откате письмо не уйдёт. Письма-сводки
+// откладываются на EMAIL_DIGEST_DELAY, чтобы собрать несколько событий в одно.
+// Возвращает false, если письмо с тем же dedupKey уже в очереди.
+func (q *Queue) Enqueue(tx *gorm.DB, recipientID, template string, data any, dedupKey *string) (bool, error) {
+	var raw any
+	if data != nil {
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			return false, err
+		}
+		raw = string(encoded)
+	}
+	var delay time.Duration
+	if isDigest(template) {
+		delay = q.cfg.DigestDelay
+	}
+	var id int64
+	err := tx.Raw(`INSERT INTO email_messages (recipient_id, template, data, dedup_key, status, next_attempt_at, created_at)
+		VALUES (?, ?, ?, ?, ?, now() + make_interval(secs => ?), now())
+		ON CONFLICT (recipient_id, dedup_key) WHERE dedup_key IS NOT NULL DO NOTHING
+		RETURNING id`,
+		recipientID, template, raw, dedupKey, models.EmailPending, delay.Seconds()).Scan(&id).Error
+	return id != 0, err
+}
+
+// EnqueueEvent ставит письмо о событии уведомления, если для события есть шаблон.
+// Поля StoryEvent берутся из payload уведомления; остальные данные в письмо не попадают.
+func (q *Queue) EnqueueEvent(tx *gorm.DB, recipientID, event string, payload json.RawMessage, dedupKey *string) (bool, error) {
+	template, ok := TemplateFor(event)
+	if !ok {
+		return false, nil
+	}
+	var e StoryEvent
+	if len(payload) > 0 {
+		// Данные не в ожидаемом виде не мешают отправке: письмо уйдёт без подробностей
+		_ = json.Unmarshal(payload, &e)
+	}
+	e.Type = event
+	return q.Enqueue(tx, recipientID, template, e, dedupKey)
+}
+
+// Claim забирает на отправку до limit писем, срок которых наступил, вместе с ещё
+// не готовыми письмами-сводками тех же получателей. Забранные письма получают статус
+// sending до истечения аренды: если экземпляр сервиса остановится, не отметив результат,
+// письма снова попадут в выдачу. SKIP LOCKED не даёт двум экземплярам забрать одно письмо.
+func (q *Queue) Claim(ctx context.Context, limit int) ([]models.EmailMessage, error) {
+	lease := time.Duration(q.cfg.BatchSize+1) * q.cfg.SendTimeout
+	var claimed []models.EmailMessage
+	err := q.session(ctx).Transaction(func(tx *gorm.DB) error {
+		locked := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+		if err := locked.Where("status IN ? AND next_attempt_at <= now()", []string{models.EmailPending, models.EmailSending}).
+			Order("next_attempt_at").Limit(limit).Find(&claimed).Error; err != nil {
+			return err
+		}
+		if len(claimed) == 0 {
+			return nil
+		}
+
+		var ids []int64
+		var digestRecipients []string
+		for _, m := range claimed {
+			ids = append(ids, m.ID)
+			if isDigest(m.Template) {
+				digestRecipients = append(digestRecipients, m.RecipientID)
+			}
+		}
+		if len(digestRecipients) > 0 {
+			var pending []models.EmailMessage
+			if err := locked.Where("status = ? AND template IN ? AND recipient_id IN ? AND id NOT IN ?",
+				models.EmailPending, digestTemplates, digestRecipients, ids).Find(&pending).Error; err != nil {
+				return err
+			}
+			for _, m := range pending {
+				ids = append(ids, m.ID)
+			}
+			claimed = append(claimed, pending...)
+		}
+
+		return tx.Model(&models.EmailMessage{}).Where("id IN ?", ids).Updates(map[string]any{
+			"status":          models.EmailSending,
+			"attempts":        gorm.Expr("attempts + 1"),
+			"next_attempt_at": gorm.Expr("now() + make_interval(secs => ?)", lease.Seconds()),
+		}).Error
+	})
+	for i := range claimed {
+		claimed[i].Status = models.EmailSending
+		claimed[i].Attempts++
+	}
+	return claimed, err
+}
+
+// markSent отмечает письма отправленными на адрес to.
+func (q *Queue) markSent(ctx context.Context, ids []int64, to string) error {
+	return q.finish(ctx, ids, map[string]any{
+		"status":     models.EmailSent,
+		"sent_at":    gorm.Expr("now()"),
+		"to_address": to,
+		"last_error": "",
+	})
+}
+
+// markRetry возвращает письма в очередь через delay после временной ошибки.
+func (q *Queue) markRetry(ctx context.Context, ids []int64, delay time.Duration, reason string) error {
+	return q.finish(ctx, ids, map[string]any{
+		"status":          models.EmailPending,
+		"next_attempt_at": gorm.Expr("now() + make_interval(secs => ?)", delay.Seconds()),
+		"last_error":      reason,
+	})
+}
+
+// markDeferred возвращает письма в очередь до момента until без учёта попытки,
+// например до конца тихих часов получателя.
+func (q *Queue) markDeferred(ctx context.Context, ids []int64, until time.Time) error {
+	return q.finish(ctx, ids, map[string]any{
+		"status":          models.EmailPending,
+		"attempts":        gorm.Expr("GREATEST(attempts - 1, 0)"),
+		"next_attempt_at": until,
+	})
+}
+
+// markDone завершает письма без отправки: status — failed или cancelled.
+func (q *Queue) markDone(ctx context.Context, ids []int64, status, reason string) error {
+	return q.finish(ctx, ids, map[string]any{"status": status, "last_error": reason})
+}
+
+func (q *Queue) finish(ctx context.Context, ids []int64, updates map[string]any) error {
+	return q.session(ctx).Model(&models.EmailMessage{}).Where("id IN ?", ids).Updates(updates).Error
+}
+
+// cancelPending отменяет ожидающие письма получателя с шаблонами из templates.
+func cancelPending(tx *gorm.DB, recipientID string, templates []string, reason string) error {
+	return tx.Model(&models.EmailMessage{}).
+		Where("recipient_id = ? AND status = ? AND template IN ?", recipientID, models.EmailPending, templates).
+		Updates(map[string]any{"status": models.EmailCancelled, "last_error": reason}).Error
+}
+
+// RecordBounce сохраняет в профиле с адресом b.Address отказ доставки: после жёсткого
+// отказа или EMAIL_SOFT_BOUNCE_LIMIT временных письма на адрес больше не отправляются,
+// жалоба на спам равносильна отписке от всех писем. Возвращает false, если профиля с таким адресом нет.
+func (q *Queue) RecordBounce(ctx context.Context, actor audit.Actor, b models.EmailBounce) (bool, error) {
+	if b.Type != models.BounceHard && b.Type != models.BounceSoft && b.Type != models.BounceComplaint {
+		return false, ErrUnknownBounce
+	}
+	found := false
+	err := q.session(ctx).Transaction(func(tx *gorm.DB) error {
+		var profile models.Profile
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("LOWER(email) = LOWER(?)", b.Address).First(&profile).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		found = true
+
+		now := time.Now()
+		action := audit.ActionEmailBounced
+		updates := map[string]any{}
+		cancel := Templates
+		switch b.Type {
+		case models.BounceHard:
+			if profile.EmailBouncedAt == nil {
+				updates["email_bounced_at"] = now
+			}
+		case models.BounceSoft:
+			updates["email_soft_bounces"] = profile.EmailSoftBounces + 1
+			if profile.EmailBouncedAt == nil && profile.EmailSoftBounces+1 >= q.cfg.SoftBounceLimit {
+				updates["email_bounced_at"] = now
+			}
+		case models.BounceComplaint:
+			action = audit.ActionEmailUnsubscribed
+			cancel = optionalTemplates()
+			if profile.EmailUnsubscribedAt == nil {
+				updates["email_unsubscribed_at"] = now
+			}
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+
+		before := profile
+		if err := tx.Model(&profile).UpdateColumns(updates).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&profile, "id = ?", profile.ID).Error; err != nil {
+			return err
+		}
+		diff := audit.DiffOf(before, profile)
+		if len(diff) > 0 {
+			if err := cancelPending(tx, profile.UserID, cancel, "адрес недоступен или пользователь отписался"); err != nil {
+				return err
+			}
+		}
+		return audit.Record(tx, audit.Entry{
+			Actor:        actor,
+			Action:       action,
+			TargetUserID: profile.UserID,
+			Reason:       b.Reason,
+			Diff:         diff,
+			Details:      map[string]string{"bounce_type": b.Type},
+		})
+	})
+	return found, err
+}
+
+// Unsubscribe отписывает пользователя от писем scope: ScopeAll — от всех, кроме служебных,
+// TemplateDigest — от дайджеста, и тогда он выключается в расписании дайджеста,
+// иначе — от событий шаблона, и тогда в настройках уведомлений выключается почта для этих событий.
+// Повторная отписка ничего не меняет. Если профиля нет, возвращает gorm.ErrRecordNotFound.
+func (q *Queue) Unsubscribe(ctx context.Context, actor audit.Actor, userID, scope string) error {
+	return q.session(ctx).Transaction(func(tx *gorm.DB) error {
+		var profile models.Profile
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ?", userID).First(&profile).Error; err != nil {
+			return err
+		}
+
+		var diff audit.Diff
+		cancel := []string{scope}
+		if scope == ScopeAll {
+			if profile.EmailUnsubscribedAt != nil {
+				return nil
+			}
+			now := time.Now()
+			diff = audit.Diff{"email_unsubscribed_at": {To: now.UTC()}}
+			if err := tx.Model(&profile).UpdateColumn("email_unsubscribed_at", now).Error; err != nil {
+				return err
+			}
+			cancel = optionalTemplates()
+		} else if scope == TemplateDigest {
+			// Дайджест не привязан к событиям: отписка выключает его расписание
+			res := tx.Model(&models.DigestSettings{}).
+				Where("user_id = ? AND frequency <> ?", userID, models.DigestOff).
+				Updates(map[string]any{"frequency": models.DigestOff, "next_send_at": nil})
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return nil
+			}
+			diff = audit.Diff{"frequency": {To: models.DigestOff}}
+		} else {
+			prefs := profile.NotificationPreferences
+			prefs.Events = maps.Clone(prefs.Events)
+			for _, event := range templateEvents(scope) {
+				channels := prefs.Events[event]
+				channels.Email = false
+				prefs.Events[event] = channels
+			}
+			if diff = audit.DiffOf(profile.NotificationPreferences, prefs); len(diff) == 0 {
+				return nil
+			}
+			if err := tx.Model(&profile).UpdateColumn("notification_preferences", prefs).Error; err != nil {
+				return err
+			}
+		}
+		if err := cancelPending(tx, userID, cancel, "пользователь отписался"); err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Entry{
+			Actor:        actor,
+			Action:       audit.ActionEmailUnsubscribed,
+			TargetUserID: userID,
+			Diff:         diff,
+			Details:      map[string]string{"scope": scope},
+		})
+	})
+}
+
+// digestTemplates — шаблоны писем-сводок.
+var digestTemplates = []string{TemplatePhaseChange}
+
+// optionalTemplates — письма, от которых отписывает ScopeAll.
+func optionalTemplates() []string {
+	return slices.DeleteFunc(slices.Clone(Templates), isTransactional)
+}
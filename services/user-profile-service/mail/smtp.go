@@ -0,0 +1,121 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"time"
+
+	"github.com/monst/story-craft/services/user-profile-service/config"
+)
+
+// ErrRecipientRejected — сервер навсегда отклонил адрес получателя (ответ 5xx на RCPT TO).
+// Такой адрес считается недоступным, как после жёсткого отказа доставки.
+var ErrRecipientRejected = errors.New("почтовый сервер отклонил адрес получателя")
+
+// Transport доставляет собранное письмо. Реализация — SMTPTransport; в тестах
+// её можно заменить, но обычно достаточно поддельного SMTP-сервера.
+type Transport interface {
+	Send(ctx context.Context, from, to string, msg []byte) error
+}
+
+// IsPermanent сообщает, что повтор отправки не поможет: сервер ответил кодом 5xx.
+func IsPermanent(err error) bool {
+	var protoErr *textproto.Error
+	return errors.As(err, &protoErr) && protoErr.Code >= 500 && protoErr.Code < 600
+}
+
+// SMTPTransport отправляет письма через SMTP-сервер: по одному соединению на письмо,
+// без TLS (локальный MailHog), со STARTTLS или поверх TLS.
+type SMTPTransport struct {
+	addr     string
+	host     string
+	tlsMode  string
+	username string
+	password string
+	timeout  time.Duration
+	// tlsConfig задаётся в тестах с самоподписанным сертификатом.
+	tlsConfig *tls.Config
+}
+
+func NewSMTPTransport(cfg config.EmailConfig) *SMTPTransport {
+	return &SMTPTransport{
+		addr:      net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(cfg.SMTPPort)),
+		host:      cfg.SMTPHost,
+		tlsMode:   cfg.SMTPTLS,
+		username:  cfg.SMTPUsername,
+		password:  cfg.SMTPPassword,
+		timeout:   cfg.SendTimeout,
+		tlsConfig: &tls.Config{ServerName: cfg.SMTPHost, MinVersion: tls.VersionTLS12},
+	}
+}
+
+// Send отправляет письмо msg с адреса from на адрес to. Вся отправка, включая
+// подключение, ограничена таймаутом транспорта и контекстом.
+func (t *SMTPTransport) Send(ctx context.Context, from, to string, msg []byte) error {
+	deadline := time.Now().Add(t.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	dialer := net.Dialer{Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, "tcp", t.addr)
+	if err != nil {
+		return err
+	}
+	// Отмена контекста прерывает зависшее чтение ответа сервера
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return err
+	}
+	if t.tlsMode == "tls" {
+		conn = tls.Client(conn, t.tlsConfig)
+	}
+
+	c, err := smtp.NewClient(conn, t.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if t.tlsMode == "starttls" {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return errors.New("SMTP-сервер не поддерживает STARTTLS")
+		}
+		if err := c.StartTLS(t.tlsConfig); err != nil {
+			return err
+		}
+	}
+	if t.username != "" {
+		if err := c.Auth(smtp.PlainAuth("", t.username, t.password, t.host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to); err != nil {
+		if IsPermanent(err) {
+			return fmt.Errorf("%w: %w", ErrRecipientRejected, err)
+		}
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
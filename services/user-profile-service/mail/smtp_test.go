@@ -0,0 +1,206 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/monst/story-craft/services/user-profile-service/config"
+)
+
+// fakeSMTP — минимальный SMTP-сервер в процессе теста. Принимает письма и сохраняет
+// их; адреса из reject отклоняются ответом 550 на RCPT TO, из tempfail — ответом 451.
+type fakeSMTP struct {
+	listener net.Listener
+	reject   map[string]bool
+	tempfail map[string]bool
+
+	mu       sync.Mutex
+	received []receivedMail
+}
+
+type receivedMail struct {
+	From, To string
+	Data     []byte
+}
+
+func startFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSMTP{listener: l, reject: map[string]bool{}, tempfail: map[string]bool{}}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+// config возвращает настройки отправки через этот сервер.
+func (s *fakeSMTP) config() config.EmailConfig {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	return config.EmailConfig{SMTPHost: "127.0.0.1", SMTPPort: addr.Port, SMTPTLS: "none", SendTimeout: 5 * time.Second}
+}
+
+func (s *fakeSMTP) messages() []receivedMail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]receivedMail(nil), s.received...)
+}
+
+func (s *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+	reply("220 fake ESMTP")
+	var current receivedMail
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimSpace(line)
+		upper := strings.ToUpper(cmd)
+		switch {
+		case strings.HasPrefix(upper, "EHLO"), strings.HasPrefix(upper, "HELO"):
+			reply("250 fake")
+		case strings.HasPrefix(upper, "MAIL FROM:"):
+			current = receivedMail{From: strings.Trim(cmd[len("MAIL FROM:"):], "<> ")}
+			reply("250 OK")
+		case strings.HasPrefix(upper, "RCPT TO:"):
+			to := strings.Trim(cmd[len("RCPT TO:"):], "<> ")
+			switch {
+			case s.reject[to]:
+				reply("550 5.1.1 No such user")
+			case s.tempfail[to]:
+				reply("451 4.3.0 Try again later")
+			default:
+				current.To = to
+				reply("250 OK")
+			}
+		case upper == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			current.Data = []byte(data.String())
+			s.mu.Lock()
+			s.received = append(s.received, current)
+			s.mu.Unlock()
+			reply("250 OK queued")
+		case upper == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestSMTPTransportSend(t *testing.T) {
+	server := startFakeSMTP(t)
+	transport := NewSMTPTransport(server.config())
+
+	raw, err := Message{
+		From:      "Story Craft <no-reply@story-craft.io>",
+		To:        "alice@example.com",
+		MessageID: messageID("no-reply@story-craft.io", 1, time.Now()),
+		Date:      time.Now(),
+		Content:   Content{Subject: "Голосование открыто", Text: "Привет, Алиса\n", HTML: "<p>Привет, Алиса</p>"},
+		Headers:   map[string]string{"List-Unsubscribe": "<https://story-craft.io/email/unsubscribe?token=t>"},
+	}.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := transport.Send(context.Background(), "no-reply@story-craft.io", "alice@example.com", raw); err != nil {
+		t.Fatal(err)
+	}
+
+	got := server.messages()
+	if len(got) != 1 || got[0].From != "no-reply@story-craft.io" || got[0].To != "alice@example.com" {
+		t.Fatalf("сервер получил %+v", got)
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(string(got[0].Data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "Голосование открыто" {
+		t.Errorf("тема: %q, %v", subject, err)
+	}
+	if msg.Header.Get("List-Unsubscribe") == "" {
+		t.Error("нет заголовка List-Unsubscribe")
+	}
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	var texts []string
+	for {
+		part, err := parts.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(part)
+		texts = append(texts, string(body))
+	}
+	if len(texts) != 2 || !strings.Contains(texts[0], "Привет, Алиса") || !strings.Contains(texts[1], "<p>Привет, Алиса</p>") {
+		t.Errorf("части письма: %q", texts)
+	}
+}
+
+func TestSMTPTransportErrors(t *testing.T) {
+	server := startFakeSMTP(t)
+	server.reject["gone@example.com"] = true
+	server.tempfail["busy@example.com"] = true
+	transport := NewSMTPTransport(server.config())
+	msg := []byte("Subject: test\r\n\r\nbody\r\n")
+
+	err := transport.Send(context.Background(), "no-reply@story-craft.io", "gone@example.com", msg)
+	if !errors.Is(err, ErrRecipientRejected) || !IsPermanent(err) {
+		t.Errorf("550: ожидали ErrRecipientRejected, получили %v", err)
+	}
+	err = transport.Send(context.Background(), "no-reply@story-craft.io", "busy@example.com", msg)
+	if err == nil || errors.Is(err, ErrRecipientRejected) || IsPermanent(err) {
+		t.Errorf("451: ожидали временную ошибку, получили %v", err)
+	}
+	if len(server.messages()) != 0 {
+		t.Error("отклонённые письма не должны приниматься")
+	}
+
+	// Недоступный сервер — временная ошибка
+	cfg := server.config()
+	server.listener.Close()
+	if err := NewSMTPTransport(cfg).Send(context.Background(), "a@b.c", "alice@example.com", msg); err == nil || IsPermanent(err) {
+		t.Errorf("недоступный сервер: ожидали временную ошибку, получили %v", err)
+	}
+}
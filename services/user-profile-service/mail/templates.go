@@ -0,0 +1,186 @@
+// Package mail отправляет письма пользователям: шаблоны на русском и английском,
+// очередь в PostgreSQL с повторами, фоновую отправку через SMTP и обратную связь
+// от почты — отказы доставки и отписки, которые сохраняются в профиле.
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"slices"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/monst/story-craft/services/user-profile-service/models"
+)
+
+// Шаблоны писем.
+const (
+	TemplateWelcome = "welcome"
+	// TemplatePhaseChange — сводка об открытии и завершении голосований: изменения,
+	// накопленные за EMAIL_DIGEST_DELAY, уходят одним письмом.
+	TemplatePhaseChange = "phase_change"
+	TemplateProposalWon = "proposal_won"
+	// TemplateEmailConfirm — ссылка подтверждения адреса, уходит на новый адрес.
+	TemplateEmailConfirm = "email_confirm"
+	// TemplateEmailChanged — уведомление о смене адреса со ссылкой отмены, уходит на старый адрес.
+	TemplateEmailChanged = "email_changed"
+	// TemplateDigest — сводка непрочитанных уведомлений за день или неделю по расписанию пользователя.
+	TemplateDigest = "digest"
+)
+
+// Templates — все шаблоны писем.
+var Templates = []string{TemplateWelcome, TemplatePhaseChange, TemplateProposalWon, TemplateEmailConfirm, TemplateEmailChanged, TemplateDigest}
+
+// eventTemplates — каким письмом сообщается о событии уведомления. Для событий
+// не из списка письма нет, даже если пользователь включил для них почту.
+var eventTemplates = map[string]string{
+	models.EventVotingOpened: TemplatePhaseChange,
+	models.EventVotingClosed: TemplatePhaseChange,
+	models.EventProposalWon:  TemplateProposalWon,
+}
+
+// TemplateFor возвращает шаблон письма для события уведомления.
+func TemplateFor(event string) (string, bool) {
+	t, ok := eventTemplates[event]
+	return t, ok
+}
+
+// templateEvents возвращает события, о которых сообщает шаблон.
+func templateEvents(template string) []string {
+	var events []string
+	for _, event := range models.NotificationEvents {
+		if eventTemplates[event] == template {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// isDigest сообщает, собираются ли письма шаблона в одно при отправке.
+func isDigest(template string) bool {
+	return slices.Contains(digestTemplates, template)
+}
+
+// isTransactional сообщает, служебное ли письмо: оно уходит и после отписки от всех писем.
+func isTransactional(template string) bool {
+	return template == TemplateWelcome || isAccount(template)
+}
+
+// isAccount сообщает, касается ли письмо адреса почты: такие письма идут на адрес
+// из данных письма, а не из профиля, и в них нет ссылки отписки.
+func isAccount(template string) bool {
+	return template == TemplateEmailConfirm || template == TemplateEmailChanged
+}
+
+//go:embed templates
+var templateFS embed.FS
+
+// StoryEvent — событие истории из данных уведомления.
+type StoryEvent struct {
+	Type          string `json:"type"`
+	StoryID       string `json:"story_id"`
+	StoryTitle    string `json:"story_title"`
+	ChapterTitle  string `json:"chapter_title,omitempty"`
+	ProposalTitle string `json:"proposal_title,omitempty"`
+	// Link — ссылка на историю в приложении, заполняется при отправке.
+	Link string `json:"-"`
+}
+
+// TemplateData — данные шаблона письма.
+type TemplateData struct {
+	// Name — отображаемое имя получателя или, если его нет, username.
+	Name   string
+	AppURL string
+	// UnsubscribeURL — страница отписки в приложении; пустая для писем без отписки.
+	UnsubscribeURL string
+	// Events — события истории; в письме о победе продолжения одно событие.
+	Events []StoryEvent
+	// ActionURL — ссылка подтверждения или отмены в письмах об адресе почты.
+	ActionURL string
+	// NewEmail — новый адрес в письмах об адресе почты.
+	NewEmail string
+	// ExpiresAt — до какого момента действует ActionURL.
+	ExpiresAt time.Time
+	// Digest — содержимое дайджеста уведомлений.
+	Digest models.DigestSummary
+}
+
+// Content — готовое письмо.
+type Content struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Renderer собирает письма из встроенных шаблонов. Для каждого шаблона и языка есть
+// HTML-версия в общем макете языка и текстовая версия, в которой задана и тема письма.
+type Renderer struct {
+	defaultLanguage string
+	html            map[string]*htmltemplate.Template
+	text            map[string]*texttemplate.Template
+}
+
+// NewRenderer разбирает шаблоны. Отсутствие шаблона на одном из языков — ошибка,
+// чтобы пропуск перевода обнаруживался при запуске, а не при отправке.
+func NewRenderer(defaultLanguage string) (*Renderer, error) {
+	if !slices.Contains(models.EmailLanguages, defaultLanguage) {
+		return nil, fmt.Errorf("нет шаблонов писем на языке %q", defaultLanguage)
+	}
+	r := &Renderer{
+		defaultLanguage: defaultLanguage,
+		html:            make(map[string]*htmltemplate.Template),
+		text:            make(map[string]*texttemplate.Template),
+	}
+	for _, lang := range models.EmailLanguages {
+		for _, name := range Templates {
+			key := lang + "/" + name
+			html, err := htmltemplate.New("layout.html").Option("missingkey=error").
+				ParseFS(templateFS, "templates/"+lang+"/layout.html", "templates/"+key+".html")
+			if err != nil {
+				return nil, fmt.Errorf("шаблон %s.html: %w", key, err)
+			}
+			text, err := texttemplate.New(name+".txt").Option("missingkey=error").
+				ParseFS(templateFS, "templates/"+key+".txt")
+			if err != nil {
+				return nil, fmt.Errorf("шаблон %s.txt: %w", key, err)
+			}
+			if text.Lookup("subject") == nil {
+				return nil, fmt.Errorf("шаблон %s.txt: нет темы письма (define \"subject\")", key)
+			}
+			r.html[key], r.text[key] = html, text
+		}
+	}
+	return r, nil
+}
+
+// Render собирает письмо name на языке lang; неизвестный или пустой язык заменяется языком по умолчанию.
+func (r *Renderer) Render(lang, name string, data TemplateData) (Content, error) {
+	if !slices.Contains(models.EmailLanguages, lang) {
+		lang = r.defaultLanguage
+	}
+	key := lang + "/" + name
+	html, text := r.html[key], r.text[key]
+	if html == nil {
+		return Content{}, fmt.Errorf("неизвестный шаблон письма %q", name)
+	}
+
+	var subject, body, htmlBody bytes.Buffer
+	if err := text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Content{}, err
+	}
+	if err := text.Execute(&body, data); err != nil {
+		return Content{}, err
+	}
+	if err := html.Execute(&htmlBody, data); err != nil {
+		return Content{}, err
+	}
+	return Content{
+		// Перевод строки в теме сломал бы заголовок письма
+		Subject: strings.Join(strings.Fields(subject.String()), " "),
+		Text:    strings.TrimSpace(body.String()) + "\n",
+		HTML:    htmlBody.String(),
+	}, nil
+}
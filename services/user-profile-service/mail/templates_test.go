@@ -0,0 +1,121 @@
+package mail
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monst/story-craft/services/user-profile-service/models"
+)
+
+func TestRenderAllTemplates(t *testing.T) {
+	r, err := NewRenderer("ru")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := TemplateData{
+		Name:           "Алиса",
+		AppURL:         "https://story-craft.io",
+		UnsubscribeURL: "https://story-craft.io/unsubscribe?token=t",
+		ActionURL:      "https://story-craft.io/email/confirm?token=c",
+		NewEmail:       "alice@new.example.com",
+		ExpiresAt:      time.Date(2026, 1, 2, 15, 4, 0, 0, time.UTC),
+		Events: []StoryEvent{
+			{Type: models.EventVotingOpened, StoryTitle: "Туманный берег", ChapterTitle: "Глава 3", ProposalTitle: "Маяк", Link: "https://story-craft.io/stories/1"},
+			{Type: models.EventVotingClosed, StoryTitle: "Город ветров", Link: "https://story-craft.io/stories/2"},
+		},
+		Digest: models.DigestSummary{Frequency: models.DigestWeekly, Total: 7, Omitted: 2, Groups: []models.DigestGroup{
+			{Type: models.EventNewChapter, StoryTitle: "Туманный берег", Count: 4, Link: "https://story-craft.io/stories/1"},
+			{Type: models.EventNewFollower, Count: 1},
+		}},
+	}
+	for _, lang := range models.EmailLanguages {
+		for _, name := range Templates {
+			content, err := r.Render(lang, name, data)
+			if err != nil {
+				t.Fatalf("%s/%s: %v", lang, name, err)
+			}
+			if content.Subject == "" || strings.Contains(content.Subject, "\n") {
+				t.Errorf("%s/%s: неверная тема %q", lang, name, content.Subject)
+			}
+			for _, body := range []string{content.Subject, content.Text, content.HTML} {
+				if strings.Contains(body, "<no value>") {
+					t.Errorf("%s/%s: в письме незаполненное поле:\n%s", lang, name, body)
+				}
+			}
+			if !strings.Contains(content.Text, "Алиса") || !strings.Contains(content.HTML, "Алиса") {
+				t.Errorf("%s/%s: в письме нет имени получателя", lang, name)
+			}
+			if isAccount(name) && (!strings.Contains(content.Text, data.ActionURL) || !strings.Contains(content.HTML, data.NewEmail)) {
+				t.Errorf("%s/%s: в письме нет ссылки или нового адреса", lang, name)
+			}
+			if name == TemplateDigest && (!strings.Contains(content.Subject, "7") || !strings.Contains(content.Text, "Туманный берег") ||
+				!strings.Contains(content.HTML, data.Digest.Groups[0].Link) || !strings.Contains(content.Text, "2")) {
+				t.Errorf("%s/%s: в дайджесте нет групп уведомлений:\n%s", lang, name, content.Text)
+			}
+		}
+	}
+}
+
+func TestRenderDigestAndLanguage(t *testing.T) {
+	r, err := NewRenderer("en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	one := TemplateData{Name: "Bob", AppURL: "https://story-craft.io", Events: []StoryEvent{
+		{Type: models.EventVotingOpened, StoryTitle: "Fog", Link: "https://story-craft.io/stories/1"},
+	}}
+	two := one
+	two.Events = append(two.Events, StoryEvent{Type: models.EventVotingClosed, StoryTitle: "Wind", Link: "https://story-craft.io/stories/2"})
+
+	single, err := r.Render("", TemplatePhaseChange, one)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest, err := r.Render("en", TemplatePhaseChange, two)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(single.Subject, "Fog") || !strings.Contains(digest.Subject, "2") {
+		t.Errorf("темы одиночного письма и сводки: %q, %q", single.Subject, digest.Subject)
+	}
+	if !strings.Contains(digest.Text, "Fog") || !strings.Contains(digest.Text, "Wind") {
+		t.Errorf("в сводке должны быть обе истории:\n%s", digest.Text)
+	}
+
+	// Пустой язык заменяется языком по умолчанию, ru выбирается явно
+	ru, err := r.Render("ru", TemplatePhaseChange, one)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ru.Subject == single.Subject {
+		t.Errorf("ожидали тему на русском, получили %q", ru.Subject)
+	}
+}
+
+func TestRenderEscapesHTML(t *testing.T) {
+	r, err := NewRenderer("ru")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := r.Render("ru", TemplateProposalWon, TemplateData{
+		Name:   `<script>alert(1)</script>`,
+		AppURL: "https://story-craft.io",
+		Events: []StoryEvent{{Type: models.EventProposalWon, StoryTitle: `<b>История</b>`, Link: "javascript:alert(1)"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(content.HTML, "<script>") || strings.Contains(content.HTML, "<b>История") {
+		t.Errorf("текст пользователя не экранирован:\n%s", content.HTML)
+	}
+	if strings.Contains(content.HTML, `href="javascript:`) {
+		t.Errorf("опасная ссылка попала в письмо:\n%s", content.HTML)
+	}
+}
+
+func TestNewRendererUnknownLanguage(t *testing.T) {
+	if _, err := NewRenderer("de"); err == nil {
+		t.Fatal("ожидали ошибку для языка без шаблонов")
+	}
+}
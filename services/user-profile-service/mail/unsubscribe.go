@@ -0,0 +1,67 @@
+package mail
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"slices"
+	"strings"
+)
+
+// ScopeAll — отписка от всех писем, кроме служебных.
+const ScopeAll = "all"
+
+// ErrInvalidToken — ссылка из письма повреждена, подписана другим ключом или для другого действия.
+var ErrInvalidToken = errors.New("неверная ссылка")
+
+// UnsubscribeToken подписывает ссылку отписки пользователя userID от писем scope:
+// ScopeAll или шаблона письма. Срока у ссылки нет: письма читают и через месяцы.
+func UnsubscribeToken(key, userID, scope string) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(userID + ":" + scope))
+	return payload + "." + sign(key, purposeUnsubscribe, payload)
+}
+
+// ParseUnsubscribeToken проверяет подпись ссылки и возвращает пользователя и область отписки.
+func ParseUnsubscribeToken(key, token string) (userID, scope string, err error) {
+	raw, err := verify(key, purposeUnsubscribe, token)
+	if err != nil {
+		return "", "", err
+	}
+	userID, scope, ok := strings.Cut(raw, ":")
+	if !ok || userID == "" || !validScope(scope) {
+		return "", "", ErrInvalidToken
+	}
+	return userID, scope, nil
+}
+
+// validScope — ScopeAll или шаблон письма, от которого можно отписаться.
+func validScope(scope string) bool {
+	return scope == ScopeAll || (slices.Contains(Templates, scope) && !isTransactional(scope))
+}
+
+// Назначения подписанных ссылок: подпись одной не подходит к другой.
+const (
+	purposeUnsubscribe = "unsubscribe"
+	purposeConfirm     = "confirm"
+	purposeRevert      = "revert"
+)
+
+func sign(key, purpose, payload string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(purpose + ":" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verify проверяет подпись ссылки с назначением purpose и возвращает её содержимое.
+func verify(key, purpose, token string) (string, error) {
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(sign(key, purpose, payload))) {
+		return "", ErrInvalidToken
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	return string(raw), nil
+}
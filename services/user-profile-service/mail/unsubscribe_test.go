@@ -0,0 +1,35 @@
+package mail
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnsubscribeToken(t *testing.T) {
+	const key = "secret"
+	userID := "7d1f4f0e-5a43-4a8e-9b0c-2f6a1f1f2c11"
+
+	for _, scope := range []string{ScopeAll, TemplatePhaseChange} {
+		gotUser, gotScope, err := ParseUnsubscribeToken(key, UnsubscribeToken(key, userID, scope))
+		if err != nil || gotUser != userID || gotScope != scope {
+			t.Errorf("%s: получили %q, %q, %v", scope, gotUser, gotScope, err)
+		}
+	}
+
+	token := UnsubscribeToken(key, userID, ScopeAll)
+	payload, _, _ := strings.Cut(token, ".")
+	_, otherSignature, _ := strings.Cut(UnsubscribeToken(key, "00000000-0000-0000-0000-000000000000", ScopeAll), ".")
+	bad := map[string]string{
+		"другой ключ":        UnsubscribeToken("other", userID, ScopeAll),
+		"чужая подпись":      payload + "." + otherSignature,
+		"без подписи":        payload,
+		"служебное письмо":   UnsubscribeToken(key, userID, TemplateWelcome),
+		"неизвестный шаблон": UnsubscribeToken(key, userID, "newsletter"),
+		"пустой":             "",
+	}
+	for name, token := range bad {
+		if _, _, err := ParseUnsubscribeToken(key, token); err != ErrInvalidToken {
+			t.Errorf("%s: ожидали ErrInvalidToken, получили %v", name, err)
+		}
+	}
+}
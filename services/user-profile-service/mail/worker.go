@@ -0,0 +1,272 @@
+package mail
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/monst/story-craft/services/user-profile-service/audit"
+	"github.com/monst/story-craft/services/user-profile-service/config"
+	"github.com/monst/story-craft/services/user-profile-service/metrics"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+)
+
+// Результаты обработки письма для метрик.
+const (
+	ResultSent      = "sent"
+	ResultRetry     = "retry"
+	ResultFailed    = "failed"
+	ResultCancelled = "cancelled"
+	ResultDeferred  = "deferred"
+)
+
+// workerActor — автор записей журнала об отказах, замеченных при отправке.
+var workerActor = audit.Actor{ID: "system:email"}
+
+// Worker отправляет письма из очереди.
+type Worker struct {
+	queue     *Queue
+	renderer  *Renderer
+	transport Transport
+	cfg       config.EmailConfig
+	metrics   *metrics.Metrics
+}
+
+func NewWorker(queue *Queue, renderer *Renderer, transport Transport, cfg config.EmailConfig, m *metrics.Metrics) *Worker {
+	return &Worker{queue: queue, renderer: renderer, transport: transport, cfg: cfg, metrics: m}
+}
+
+// Run возвращает фоновую задачу для lifecycle.Manager.Go: раз в EMAIL_POLL_INTERVAL
+// она отправляет готовые письма, пока очередь не опустеет.
+func (w *Worker) Run(ctx context.Context) error {
+	ticker := time.NewTicker(w.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			for {
+				n, err := w.ProcessBatch(ctx)
+				if err != nil && ctx.Err() == nil {
+					slog.Warn("Не удалось обработать очередь писем", slog.Any("error", err))
+				}
+				if err != nil || n < w.cfg.BatchSize {
+					break
+				}
+			}
+		}
+	}
+}
+
+// ProcessBatch забирает из очереди готовые письма и отправляет их. Письма-сводки
+// одного получателя уходят одним письмом. Возвращает число обработанных записей очереди.
+func (w *Worker) ProcessBatch(ctx context.Context) (int, error) {
+	claimed, err := w.queue.Claim(ctx, w.cfg.BatchSize)
+	if err != nil || len(claimed) == 0 {
+		return 0, err
+	}
+
+	var recipientIDs []string
+	for _, m := range claimed {
+		recipientIDs = append(recipientIDs, m.RecipientID)
+	}
+	var profiles []models.Profile
+	if err := w.queue.session(ctx).Where("user_id IN ?", recipientIDs).Find(&profiles).Error; err != nil {
+		return 0, err
+	}
+	byUser := make(map[string]*models.Profile, len(profiles))
+	for i := range profiles {
+		byUser[profiles[i].UserID] = &profiles[i]
+	}
+
+	for _, group := range groupMessages(claimed) {
+		if err := w.deliver(ctx, byUser[group[0].RecipientID], group); err != nil {
+			return len(claimed), err
+		}
+	}
+	return len(claimed), nil
+}
+
+// groupMessages объединяет письма-сводки одного получателя, остальные письма идут по одному.
+func groupMessages(messages []models.EmailMessage) [][]models.EmailMessage {
+	var groups [][]models.EmailMessage
+	index := map[string]int{}
+	for _, m := range messages {
+		if !isDigest(m.Template) {
+			groups = append(groups, []models.EmailMessage{m})
+			continue
+		}
+		key := m.RecipientID + "/" + m.Template
+		if i, ok := index[key]; ok {
+			groups[i] = append(groups[i], m)
+			continue
+		}
+		index[key] = len(groups)
+		groups = append(groups, []models.EmailMessage{m})
+	}
+	return groups
+}
+
+// deliver отправляет группу писем одним письмом и сохраняет результат.
+// Возвращает только ошибки сохранения результата в очереди.
+func (w *Worker) deliver(ctx context.Context, profile *models.Profile, group []models.EmailMessage) error {
+	template := group[0].Template
+	ids := make([]int64, 0, len(group))
+	attempts := 0
+	for _, m := range group {
+		ids = append(ids, m.ID)
+		attempts = max(attempts, m.Attempts)
+	}
+	cancel := func(reason string) error {
+		w.metrics.Email(template, ResultCancelled)
+		return w.queue.markDone(ctx, ids, models.EmailCancelled, reason)
+	}
+	fail := func(reason string) error {
+		w.metrics.Email(template, ResultFailed)
+		return w.queue.markDone(ctx, ids, models.EmailFailed, reason)
+	}
+
+	// Получатель мог удалить профиль, отписаться или выключить письма, пока письмо ждало отправки
+	if profile == nil {
+		return cancel("профиль удалён")
+	}
+	to := profile.Email
+	var change changeData
+	if isAccount(template) {
+		if err := json.Unmarshal(group[0].Data, &change); err != nil || change.Address == "" {
+			return fail("неверные данные письма об адресе")
+		}
+		to = change.Address
+	}
+	// Отказ относится к адресу из профиля; письма об адресе могут идти на другой
+	ownAddress := strings.EqualFold(to, profile.Email)
+	if ownAddress && profile.EmailBouncedAt != nil {
+		return cancel("письма на адрес не доставляются")
+	}
+	prefs := profile.NotificationPreferences
+	var digest models.DigestSummary
+	if template == TemplateDigest {
+		if err := json.Unmarshal(group[0].Data, &digest); err != nil {
+			return fail("неверные данные дайджеста: " + err.Error())
+		}
+		for i, g := range digest.Groups {
+			if g.StoryID != "" {
+				digest.Groups[i].Link = w.cfg.AppURL + "/stories/" + url.PathEscape(g.StoryID)
+			}
+		}
+	}
+	events := make([]StoryEvent, 0, len(group))
+	for _, m := range group {
+		if template == TemplateDigest {
+			// Уведомления дайджеста уже отобраны по настройкам при сборке сводки
+			break
+		}
+		var e StoryEvent
+		if len(m.Data) > 0 && !isAccount(template) {
+			if err := json.Unmarshal(m.Data, &e); err != nil {
+				return fail("неверные данные письма: " + err.Error())
+			}
+		}
+		if e.StoryID != "" {
+			e.Link = w.cfg.AppURL + "/stories/" + url.PathEscape(e.StoryID)
+		}
+		if e.Type == "" || isTransactional(template) || prefs.Allows(e.Type, models.ChannelEmail) {
+			events = append(events, e)
+		}
+	}
+	now := time.Now()
+	scope := ScopeAll
+	if !isTransactional(template) {
+		if profile.EmailUnsubscribedAt != nil {
+			return cancel("пользователь отписался от писем")
+		}
+		if len(events) == 0 && template != TemplateDigest {
+			return cancel("письма выключены в настройках уведомлений")
+		}
+		if prefs.QuietHours.Active(now) {
+			w.metrics.Email(template, ResultDeferred)
+			return w.queue.markDeferred(ctx, ids, prefs.QuietHours.Ends(now))
+		}
+		scope = template
+	}
+
+	name := profile.DisplayName
+	if name == "" {
+		name = profile.Username
+	}
+	data := TemplateData{Name: name, AppURL: w.cfg.AppURL, Events: events, Digest: digest}
+	headers := map[string]string{}
+	switch template {
+	case TemplateEmailConfirm:
+		data.NewEmail, data.ExpiresAt = change.NewEmail, change.ExpiresAt
+		data.ActionURL = w.cfg.AppURL + "/email/confirm?token=" + ConfirmToken(w.cfg.SigningKey, change.ChangeID, change.ExpiresAt)
+	case TemplateEmailChanged:
+		data.NewEmail, data.ExpiresAt = change.NewEmail, change.ExpiresAt
+		data.ActionURL = w.cfg.AppURL + "/email/revert?token=" + RevertToken(w.cfg.SigningKey, change.ChangeID, change.ExpiresAt)
+	default:
+		token := UnsubscribeToken(w.cfg.SigningKey, profile.UserID, scope)
+		data.UnsubscribeURL = w.cfg.AppURL + "/unsubscribe?token=" + token
+		// Отписка в один клик из почтового клиента (RFC 8058)
+		headers["List-Unsubscribe"] = "<" + w.cfg.PublicURL + "/email/unsubscribe?token=" + token + ">"
+		headers["List-Unsubscribe-Post"] = "List-Unsubscribe=One-Click"
+	}
+	content, err := w.renderer.Render(prefs.Language, template, data)
+	if err != nil {
+		return fail("не удалось собрать письмо: " + err.Error())
+	}
+	from, err := mail.ParseAddress(w.cfg.From)
+	if err != nil {
+		return fail("неверный адрес отправителя: " + err.Error())
+	}
+	raw, err := Message{
+		From:      w.cfg.From,
+		To:        to,
+		MessageID: messageID(w.cfg.From, ids[0], now),
+		Date:      now,
+		Content:   content,
+		Headers:   headers,
+	}.Bytes()
+	if err != nil {
+		return fail(err.Error())
+	}
+
+	sendCtx, cancelSend := context.WithTimeout(ctx, w.cfg.SendTimeout)
+	err = w.transport.Send(sendCtx, from.Address, to, raw)
+	cancelSend()
+	switch {
+	case err == nil:
+		w.metrics.Email(template, ResultSent)
+		return w.queue.markSent(ctx, ids, to)
+	case ctx.Err() != nil:
+		// Сервис останавливается: письма вернутся в очередь по истечении аренды
+		return nil
+	case errors.Is(err, ErrRecipientRejected) && ownAddress:
+		if _, bounceErr := w.queue.RecordBounce(ctx, workerActor, models.EmailBounce{
+			Address: profile.Email, Type: models.BounceHard, Reason: err.Error(),
+		}); bounceErr != nil {
+			slog.Warn("Не удалось сохранить отказ доставки", slog.Any("error", bounceErr))
+		}
+		return fail(err.Error())
+	case IsPermanent(err) || attempts >= w.cfg.MaxAttempts:
+		return fail(err.Error())
+	default:
+		w.metrics.Email(template, ResultRetry)
+		return w.queue.markRetry(ctx, ids, w.backoff(attempts), err.Error())
+	}
+}
+
+// backoff — пауза перед повтором после attempt неудачных попыток: удваивается от
+// EMAIL_RETRY_BACKOFF до EMAIL_MAX_RETRY_BACKOFF.
+func (w *Worker) backoff(attempt int) time.Duration {
+	delay := w.cfg.RetryBackoff
+	for i := 1; i < attempt && delay < w.cfg.MaxRetryBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, w.cfg.MaxRetryBackoff)
+}
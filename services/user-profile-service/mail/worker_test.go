@@ -0,0 +1,223 @@
+package mail
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monst/story-craft/services/user-profile-service/audit"
+	"github.com/monst/story-craft/services/user-profile-service/config"
+	"github.com/monst/story-craft/services/user-profile-service/metrics"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"github.com/monst/story-craft/services/user-profile-service/testutil"
+	"gorm.io/gorm"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(testutil.RunWithPostgres(m))
+}
+
+func testEmailConfig(server *fakeSMTP) config.EmailConfig {
+	cfg := server.config()
+	cfg.Enabled = true
+	cfg.From = "Story Craft <no-reply@story-craft.io>"
+	cfg.DefaultLanguage = "ru"
+	cfg.AppURL = "https://story-craft.io"
+	cfg.PublicURL = "https://api.story-craft.io"
+	cfg.SigningKey = "secret"
+	cfg.PollInterval = time.Second
+	cfg.BatchSize = 20
+	cfg.MaxAttempts = 2
+	cfg.RetryBackoff = time.Minute
+	cfg.MaxRetryBackoff = time.Hour
+	cfg.SoftBounceLimit = 2
+	return cfg
+}
+
+func newTestWorker(t *testing.T, db *gorm.DB, cfg config.EmailConfig) (*Queue, *Worker) {
+	t.Helper()
+	renderer, err := NewRenderer(cfg.DefaultLanguage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queue := NewQueue(db, cfg)
+	return queue, NewWorker(queue, renderer, NewSMTPTransport(cfg), cfg, metrics.New())
+}
+
+func messageStatuses(t *testing.T, db *gorm.DB, recipientID string) map[string]string {
+	t.Helper()
+	var messages []models.EmailMessage
+	if err := db.Where("recipient_id = ?", recipientID).Find(&messages).Error; err != nil {
+		t.Fatal(err)
+	}
+	statuses := map[string]string{}
+	for _, m := range messages {
+		key := m.Template
+		if m.DedupKey != nil {
+			key = *m.DedupKey
+		}
+		statuses[key] = m.Status
+	}
+	return statuses
+}
+
+func TestWorkerSendsDigest(t *testing.T) {
+	db := testutil.NewDB(t)
+	fx := testutil.LoadFixtures(t, db)
+	server := startFakeSMTP(t)
+	queue, worker := newTestWorker(t, db, testEmailConfig(server))
+	ctx := context.Background()
+
+	// Два изменения фаз уходят одним письмом, повтор с тем же ключом не ставится
+	for _, key := range []string{"story-1:opened", "story-2:opened", "story-1:opened"} {
+		payload := []byte(`{"story_id":"` + strings.Split(key, ":")[0] + `","story_title":"История ` + key + `"}`)
+		if _, err := queue.EnqueueEvent(db, fx.Alice.UserID, models.EventVotingOpened, payload, &key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n, err := worker.ProcessBatch(ctx)
+	if err != nil || n != 2 {
+		t.Fatalf("ожидали 2 записи очереди, получили %d, %v", n, err)
+	}
+
+	got := server.messages()
+	if len(got) != 1 || got[0].To != "alice@example.com" {
+		t.Fatalf("ожидали одно письмо для Alice, получили %+v", got)
+	}
+	data := string(got[0].Data)
+	for _, want := range []string{"story-1", "story-2", "List-Unsubscribe: <https://api.story-craft.io/email/unsubscribe?token="} {
+		if !strings.Contains(data, want) {
+			t.Errorf("в письме нет %q:\n%s", want, data)
+		}
+	}
+	for key, status := range messageStatuses(t, db, fx.Alice.UserID) {
+		if status != models.EmailSent {
+			t.Errorf("%s: ожидали sent, получили %s", key, status)
+		}
+	}
+}
+
+func TestWorkerRetriesAndBounces(t *testing.T) {
+	db := testutil.NewDB(t)
+	fx := testutil.LoadFixtures(t, db)
+	server := startFakeSMTP(t)
+	server.tempfail["alice@example.com"] = true
+	server.reject["bob@example.com"] = true
+	queue, worker := newTestWorker(t, db, testEmailConfig(server))
+	ctx := context.Background()
+
+	key := TemplateWelcome
+	for _, id := range []string{fx.Alice.UserID, fx.Bob.UserID} {
+		if _, err := queue.Enqueue(db, id, TemplateWelcome, nil, &key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := worker.ProcessBatch(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Временная ошибка возвращает письмо в очередь, адрес Bob отклонён навсегда
+	var alice models.EmailMessage
+	db.Where("recipient_id = ?", fx.Alice.UserID).First(&alice)
+	if alice.Status != models.EmailPending || alice.Attempts != 1 || !alice.NextAttemptAt.After(time.Now().Add(30*time.Second)) {
+		t.Errorf("письмо Alice должно ждать повтора: %+v", alice)
+	}
+	if status := messageStatuses(t, db, fx.Bob.UserID)[TemplateWelcome]; status != models.EmailFailed {
+		t.Errorf("письмо Bob: ожидали failed, получили %s", status)
+	}
+	var bob models.Profile
+	db.First(&bob, "user_id = ?", fx.Bob.UserID)
+	if bob.EmailBouncedAt == nil {
+		t.Error("отказ адреса Bob не сохранён в профиле")
+	}
+
+	// После EMAIL_MAX_ATTEMPTS письмо больше не повторяется
+	db.Model(&alice).Update("next_attempt_at", time.Now().Add(-time.Second))
+	if _, err := worker.ProcessBatch(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if status := messageStatuses(t, db, fx.Alice.UserID)[TemplateWelcome]; status != models.EmailFailed {
+		t.Errorf("письмо Alice: ожидали failed после последней попытки, получили %s", status)
+	}
+}
+
+func TestUnsubscribeAndBounceFeedback(t *testing.T) {
+	db := testutil.NewDB(t)
+	fx := testutil.LoadFixtures(t, db)
+	server := startFakeSMTP(t)
+	queue, worker := newTestWorker(t, db, testEmailConfig(server))
+	ctx := context.Background()
+	actor := audit.Actor{ID: fx.Alice.UserID}
+
+	key := "story-1:won"
+	if _, err := queue.EnqueueEvent(db, fx.Alice.UserID, models.EventProposalWon, nil, &key); err != nil {
+		t.Fatal(err)
+	}
+	// Отписка от писем о победе выключает почту для события и отменяет ожидающее письмо
+	if err := queue.Unsubscribe(ctx, actor, fx.Alice.UserID, TemplateProposalWon); err != nil {
+		t.Fatal(err)
+	}
+	var alice models.Profile
+	db.First(&alice, "user_id = ?", fx.Alice.UserID)
+	if alice.NotificationPreferences.Allows(models.EventProposalWon, models.ChannelEmail) {
+		t.Error("почта о победе продолжения не выключена")
+	}
+	if !alice.NotificationPreferences.Allows(models.EventVotingOpened, models.ChannelEmail) {
+		t.Error("отписка не должна затрагивать другие письма")
+	}
+	if status := messageStatuses(t, db, fx.Alice.UserID)[key]; status != models.EmailCancelled {
+		t.Errorf("ожидали cancelled, получили %s", status)
+	}
+	if _, err := worker.ProcessBatch(ctx); err != nil || len(server.messages()) != 0 {
+		t.Errorf("отменённое письмо не должно уходить: %v", err)
+	}
+
+	// Временные отказы копятся до EMAIL_SOFT_BOUNCE_LIMIT
+	bounce := models.EmailBounce{Address: "BOB@example.com", Type: models.BounceSoft}
+	for i := range 2 {
+		found, err := queue.RecordBounce(ctx, workerActor, bounce)
+		if err != nil || !found {
+			t.Fatalf("отказ %d: %v, %v", i, found, err)
+		}
+		var bob models.Profile
+		db.First(&bob, "user_id = ?", fx.Bob.UserID)
+		if (bob.EmailBouncedAt != nil) != (i == 1) {
+			t.Errorf("после %d временных отказов bounced_at = %v", i+1, bob.EmailBouncedAt)
+		}
+	}
+	if found, err := queue.RecordBounce(ctx, workerActor, models.EmailBounce{Address: "nobody@example.com", Type: models.BounceHard}); err != nil || found {
+		t.Errorf("неизвестный адрес: %v, %v", found, err)
+	}
+	if _, err := queue.RecordBounce(ctx, workerActor, models.EmailBounce{Address: "bob@example.com", Type: "spam"}); err != ErrUnknownBounce {
+		t.Errorf("ожидали ErrUnknownBounce, получили %v", err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	w := &Worker{cfg: config.EmailConfig{RetryBackoff: time.Minute, MaxRetryBackoff: 10 * time.Minute}}
+	want := []time.Duration{time.Minute, time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 10 * time.Minute, 10 * time.Minute}
+	for attempt, d := range want {
+		if got := w.backoff(attempt); got != d {
+			t.Errorf("попытка %d: ожидали %s, получили %s", attempt, d, got)
+		}
+	}
+}
+
+func TestGroupMessages(t *testing.T) {
+	groups := groupMessages([]models.EmailMessage{
+		{ID: 1, RecipientID: "a", Template: TemplatePhaseChange},
+		{ID: 2, RecipientID: "a", Template: TemplateProposalWon},
+		{ID: 3, RecipientID: "b", Template: TemplatePhaseChange},
+		{ID: 4, RecipientID: "a", Template: TemplatePhaseChange},
+		{ID: 5, RecipientID: "a", Template: TemplateProposalWon},
+	})
+	var sizes []int
+	for _, g := range groups {
+		sizes = append(sizes, len(g))
+	}
+	if len(groups) != 4 || len(groups[0]) != 2 || groups[0][1].ID != 4 {
+		t.Errorf("ожидали сводку из 1 и 4 и отдельные письма, получили размеры %v", sizes)
+	}
+}
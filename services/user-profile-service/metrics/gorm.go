@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const startedAtKey = "metrics:started_at"
+
+// GormPlugin измеряет длительность и ошибки всех запросов GORM.
+// Подключается через db.Use(m.GormPlugin()).
+func (m *Metrics) GormPlugin() gorm.Plugin {
+	return &gormPlugin{m: m}
+}
+
+type gormPlugin struct {
+	m *Metrics
+}
+
+func (p *gormPlugin) Name() string {
+	return "metrics"
+}
+
+func (p *gormPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	hooks := []struct {
+		operation string
+		before    func(string, func(*gorm.DB)) error
+		after     func(string, func(*gorm.DB)) error
+	}{
+		{"create", cb.Create().Before("gorm:create").Register, cb.Create().After("gorm:create").Register},
+		{"query", cb.Query().Before("gorm:query").Register, cb.Query().After("gorm:query").Register},
+		{"update", cb.Update().Before("gorm:update").Register, cb.Update().After("gorm:update").Register},
+		{"delete", cb.Delete().Before("gorm:delete").Register, cb.Delete().After("gorm:delete").Register},
+		{"row", cb.Row().Before("gorm:row").Register, cb.Row().After("gorm:row").Register},
+		{"raw", cb.Raw().Before("gorm:raw").Register, cb.Raw().After("gorm:raw").Register},
+	}
+	for _, h := range hooks {
+		if err := h.before("metrics:before_"+h.operation, before); err != nil {
+			return err
+		}
+		if err := h.after("metrics:after_"+h.operation, p.after(h.operation)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func before(db *gorm.DB) {
+	db.InstanceSet(startedAtKey, time.Now())
+}
+
+func (p *gormPlugin) after(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		v, ok := db.InstanceGet(startedAtKey)
+		if !ok {
+			return
+		}
+		table := db.Statement.Table
+		if table == "" {
+			table = "unknown"
+		}
+		p.m.dbDuration.WithLabelValues(operation, table).Observe(time.Since(v.(time.Time)).Seconds())
+		if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			p.m.dbErrors.WithLabelValues(operation, table).Inc()
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// unmatchedRoute — метка для запросов, не попавших ни в один маршрут. Сырой путь
+// в метку не пишем, иначе перебор адресов раздует число временных рядов.
+const unmatchedRoute = "unmatched"
+
+// Middleware собирает RED-метрики. Маршрут берётся из шаблона gin
+// (например, /profiles/:user_id), а не из фактического пути.
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		m.httpInFlight.Inc()
+		defer m.httpInFlight.Dec()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		method := c.Request.Method
+		m.httpRequests.WithLabelValues(method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		m.httpDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,241 @@
+// Package metrics собирает метрики Prometheus: RED-метрики HTTP по шаблонам маршрутов,
+// задержки и ошибки запросов GORM, заполненность пула соединений и бизнес-события.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "user_profile"
+
+// Metrics хранит собственный реестр, поэтому в тестах можно создавать
+// независимые экземпляры без конфликтов регистрации.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+	httpInFlight prometheus.Gauge
+
+	dbDuration *prometheus.HistogramVec
+	dbErrors   *prometheus.CounterVec
+
+	profileEvents    *prometheus.CounterVec
+	profileConflicts *prometheus.CounterVec
+
+	rateLimited *prometheus.CounterVec
+
+	screeningFindings *prometheus.CounterVec
+
+	notifications *prometheus.CounterVec
+
+	streams *prometheus.GaugeVec
+
+	emails *prometheus.CounterVec
+
+	pushMessages *prometheus.CounterVec
+
+	cronRuns *prometheus.CounterVec
+	digests  *prometheus.CounterVec
+
+	jobs        *prometheus.CounterVec
+	jobDuration *prometheus.HistogramVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Количество HTTP-запросов по маршруту, методу и коду ответа.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Время обработки HTTP-запросов.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		httpInFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "http_requests_in_flight",
+			Help:      "Количество HTTP-запросов в обработке.",
+		}),
+		dbDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "db_query_duration_seconds",
+			Help:      "Время выполнения запросов к БД через GORM.",
+			Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+		}, []string{"operation", "table"}),
+		dbErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "db_query_errors_total",
+			Help:      "Количество ошибок запросов к БД (отсутствие записи ошибкой не считается).",
+		}, []string{"operation", "table"}),
+		profileEvents: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "profile_events_total",
+			Help:      "Изменения профилей по типу события: created, updated, deleted.",
+		}, []string{"event"}),
+		profileConflicts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "profile_conflicts_total",
+			Help:      "Отказы из-за конфликта уникальности (409) по операции.",
+		}, []string{"operation"}),
+		rateLimited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rate_limited_total",
+			Help:      "Запросы, отклонённые ограничением частоты (429), по группе маршрутов.",
+		}, []string{"group"}),
+		screeningFindings: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "screening_findings_total",
+			Help:      "Срабатывания правил проверки текста профилей по правилу и действию.",
+		}, []string{"rule", "action"}),
+		notifications: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "notifications_total",
+			Help:      "Уведомления, принятые внутренним API, по типу и результату: created, duplicate, muted.",
+		}, []string{"type", "result"}),
+		streams: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "event_streams_open",
+			Help:      "Открытые потоки событий клиентов по транспорту: sse или websocket.",
+		}, []string{"transport"}),
+		emails: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "emails_total",
+			Help:      "Письма из очереди по шаблону и результату: sent, retry, failed, cancelled, deferred.",
+		}, []string{"template", "result"}),
+		pushMessages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "push_messages_total",
+			Help:      "Push-сообщения из очереди по событию и результату: sent, retry, failed, cancelled, deferred, pruned.",
+		}, []string{"type", "result"}),
+		cronRuns: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cron_runs_total",
+			Help:      "Запуски задач по расписанию по задаче и результату: ok, error, skipped (задачу выполняет другой экземпляр).",
+		}, []string{"job", "result"}),
+		digests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "digests_total",
+			Help:      "Дайджесты уведомлений по каналу и результату: sent или empty (новых уведомлений не было).",
+		}, []string{"channel", "result"}),
+		jobs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "jobs_total",
+			Help:      "Попытки фоновых задач по виду и результату: done, retry, failed, interrupted (задача возвращена в очередь при остановке).",
+		}, []string{"kind", "result"}),
+		jobDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "job_duration_seconds",
+			Help:      "Время выполнения попытки фоновой задачи.",
+			Buckets:   []float64{.01, .05, .1, .5, 1, 5, 10, 30, 60, 300},
+		}, []string{"kind"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests, m.httpDuration, m.httpInFlight,
+		m.dbDuration, m.dbErrors,
+		m.profileEvents, m.profileConflicts,
+		m.rateLimited,
+		m.screeningFindings,
+		m.notifications,
+		m.streams,
+		m.emails,
+		m.pushMessages,
+		m.cronRuns, m.digests,
+		m.jobs, m.jobDuration,
+	)
+	return m
+}
+
+// Handler отдаёт метрики в формате Prometheus.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// Registry возвращает реестр для регистрации дополнительных метрик.
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// WatchDBPool добавляет метрики заполненности пула соединений: открытые, занятые,
+// простаивающие соединения, ожидания свободного соединения и их длительность.
+func (m *Metrics) WatchDBPool(name string, db *sql.DB) {
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// Типы событий профиля для ProfileEvent.
+const (
+	EventCreated = "created"
+	EventUpdated = "updated"
+	EventDeleted = "deleted"
+)
+
+// ProfileEvent учитывает успешное изменение профиля.
+func (m *Metrics) ProfileEvent(event string) {
+	m.profileEvents.WithLabelValues(event).Inc()
+}
+
+// ProfileConflict учитывает отказ операции из-за конфликта уникальности.
+func (m *Metrics) ProfileConflict(operation string) {
+	m.profileConflicts.WithLabelValues(operation).Inc()
+}
+
+// RateLimited учитывает запрос, отклонённый ограничением частоты.
+func (m *Metrics) RateLimited(group string) {
+	m.rateLimited.WithLabelValues(group).Inc()
+}
+
+// ScreeningFinding учитывает срабатывание правила проверки текста.
+func (m *Metrics) ScreeningFinding(rule, action string) {
+	m.screeningFindings.WithLabelValues(rule, action).Inc()
+}
+
+// Notification учитывает уведомление, принятое внутренним API.
+func (m *Metrics) Notification(notificationType, result string) {
+	m.notifications.WithLabelValues(notificationType, result).Inc()
+}
+
+// StreamOpened учитывает открытый поток событий и возвращает функцию, которую нужно вызвать при его закрытии.
+func (m *Metrics) StreamOpened(transport string) (closed func()) {
+	g := m.streams.WithLabelValues(transport)
+	g.Inc()
+	return g.Dec
+}
+
+// Email учитывает результат обработки письма из очереди.
+func (m *Metrics) Email(template, result string) {
+	m.emails.WithLabelValues(template, result).Inc()
+}
+
+// Push учитывает результат обработки push-сообщения из очереди.
+func (m *Metrics) Push(event, result string) {
+	m.pushMessages.WithLabelValues(event, result).Inc()
+}
+
+// CronRun учитывает запуск задачи по расписанию.
+func (m *Metrics) CronRun(job, result string) {
+	m.cronRuns.WithLabelValues(job, result).Inc()
+}
+
+// Digest учитывает обработанный дайджест пользователя.
+func (m *Metrics) Digest(channel, result string) {
+	m.digests.WithLabelValues(channel, result).Inc()
+}
+
+// Job учитывает попытку фоновой задачи и её длительность.
+func (m *Metrics) Job(kind, result string, duration time.Duration) {
+	m.jobs.WithLabelValues(kind, result).Inc()
+	m.jobDuration.WithLabelValues(kind).Observe(duration.Seconds())
+}
@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	promtest "github.com/prometheus/client_golang/prometheus/testutil"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestMiddlewareUsesRouteTemplate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := New()
+	r := gin.New()
+	r.Use(m.Middleware())
+	r.GET("/profiles/:user_id", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for _, path := range []string{"/profiles/a", "/profiles/b", "/profiles/c", "/nope/123"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if got := promtest.ToFloat64(m.httpRequests.WithLabelValues("GET", "/profiles/:user_id", "200")); got != 3 {
+		t.Errorf("ожидали 3 запроса по шаблону маршрута, получили %v", got)
+	}
+	if got := promtest.ToFloat64(m.httpRequests.WithLabelValues("GET", unmatchedRoute, "404")); got != 1 {
+		t.Errorf("ненайденные маршруты должны попадать в %q, получили %v", unmatchedRoute, got)
+	}
+	if n := promtest.CollectAndCount(m.httpRequests); n != 2 {
+		t.Errorf("сырые пути не должны попадать в метки, временных рядов: %d", n)
+	}
+}
+
+func TestGormPlugin(t *testing.T) {
+	m := New()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:                 true,
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Use(m.GormPlugin()); err != nil {
+		t.Fatal(err)
+	}
+
+	type Profile struct{ ID int }
+	var p Profile
+	db.Table("user_profiles").First(&p)
+	db.Table("user_profiles").Where("id = ?", 1).Update("id", 2)
+
+	if n := promtest.CollectAndCount(m.dbDuration); n != 2 {
+		t.Errorf("ожидали метрики для query и update, временных рядов: %d", n)
+	}
+	if n := promtest.CollectAndCount(m.dbErrors); n != 0 {
+		t.Errorf("ошибок не было, но счётчик заполнен: %d", n)
+	}
+
+	// UPDATE без условий GORM отклоняет с ErrMissingWhereClause
+	db.Table("user_profiles").Update("id", 3)
+	if got := promtest.ToFloat64(m.dbErrors.WithLabelValues("update", "user_profiles")); got != 1 {
+		t.Errorf("ошибка update должна учитываться, получили %v", got)
+	}
+}
+
+func TestHandlerExposesBusinessCounters(t *testing.T) {
+	m := New()
+	m.ProfileEvent(EventCreated)
+	m.ProfileConflict("create")
+
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+	for _, want := range []string{
+		`user_profile_profile_events_total{event="created"} 1`,
+		`user_profile_profile_conflicts_total{operation="create"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("в выводе /metrics нет %q", want)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package middleware
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/monst/story-craft/services/user-profile-service/config"
+)
+
+// CORS применяет политику CORS из конфигурации. Политика выбирается по самому
+// длинному подходящему префиксу из cfg.Routes, иначе используется общая.
+//
+// Preflight-запрос (OPTIONS с Access-Control-Request-Method) обрабатывается целиком
+// здесь: при разрешённых origin, методе и заголовках — 204, иначе 403 без CORS-заголовков.
+// Обычные запросы с неразрешённым origin проходят дальше без CORS-заголовков,
+// и ответ блокирует уже браузер.
+//
+// Middleware подключается на уровне движка, а не группы: у маршрутов нет обработчиков
+// OPTIONS, и preflight-запрос доходит только до глобальных middleware.
+func CORS(cfg config.CORSConfig) gin.HandlerFunc {
+	def := newCORSPolicy(cfg)
+
+	routes := make([]corsRoute, 0, len(cfg.Routes))
+	for _, r := range cfg.Routes {
+		routes = append(routes, corsRoute{prefix: r.PathPrefix, policy: newCORSPolicy(mergeCORSRoute(cfg, r))})
+	}
+	sort.SliceStable(routes, func(i, j int) bool { return len(routes[i].prefix) > len(routes[j].prefix) })
+
+	return func(c *gin.Context) {
+		p := def
+		for _, r := range routes {
+			if matchPathPrefix(c.Request.URL.Path, r.prefix) {
+				p = r.policy
+				break
+			}
+		}
+
+		h := c.Writer.Header()
+		origin := c.GetHeader("Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+
+		// Ответ зависит от origin, поэтому кеши должны различать запросы по нему
+		if preflight {
+			h.Add("Vary", "Origin")
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+		} else if !p.anyOrigin {
+			h.Add("Vary", "Origin")
+		}
+
+		if origin == "" {
+			c.Next()
+			return
+		}
+		if !p.allowOrigin(origin) {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if preflight {
+			p.handlePreflight(c, origin)
+			return
+		}
+
+		p.setOrigin(h, origin)
+		if p.exposedHeaders != "" {
+			h.Set("Access-Control-Expose-Headers", p.exposedHeaders)
+		}
+		c.Next()
+	}
+}
+
+type corsRoute struct {
+	prefix string
+	policy *corsPolicy
+}
+
+// corsPolicy — разобранная политика, готовая к проверке запросов.
+type corsPolicy struct {
+	anyOrigin   bool
+	origins     map[string]bool
+	wildcards   []wildcardOrigin
+	credentials bool
+
+	methods        map[string]bool
+	allowMethods   string
+	anyHeader      bool
+	headers        map[string]bool
+	exposedHeaders string
+	maxAge         string
+}
+
+// wildcardOrigin — шаблон вида https://*.story-craft.io[:port].
+type wildcardOrigin struct {
+	scheme, suffix, port string
+}
+
+func newCORSPolicy(cfg config.CORSConfig) *corsPolicy {
+	p := &corsPolicy{
+		origins:        make(map[string]bool),
+		credentials:    cfg.AllowCredentials,
+		methods:        make(map[string]bool),
+		headers:        make(map[string]bool),
+		exposedHeaders: strings.Join(cfg.ExposedHeaders, ", "),
+	}
+
+	for _, origin := range cfg.AllowedOrigins {
+		if origin == "*" {
+			p.anyOrigin = true
+			continue
+		}
+		u, err := url.Parse(strings.ToLower(origin))
+		if err != nil {
+			continue
+		}
+		if strings.HasPrefix(u.Host, "*.") {
+			p.wildcards = append(p.wildcards, wildcardOrigin{
+				scheme: u.Scheme,
+				suffix: strings.TrimPrefix(u.Hostname(), "*"),
+				port:   u.Port(),
+			})
+			continue
+		}
+		p.origins[u.Scheme+"://"+u.Host] = true
+	}
+	// Конфигурация не допускает * с учётными данными; если политика собрана в обход
+	// проверки, учётные данные не разрешаются, а не подставляется origin запроса
+	if p.anyOrigin {
+		p.credentials = false
+	}
+
+	methods := make([]string, 0, len(cfg.AllowedMethods))
+	for _, m := range cfg.AllowedMethods {
+		m = strings.ToUpper(m)
+		p.methods[m] = true
+		methods = append(methods, m)
+	}
+	p.allowMethods = strings.Join(methods, ", ")
+
+	for _, header := range cfg.AllowedHeaders {
+		if header == "*" {
+			p.anyHeader = true
+			continue
+		}
+		p.headers[strings.ToLower(header)] = true
+	}
+
+	if cfg.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(int(cfg.MaxAge.Seconds()))
+	}
+	return p
+}
+
+// mergeCORSRoute накладывает заданные поля переопределения на общую политику.
+func mergeCORSRoute(base config.CORSConfig, r config.CORSRoute) config.CORSConfig {
+	merged := base
+	if r.AllowedOrigins != nil {
+		merged.AllowedOrigins = r.AllowedOrigins
+	}
+	if r.AllowCredentials != nil {
+		merged.AllowCredentials = *r.AllowCredentials
+	}
+	if r.AllowedMethods != nil {
+		merged.AllowedMethods = r.AllowedMethods
+	}
+	if r.AllowedHeaders != nil {
+		merged.AllowedHeaders = r.AllowedHeaders
+	}
+	if r.ExposedHeaders != nil {
+		merged.ExposedHeaders = r.ExposedHeaders
+	}
+	if r.MaxAge != nil {
+		merged.MaxAge = *r.MaxAge
+	}
+	return merged
+}
+
+func (p *corsPolicy) allowOrigin(origin string) bool {
+	origin = strings.ToLower(origin)
+	if p.origins[origin] {
+		return true
+	}
+	if p.anyOrigin {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	host := u.Hostname()
+	for _, w := range p.wildcards {
+		if u.Scheme == w.scheme && u.Port() == w.port && len(host) > len(w.suffix) && strings.HasSuffix(host, w.suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// setOrigin выставляет Access-Control-Allow-Origin: * для политики без списка
+// origin, иначе сам origin запроса.
+func (p *corsPolicy) setOrigin(h http.Header, origin string) {
+	if p.anyOrigin {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+	if p.credentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (p *corsPolicy) handlePreflight(c *gin.Context, origin string) {
+	method := strings.ToUpper(c.GetHeader("Access-Control-Request-Method"))
+	if !p.methods[method] {
+		c.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+
+	var requested []string
+	for _, header := range strings.Split(c.GetHeader("Access-Control-Request-Headers"), ",") {
+		header = strings.ToLower(strings.TrimSpace(header))
+		if header == "" {
+			continue
+		}
+		if !p.anyHeader && !p.headers[header] {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		requested = append(requested, header)
+	}
+
+	h := c.Writer.Header()
+	p.setOrigin(h, origin)
+	h.Set("Access-Control-Allow-Methods", p.allowMethods)
+	if len(requested) > 0 {
+		h.Set("Access-Control-Allow-Headers", strings.Join(requested, ", "))
+	}
+	if p.maxAge != "" {
+		h.Set("Access-Control-Max-Age", p.maxAge)
+	}
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
+// matchPathPrefix сравнивает префикс по границам сегментов: /admin подходит
+// для /admin и /admin/audit, но не для /administrators.
+func matchPathPrefix(path, prefix string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || strings.HasSuffix(prefix, "/") || path[len(prefix)] == '/'
+}
+
+// OriginChecker возвращает проверку origin по политике CORS для маршрутов с путём path.
+// Нужна для WebSocket: браузер не выполняет preflight для рукопожатия, и origin
+// проверяет сам сервер, чтобы чужой сайт не открыл соединение от имени пользователя.
+func OriginChecker(cfg config.CORSConfig, path string) func(origin string) bool {
+	p := newCORSPolicy(cfg)
+	longest := -1
+	for _, r := range cfg.Routes {
+		if matchPathPrefix(path, r.PathPrefix) && len(r.PathPrefix) > longest {
+			p, longest = newCORSPolicy(mergeCORSRoute(cfg, r)), len(r.PathPrefix)
+		}
+	}
+	return p.allowOrigin
+}
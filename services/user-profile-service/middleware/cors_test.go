@@ -0,0 +1,204 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/monst/story-craft/services/user-profile-service/config"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func newCORSRouter(cfg config.CORSConfig) *gin.Engine {
+	r := gin.New()
+	r.Use(CORS(cfg))
+	ok := func(c *gin.Context) { c.Header("ETag", `"v1"`); c.Status(http.StatusOK) }
+	r.GET("/profiles/:user_id", ok)
+	r.PATCH("/profiles/:user_id", ok)
+	r.GET("/admin/audit", ok)
+	r.GET("/administrators", ok)
+	return r
+}
+
+func baseCORSConfig() config.CORSConfig {
+	cfg := config.Default().CORS
+	cfg.AllowedOrigins = []string{"https://story-craft.io", "https://*.story-craft.io"}
+	cfg.AllowCredentials = true
+	return cfg
+}
+
+func preflight(r http.Handler, path, origin, method, headers string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodOptions, path, nil)
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", method)
+	if headers != "" {
+		req.Header.Set("Access-Control-Request-Headers", headers)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCORSPreflight(t *testing.T) {
+	r := newCORSRouter(baseCORSConfig())
+
+	cases := []struct {
+		name       string
+		origin     string
+		method     string
+		headers    string
+		wantStatus int
+		wantOrigin string
+	}{
+		{"exact origin", "https://story-craft.io", "PATCH", "content-type, authorization", http.StatusNoContent, "https://story-craft.io"},
+		{"wildcard subdomain", "https://app.story-craft.io", "PATCH", "", http.StatusNoContent, "https://app.story-craft.io"},
+		{"nested subdomain", "https://a.b.story-craft.io", "GET", "", http.StatusNoContent, "https://a.b.story-craft.io"},
+		{"origin case is ignored", "HTTPS://App.Story-Craft.io", "GET", "", http.StatusNoContent, "HTTPS://App.Story-Craft.io"},
+		{"wildcard does not match apex over http", "http://app.story-craft.io", "GET", "", http.StatusForbidden, ""},
+		{"suffix attack", "https://evilstory-craft.io", "GET", "", http.StatusForbidden, ""},
+		{"wildcard port must match", "https://app.story-craft.io:8443", "GET", "", http.StatusForbidden, ""},
+		{"unknown origin", "https://evil.example", "GET", "", http.StatusForbidden, ""},
+		{"null origin", "null", "GET", "", http.StatusForbidden, ""},
+		{"method not allowed", "https://story-craft.io", "TRACE", "", http.StatusForbidden, ""},
+		{"header not allowed", "https://story-craft.io", "PATCH", "content-type, x-debug", http.StatusForbidden, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := preflight(r, "/profiles/42", tc.origin, tc.method, tc.headers)
+			if w.Code != tc.wantStatus {
+				t.Fatalf("ожидался статус %d, получили %d", tc.wantStatus, w.Code)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tc.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, ожидалось %q", got, tc.wantOrigin)
+			}
+			if vary := w.Header().Values("Vary"); len(vary) != 3 {
+				t.Errorf("preflight должен варьироваться по Origin и Access-Control-Request-*: %v", vary)
+			}
+			if tc.wantStatus != http.StatusNoContent {
+				return
+			}
+			if w.Header().Get("Access-Control-Allow-Credentials") != "true" {
+				t.Error("ожидался Access-Control-Allow-Credentials: true")
+			}
+			if got := w.Header().Get("Access-Control-Max-Age"); got != "600" {
+				t.Errorf("Access-Control-Max-Age = %q, ожидалось 600", got)
+			}
+			if tc.headers != "" && w.Header().Get("Access-Control-Allow-Headers") != tc.headers {
+				t.Errorf("Access-Control-Allow-Headers = %q, ожидалось %q", w.Header().Get("Access-Control-Allow-Headers"), tc.headers)
+			}
+		})
+	}
+}
+
+func TestCORSWildcardNeverReflectsOrigin(t *testing.T) {
+	cfg := config.Default().CORS
+	cfg.AllowCredentials = true
+	r := newCORSRouter(cfg)
+
+	w := preflight(r, "/profiles/42", "https://anything.example", "GET", "")
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("для * origin запроса не должен подставляться, получили %q", got)
+	}
+	if w.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Error("* нельзя отдавать вместе с Access-Control-Allow-Credentials")
+	}
+}
+
+func TestCORSWildcardWithoutCredentials(t *testing.T) {
+	r := newCORSRouter(config.Default().CORS)
+
+	req := httptest.NewRequest(http.MethodGet, "/profiles/42", nil)
+	req.Header.Set("Origin", "https://anything.example")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Access-Control-Allow-Origin = %q, ожидалось *", got)
+	}
+	if w.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Error("* нельзя отдавать вместе с Access-Control-Allow-Credentials")
+	}
+	if w.Header().Get("Vary") != "" {
+		t.Errorf("ответ с * не зависит от origin, Vary не нужен: %q", w.Header().Get("Vary"))
+	}
+}
+
+func TestCORSActualRequest(t *testing.T) {
+	r := newCORSRouter(baseCORSConfig())
+
+	req := httptest.NewRequest(http.MethodGet, "/profiles/42", nil)
+	req.Header.Set("Origin", "https://app.story-craft.io")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("ожидался 200, получили %d", w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Expose-Headers"); got != "ETag, X-Request-ID, X-Trace-Id, Idempotent-Replayed" {
+		t.Errorf("Access-Control-Expose-Headers = %q", got)
+	}
+	if w.Header().Get("Vary") != "Origin" {
+		t.Errorf("ожидался Vary: Origin, получили %q", w.Header().Get("Vary"))
+	}
+
+	// Запрос с чужого origin выполняется, но без CORS-заголовков
+	req = httptest.NewRequest(http.MethodGet, "/profiles/42", nil)
+	req.Header.Set("Origin", "https://evil.example")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("чужой origin не должен получать CORS-заголовки: %d %v", w.Code, w.Header())
+	}
+}
+
+func TestCORSOptionsWithoutPreflightHeaders(t *testing.T) {
+	r := newCORSRouter(baseCORSConfig())
+
+	req := httptest.NewRequest(http.MethodOptions, "/profiles/42", nil)
+	req.Header.Set("Origin", "https://story-craft.io")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Header().Get("Access-Control-Allow-Methods") != "" {
+		t.Error("OPTIONS без Access-Control-Request-Method не является preflight-запросом")
+	}
+}
+
+func TestCORSRouteOverride(t *testing.T) {
+	cfg := baseCORSConfig()
+	noCredentials := false
+	maxAge := time.Minute
+	cfg.Routes = []config.CORSRoute{
+		{PathPrefix: "/admin", AllowedOrigins: []string{"https://admin.story-craft.io"}, AllowedMethods: []string{"GET"}},
+		{PathPrefix: "/admin/audit", AllowCredentials: &noCredentials, MaxAge: &maxAge},
+	}
+	r := newCORSRouter(cfg)
+
+	if w := preflight(r, "/admin/audit", "https://app.story-craft.io", "GET", ""); w.Code != http.StatusNoContent {
+		t.Errorf("/admin/audit наследует origin из общей политики, получили %d", w.Code)
+	} else {
+		if w.Header().Get("Access-Control-Allow-Credentials") != "" {
+			t.Error("для /admin/audit учётные данные отключены")
+		}
+		if w.Header().Get("Access-Control-Max-Age") != "60" {
+			t.Errorf("Max-Age для /admin/audit = %q, ожидалось 60", w.Header().Get("Access-Control-Max-Age"))
+		}
+	}
+
+	cfg.Routes = cfg.Routes[:1]
+	r = newCORSRouter(cfg)
+	if w := preflight(r, "/admin/audit", "https://app.story-craft.io", "GET", ""); w.Code != http.StatusForbidden {
+		t.Errorf("для /admin разрешён только admin.story-craft.io, получили %d", w.Code)
+	}
+	if w := preflight(r, "/admin/audit", "https://admin.story-craft.io", "DELETE", ""); w.Code != http.StatusForbidden {
+		t.Errorf("для /admin разрешён только GET, получили %d", w.Code)
+	}
+	if w := preflight(r, "/administrators", "https://app.story-craft.io", "GET", ""); w.Code != http.StatusNoContent {
+		t.Errorf("префикс /admin не должен применяться к /administrators, получили %d", w.Code)
+	}
+}
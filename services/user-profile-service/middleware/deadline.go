@@ -0,0 +1,26 @@
+// Package middleware содержит общие middleware для gin-роутера сервиса.
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// QueryDeadline задаёт дедлайн контексту запроса. Обработчики передают этот контекст
+// в GORM через WithContext, поэтому запросы к БД прерываются, когда время вышло
+// или клиент закрыл соединение.
+func QueryDeadline(timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
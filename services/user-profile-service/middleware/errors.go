@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/monst/story-craft/services/user-profile-service/tracing"
+)
+
+// ErrorBody собирает тело ответа с ошибкой в общем для сервиса формате
+// {"error": ..., "trace_id": ...}, дополненное полями extra. Ответы с ошибкой
+// и в middleware, и в обработчиках строятся только через неё.
+func ErrorBody(c *gin.Context, message string, extra gin.H) gin.H {
+	body := gin.H{"error": message}
+	for k, v := range extra {
+		body[k] = v
+	}
+	if traceID := tracing.TraceID(c.Request.Context()); traceID != "" {
+		body["trace_id"] = traceID
+	}
+	return body
+}
+
+// AbortWithError прерывает обработку и отвечает ошибкой в формате ErrorBody.
+func AbortWithError(c *gin.Context, status int, message string) {
+	c.AbortWithStatusJSON(status, ErrorBody(c, message, nil))
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InternalTokenHeader — заголовок с общим секретом, которым другие сервисы подписывают
+// вызовы внутреннего API. Эти маршруты не должны публиковаться через API-шлюз.
+const InternalTokenHeader = "X-Internal-Token"
+
+// RequireInternalToken пропускает запросы с заголовком X-Internal-Token, равным token.
+// Пустой token отключает маршрут: без настроенного секрета внутренний API недоступен.
+func RequireInternalToken(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			AbortWithError(c, http.StatusServiceUnavailable, "Внутренний API не настроен")
+			return
+		}
+		got := c.GetHeader(InternalTokenHeader)
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			AbortWithError(c, http.StatusUnauthorized, "Неверный внутренний токен")
+			return
+		}
+		c.Next()
+	}
+}
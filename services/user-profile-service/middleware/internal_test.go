@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequireInternalToken(t *testing.T) {
+	cases := []struct {
+		name       string
+		configured string
+		sent       string
+		want       int
+	}{
+		{"верный токен", "secret", "secret", http.StatusOK},
+		{"неверный токен", "secret", "guess", http.StatusUnauthorized},
+		{"без токена", "secret", "", http.StatusUnauthorized},
+		{"не настроен", "", "", http.StatusServiceUnavailable},
+	}
+	for _, tc := range cases {
+		r := gin.New()
+		r.POST("/internal", RequireInternalToken(tc.configured), func(c *gin.Context) { c.Status(http.StatusOK) })
+		req := httptest.NewRequest(http.MethodPost, "/internal", nil)
+		if tc.sent != "" {
+			req.Header.Set(InternalTokenHeader, tc.sent)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tc.want {
+			t.Errorf("%s: ожидали %d, получили %d", tc.name, tc.want, w.Code)
+		}
+	}
+}
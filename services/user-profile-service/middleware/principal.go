@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"encoding/json"
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/monst/story-craft/services/user-profile-service/logging"
+)
+
+// UserObjectHeader — заголовок, в котором API-шлюз передаёт содержимое проверенного JWT.
+const UserObjectHeader = "x-user-object"
+
+const principalKey = "principal"
+
+// Principal — пользователь, от имени которого выполняется запрос.
+type Principal struct {
+	UserID string `json:"userId"`
+	Role   string `json:"role,omitempty"`
+}
+
+// Authenticate разбирает x-user-object и сохраняет Principal в контексте запроса.
+// Запросы без заголовка или с некорректным заголовком проходят дальше как анонимные:
+// обязательность авторизации проверяют сами обработчики.
+func Authenticate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw := c.GetHeader(UserObjectHeader)
+		if raw == "" {
+			c.Next()
+			return
+		}
+
+		var p Principal
+		if err := json.Unmarshal([]byte(raw), &p); err != nil || p.UserID == "" {
+			logging.FromContext(c.Request.Context()).Warn("Некорректный заголовок x-user-object, запрос обрабатывается как анонимный",
+				slog.Any("error", err))
+			c.Next()
+			return
+		}
+
+		c.Set(principalKey, p)
+		c.Next()
+	}
+}
+
+// CurrentPrincipal возвращает пользователя, установленного Authenticate.
+func CurrentPrincipal(c *gin.Context) (Principal, bool) {
+	p, ok := c.Get(principalKey)
+	if !ok {
+		return Principal{}, false
+	}
+	principal, ok := p.(Principal)
+	return principal, ok
+}
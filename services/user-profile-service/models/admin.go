@@ -0,0 +1,44 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type ChangeRole struct {
+	Role   string `json:"role" example:"AUTHOR" binding:"required" swaggertype:"string"`
+	Reason string `json:"reason" example:"Подтверждён статус автора" swaggertype:"string"`
+} // @name ChangeRole
+
+// Moderation — причина и срок блокировки или бана. Без Until бан бессрочный.
+type Moderation struct {
+	Reason string     `json:"reason" example:"Спам в описании профиля" binding:"required" swaggertype:"string"`
+	Until  *time.Time `json:"until" example:"2030-01-01T00:00:00Z" swaggertype:"string"`
+} // @name Moderation
+
+// ResetFields — поля профиля, которые администратор сбрасывает к пустому значению.
+type ResetFields struct {
+	Fields []string `json:"fields" example:"display_name,bio,avatar_url" binding:"required"`
+	Reason string   `json:"reason" example:"Оскорбительное содержимое" binding:"required" swaggertype:"string"`
+} // @name ResetFields
+
+// AuditEntry — запись журнала изменений профилей. Таблица только для добавления:
+// Hash считается от полей записи и PrevHash, хеша предыдущей записи.
+type AuditEntry struct {
+	ID           uint64          `gorm:"primaryKey" json:"id"`
+	ActorID      string          `gorm:"size:255;not null;index" json:"actor_id"`
+	Action       string          `gorm:"size:64;not null;index" json:"action"`
+	TargetUserID string          `gorm:"size:255;index" json:"target_user_id"`
+	Reason       string          `gorm:"type:text" json:"reason,omitempty"`
+	Diff         json.RawMessage `gorm:"type:jsonb" json:"diff,omitempty" swaggertype:"object"`
+	Details      json.RawMessage `gorm:"type:jsonb" json:"details,omitempty" swaggertype:"object"`
+	RequestID    string          `gorm:"size:128" json:"request_id,omitempty"`
+	IP           string          `gorm:"size:64" json:"ip,omitempty"`
+	PrevHash     string          `gorm:"size:64;not null;default:''" json:"prev_hash"`
+	Hash         string          `gorm:"size:64;not null;default:''" json:"hash"`
+	CreatedAt    time.Time       `gorm:"type:timestamp;not null;default:now();index" json:"created_at"`
+} // @name AuditEntry
+
+func (AuditEntry) TableName() string {
+	return "audit_log"
+}
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// EventDigest — тип уведомления во входящих со сводкой за день или неделю.
+const EventDigest = "digest"
+
+// Частота дайджеста.
+const (
+	DigestOff    = "off"
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// DigestFrequencies — все значения частоты дайджеста.
+var DigestFrequencies = []string{DigestOff, DigestDaily, DigestWeekly}
+
+// DigestChannels — куда приходит дайджест: письмом или уведомлением во входящих.
+var DigestChannels = []string{ChannelEmail, ChannelInApp}
+
+// DigestSettings — расписание дайджеста пользователя: сводка непрочитанных уведомлений
+// раз в день или неделю в час SendHour по часовому поясу пользователя.
+type DigestSettings struct {
+	UserID    string `gorm:"type:uuid;primaryKey" json:"-"`
+	Frequency string `gorm:"size:10;not null;default:off" json:"frequency" example:"daily"`
+	// Channel — email или in_app.
+	Channel string `gorm:"size:10;not null;default:email" json:"channel" example:"email"`
+	// SendHour — час отправки от 0 до 23 по часовому поясу TimeZone.
+	SendHour int `gorm:"not null;default:9" json:"send_hour" example:"9"`
+	// Weekday — день недели еженедельного дайджеста: 0 — воскресенье, 1 — понедельник.
+	Weekday  int    `gorm:"not null;default:1" json:"weekday" example:"1"`
+	TimeZone string `gorm:"size:64;not null;default:UTC" json:"time_zone" example:"Europe/Moscow"`
+	// NextSendAt — когда будет собран следующий дайджест; пусто, если дайджест выключен.
+	NextSendAt *time.Time `gorm:"type:timestamp;index" json:"next_send_at,omitempty"`
+	// LastSentAt — когда собран предыдущий дайджест: следующий включает уведомления после него.
+	LastSentAt *time.Time `gorm:"type:timestamp" json:"last_sent_at,omitempty"`
+	UpdatedAt  time.Time  `gorm:"type:timestamp" json:"updated_at"`
+} // @name DigestSettings
+
+// TableName определяет имя таблицы в базе данных
+func (DigestSettings) TableName() string {
+	return "digest_settings"
+}
+
+// DefaultDigestSettings — дайджест выключен, письмо в 9 утра по UTC, еженедельный — по понедельникам.
+func DefaultDigestSettings(userID string) DigestSettings {
+	return DigestSettings{
+		UserID:    userID,
+		Frequency: DigestOff,
+		Channel:   ChannelEmail,
+		SendHour:  9,
+		Weekday:   int(time.Monday),
+		TimeZone:  "UTC",
+	}
+}
+
+// DigestSettingsPatch — изменение расписания дайджеста: поля, которых нет в запросе, не меняются.
+type DigestSettingsPatch struct {
+	Frequency *string `json:"frequency,omitempty" example:"weekly"`
+	Channel   *string `json:"channel,omitempty" example:"in_app"`
+	SendHour  *int    `json:"send_hour,omitempty" example:"20"`
+	Weekday   *int    `json:"weekday,omitempty" example:"5"`
+	TimeZone  *string `json:"time_zone,omitempty" example:"Asia/Kolkata"`
+} // @name DigestSettingsPatch
+
+// Apply возвращает настройки s с изменениями из p.
+func (p DigestSettingsPatch) Apply(s DigestSettings) DigestSettings {
+	if p.Frequency != nil {
+		s.Frequency = *p.Frequency
+	}
+	if p.Channel != nil {
+		s.Channel = *p.Channel
+	}
+	if p.SendHour != nil {
+		s.SendHour = *p.SendHour
+	}
+	if p.Weekday != nil {
+		s.Weekday = *p.Weekday
+	}
+	if p.TimeZone != nil {
+		s.TimeZone = *p.TimeZone
+	}
+	return s
+}
+
+// Validate проверяет настройки из запроса и возвращает описание первой ошибки.
+func (s DigestSettings) Validate() error {
+	if !slices.Contains(DigestFrequencies, s.Frequency) {
+		return fmt.Errorf("frequency: ожидалось off, daily или weekly, получено %q", s.Frequency)
+	}
+	if !slices.Contains(DigestChannels, s.Channel) {
+		return fmt.Errorf("channel: ожидалось email или in_app, получено %q", s.Channel)
+	}
+	if s.SendHour < 0 || s.SendHour > 23 {
+		return fmt.Errorf("send_hour: ожидался час от 0 до 23, получено %d", s.SendHour)
+	}
+	if s.Weekday < 0 || s.Weekday > 6 {
+		return fmt.Errorf("weekday: ожидался день недели от 0 (воскресенье) до 6, получено %d", s.Weekday)
+	}
+	if _, err := time.LoadLocation(s.TimeZone); err != nil || s.TimeZone == "" {
+		return fmt.Errorf("time_zone: неизвестный часовой пояс %q", s.TimeZone)
+	}
+	return nil
+}
+
+// Period — за какой срок собирается дайджест.
+func (s DigestSettings) Period() time.Duration {
+	if s.Frequency == DigestWeekly {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// Next возвращает первый после after момент отправки: SendHour:00 по местному времени,
+// для еженедельного дайджеста — в день Weekday. Если в этот час местное время
+// переводится вперёд, отправка сдвигается на первый существующий момент после него.
+// Для выключенного дайджеста возвращает nil.
+func (s DigestSettings) Next(after time.Time) *time.Time {
+	if s.Frequency != DigestDaily && s.Frequency != DigestWeekly {
+		return nil
+	}
+	loc, err := time.LoadLocation(s.TimeZone)
+	if err != nil {
+		loc = time.UTC
+	}
+	local := after.In(loc)
+	for day := 0; day <= 7; day++ {
+		at := time.Date(local.Year(), local.Month(), local.Day()+day, s.SendHour, 0, 0, 0, loc)
+		if s.Frequency == DigestWeekly && int(at.Weekday()) != s.Weekday {
+			continue
+		}
+		if at.After(after) {
+			next := at.UTC()
+			return &next
+		}
+	}
+	return nil
+}
+
+// DigestGroup — уведомления одного типа об одной истории в дайджесте.
+type DigestGroup struct {
+	Type       string `json:"type" example:"new_chapter"`
+	StoryID    string `json:"story_id,omitempty"`
+	StoryTitle string `json:"story_title,omitempty"`
+	Count      int    `json:"count" example:"3"`
+	// LatestAt — время последнего уведомления группы.
+	LatestAt time.Time `json:"latest_at"`
+	// Link — ссылка на историю в письме, заполняется при отправке.
+	Link string `json:"-"`
+} // @name DigestGroup
+
+// DigestSummary — содержимое дайджеста: данные уведомления digest во входящих и письма.
+type DigestSummary struct {
+	Frequency string        `json:"frequency" example:"daily"`
+	Since     time.Time     `json:"since"`
+	Until     time.Time     `json:"until"`
+	Total     int           `json:"total" example:"12"`
+	Groups    []DigestGroup `json:"groups"`
+	// Omitted — сколько уведомлений не вошло в группы сверх DIGEST_MAX_GROUPS.
+	Omitted int `json:"omitted,omitempty"`
+} // @name DigestSummary
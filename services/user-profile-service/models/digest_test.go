@@ -0,0 +1,77 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDigestSettingsNext(t *testing.T) {
+	at := func(s string) time.Time {
+		t.Helper()
+		v, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	daily := DefaultDigestSettings("u")
+	daily.Frequency = DigestDaily
+	daily.SendHour = 9
+	daily.TimeZone = "Europe/Moscow"
+	weekly := daily
+	weekly.Frequency = DigestWeekly
+	weekly.Weekday = int(time.Friday)
+	kolkata := daily
+	kolkata.TimeZone = "Asia/Kolkata"
+	// 2026-03-08 — переход на летнее время в Нью-Йорке: 9 утра по местному времени — уже 13:00 UTC
+	newYork := daily
+	newYork.TimeZone = "America/New_York"
+
+	cases := []struct {
+		name     string
+		settings DigestSettings
+		after    string
+		want     string
+	}{
+		{"сегодня позже", daily, "2026-03-10T05:00:00Z", "2026-03-10T06:00:00Z"},
+		{"ровно в час отправки — следующий день", daily, "2026-03-10T06:00:00Z", "2026-03-11T06:00:00Z"},
+		{"полчаса сдвига", kolkata, "2026-03-10T00:00:00Z", "2026-03-10T03:30:00Z"},
+		{"до перехода на летнее время", newYork, "2026-03-07T15:00:00Z", "2026-03-08T13:00:00Z"},
+		{"ближайшая пятница", weekly, "2026-03-10T05:00:00Z", "2026-03-13T06:00:00Z"},
+		{"в пятницу после отправки — через неделю", weekly, "2026-03-13T07:00:00Z", "2026-03-20T06:00:00Z"},
+	}
+	for _, tc := range cases {
+		got := tc.settings.Next(at(tc.after))
+		if got == nil || !got.Equal(at(tc.want)) {
+			t.Errorf("%s: ожидали %s, получили %v", tc.name, tc.want, got)
+		}
+	}
+
+	if next := DefaultDigestSettings("u").Next(time.Now()); next != nil {
+		t.Errorf("выключенный дайджест не планируется, получили %v", next)
+	}
+}
+
+func TestDigestSettingsValidate(t *testing.T) {
+	weekly, hour, zone := DigestWeekly, 23, "Asia/Kolkata"
+	valid := DigestSettingsPatch{Frequency: &weekly, SendHour: &hour, TimeZone: &zone}.Apply(DefaultDigestSettings("u"))
+	if err := valid.Validate(); err != nil || valid.Channel != ChannelEmail || valid.Weekday != int(time.Monday) {
+		t.Fatalf("изменение должно сохранить непереданные поля: %+v, %v", valid, err)
+	}
+
+	cases := map[string]func(*DigestSettings){
+		"частота":      func(s *DigestSettings) { s.Frequency = "hourly" },
+		"канал":        func(s *DigestSettings) { s.Channel = ChannelWebPush },
+		"час":          func(s *DigestSettings) { s.SendHour = 24 },
+		"день недели":  func(s *DigestSettings) { s.Weekday = 7 },
+		"часовой пояс": func(s *DigestSettings) { s.TimeZone = "Mars/Olympus" },
+		"пустой пояс":  func(s *DigestSettings) { s.TimeZone = "" },
+	}
+	for name, modify := range cases {
+		s := DefaultDigestSettings("u")
+		modify(&s)
+		if err := s.Validate(); err == nil {
+			t.Errorf("%s: ожидали ошибку", name)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Состояния письма в очереди.
+const (
+	EmailPending = "pending"
+	// EmailSending — письмо забрано на отправку до NextAttemptAt; если экземпляр сервиса
+	// за это время не отметил результат, письмо снова становится доступным.
+	EmailSending   = "sending"
+	EmailSent      = "sent"
+	EmailFailed    = "failed"
+	EmailCancelled = "cancelled"
+)
+
+// EmailMessage — письмо в очереди отправки. Текст письма собирается из шаблона Template
+// при отправке, адрес и язык берутся из профиля получателя на тот же момент.
+type EmailMessage struct {
+	ID          int64           `gorm:"primaryKey" json:"id"`
+	RecipientID string          `gorm:"type:uuid;not null;index;uniqueIndex:idx_email_messages_dedup,priority:1,where:dedup_key IS NOT NULL" json:"recipient_id"`
+	Template    string          `gorm:"size:50;not null" json:"template" example:"proposal_won"`
+	Data        json.RawMessage `gorm:"type:jsonb" json:"data,omitempty" swaggertype:"object"`
+	// DedupKey не даёт поставить одно и то же письмо получателю дважды.
+	DedupKey      *string    `gorm:"size:255;uniqueIndex:idx_email_messages_dedup,priority:2" json:"-"`
+	Status        string     `gorm:"size:20;not null;default:'pending';index:idx_email_messages_due,priority:1" json:"status"`
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt time.Time  `gorm:"type:timestamp;not null;default:now();index:idx_email_messages_due,priority:2" json:"next_attempt_at"`
+	LastError     string     `gorm:"type:text" json:"last_error,omitempty"`
+	ToAddress     string     `gorm:"size:255" json:"to_address,omitempty"`
+	SentAt        *time.Time `gorm:"type:timestamp" json:"sent_at,omitempty"`
+	CreatedAt     time.Time  `gorm:"type:timestamp;not null;default:now()" json:"created_at"`
+} // @name EmailMessage
+
+// TableName определяет имя таблицы в базе данных
+func (EmailMessage) TableName() string {
+	return "email_messages"
+}
+
+// Виды отказов доставки, о которых сообщает почтовый сервер или провайдер.
+const (
+	// BounceHard — адреса не существует или он навсегда отклоняет почту.
+	BounceHard = "hard"
+	// BounceSoft — временная ошибка: переполнен ящик, сервер недоступен.
+	BounceSoft = "soft"
+	// BounceComplaint — получатель отметил письмо как спам.
+	BounceComplaint = "complaint"
+)
+
+// EmailBounce — сообщение внутреннего API об отказе доставки на адрес.
+type EmailBounce struct {
+	Address string `json:"address" binding:"required" example:"user@example.com"`
+	Type    string `json:"type" binding:"required" example:"hard"`
+	Reason  string `json:"reason" example:"550 5.1.1 user unknown"`
+} // @name EmailBounce
+
+// EmailChange — запрос смены адреса или подтверждения текущего (тогда NewEmail равен OldEmail).
+// Адрес в профиле меняется, только когда владелец переходит по ссылке из письма на NewEmail;
+// после смены на OldEmail уходит ссылка отмены, действующая до RevertExpiresAt.
+type EmailChange struct {
+	ID       int64  `gorm:"primaryKey" json:"id"`
+	UserID   string `gorm:"type:uuid;not null;index" json:"user_id"`
+	OldEmail string `gorm:"size:255;not null" json:"old_email"`
+	NewEmail string `gorm:"size:255;not null" json:"new_email"`
+	// ExpiresAt — срок ссылки подтверждения.
+	ExpiresAt   time.Time  `gorm:"type:timestamp;not null" json:"expires_at"`
+	ConfirmedAt *time.Time `gorm:"type:timestamp" json:"confirmed_at,omitempty"`
+	// RevertExpiresAt — срок ссылки отмены; задаётся при подтверждении смены адреса.
+	RevertExpiresAt *time.Time `gorm:"type:timestamp" json:"revert_expires_at,omitempty"`
+	RevertedAt      *time.Time `gorm:"type:timestamp" json:"reverted_at,omitempty"`
+	// CancelledAt — запрос заменён более новым, его ссылка больше не действует.
+	CancelledAt *time.Time `gorm:"type:timestamp" json:"cancelled_at,omitempty"`
+	CreatedAt   time.Time  `gorm:"type:timestamp;not null;default:now()" json:"created_at"`
+}
+
+// TableName определяет имя таблицы в базе данных
+func (EmailChange) TableName() string {
+	return "email_changes"
+}
+
+// EmailStatus — адрес пользователя и его подтверждение для других сервисов.
+type EmailStatus struct {
+	UserID          string     `json:"user_id"`
+	Email           string     `json:"email"`
+	Verified        bool       `json:"verified"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	// Deliverable — письма на адрес доставляются: нет жёсткого отказа.
+	Deliverable bool `json:"deliverable"`
+} // @name EmailStatus
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// IdempotencyKey — сохранённый результат запроса с заголовком Idempotency-Key.
+// Ключ уникален в пределах пользователя. Пока запрос выполняется, Completed = false,
+// а LockedAt показывает, когда обработку начали.
+type IdempotencyKey struct {
+	UserID       string    `gorm:"size:255;primaryKey" json:"user_id"`
+	Key          string    `gorm:"size:255;primaryKey" json:"key"`
+	RequestHash  string    `gorm:"size:64;not null" json:"request_hash"`
+	Completed    bool      `gorm:"not null;default:false" json:"completed"`
+	StatusCode   int       `json:"status_code"`
+	ContentType  string    `gorm:"size:255" json:"content_type"`
+	ResponseBody []byte    `gorm:"type:bytea" json:"-"`
+	LockedAt     time.Time `gorm:"type:timestamp;not null" json:"locked_at"`
+	CreatedAt    time.Time `gorm:"type:timestamp;not null;default:now()" json:"created_at"`
+	ExpiresAt    time.Time `gorm:"type:timestamp;not null;index" json:"expires_at"`
+}
+
+func (IdempotencyKey) TableName() string {
+	return "idempotency_keys"
+}
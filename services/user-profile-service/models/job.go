@@ -0,0 +1,44 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Состояния фоновой задачи в очереди.
+const (
+	JobPending = "pending"
+	// JobRunning — задача выполняется до RunAt; если экземпляр сервиса за это время
+	// не отметил результат, задача снова становится доступной.
+	JobRunning = "running"
+	JobDone    = "done"
+	// JobFailed — попытки закончились или ошибка постоянная; задачу можно повторить через /admin/jobs.
+	JobFailed = "failed"
+)
+
+// JobStatuses — допустимые значения фильтра status в списке задач.
+var JobStatuses = []string{JobPending, JobRunning, JobDone, JobFailed}
+
+// Job — фоновая задача в очереди. Обработчик выбирается по Kind, аргументы
+// обработчика хранятся в Payload.
+type Job struct {
+	ID   int64  `gorm:"primaryKey" json:"id"`
+	Kind string `gorm:"size:100;not null;index" json:"kind" example:"digest.send"`
+	// UniqueKey — пока задача с этим ключом ждёт или выполняется, такая же не ставится.
+	UniqueKey *string         `gorm:"size:255;uniqueIndex:idx_jobs_unique,where:unique_key IS NOT NULL AND (status = 'pending' OR status = 'running')" json:"unique_key,omitempty"`
+	Payload   json.RawMessage `gorm:"type:jsonb" json:"payload,omitempty" swaggertype:"object"`
+	Status    string          `gorm:"size:20;not null;default:'pending';index:idx_jobs_due,priority:1" json:"status"`
+	Attempts  int             `gorm:"not null;default:0" json:"attempts"`
+	// MaxAttempts — после стольких ошибок задача получает статус failed.
+	MaxAttempts int `gorm:"not null" json:"max_attempts"`
+	// RunAt — когда задачу можно забрать: время запуска или повтора, у выполняемой — конец аренды.
+	RunAt      time.Time  `gorm:"type:timestamp;not null;default:now();index:idx_jobs_due,priority:2" json:"run_at"`
+	LastError  string     `gorm:"type:text" json:"last_error,omitempty"`
+	CreatedAt  time.Time  `gorm:"type:timestamp;not null;default:now()" json:"created_at"`
+	FinishedAt *time.Time `gorm:"type:timestamp;index" json:"finished_at,omitempty"`
+} // @name Job
+
+// TableName определяет имя таблицы в базе данных
+func (Job) TableName() string {
+	return "jobs"
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Notification — уведомление во входящих пользователя. Тип совпадает с событием
+// из настроек уведомлений, Payload — данные для отображения (история, глава, автор).
+type Notification struct {
+	ID          int64           `gorm:"primaryKey;index:idx_notifications_recipient,priority:2" json:"id"`
+	RecipientID string          `gorm:"type:uuid;not null;index:idx_notifications_recipient,priority:1;uniqueIndex:idx_notifications_dedup,priority:1,where:dedup_key IS NOT NULL" json:"recipient_id"`
+	Type        string          `gorm:"size:50;not null" json:"type" example:"voting_opened"`
+	Payload     json.RawMessage `gorm:"type:jsonb" json:"payload,omitempty" swaggertype:"object"`
+	// DedupKey — ключ отправителя: повторная доставка получателю с тем же ключом не создаёт второе уведомление.
+	DedupKey  *string    `gorm:"size:255;uniqueIndex:idx_notifications_dedup,priority:2" json:"-"`
+	ReadAt    *time.Time `gorm:"type:timestamp" json:"read_at,omitempty"`
+	CreatedAt time.Time  `gorm:"type:timestamp;not null;default:now()" json:"created_at"`
+	// ExpiresAt — после этого момента уведомление не показывается и удаляется фоновой очисткой.
+	ExpiresAt time.Time `gorm:"type:timestamp;not null;index" json:"expires_at"`
+} // @name Notification
+
+// IsRead сообщает, прочитано ли уведомление.
+func (n Notification) IsRead() bool {
+	return n.ReadAt != nil
+}
+
+// IngestNotification — запрос внутреннего API на создание уведомлений для нескольких получателей.
+type IngestNotification struct {
+	Type         string          `json:"type" binding:"required" example:"voting_opened"`
+	RecipientIDs []string        `json:"recipient_ids" binding:"required" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Payload      json.RawMessage `json:"payload" swaggertype:"object"`
+	// DedupKey — идентификатор события у отправителя, уникален для каждого получателя.
+	DedupKey string `json:"dedup_key" example:"story-42:voting-3"`
+	// TTL — срок хранения, например 72h; по умолчанию берётся из конфигурации.
+	TTL string `json:"ttl" example:"72h"`
+} // @name IngestNotification
+
+// NotificationPage — страница входящих. NextCursor пуст, если уведомлений больше нет.
+type NotificationPage struct {
+	Items       []Notification `json:"items"`
+	NextCursor  string         `json:"next_cursor,omitempty"`
+	UnreadCount int64          `json:"unread_count"`
+} // @name NotificationPage
+
+// TableName определяет имя таблицы в базе данных
+func (Notification) TableName() string {
+	return "notifications"
+}
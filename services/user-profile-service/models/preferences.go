@@ -0,0 +1,304 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"time"
+)
+
+// События, о которых уведомляется пользователь.
+const (
+	EventNewChapter   = "new_chapter"
+	EventVotingOpened = "voting_opened"
+	EventVotingClosed = "voting_closed"
+	EventProposalWon  = "proposal_won"
+	EventNewFollower  = "new_follower"
+)
+
+// NotificationEvents — все события в порядке показа в настройках.
+var NotificationEvents = []string{EventNewChapter, EventVotingOpened, EventVotingClosed, EventProposalWon, EventNewFollower}
+
+// Каналы доставки уведомлений.
+const (
+	ChannelInApp   = "in_app"
+	ChannelEmail   = "email"
+	ChannelWebPush = "web_push"
+)
+
+// NotificationChannels — все каналы доставки.
+var NotificationChannels = []string{ChannelInApp, ChannelEmail, ChannelWebPush}
+
+// EmailLanguages — языки, на которых есть шаблоны писем.
+var EmailLanguages = []string{"ru", "en"}
+
+// PreferencesVersion — текущая версия формата настроек уведомлений. Увеличивается
+// при изменении формата вместе с добавлением шага в preferenceMigrations.
+const PreferencesVersion = 1
+
+// EventChannels — по каким каналам приходит уведомление о событии.
+type EventChannels struct {
+	InApp   bool `json:"in_app"`
+	Email   bool `json:"email"`
+	WebPush bool `json:"web_push"`
+} // @name EventChannels
+
+// Enabled сообщает, включён ли канал channel.
+func (c EventChannels) Enabled(channel string) bool {
+	switch channel {
+	case ChannelInApp:
+		return c.InApp
+	case ChannelEmail:
+		return c.Email
+	case ChannelWebPush:
+		return c.WebPush
+	}
+	return false
+}
+
+// QuietHours — время, когда уведомления по email и push не отправляются.
+// Start и End — время суток HH:MM в часовом поясе TimeZone; интервал может переходить через полночь.
+type QuietHours struct {
+	Enabled  bool   `json:"enabled"`
+	Start    string `json:"start" example:"22:00"`
+	End      string `json:"end" example:"08:00"`
+	TimeZone string `json:"time_zone" example:"Europe/Moscow"`
+} // @name QuietHours
+
+// Active сообщает, приходится ли момент t на тихие часы.
+func (q QuietHours) Active(t time.Time) bool {
+	if !q.Enabled {
+		return false
+	}
+	loc, err := time.LoadLocation(q.TimeZone)
+	if err != nil {
+		loc = time.UTC
+	}
+	start, errStart := minuteOfDay(q.Start)
+	end, errEnd := minuteOfDay(q.End)
+	if errStart != nil || errEnd != nil || start == end {
+		return false
+	}
+	local := t.In(loc)
+	now := local.Hour()*60 + local.Minute()
+	if start < end {
+		return now >= start && now < end
+	}
+	return now >= start || now < end
+}
+
+// Ends возвращает ближайший после t момент окончания тихих часов.
+func (q QuietHours) Ends(t time.Time) time.Time {
+	loc, err := time.LoadLocation(q.TimeZone)
+	if err != nil {
+		loc = time.UTC
+	}
+	end, err := minuteOfDay(q.End)
+	if err != nil {
+		return t
+	}
+	local := t.In(loc)
+	ends := time.Date(local.Year(), local.Month(), local.Day(), end/60, end%60, 0, 0, loc)
+	if !ends.After(local) {
+		ends = ends.AddDate(0, 0, 1)
+	}
+	return ends
+}
+
+var clockPattern = regexp.MustCompile(`^([01]\d|2[0-3]):[0-5]\d$`)
+
+func minuteOfDay(s string) (int, error) {
+	if !clockPattern.MatchString(s) {
+		return 0, fmt.Errorf("ожидалось время HH:MM, получено %q", s)
+	}
+	t, _ := time.Parse("15:04", s)
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// NotificationPreferences — настройки уведомлений пользователя. Хранятся в профиле
+// как jsonb с номером версии: при чтении документ старой версии приводится к текущей.
+type NotificationPreferences struct {
+	Version    int                      `json:"version" example:"1"`
+	Events     map[string]EventChannels `json:"events"`
+	QuietHours QuietHours               `json:"quiet_hours"`
+	// Language — язык писем: ru или en; пустой — язык сервиса по умолчанию.
+	Language string `json:"language,omitempty" example:"ru"`
+} // @name NotificationPreferences
+
+// DefaultNotificationPreferences — настройки нового пользователя: всё приходит в приложение,
+// на почту — только важное, push выключен до подписки браузера.
+func DefaultNotificationPreferences() NotificationPreferences {
+	return NotificationPreferences{
+		Version: PreferencesVersion,
+		Events: map[string]EventChannels{
+			EventNewChapter:   {InApp: true},
+			EventVotingOpened: {InApp: true, Email: true},
+			EventVotingClosed: {InApp: true},
+			EventProposalWon:  {InApp: true, Email: true},
+			EventNewFollower:  {InApp: true},
+		},
+		QuietHours: QuietHours{Start: "22:00", End: "08:00", TimeZone: "UTC"},
+	}
+}
+
+// EmailEnabled сообщает, включено ли хотя бы одно уведомление по почте.
+func (p NotificationPreferences) EmailEnabled() bool {
+	for _, channels := range p.Events {
+		if channels.Email {
+			return true
+		}
+	}
+	return false
+}
+
+// WebPushEvents — события, push о которых включаются при первой подписке браузера,
+// если пользователь ещё не включал push сам.
+var WebPushEvents = []string{EventVotingOpened, EventProposalWon}
+
+// WebPushEnabled сообщает, включено ли хотя бы одно уведомление через push.
+func (p NotificationPreferences) WebPushEnabled() bool {
+	for _, channels := range p.Events {
+		if channels.WebPush {
+			return true
+		}
+	}
+	return false
+}
+
+// WithWebPush возвращает копию настроек с push для событий WebPushEvents.
+func (p NotificationPreferences) WithWebPush() NotificationPreferences {
+	p.Events = maps.Clone(p.Events)
+	for _, event := range WebPushEvents {
+		channels := p.Events[event]
+		channels.WebPush = true
+		p.Events[event] = channels
+	}
+	return p
+}
+
+// Allows сообщает, нужно ли доставлять уведомление о событии event по каналу channel.
+func (p NotificationPreferences) Allows(event, channel string) bool {
+	return p.Events[event].Enabled(channel)
+}
+
+// Validate проверяет настройки из запроса и возвращает описание первой ошибки.
+func (p NotificationPreferences) Validate() error {
+	for event := range p.Events {
+		if !slices.Contains(NotificationEvents, event) {
+			return fmt.Errorf("неизвестное событие %q", event)
+		}
+	}
+	if _, err := minuteOfDay(p.QuietHours.Start); err != nil {
+		return fmt.Errorf("quiet_hours.start: %w", err)
+	}
+	if _, err := minuteOfDay(p.QuietHours.End); err != nil {
+		return fmt.Errorf("quiet_hours.end: %w", err)
+	}
+	if _, err := time.LoadLocation(p.QuietHours.TimeZone); err != nil || p.QuietHours.TimeZone == "" {
+		return fmt.Errorf("quiet_hours.time_zone: неизвестный часовой пояс %q", p.QuietHours.TimeZone)
+	}
+	if p.Language != "" && !slices.Contains(EmailLanguages, p.Language) {
+		return fmt.Errorf("language: ожидалось ru или en, получено %q", p.Language)
+	}
+	return nil
+}
+
+// Normalize дополняет настройки значениями по умолчанию для событий и полей, которых нет в документе.
+func (p NotificationPreferences) Normalize() NotificationPreferences {
+	defaults := DefaultNotificationPreferences()
+	events := make(map[string]EventChannels, len(NotificationEvents))
+	for _, event := range NotificationEvents {
+		if channels, ok := p.Events[event]; ok {
+			events[event] = channels
+		} else {
+			events[event] = defaults.Events[event]
+		}
+	}
+	p.Events = events
+	if p.QuietHours.Start == "" {
+		p.QuietHours.Start = defaults.QuietHours.Start
+	}
+	if p.QuietHours.End == "" {
+		p.QuietHours.End = defaults.QuietHours.End
+	}
+	if p.QuietHours.TimeZone == "" {
+		p.QuietHours.TimeZone = defaults.QuietHours.TimeZone
+	}
+	p.Version = PreferencesVersion
+	return p
+}
+
+// preferenceMigrations[v] переводит документ версии v в версию v+1.
+var preferenceMigrations = map[int]func(doc map[string]any){
+	// Версия 0 — документ без поля version: формат совпадает с первой версией,
+	// недостающие поля заполнит Normalize
+	0: func(doc map[string]any) {},
+}
+
+// DecodeNotificationPreferences разбирает сохранённые настройки, последовательно
+// применяя миграции от версии документа до PreferencesVersion.
+func DecodeNotificationPreferences(raw []byte) (NotificationPreferences, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return DefaultNotificationPreferences(), nil
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return NotificationPreferences{}, err
+	}
+	version := 0
+	if v, ok := doc["version"].(float64); ok {
+		version = int(v)
+	}
+	if version > PreferencesVersion {
+		return NotificationPreferences{}, fmt.Errorf("настройки уведомлений версии %d новее поддерживаемой %d", version, PreferencesVersion)
+	}
+	for ; version < PreferencesVersion; version++ {
+		migrate, ok := preferenceMigrations[version]
+		if !ok {
+			return NotificationPreferences{}, fmt.Errorf("нет миграции настроек уведомлений с версии %d", version)
+		}
+		migrate(doc)
+	}
+
+	migrated, err := json.Marshal(doc)
+	if err != nil {
+		return NotificationPreferences{}, err
+	}
+	var p NotificationPreferences
+	if err := json.Unmarshal(migrated, &p); err != nil {
+		return NotificationPreferences{}, err
+	}
+	return p.Normalize(), nil
+}
+
+// Value реализует driver.Valuer. Настройки по умолчанию, которые пользователь не менял, хранятся как NULL.
+func (p NotificationPreferences) Value() (driver.Value, error) {
+	if p.Version == 0 {
+		return nil, nil
+	}
+	raw, err := json.Marshal(p)
+	return string(raw), err
+}
+
+// Scan реализует sql.Scanner и приводит документ к текущей версии.
+func (p *NotificationPreferences) Scan(src any) error {
+	var raw []byte
+	switch v := src.(type) {
+	case nil:
+	case []byte:
+		raw = v
+	case string:
+		raw = []byte(v)
+	default:
+		return fmt.Errorf("NotificationPreferences: неподдерживаемый тип %T", src)
+	}
+	decoded, err := DecodeNotificationPreferences(raw)
+	if err != nil {
+		return err
+	}
+	*p = decoded
+	return nil
+}
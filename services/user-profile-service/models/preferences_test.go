@@ -0,0 +1,110 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDecodeNotificationPreferences(t *testing.T) {
+	// NULL — настройки по умолчанию
+	p, err := DecodeNotificationPreferences(nil)
+	if err != nil || p.Version != PreferencesVersion || !p.Allows(EventProposalWon, ChannelEmail) {
+		t.Fatalf("ожидали настройки по умолчанию, получили %+v, %v", p, err)
+	}
+
+	// Документ без версии приводится к текущей, недостающие события берутся по умолчанию
+	p, err = DecodeNotificationPreferences([]byte(`{"events":{"new_chapter":{"in_app":false,"email":true}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Version != PreferencesVersion {
+		t.Errorf("неверная миграция: %+v", p)
+	}
+	if p.Allows(EventNewChapter, ChannelInApp) || !p.Allows(EventNewChapter, ChannelEmail) {
+		t.Errorf("сохранённые каналы должны сохраниться: %+v", p.Events[EventNewChapter])
+	}
+	if !p.Allows(EventNewFollower, ChannelInApp) || p.QuietHours.TimeZone != "UTC" {
+		t.Errorf("недостающие поля должны получить значения по умолчанию: %+v", p)
+	}
+
+	if _, err := DecodeNotificationPreferences([]byte(`{"version":99}`)); err == nil {
+		t.Error("документ новее поддерживаемой версии должен давать ошибку")
+	}
+}
+
+func TestNotificationPreferencesValue(t *testing.T) {
+	var p NotificationPreferences
+	if v, err := p.Value(); v != nil || err != nil {
+		t.Errorf("нулевые настройки должны храниться как NULL, получили %v, %v", v, err)
+	}
+
+	saved, err := DefaultNotificationPreferences().Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Scan(saved); err != nil {
+		t.Fatal(err)
+	}
+	if !p.Allows(EventVotingOpened, ChannelEmail) || p.Allows(EventNewChapter, ChannelWebPush) {
+		t.Errorf("настройки не пережили сохранение: %+v", p)
+	}
+}
+
+func TestNotificationPreferencesValidate(t *testing.T) {
+	cases := []struct {
+		name   string
+		modify func(*NotificationPreferences)
+		valid  bool
+	}{
+		{"по умолчанию", func(*NotificationPreferences) {}, true},
+		{"часовой пояс", func(p *NotificationPreferences) { p.QuietHours.TimeZone = "Europe/Moscow" }, true},
+		{"неизвестное событие", func(p *NotificationPreferences) { p.Events["chapter_liked"] = EventChannels{} }, false},
+		{"неверное время", func(p *NotificationPreferences) { p.QuietHours.Start = "24:00" }, false},
+		{"неизвестный часовой пояс", func(p *NotificationPreferences) { p.QuietHours.TimeZone = "Mars/Olympus" }, false},
+	}
+	for _, tc := range cases {
+		p := DefaultNotificationPreferences()
+		tc.modify(&p)
+		if err := p.Validate(); (err == nil) != tc.valid {
+			t.Errorf("%s: valid=%v, ошибка %v", tc.name, tc.valid, err)
+		}
+	}
+}
+
+func TestQuietHoursActive(t *testing.T) {
+	q := QuietHours{Enabled: true, Start: "22:00", End: "08:00", TimeZone: "Europe/Moscow"}
+	cases := []struct {
+		utc  string
+		want bool
+	}{
+		{"2026-03-10T18:59:00Z", false}, // 21:59 по Москве
+		{"2026-03-10T19:00:00Z", true},  // 22:00
+		{"2026-03-10T23:30:00Z", true},  // 02:30 следующего дня
+		{"2026-03-11T05:00:00Z", false}, // 08:00
+	}
+	for _, tc := range cases {
+		at, _ := time.Parse(time.RFC3339, tc.utc)
+		if got := q.Active(at); got != tc.want {
+			t.Errorf("%s: ожидали %v, получили %v", tc.utc, tc.want, got)
+		}
+	}
+
+	q.Enabled = false
+	if q.Active(time.Date(2026, 3, 10, 20, 0, 0, 0, time.UTC)) {
+		t.Error("выключенные тихие часы не должны действовать")
+	}
+}
+
+func TestWithWebPush(t *testing.T) {
+	p := DefaultNotificationPreferences()
+	if p.WebPushEnabled() {
+		t.Fatal("push должен быть выключен по умолчанию")
+	}
+	enabled := p.WithWebPush()
+	if !enabled.Allows(EventProposalWon, ChannelWebPush) || !enabled.Allows(EventProposalWon, ChannelEmail) || enabled.Allows(EventNewChapter, ChannelWebPush) {
+		t.Errorf("неверные каналы после включения push: %+v", enabled.Events)
+	}
+	if p.WebPushEnabled() {
+		t.Error("WithWebPush не должен менять исходные настройки")
+	}
+}
@@ -50,6 +50,7 @@
 package models
 
 import (
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
@@ -63,18 +64,62 @@ type Profile struct {
 	Email       string         `gorm:"size:255;not null;unique" json:"email"`
 	DisplayName string         `gorm:"size:255" json:"display_name"`
 	Bio         string         `gorm:"type:text" json:"bio"`
-	Role        string         `gorm:"size:50;not null;default:'user'" json:"role"`
+	Role        string         `gorm:"size:50;not null;default:'USER'" json:"role"`
 	AvatarURL   string         `gorm:"size:255" json:"avatar_url"`
 	LastSeen    *time.Time     `gorm:"type:timestamp" json:"last_seen"`
 	CreatedAt   time.Time      `gorm:"type:timestamp;not null;default:now()" json:"created_at"`
 	UpdatedAt   time.Time      `gorm:"type:timestamp;not null;default:now()" json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// Status — состояние модерации: ACTIVE, SUSPENDED или BANNED.
+	// Ограничение действует до StatusUntil, без срока — бессрочно.
+	Status       string     `gorm:"size:20;not null;default:'ACTIVE';index" json:"status"`
+	StatusReason string     `gorm:"type:text" json:"status_reason,omitempty"`
+	StatusUntil  *time.Time `gorm:"type:timestamp" json:"status_until,omitempty"`
+	// FlaggedAt — когда профиль отмечен для проверки модератором.
+	FlaggedAt  *time.Time `gorm:"type:timestamp;index" json:"flagged_at,omitempty"`
+	FlagReason string     `gorm:"type:text" json:"flag_reason,omitempty"`
+	// HiddenFields — поля, скрытые модератором по жалобе. Владелец видит их и может исправить:
+	// изменённое поле снова становится видимым.
+	HiddenFields StringList `gorm:"type:jsonb" json:"hidden_fields,omitempty" swaggertype:"array,string"`
+	// HeldFields — поля, задержанные автоматической проверкой текста до решения модератора.
+	// Видны только владельцу; снимаются, когда модератор снимает отметку о проверке.
+	HeldFields StringList `gorm:"type:jsonb" json:"held_fields,omitempty" swaggertype:"array,string"`
+	// NotificationPreferences — настройки уведомлений; видны только владельцу через
+	// /profiles/{user_id}/notification-preferences. NULL — настройки по умолчанию.
+	NotificationPreferences NotificationPreferences `gorm:"type:jsonb" json:"-"`
+	// EmailBouncedAt — когда письма на Email перестали доставляться; пока адрес не изменён, писем нет.
+	EmailBouncedAt *time.Time `gorm:"type:timestamp" json:"email_bounced_at,omitempty"`
+	// EmailSoftBounces — временные отказы с последней смены адреса; после EMAIL_SOFT_BOUNCE_LIMIT адрес считается недоступным.
+	EmailSoftBounces int `gorm:"not null;default:0" json:"-"`
+	// EmailUnsubscribedAt — когда пользователь отписался от всех писем, кроме служебных.
+	// Сбрасывается, если он снова включает письма в настройках уведомлений.
+	EmailUnsubscribedAt *time.Time `gorm:"type:timestamp" json:"email_unsubscribed_at,omitempty"`
+	// EmailVerifiedAt — когда владелец подтвердил Email по ссылке из письма; NULL — адрес не подтверждён.
+	EmailVerifiedAt *time.Time `gorm:"type:timestamp" json:"email_verified_at,omitempty"`
+	// PendingEmail — новый адрес, который ждёт подтверждения; виден только владельцу.
+	PendingEmail string `gorm:"size:255" json:"pending_email,omitempty"`
+
+	// Links — ссылки на соцсети и сайты, не больше PROFILE_MAX_LINKS.
+	Links    SocialLinks `gorm:"type:jsonb" json:"links,omitempty"`
+	Location string      `gorm:"size:100" json:"location,omitempty"`
+	Pronouns string      `gorm:"size:50" json:"pronouns,omitempty"`
+	// Languages — языки, на которых пишет автор, в виде тегов BCP 47.
+	Languages StringList `gorm:"type:jsonb" json:"languages,omitempty" swaggertype:"array,string"`
+	// FavoriteGenres — любимые жанры, названия жанров story-service.
+	FavoriteGenres StringList `gorm:"type:jsonb" json:"favorite_genres,omitempty" swaggertype:"array,string"`
+	// FieldVisibility — кому видны расширенные поля; видна только владельцу.
+	FieldVisibility FieldVisibility `gorm:"type:jsonb" json:"field_visibility,omitempty" swaggertype:"object,string"`
+	// BioHTML — Bio, отрисованное из CommonMark в безопасный HTML; обновляется вместе с Bio.
+	BioHTML string `gorm:"type:text" json:"bio_html"`
+	// BioHTMLVersion — версия отрисовки BioHTML; устаревший HTML перерисовывает bio.Rerender.
+	BioHTMLVersion int `gorm:"not null;default:0" json:"-"`
 } // @name Profile
 
 type InputProfile struct {
 	UserID    string `json:"userId" example:"550e8400-e29b-41d4-a716-446655440000" binding:"required" swaggertype:"string"`
 	Email     string `json:"email" example:"user@example.com" binding:"required" swaggertype:"string"`
-	Role      string `json:"role" example:"user" swaggertype:"string"`
+	Role      string `json:"role" example:"USER" swaggertype:"string"`
 	Username  string `json:"username" example:"user123" binding:"required" swaggertype:"string"`
 	AvatarURL string `json:"avatarUrl" example:"https://example.com/avatar.jpg" swaggertype:"string"`
 } // @name InputProfile
@@ -85,8 +130,17 @@ type UpdateProfile struct {
 	Role        string `json:"role"`
 	Username    string `json:"username"`
 	AvatarURL   string `json:"avatarUrl"`
-	Bio         string `json:"bio"`
+	Bio         string `json:"bio" example:"Пишу *фэнтези*"`
 	DisplayName string `json:"displayName"`
+
+	// Расширенные поля меняются, только если переданы; пустое значение очищает поле.
+	Links          *[]string `json:"links,omitempty" gorm:"-" example:"https://github.com/alice"`
+	Location       *string   `json:"location,omitempty" gorm:"-" example:"Москва"`
+	Pronouns       *string   `json:"pronouns,omitempty" gorm:"-" example:"она/её"`
+	Languages      *[]string `json:"languages,omitempty" gorm:"-" example:"ru,en-US"`
+	FavoriteGenres *[]string `json:"favoriteGenres,omitempty" gorm:"-" example:"Фэнтези"`
+	// FieldVisibility меняет видимость перечисленных полей: public, registered или private.
+	FieldVisibility map[string]string `json:"fieldVisibility,omitempty" gorm:"-" swaggertype:"object,string"`
 } // @name UpdateProfile
 
 
@@ -95,3 +149,47 @@ type UpdateProfile struct {
 func (Profile) TableName() string {
 	return "user_profiles"
 }
+
+// VisibleTo возвращает профиль в том виде, в каком его видит viewerID (пустой — гость):
+// скрытые модератором и задержанные проверкой поля и их списки, отметки модерации, причина ограничения
+// и состояние доставки писем видны только владельцу, а расширенные поля — тем, кому их
+// открыл владелец.
+func (p Profile) VisibleTo(viewerID string) Profile {
+	if viewerID != "" && viewerID == p.UserID {
+		return p
+	}
+	p.PendingEmail = ""
+	p.StatusReason = ""
+	p.EmailBouncedAt, p.EmailUnsubscribedAt, p.EmailVerifiedAt = nil, nil, nil
+	hidden := append(slices.Clone(p.HiddenFields), p.HeldFields...)
+	for _, field := range ExtendedFields {
+		if !p.FieldVisibility.Allows(field, viewerID != "") {
+			hidden = append(hidden, field)
+		}
+	}
+	p.FieldVisibility = nil
+	p.FlaggedAt, p.FlagReason, p.HiddenFields, p.HeldFields = nil, "", nil, nil
+	for _, field := range hidden {
+		switch field {
+		case "username":
+			p.Username = ""
+		case "display_name":
+			p.DisplayName = ""
+		case "bio":
+			p.Bio, p.BioHTML = "", ""
+		case "avatar_url":
+			p.AvatarURL = ""
+		case "links":
+			p.Links = nil
+		case "location":
+			p.Location = ""
+		case "pronouns":
+			p.Pronouns = ""
+		case "languages":
+			p.Languages = nil
+		case "favorite_genres":
+			p.FavoriteGenres = nil
+		}
+	}
+	return p
+}
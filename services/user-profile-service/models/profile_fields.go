@@ -0,0 +1,231 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/language"
+)
+
+// Видимость расширенных полей профиля для других пользователей.
+const (
+	VisibilityPublic = "public"
+	// VisibilityRegistered — поле видно только вошедшим пользователям.
+	VisibilityRegistered = "registered"
+	// VisibilityPrivate — поле видно только владельцу.
+	VisibilityPrivate = "private"
+)
+
+// Visibilities — допустимые значения видимости поля.
+var Visibilities = []string{VisibilityPublic, VisibilityRegistered, VisibilityPrivate}
+
+// ExtendedFields — поля профиля, видимость которых задаёт владелец.
+var ExtendedFields = []string{"links", "location", "pronouns", "languages", "favorite_genres"}
+
+// Ограничения длины текстовых расширенных полей в символах, как у колонок.
+const (
+	MaxLocationLength = 100
+	MaxPronounsLength = 50
+	maxLinkLength     = 255
+)
+
+// Типы ссылок на соцсети; адрес другого сайта получает тип website.
+const LinkWebsite = "website"
+
+// linkTypes — домены соцсетей и тип ссылки; поддомены получают тип своего домена.
+var linkTypes = map[string]string{
+	"twitter.com":   "twitter",
+	"x.com":         "twitter",
+	"bsky.app":      "bluesky",
+	"instagram.com": "instagram",
+	"facebook.com":  "facebook",
+	"fb.com":        "facebook",
+	"youtube.com":   "youtube",
+	"youtu.be":      "youtube",
+	"tiktok.com":    "tiktok",
+	"twitch.tv":     "twitch",
+	"github.com":    "github",
+	"linkedin.com":  "linkedin",
+	"t.me":          "telegram",
+	"telegram.me":   "telegram",
+	"vk.com":        "vk",
+	"patreon.com":   "patreon",
+	"boosty.to":     "boosty",
+}
+
+// SocialLink — ссылка владельца профиля на соцсеть или сайт.
+type SocialLink struct {
+	Type string `json:"type" example:"github"`
+	URL  string `json:"url" example:"https://github.com/alice"`
+} // @name SocialLink
+
+// ParseSocialLink проверяет адрес ссылки и определяет её тип по домену.
+// Принимаются только адреса http и https без логина и пароля.
+func ParseSocialLink(raw string) (SocialLink, error) {
+	raw = strings.TrimSpace(raw)
+	if len(raw) > maxLinkLength {
+		return SocialLink{}, fmt.Errorf("ссылка длиннее %d символов", maxLinkLength)
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Hostname() == "" || u.User != nil {
+		return SocialLink{}, fmt.Errorf("неверная ссылка %q: ожидался адрес http или https", raw)
+	}
+	u.Host = strings.ToLower(u.Host)
+
+	host := strings.TrimPrefix(strings.TrimPrefix(u.Hostname(), "www."), "m.")
+	link := SocialLink{Type: LinkWebsite, URL: u.String()}
+	for domain, kind := range linkTypes {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			link.Type = kind
+			break
+		}
+	}
+	return link, nil
+}
+
+// SocialLinks — ссылки профиля, хранимые в колонке jsonb. Пустой список хранится как NULL.
+type SocialLinks []SocialLink
+
+// Value реализует driver.Valuer.
+func (l SocialLinks) Value() (driver.Value, error) {
+	if len(l) == 0 {
+		return nil, nil
+	}
+	raw, err := json.Marshal([]SocialLink(l))
+	return string(raw), err
+}
+
+// Scan реализует sql.Scanner.
+func (l *SocialLinks) Scan(src any) error {
+	raw, err := jsonbBytes(src)
+	if err != nil || raw == nil {
+		*l = nil
+		return err
+	}
+	var links []SocialLink
+	if err := json.Unmarshal(raw, &links); err != nil {
+		return err
+	}
+	if len(links) == 0 {
+		links = nil
+	}
+	*l = links
+	return nil
+}
+
+// URLs возвращает адреса ссылок.
+func (l SocialLinks) URLs() []string {
+	urls := make([]string, 0, len(l))
+	for _, link := range l {
+		urls = append(urls, link.URL)
+	}
+	return urls
+}
+
+// CanonicalLanguage проверяет тег языка BCP 47 и приводит его к канонической
+// записи, например en-us — к en-US.
+func CanonicalLanguage(tag string) (string, error) {
+	parsed, err := language.Parse(strings.TrimSpace(tag))
+	if err != nil || parsed == language.Und {
+		return "", fmt.Errorf("неверный тег языка %q: ожидался BCP 47, например ru или en-US", tag)
+	}
+	return parsed.String(), nil
+}
+
+// ValidateProfileText проверяет длину текстового расширенного поля.
+func ValidateProfileText(field, value string, limit int) error {
+	if utf8.RuneCountInString(value) > limit {
+		return fmt.Errorf("%s длиннее %d символов", field, limit)
+	}
+	if strings.ContainsAny(value, "\r\n") {
+		return fmt.Errorf("%s должно быть одной строкой", field)
+	}
+	return nil
+}
+
+// FieldVisibility — видимость расширенных полей (поле → уровень), хранимая в колонке jsonb.
+// Поле без записи видно всем.
+type FieldVisibility map[string]string
+
+// Value реализует driver.Valuer.
+func (v FieldVisibility) Value() (driver.Value, error) {
+	if len(v) == 0 {
+		return nil, nil
+	}
+	raw, err := json.Marshal(map[string]string(v))
+	return string(raw), err
+}
+
+// Scan реализует sql.Scanner.
+func (v *FieldVisibility) Scan(src any) error {
+	raw, err := jsonbBytes(src)
+	if err != nil || raw == nil {
+		*v = nil
+		return err
+	}
+	var levels map[string]string
+	if err := json.Unmarshal(raw, &levels); err != nil {
+		return err
+	}
+	if len(levels) == 0 {
+		levels = nil
+	}
+	*v = levels
+	return nil
+}
+
+// Merge возвращает видимость с изменениями из patch; уровень public удаляет запись.
+func (v FieldVisibility) Merge(patch map[string]string) (FieldVisibility, error) {
+	merged := FieldVisibility{}
+	for field, level := range v {
+		merged[field] = level
+	}
+	for field, level := range patch {
+		if !slices.Contains(ExtendedFields, field) {
+			return nil, fmt.Errorf("видимость поля %s не настраивается, допустимы %s", field, strings.Join(ExtendedFields, ", "))
+		}
+		if !slices.Contains(Visibilities, level) {
+			return nil, fmt.Errorf("неверная видимость %q поля %s, допустимы %s", level, field, strings.Join(Visibilities, ", "))
+		}
+		if level == VisibilityPublic {
+			delete(merged, field)
+		} else {
+			merged[field] = level
+		}
+	}
+	if len(merged) == 0 {
+		return nil, nil
+	}
+	return merged, nil
+}
+
+// Allows сообщает, видно ли поле field другому пользователю; signedIn — вошёл ли он.
+func (v FieldVisibility) Allows(field string, signedIn bool) bool {
+	switch v[field] {
+	case VisibilityPrivate:
+		return false
+	case VisibilityRegistered:
+		return signedIn
+	default:
+		return true
+	}
+}
+
+// jsonbBytes возвращает значение колонки jsonb или nil для NULL.
+func jsonbBytes(src any) ([]byte, error) {
+	switch v := src.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	default:
+		return nil, fmt.Errorf("jsonb: неподдерживаемый тип %T", src)
+	}
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVisibleTo(t *testing.T) {
+	now := time.Now()
+	p := Profile{
+		UserID: "owner", Username: "spammer", Bio: "купи", DisplayName: "Имя", HiddenFields: StringList{"username", "bio"},
+		EmailBouncedAt: &now, EmailUnsubscribedAt: &now, EmailVerifiedAt: &now, Status: StatusSuspended, StatusReason: "спам",
+		FlaggedAt: &now, FlagReason: "SCREENING: profanity", HeldFields: StringList{"display_name"},
+	}
+
+	if got := p.VisibleTo("owner"); got.Bio != "купи" || got.Username != "spammer" || got.EmailVerifiedAt == nil || got.FlagReason == "" {
+		t.Errorf("владелец должен видеть скрытые поля: %+v", got)
+	}
+	got := p.VisibleTo("someone")
+	if got.Bio != "" || got.Username != "" || got.DisplayName != "" {
+		t.Errorf("другим скрытые поля не показываются: %+v", got)
+	}
+	if got.FlaggedAt != nil || got.FlagReason != "" || got.HiddenFields != nil || got.HeldFields != nil {
+		t.Errorf("отметки модерации видны только владельцу: %+v", got)
+	}
+	if got.StatusReason != "" || got.Status != StatusSuspended {
+		t.Errorf("причина ограничения видна только владельцу: %+v", got)
+	}
+	if got.EmailBouncedAt != nil || got.EmailUnsubscribedAt != nil || got.EmailVerifiedAt != nil {
+		t.Errorf("состояние доставки писем видно только владельцу: %+v", got)
+	}
+	if p.Bio != "купи" {
+		t.Error("VisibleTo не должен менять исходный профиль")
+	}
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// PushSubscription — подписка браузера на устройстве пользователя на Web Push.
+// Endpoint выдаёт push-сервис браузера; P256dh и Auth — ключи шифрования сообщений
+// для этого браузера (RFC 8291), наружу они не отдаются.
+type PushSubscription struct {
+	ID       int64  `gorm:"primaryKey" json:"id"`
+	UserID   string `gorm:"type:uuid;not null;index" json:"user_id"`
+	Endpoint string `gorm:"type:text;not null;uniqueIndex" json:"endpoint" example:"https://fcm.googleapis.com/fcm/send/dGVzdA"`
+	P256dh   string `gorm:"size:100;not null" json:"-"`
+	Auth     string `gorm:"size:50;not null" json:"-"`
+	// DeviceName — подпись устройства для списка подписок, например «Рабочий ноутбук».
+	DeviceName string `gorm:"size:100" json:"device_name,omitempty" example:"Firefox на ноутбуке"`
+	UserAgent  string `gorm:"size:255" json:"user_agent,omitempty"`
+	// ExpiresAt — срок подписки, если его сообщил браузер (expirationTime).
+	ExpiresAt     *time.Time `gorm:"type:timestamp" json:"expires_at,omitempty"`
+	LastSuccessAt *time.Time `gorm:"type:timestamp" json:"last_success_at,omitempty"`
+	CreatedAt     time.Time  `gorm:"type:timestamp;not null;default:now()" json:"created_at"`
+	UpdatedAt     time.Time  `gorm:"type:timestamp;not null;default:now()" json:"updated_at"`
+} // @name PushSubscription
+
+// TableName определяет имя таблицы в базе данных
+func (PushSubscription) TableName() string {
+	return "push_subscriptions"
+}
+
+// PushSubscriptionKeys — ключи шифрования из PushSubscription.toJSON() браузера.
+type PushSubscriptionKeys struct {
+	P256dh string `json:"p256dh" binding:"required" example:"BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4"`
+	Auth   string `json:"auth" binding:"required" example:"BTBZMqHH6r4Tts7J_aSIgg"`
+} // @name PushSubscriptionKeys
+
+// PushSubscriptionInput — подписка в том виде, в каком её возвращает PushSubscription.toJSON(),
+// с необязательной подписью устройства.
+type PushSubscriptionInput struct {
+	Endpoint string `json:"endpoint" binding:"required" example:"https://fcm.googleapis.com/fcm/send/dGVzdA"`
+	// ExpirationTime — срок подписки в миллисекундах Unix или null.
+	ExpirationTime *int64               `json:"expirationTime" example:"1767225600000"`
+	Keys           PushSubscriptionKeys `json:"keys" binding:"required"`
+	DeviceName     string               `json:"device_name" example:"Firefox на ноутбуке"`
+} // @name PushSubscriptionInput
+
+// Состояния push-сообщения в очереди.
+const (
+	PushPending = "pending"
+	// PushSending — сообщение забрано на отправку до NextAttemptAt; если экземпляр сервиса
+	// за это время не отметил результат, сообщение снова становится доступным.
+	PushSending   = "sending"
+	PushSent      = "sent"
+	PushFailed    = "failed"
+	PushCancelled = "cancelled"
+)
+
+// PushMessage — push-сообщение в очереди отправки на одну подписку. Текст уведомления
+// показывает service worker приложения по Type и Payload.
+type PushMessage struct {
+	ID             int64           `gorm:"primaryKey" json:"id"`
+	SubscriptionID int64           `gorm:"not null;index;uniqueIndex:idx_push_messages_dedup,priority:1,where:dedup_key IS NOT NULL" json:"subscription_id"`
+	RecipientID    string          `gorm:"type:uuid;not null;index" json:"recipient_id"`
+	Type           string          `gorm:"size:50;not null" json:"type" example:"proposal_won"`
+	Payload        json.RawMessage `gorm:"type:jsonb" json:"payload,omitempty" swaggertype:"object"`
+	// DedupKey не даёт отправить одно и то же событие на устройство дважды.
+	DedupKey      *string    `gorm:"size:255;uniqueIndex:idx_push_messages_dedup,priority:2" json:"-"`
+	Status        string     `gorm:"size:20;not null;default:'pending';index:idx_push_messages_due,priority:1" json:"status"`
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt time.Time  `gorm:"type:timestamp;not null;default:now();index:idx_push_messages_due,priority:2" json:"next_attempt_at"`
+	LastError     string     `gorm:"type:text" json:"last_error,omitempty"`
+	SentAt        *time.Time `gorm:"type:timestamp" json:"sent_at,omitempty"`
+	CreatedAt     time.Time  `gorm:"type:timestamp;not null;default:now()" json:"created_at"`
+} // @name PushMessage
+
+// TableName определяет имя таблицы в базе данных
+func (PushMessage) TableName() string {
+	return "push_messages"
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Категории жалоб на профиль.
+const (
+	ReportSpam          = "SPAM"
+	ReportHarassment    = "HARASSMENT"
+	ReportHateSpeech    = "HATE_SPEECH"
+	ReportImpersonation = "IMPERSONATION"
+	ReportInappropriate = "INAPPROPRIATE"
+	ReportOther         = "OTHER"
+)
+
+// ReportCategories — допустимые категории жалоб.
+var ReportCategories = []string{ReportSpam, ReportHarassment, ReportHateSpeech, ReportImpersonation, ReportInappropriate, ReportOther}
+
+// Состояния жалобы в очереди модерации. OPEN и TRIAGED — жалоба ещё рассматривается,
+// ACTIONED и DISMISSED — решение принято.
+const (
+	ReportOpen      = "OPEN"
+	ReportTriaged   = "TRIAGED"
+	ReportActioned  = "ACTIONED"
+	ReportDismissed = "DISMISSED"
+)
+
+// EventReportResolved — тип уведомления автору жалобы о решении модератора,
+// Payload — ReportResolution.
+const EventReportResolved = "report_resolved"
+
+// ReportResolution — данные уведомления о решении по жалобе.
+type ReportResolution struct {
+	ReportID     uint64 `json:"report_id"`
+	TargetUserID string `json:"target_user_id"`
+	Status       string `json:"status"`
+	Outcome      string `json:"outcome"`
+} // @name ReportResolution
+
+// HideableFields — поля профиля, на которые можно пожаловаться и которые модератор может скрыть.
+var HideableFields = []string{"username", "display_name", "bio", "avatar_url", "links", "location", "pronouns"}
+
+// Report — жалоба пользователя на профиль. Пока жалоба не рассмотрена, повторная
+// жалоба того же пользователя на тот же профиль не создаёт новую запись.
+type Report struct {
+	ID           uint64     `gorm:"primaryKey" json:"id"`
+	ReporterID   string     `gorm:"size:255;not null;index;uniqueIndex:idx_reports_active,where:resolved_at IS NULL" json:"reporter_id"`
+	TargetUserID string     `gorm:"size:255;not null;index;uniqueIndex:idx_reports_active" json:"target_user_id"`
+	Category     string     `gorm:"size:32;not null" json:"category"`
+	Fields       StringList `gorm:"type:jsonb" json:"fields,omitempty" swaggertype:"array,string"`
+	Comment      string     `gorm:"type:text" json:"comment,omitempty"`
+	// Snapshot — значения полей профиля на момент жалобы, чтобы модератор видел их и после правки или скрытия.
+	Snapshot json.RawMessage `gorm:"type:jsonb" json:"snapshot,omitempty" swaggertype:"object"`
+	Status   string          `gorm:"size:16;not null;default:'OPEN';index" json:"status"`
+	// ModeratorID не показывается автору жалобы.
+	ModeratorID string `gorm:"size:255" json:"moderator_id,omitempty"`
+	// Outcome — сообщение автору жалобы о принятом решении.
+	Outcome      string     `gorm:"type:text" json:"outcome,omitempty"`
+	HiddenFields StringList `gorm:"type:jsonb" json:"hidden_fields,omitempty" swaggertype:"array,string"`
+	ResolvedAt   *time.Time `gorm:"type:timestamp" json:"resolved_at,omitempty"`
+	CreatedAt    time.Time  `gorm:"type:timestamp;not null;default:now();index" json:"created_at"`
+	UpdatedAt    time.Time  `gorm:"type:timestamp;not null;default:now()" json:"updated_at"`
+} // @name Report
+
+func (Report) TableName() string {
+	return "profile_reports"
+}
+
+// CreateReport — жалоба на профиль. Fields уточняет, что именно нарушает правила.
+type CreateReport struct {
+	Category string   `json:"category" example:"SPAM" binding:"required" swaggertype:"string"`
+	Fields   []string `json:"fields" example:"bio"`
+	Comment  string   `json:"comment" example:"Реклама казино в описании" swaggertype:"string"`
+} // @name CreateReport
+
+// ReportDecision — решение модератора по жалобе. HideFields используется только при ACTIONED.
+type ReportDecision struct {
+	Outcome    string   `json:"outcome" example:"Описание профиля скрыто" binding:"required" swaggertype:"string"`
+	HideFields []string `json:"hide_fields" example:"bio"`
+} // @name ReportDecision
+
+// IsOpen сообщает, что жалоба ещё ждёт решения модератора.
+func (r Report) IsOpen() bool {
+	return r.Status == ReportOpen || r.Status == ReportTriaged
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStringListRoundTrip(t *testing.T) {
+	v, err := StringList{"bio", "avatar_url"}.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var l StringList
+	if err := l.Scan([]byte(v.(string))); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(l, StringList{"bio", "avatar_url"}) {
+		t.Errorf("получили %v", l)
+	}
+
+	if v, _ := (StringList{}).Value(); v != nil {
+		t.Errorf("пустой список должен храниться как NULL, получили %v", v)
+	}
+	if err := l.Scan("[]"); err != nil || l != nil {
+		t.Errorf("пустой массив должен читаться как nil: %v, %v", l, err)
+	}
+	if err := l.Scan(nil); err != nil || l != nil {
+		t.Errorf("NULL должен читаться как nil: %v, %v", l, err)
+	}
+}
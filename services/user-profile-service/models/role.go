@@ -0,0 +1,38 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Роли пользователей, общие с story-service.
+const (
+	RoleUser   = "USER"
+	RoleAuthor = "AUTHOR"
+	RoleAdmin  = "ADMIN"
+)
+
+// Состояния модерации профиля.
+const (
+	StatusActive    = "ACTIVE"
+	StatusSuspended = "SUSPENDED"
+	StatusBanned    = "BANNED"
+)
+
+// NormalizeRole приводит роль к верхнему регистру и проверяет, что она известна.
+func NormalizeRole(role string) (string, bool) {
+	role = strings.ToUpper(strings.TrimSpace(role))
+	switch role {
+	case RoleUser, RoleAuthor, RoleAdmin:
+		return role, true
+	}
+	return "", false
+}
+
+// IsRestricted сообщает, действует ли на момент now блокировка или бан профиля.
+func (p Profile) IsRestricted(now time.Time) bool {
+	if p.Status == "" || p.Status == StatusActive {
+		return false
+	}
+	return p.StatusUntil == nil || p.StatusUntil.After(now)
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNormalizeRole(t *testing.T) {
+	cases := map[string]string{"user": RoleUser, " Author ": RoleAuthor, "ADMIN": RoleAdmin, "moderator": "", "": ""}
+	for in, want := range cases {
+		got, ok := NormalizeRole(in)
+		if got != want || ok != (want != "") {
+			t.Errorf("NormalizeRole(%q) = %q, %v; ожидалось %q", in, got, ok, want)
+		}
+	}
+}
+
+func TestIsRestricted(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+
+	cases := []struct {
+		name    string
+		profile Profile
+		want    bool
+	}{
+		{"активный", Profile{Status: StatusActive}, false},
+		{"старый профиль без статуса", Profile{}, false},
+		{"блокировка действует", Profile{Status: StatusSuspended, StatusUntil: &future}, true},
+		{"блокировка истекла", Profile{Status: StatusSuspended, StatusUntil: &past}, false},
+		{"бессрочный бан", Profile{Status: StatusBanned}, true},
+	}
+	for _, tc := range cases {
+		if got := tc.profile.IsRestricted(now); got != tc.want {
+			t.Errorf("%s: IsRestricted = %v, ожидалось %v", tc.name, got, tc.want)
+		}
+	}
+}
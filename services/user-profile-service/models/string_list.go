@@ -0,0 +1,50 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+// StringList — список строк, хранимый в колонке jsonb. Пустой список хранится как NULL.
+type StringList []string
+
+// Value реализует driver.Valuer.
+func (l StringList) Value() (driver.Value, error) {
+	if len(l) == 0 {
+		return nil, nil
+	}
+	raw, err := json.Marshal([]string(l))
+	return string(raw), err
+}
+
+// Scan реализует sql.Scanner.
+func (l *StringList) Scan(src any) error {
+	var raw []byte
+	switch v := src.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case []byte:
+		raw = v
+	case string:
+		raw = []byte(v)
+	default:
+		return fmt.Errorf("StringList: неподдерживаемый тип %T", src)
+	}
+	var items []string
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		items = nil
+	}
+	*l = items
+	return nil
+}
+
+// Contains сообщает, есть ли s в списке.
+func (l StringList) Contains(s string) bool {
+	return slices.Contains(l, s)
+}
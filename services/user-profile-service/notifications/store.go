@@ -0,0 +1,283 @@
+// Package notifications хранит входящие уведомления пользователей: создание через
+// внутренний API с учётом настроек получателя, выдачу страницами по курсору,
+// отметки о прочтении и фоновую очистку по сроку хранения.
+package notifications
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/monst/story-craft/services/user-profile-service/mail"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"github.com/monst/story-craft/services/user-profile-service/push"
+	"github.com/monst/story-craft/services/user-profile-service/realtime"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// ErrNotFound — уведомления нет, оно истекло или принадлежит другому пользователю.
+var ErrNotFound = errors.New("уведомление не найдено")
+
+// ErrInvalidCursor — курсор страницы не выдан этим сервисом.
+var ErrInvalidCursor = errors.New("неверный курсор")
+
+// cleanupBatch — сколько истёкших уведомлений удаляется одним запросом,
+// чтобы очистка не держала долгие блокировки.
+const cleanupBatch = 1000
+
+// Результаты создания уведомления для получателя.
+const (
+	ResultCreated   = "created"
+	ResultDuplicate = "duplicate"
+	// ResultMuted — получатель отключил уведомления этого типа во всех каналах или профиля нет.
+	ResultMuted = "muted"
+)
+
+// Store работает с таблицей notifications в основной БД: уведомления читаются сразу
+// после создания и отметки о прочтении, отставание реплики здесь заметно.
+type Store struct {
+	db *gorm.DB
+	// email — очередь писем; nil, если отправка писем выключена.
+	email *mail.Queue
+	// push — подписки браузеров и очередь push; nil, если Web Push выключен.
+	push *push.Store
+}
+
+func NewStore(db *gorm.DB, email *mail.Queue, push *push.Store) *Store {
+	return &Store{db: db, email: email, push: push}
+}
+
+func (s *Store) session(ctx context.Context) *gorm.DB {
+	return s.db.WithContext(ctx).Clauses(dbresolver.Write).Session(&gorm.Session{})
+}
+
+// Ingest создаёт уведомление n для каждого из recipientIDs, у кого включён канал in_app
+// для этого типа, со сроком хранения ttl, и отправляет его в потоки событий получателя.
+// Тем, у кого включена почта, в той же транзакции ставится письмо, если для типа есть шаблон,
+// а тем, у кого включён push, — сообщение на каждое подписанное устройство.
+// Повтор с тем же DedupKey для получателя пропускается во всех каналах.
+// Время создания и истечения берётся из now() базы, как у ключей идемпотентности.
+// Возвращает созданные уведомления и результат по каждому получателю.
+func (s *Store) Ingest(ctx context.Context, n models.Notification, recipientIDs []string, ttl time.Duration) ([]models.Notification, map[string]string, error) {
+	results := make(map[string]string, len(recipientIDs))
+	var created []models.Notification
+
+	err := s.session(ctx).Transaction(func(tx *gorm.DB) error {
+		var profiles []models.Profile
+		if err := tx.Select("user_id", "notification_preferences").
+			Where("user_id IN ?", recipientIDs).Find(&profiles).Error; err != nil {
+			return err
+		}
+		prefs := make(map[string]models.NotificationPreferences, len(profiles))
+		for _, p := range profiles {
+			prefs[p.UserID] = p.NotificationPreferences
+		}
+
+		for _, recipientID := range recipientIDs {
+			if _, seen := results[recipientID]; seen {
+				continue
+			}
+			p, ok := prefs[recipientID]
+			inApp := ok && p.Allows(n.Type, models.ChannelInApp)
+			email := ok && s.email != nil && p.Allows(n.Type, models.ChannelEmail)
+			webPush := ok && s.push != nil && p.Allows(n.Type, models.ChannelWebPush)
+			if !inApp && !email && !webPush {
+				results[recipientID] = ResultMuted
+				continue
+			}
+			results[recipientID] = ResultDuplicate
+			if email {
+				queued, err := s.email.EnqueueEvent(tx, recipientID, n.Type, n.Payload, n.DedupKey)
+				if err != nil {
+					return err
+				}
+				if queued {
+					results[recipientID] = ResultCreated
+				}
+			}
+			if webPush {
+				queued, err := s.push.Enqueue(tx, recipientID, n.Type, n.Payload, n.DedupKey)
+				if err != nil {
+					return err
+				}
+				if queued {
+					results[recipientID] = ResultCreated
+				}
+			}
+			if !inApp {
+				continue
+			}
+			note := n
+			note.RecipientID = recipientID
+			note, inserted, err := Insert(tx, note, ttl)
+			if err != nil {
+				return err
+			}
+			if !inserted {
+				continue
+			}
+			results[recipientID] = ResultCreated
+			created = append(created, note)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return created, results, nil
+}
+
+// Insert создаёт уведомление n во входящих получателя n.RecipientID со сроком хранения ttl
+// и отправляет его в потоки событий получателя. Вызывается в транзакции, как realtime.Publish.
+// Возвращает false, если уведомление с тем же DedupKey у получателя уже есть.
+func Insert(tx *gorm.DB, n models.Notification, ttl time.Duration) (models.Notification, bool, error) {
+	// RETURNING не вернёт строку, если запись с тем же ключом уже есть
+	var row struct {
+		ID        int64
+		CreatedAt time.Time
+		ExpiresAt time.Time
+	}
+	if err := tx.Raw(`INSERT INTO notifications (recipient_id, type, payload, dedup_key, created_at, expires_at)
+		VALUES (?, ?, ?, ?, now(), now() + make_interval(secs => ?))
+		ON CONFLICT (recipient_id, dedup_key) WHERE dedup_key IS NOT NULL DO NOTHING
+		RETURNING id, created_at, expires_at`,
+		n.RecipientID, n.Type, payloadValue(n.Payload), n.DedupKey, ttl.Seconds()).
+		Scan(&row).Error; err != nil {
+		return n, false, err
+	}
+	if row.ID == 0 {
+		return n, false, nil
+	}
+	n.ID, n.CreatedAt, n.ExpiresAt = row.ID, row.CreatedAt, row.ExpiresAt
+	return n, true, realtime.Publish(tx, n.RecipientID, realtime.EventNotification, n)
+}
+
+// payloadValue передаёт пустой Payload как NULL, а не как пустую строку, которую jsonb не примет.
+func payloadValue(payload []byte) any {
+	if len(payload) == 0 {
+		return nil
+	}
+	return string(payload)
+}
+
+// ListOptions — параметры страницы входящих.
+type ListOptions struct {
+	// Before — выдать уведомления старше этого идентификатора (из курсора), 0 — с самых новых.
+	Before     int64
+	Limit      int
+	UnreadOnly bool
+}
+
+// List возвращает неистёкшие уведомления получателя от новых к старым.
+func (s *Store) List(ctx context.Context, recipientID string, opts ListOptions) ([]models.Notification, error) {
+	q := s.active(ctx, recipientID)
+	if opts.UnreadOnly {
+		q = q.Where("read_at IS NULL")
+	}
+	if opts.Before > 0 {
+		q = q.Where("id < ?", opts.Before)
+	}
+	notes := []models.Notification{}
+	err := q.Order("id DESC").Limit(opts.Limit).Find(&notes).Error
+	return notes, err
+}
+
+// UnreadCount возвращает число непрочитанных неистёкших уведомлений получателя.
+func (s *Store) UnreadCount(ctx context.Context, recipientID string) (int64, error) {
+	var count int64
+	err := s.active(ctx, recipientID).Where("read_at IS NULL").Count(&count).Error
+	return count, err
+}
+
+// MarkRead отмечает уведомление прочитанным. Повторная отметка не меняет время прочтения.
+func (s *Store) MarkRead(ctx context.Context, recipientID string, id int64) (models.Notification, error) {
+	var note models.Notification
+	err := s.session(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Notification{}).
+			Where("id = ? AND recipient_id = ? AND read_at IS NULL", id, recipientID).
+			Update("read_at", gorm.Expr("now()")).Error; err != nil {
+			return err
+		}
+		err := tx.Where("id = ? AND recipient_id = ? AND expires_at > now()", id, recipientID).First(&note).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotFound
+		}
+		return err
+	})
+	return note, err
+}
+
+// MarkAllRead отмечает прочитанными все уведомления получателя с идентификатором не больше upTo
+// (0 — все), чтобы пришедшие после загрузки списка остались непрочитанными. Возвращает число отмеченных.
+func (s *Store) MarkAllRead(ctx context.Context, recipientID string, upTo int64) (int64, error) {
+	q := s.active(ctx, recipientID).Where("read_at IS NULL")
+	if upTo > 0 {
+		q = q.Where("id <= ?", upTo)
+	}
+	res := q.Update("read_at", gorm.Expr("now()"))
+	return res.RowsAffected, res.Error
+}
+
+func (s *Store) active(ctx context.Context, recipientID string) *gorm.DB {
+	return s.session(ctx).Model(&models.Notification{}).
+		Where("recipient_id = ? AND expires_at > now()", recipientID)
+}
+
+// DeleteExpired удаляет уведомления с истёкшим сроком хранения порциями по cleanupBatch.
+func (s *Store) DeleteExpired(ctx context.Context) (int64, error) {
+	var total int64
+	for {
+		res := s.session(ctx).Exec(`DELETE FROM notifications WHERE id IN (
+			SELECT id FROM notifications WHERE expires_at <= now() LIMIT ?)`, cleanupBatch)
+		if res.Error != nil {
+			return total, res.Error
+		}
+		total += res.RowsAffected
+		if res.RowsAffected < cleanupBatch {
+			return total, nil
+		}
+	}
+}
+
+// Cleanup возвращает фоновую задачу для lifecycle.Manager.Go, которая раз в interval
+// удаляет истёкшие уведомления.
+func (s *Store) Cleanup(interval time.Duration) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ticker.C:
+				if n, err := s.DeleteExpired(ctx); err != nil && ctx.Err() == nil {
+					slog.Warn("Не удалось удалить истёкшие уведомления", slog.Any("error", err))
+				} else if n > 0 {
+					slog.Debug("Удалены истёкшие уведомления", slog.Int64("count", n))
+				}
+			}
+		}
+	}
+}
+
+// EncodeCursor возвращает курсор следующей страницы после уведомления id.
+func EncodeCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+// DecodeCursor разбирает курсор, выданный EncodeCursor.
+func DecodeCursor(cursor string) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	id, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, ErrInvalidCursor
+	}
+	return id, nil
+}
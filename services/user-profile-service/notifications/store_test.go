@@ -0,0 +1,106 @@
+package notifications
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"github.com/monst/story-craft/services/user-profile-service/testutil"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(testutil.RunWithPostgres(m))
+}
+
+func TestCursor(t *testing.T) {
+	id, err := DecodeCursor(EncodeCursor(42))
+	if err != nil || id != 42 {
+		t.Fatalf("ожидали 42, получили %d, %v", id, err)
+	}
+	for _, bad := range []string{"!!", "YWJj", EncodeCursor(0)} {
+		if _, err := DecodeCursor(bad); err != ErrInvalidCursor {
+			t.Errorf("%q: ожидали ErrInvalidCursor, получили %v", bad, err)
+		}
+	}
+}
+
+func TestIngest(t *testing.T) {
+	db := testutil.NewDB(t)
+	fx := testutil.LoadFixtures(t, db)
+	store := NewStore(db, nil, nil)
+	ctx := context.Background()
+
+	// Bob отключил уведомления о новых главах в приложении
+	prefs := models.DefaultNotificationPreferences()
+	prefs.Events[models.EventNewChapter] = models.EventChannels{Email: true}
+	if err := db.Model(&fx.Bob).Update("notification_preferences", prefs).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	key := "story-1:chapter-2"
+	note := models.Notification{Type: models.EventNewChapter, Payload: []byte(`{"story_id":"1"}`), DedupKey: &key}
+	recipients := []string{fx.Alice.UserID, fx.Bob.UserID, fx.Deleted.UserID}
+	created, results, err := store.Ingest(ctx, note, recipients, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(created) != 1 || created[0].RecipientID != fx.Alice.UserID || created[0].ID == 0 {
+		t.Fatalf("ожидали одно уведомление для Alice, получили %+v", created)
+	}
+	if results[fx.Bob.UserID] != ResultMuted || results[fx.Deleted.UserID] != ResultMuted {
+		t.Errorf("Bob и удалённый профиль не должны получить уведомление: %v", results)
+	}
+
+	// Повторная доставка не создаёт дубликат
+	created, results, err = store.Ingest(ctx, note, recipients, time.Hour)
+	if err != nil || len(created) != 0 || results[fx.Alice.UserID] != ResultDuplicate {
+		t.Errorf("ожидали дубликат, получили %+v, %v, %v", created, results, err)
+	}
+}
+
+func TestReadStateAndCleanup(t *testing.T) {
+	db := testutil.NewDB(t)
+	fx := testutil.LoadFixtures(t, db)
+	store := NewStore(db, nil, nil)
+	ctx := context.Background()
+
+	var ids []int64
+	for range 3 {
+		created, _, err := store.Ingest(ctx, models.Notification{Type: models.EventVotingOpened}, []string{fx.Alice.UserID}, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, created[0].ID)
+	}
+
+	if _, err := store.MarkRead(ctx, fx.Bob.UserID, ids[0]); err != ErrNotFound {
+		t.Errorf("чужое уведомление: ожидали ErrNotFound, получили %v", err)
+	}
+	note, err := store.MarkRead(ctx, fx.Alice.UserID, ids[0])
+	if err != nil || !note.IsRead() {
+		t.Fatalf("уведомление не отмечено: %+v, %v", note, err)
+	}
+	if n, _ := store.UnreadCount(ctx, fx.Alice.UserID); n != 2 {
+		t.Errorf("ожидали 2 непрочитанных, получили %d", n)
+	}
+
+	// Отмечаются только уведомления не новее up_to
+	if n, err := store.MarkAllRead(ctx, fx.Alice.UserID, ids[1]); err != nil || n != 1 {
+		t.Errorf("ожидали одно отмеченное, получили %d, %v", n, err)
+	}
+	unread, _ := store.List(ctx, fx.Alice.UserID, ListOptions{Limit: 10, UnreadOnly: true})
+	if len(unread) != 1 || unread[0].ID != ids[2] {
+		t.Errorf("непрочитанным должно остаться последнее уведомление: %+v", unread)
+	}
+
+	// Истёкшие уведомления не показываются и удаляются очисткой
+	db.Exec("UPDATE notifications SET expires_at = now() - interval '1 minute' WHERE id = ?", ids[2])
+	if n, _ := store.UnreadCount(ctx, fx.Alice.UserID); n != 0 {
+		t.Errorf("истёкшее уведомление не должно считаться, получили %d", n)
+	}
+	if n, err := store.DeleteExpired(ctx); err != nil || n != 1 {
+		t.Errorf("ожидали удаление одного уведомления, получили %d, %v", n, err)
+	}
+}
@@ -0,0 +1,120 @@
+package push
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// ErrInvalidKeys — ключи шифрования подписки не подходят: p256dh должен быть
+// несжатой точкой P-256 (65 байт), auth — 16 байт, оба в base64url.
+var ErrInvalidKeys = errors.New("неверные ключи подписки: ожидались p256dh (65 байт) и auth (16 байт) в base64url")
+
+// ErrPayloadTooLarge — зашифрованное сообщение не помещается в 4096 байт, которые
+// обязан принимать push-сервис (RFC 8030, раздел 7.2).
+var ErrPayloadTooLarge = errors.New("сообщение слишком большое для Web Push")
+
+const (
+	// recordSize — размер записи aes128gcm; сообщение всегда шифруется одной записью.
+	recordSize = 4096
+	// headerSize — заголовок aes128gcm: соль, размер записи, длина и сам открытый ключ сервера.
+	headerSize = 16 + 4 + 1 + 65
+	// MaxPayload — наибольший открытый текст, который помещается в одну запись вместе
+	// с заголовком, разделителем и тегом AES-GCM.
+	MaxPayload = recordSize - headerSize - 1 - 16
+)
+
+// Keys — ключи шифрования подписки браузера.
+type Keys struct {
+	public *ecdh.PublicKey
+	auth   []byte
+}
+
+// ParseKeys проверяет и разбирает ключи p256dh и auth из подписки браузера.
+func ParseKeys(p256dh, auth string) (Keys, error) {
+	rawPublic, err := decodeBase64(p256dh)
+	if err != nil || len(rawPublic) != 65 {
+		return Keys{}, ErrInvalidKeys
+	}
+	public, err := ecdh.P256().NewPublicKey(rawPublic)
+	if err != nil {
+		return Keys{}, ErrInvalidKeys
+	}
+	rawAuth, err := decodeBase64(auth)
+	if err != nil || len(rawAuth) != 16 {
+		return Keys{}, ErrInvalidKeys
+	}
+	return Keys{public: public, auth: rawAuth}, nil
+}
+
+// Encrypt шифрует сообщение для браузера по RFC 8291 в формате aes128gcm (RFC 8188)
+// с новыми солью и эфемерным ключом сервера для каждого сообщения.
+func Encrypt(plaintext []byte, keys Keys) ([]byte, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	ephemeral, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return encrypt(plaintext, keys, salt, ephemeral)
+}
+
+func encrypt(plaintext []byte, keys Keys, salt []byte, ephemeral *ecdh.PrivateKey) ([]byte, error) {
+	if len(plaintext) > MaxPayload {
+		return nil, ErrPayloadTooLarge
+	}
+	secret, err := ephemeral.ECDH(keys.public)
+	if err != nil {
+		return nil, err
+	}
+	serverPublic := ephemeral.PublicKey().Bytes()
+
+	// Общий секрет связывается с auth браузера и обоими открытыми ключами (RFC 8291, раздел 3.4)
+	keyInfo := append([]byte("WebPush: info\x00"), keys.public.Bytes()...)
+	keyInfo = append(keyInfo, serverPublic...)
+	ikm, err := expand(hkdf.Extract(sha256.New, secret, keys.auth), keyInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+	prk := hkdf.Extract(sha256.New, ikm, salt)
+	cek, err := expand(prk, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := expand(prk, []byte("Content-Encoding: nonce\x00"), 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	body := make([]byte, headerSize, headerSize+len(plaintext)+1+gcm.Overhead())
+	copy(body, salt)
+	binary.BigEndian.PutUint32(body[16:], recordSize)
+	body[20] = byte(len(serverPublic))
+	copy(body[21:], serverPublic)
+	// Единственная запись заканчивается разделителем 0x02 без дополнения
+	record := append(append([]byte{}, plaintext...), 0x02)
+	return gcm.Seal(body, nonce, record, nil), nil
+}
+
+func expand(prk, info []byte, n int) ([]byte, error) {
+	out := make([]byte, n)
+	_, err := io.ReadFull(hkdf.Expand(sha256.New, prk, info), out)
+	return out, err
+}
@@ -0,0 +1,82 @@
+package push
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func mustDecode(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := decodeBase64(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// Пример из приложения A RFC 8291.
+func TestEncryptRFC8291Example(t *testing.T) {
+	keys, err := ParseKeys(
+		"BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4",
+		"BTBZMqHH6r4Tts7J_aSIgg",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ecdh.P256().NewPrivateKey(mustDecode(t, "yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := encrypt([]byte("When I grow up, I want to be a watermelon"), keys, mustDecode(t, "DGv6ra1nlYgDCS1FRnbzlw"), server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "DGv6ra1nlYgDCS1FRnbzlwAAEABBBP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A_yl95bQpu6cVPTpK4Mqgkf1CXztLVBSt2Ks3oZwbuwXPXLWyouBWLVWGNWQexSgSxsj_Qulcy4a-fN"
+	if got := base64.RawURLEncoding.EncodeToString(body); got != want {
+		t.Errorf("неверный результат шифрования:\nполучено %s\nожидалось %s", got, want)
+	}
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	browser := newBrowser(t)
+	for _, size := range []int{0, 1, 100, MaxPayload} {
+		plaintext := bytes.Repeat([]byte{'x'}, size)
+		body, err := Encrypt(plaintext, browser.keys(t))
+		if err != nil {
+			t.Fatalf("%d байт: %v", size, err)
+		}
+		if len(body) > recordSize {
+			t.Errorf("%d байт: зашифровано в %d байт, больше %d", size, len(body), recordSize)
+		}
+		got, err := browser.decrypt(body)
+		if err != nil || !bytes.Equal(got, plaintext) {
+			t.Errorf("%d байт: расшифровка не совпала: %v", size, err)
+		}
+	}
+	if _, err := Encrypt(make([]byte, MaxPayload+1), browser.keys(t)); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("ожидали ErrPayloadTooLarge, получили %v", err)
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	browser := newBrowser(t)
+	p256dh, auth := browser.subscriptionKeys()
+	if _, err := ParseKeys(p256dh+"==", auth); err != nil {
+		t.Errorf("ключи с дополнением base64 должны приниматься: %v", err)
+	}
+	bad := map[string][2]string{
+		"пустые":             {"", ""},
+		"короткий auth":      {p256dh, "AAAA"},
+		"сжатая точка":       {base64.RawURLEncoding.EncodeToString(mustDecode(t, p256dh)[:33]), auth},
+		"точка не на кривой": {base64.RawURLEncoding.EncodeToString(append([]byte{4}, make([]byte, 64)...)), auth},
+		"не base64":          {"!!!", auth},
+	}
+	for name, k := range bad {
+		if _, err := ParseKeys(k[0], k[1]); !errors.Is(err, ErrInvalidKeys) {
+			t.Errorf("%s: ожидали ErrInvalidKeys, получили %v", name, err)
+		}
+	}
+}
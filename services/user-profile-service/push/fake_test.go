@@ -0,0 +1,219 @@
+package push
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// browser — ключи браузера, на который подписан пользователь: расшифровывает сообщения, как это сделал бы браузер.
+type browser struct {
+	priv *ecdh.PrivateKey
+	auth []byte
+}
+
+func newBrowser(t *testing.T) browser {
+	t.Helper()
+	priv, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := make([]byte, 16)
+	rand.Read(auth)
+	return browser{priv: priv, auth: auth}
+}
+
+func (b browser) subscriptionKeys() (p256dh, auth string) {
+	return base64.RawURLEncoding.EncodeToString(b.priv.PublicKey().Bytes()), base64.RawURLEncoding.EncodeToString(b.auth)
+}
+
+func (b browser) keys(t *testing.T) Keys {
+	t.Helper()
+	keys, err := ParseKeys(b.subscriptionKeys())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+// decrypt расшифровывает тело aes128gcm из одной записи по RFC 8291.
+func (b browser) decrypt(body []byte) ([]byte, error) {
+	if len(body) < headerSize || body[20] != 65 {
+		return nil, errors.New("неверный заголовок aes128gcm")
+	}
+	salt, serverPublic := body[:16], body[21:86]
+	if rs := binary.BigEndian.Uint32(body[16:20]); int(rs) < len(body)-headerSize {
+		return nil, fmt.Errorf("запись больше rs=%d", rs)
+	}
+	server, err := ecdh.P256().NewPublicKey(serverPublic)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := b.priv.ECDH(server)
+	if err != nil {
+		return nil, err
+	}
+	keyInfo := append([]byte("WebPush: info\x00"), b.priv.PublicKey().Bytes()...)
+	keyInfo = append(keyInfo, serverPublic...)
+	ikm, _ := expand(hkdf.Extract(sha256.New, secret, b.auth), keyInfo, 32)
+	prk := hkdf.Extract(sha256.New, ikm, salt)
+	cek, _ := expand(prk, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	nonce, _ := expand(prk, []byte("Content-Encoding: nonce\x00"), 12)
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	record, err := gcm.Open(nil, nonce, body[headerSize:], nil)
+	if err != nil {
+		return nil, err
+	}
+	record = bytes.TrimRight(record, "\x00")
+	if len(record) == 0 || record[len(record)-1] != 0x02 {
+		return nil, errors.New("нет разделителя последней записи")
+	}
+	return record[:len(record)-1], nil
+}
+
+// pushRequest — сообщение, принятое поддельным push-сервисом, после расшифровки.
+type pushRequest struct {
+	Path    string
+	TTL     string
+	Urgency string
+	Payload []byte
+}
+
+// fakePushService — локальный push-сервис: проверяет подпись VAPID и шифрование,
+// как настоящий, и отвечает status для путей из statuses.
+type fakePushService struct {
+	*httptest.Server
+	vapidKey string
+
+	mu       sync.Mutex
+	browsers map[string]browser
+	statuses map[string]int
+	received []pushRequest
+}
+
+func startFakePush(t *testing.T, vapid *VAPID) *fakePushService {
+	t.Helper()
+	f := &fakePushService{vapidKey: vapid.PublicKey(), browsers: map[string]browser{}, statuses: map[string]int{}}
+	f.Server = httptest.NewTLSServer(http.HandlerFunc(f.handle))
+	t.Cleanup(f.Close)
+	return f
+}
+
+// subscribe регистрирует новый браузер и возвращает его подписку.
+func (f *fakePushService) subscribe(t *testing.T, path string) (endpoint, p256dh, auth string) {
+	t.Helper()
+	b := newBrowser(t)
+	f.mu.Lock()
+	f.browsers[path] = b
+	f.mu.Unlock()
+	p256dh, auth = b.subscriptionKeys()
+	return f.URL + path, p256dh, auth
+}
+
+func (f *fakePushService) setStatus(path string, status int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.statuses[path] = status
+}
+
+func (f *fakePushService) messages() []pushRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]pushRequest(nil), f.received...)
+}
+
+func (f *fakePushService) handle(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if status, ok := f.statuses[r.URL.Path]; ok {
+		w.WriteHeader(status)
+		return
+	}
+	b, ok := f.browsers[r.URL.Path]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err := f.checkVAPID(r); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodPost || r.Header.Get("Content-Encoding") != "aes128gcm" || r.Header.Get("TTL") == "" {
+		http.Error(w, "неверный запрос", http.StatusBadRequest)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	if len(body) > recordSize {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		return
+	}
+	payload, err := b.decrypt(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.received = append(f.received, pushRequest{Path: r.URL.Path, TTL: r.Header.Get("TTL"), Urgency: r.Header.Get("Urgency"), Payload: payload})
+	w.WriteHeader(http.StatusCreated)
+}
+
+// checkVAPID проверяет заголовок Authorization по RFC 8292: ключ, аудиторию, срок и подпись ES256.
+func (f *fakePushService) checkVAPID(r *http.Request) error {
+	token, key, ok := strings.Cut(strings.TrimPrefix(r.Header.Get("Authorization"), "vapid t="), ", k=")
+	if !ok || key != f.vapidKey {
+		return errors.New("неверный заголовок vapid")
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errors.New("неверный JWT")
+	}
+	rawKey, _ := decodeBase64(key)
+	pub := ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(rawKey[1:33]), Y: new(big.Int).SetBytes(rawKey[33:])}
+	sig, _ := decodeBase64(parts[2])
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if len(sig) != 64 || !ecdsa.Verify(&pub, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+		return errors.New("неверная подпись JWT")
+	}
+	var claims struct {
+		Aud string `json:"aud"`
+		Exp int64  `json:"exp"`
+		Sub string `json:"sub"`
+	}
+	raw, _ := decodeBase64(parts[1])
+	if err := json.Unmarshal(raw, &claims); err != nil {
+		return err
+	}
+	if claims.Aud != "https://"+r.Host || claims.Sub == "" {
+		return fmt.Errorf("неверные aud или sub: %+v", claims)
+	}
+	if exp := time.Unix(claims.Exp, 0); exp.Before(time.Now()) || exp.After(time.Now().Add(24*time.Hour)) {
+		return fmt.Errorf("срок JWT %s вне допустимых суток", exp)
+	}
+	return nil
+}
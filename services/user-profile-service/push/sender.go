@@ -0,0 +1,89 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrSubscriptionGone — push-сервис больше не знает подписку (404 или 410):
+// пользователь отписался в браузере или подписка истекла. Такая подписка удаляется.
+var ErrSubscriptionGone = errors.New("подписка больше не действует")
+
+// StatusError — push-сервис отклонил сообщение с кодом Code.
+type StatusError struct {
+	Code int
+	Body string
+	// RetryAfter — пауза из заголовка Retry-After ответа 429 или 503, если сервис её указал.
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("push-сервис ответил %d: %s", e.Code, e.Body)
+}
+
+// Temporary сообщает, что отправку стоит повторить: сервис перегружен или недоступен.
+func (e *StatusError) Temporary() bool {
+	return e.Code == http.StatusTooManyRequests || e.Code >= 500
+}
+
+// Sender отправляет зашифрованные сообщения push-сервисам браузеров по RFC 8030.
+type Sender struct {
+	client *http.Client
+	vapid  *VAPID
+	ttl    time.Duration
+}
+
+// NewSender создаёт отправителя с подписью vapid; ttl — сколько push-сервис хранит
+// сообщение для браузера не в сети. client задаётся в тестах с самоподписанным сертификатом.
+func NewSender(client *http.Client, vapid *VAPID, ttl time.Duration) *Sender {
+	if client == nil {
+		client = &http.Client{}
+	}
+	return &Sender{client: client, vapid: vapid, ttl: ttl}
+}
+
+// Send шифрует payload для подписки и отправляет его на endpoint. Ошибка
+// ErrSubscriptionGone означает, что подписку нужно удалить, *StatusError — ответ сервиса.
+func (s *Sender) Send(ctx context.Context, endpoint string, keys Keys, payload []byte, urgency string) error {
+	body, err := Encrypt(payload, keys)
+	if err != nil {
+		return err
+	}
+	auth, err := s.vapid.Authorization(endpoint, time.Now())
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", auth)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("TTL", strconv.Itoa(int(s.ttl.Seconds())))
+	req.Header.Set("Urgency", urgency)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	text, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return ErrSubscriptionGone
+	}
+	statusErr := &StatusError{Code: resp.StatusCode, Body: string(bytes.TrimSpace(text))}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		statusErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return statusErr
+}
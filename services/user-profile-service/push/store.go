@@ -0,0 +1,315 @@
+package push
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/monst/story-craft/services/user-profile-service/audit"
+	"github.com/monst/story-craft/services/user-profile-service/config"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/plugin/dbresolver"
+)
+
+// ErrNotFound — подписки нет или она принадлежит другому пользователю.
+var ErrNotFound = errors.New("подписка не найдена")
+
+// ErrInvalidEndpoint — адрес подписки не https или ведёт не на разрешённый push-сервис.
+var ErrInvalidEndpoint = errors.New("адрес подписки должен вести на разрешённый push-сервис по https")
+
+// Store хранит подписки браузеров и очередь push-сообщений в основной БД:
+// как и очередь писем, она меняется при каждом чтении.
+type Store struct {
+	db  *gorm.DB
+	cfg config.WebPushConfig
+}
+
+func NewStore(db *gorm.DB, cfg config.WebPushConfig) *Store {
+	return &Store{db: db, cfg: cfg}
+}
+
+func (s *Store) session(ctx context.Context) *gorm.DB {
+	return s.db.WithContext(ctx).Clauses(dbresolver.Write).Session(&gorm.Session{})
+}
+
+// Validate проверяет подписку из запроса: адрес push-сервиса из WEBPUSH_ALLOWED_HOSTS
+// по https и ключи шифрования. Сервис сам отправляет запросы на этот адрес, поэтому
+// произвольные адреса, в том числе внутренней сети, не принимаются.
+func (s *Store) Validate(input models.PushSubscriptionInput) error {
+	u, err := url.Parse(input.Endpoint)
+	if err != nil || u.Scheme != "https" || u.User != nil || !s.allowedHost(u.Hostname()) {
+		return ErrInvalidEndpoint
+	}
+	_, err = ParseKeys(input.Keys.P256dh, input.Keys.Auth)
+	return err
+}
+
+func (s *Store) allowedHost(host string) bool {
+	host = strings.ToLower(host)
+	for _, allowed := range s.cfg.AllowedHosts {
+		allowed = strings.ToLower(allowed)
+		if suffix, ok := strings.CutPrefix(allowed, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+		} else if host == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// Subscribe сохраняет подписку браузера пользователя. Подписка с тем же адресом
+// обновляется, даже если раньше принадлежала другому пользователю: на устройстве
+// вошли под другой учётной записью. Если подписок больше WEBPUSH_MAX_SUBSCRIPTIONS,
+// удаляются самые давние. При первой подписке включается push для WebPushEvents,
+// если пользователь ещё не включал его сам. Возвращает подписку и true, если она новая.
+// Если профиля нет, возвращает gorm.ErrRecordNotFound.
+func (s *Store) Subscribe(ctx context.Context, actor audit.Actor, userID string, input models.PushSubscriptionInput, userAgent string) (models.PushSubscription, bool, error) {
+	if err := s.Validate(input); err != nil {
+		return models.PushSubscription{}, false, err
+	}
+	var sub models.PushSubscription
+	created := false
+	err := s.session(ctx).Transaction(func(tx *gorm.DB) error {
+		var profile models.Profile
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ?", userID).First(&profile).Error; err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&models.PushSubscription{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+			return err
+		}
+
+		fields := models.PushSubscription{
+			UserID:     userID,
+			Endpoint:   input.Endpoint,
+			P256dh:     input.Keys.P256dh,
+			Auth:       input.Keys.Auth,
+			DeviceName: truncate(input.DeviceName, 100),
+			UserAgent:  truncate(userAgent, 255),
+		}
+		if input.ExpirationTime != nil {
+			expires := time.UnixMilli(*input.ExpirationTime)
+			fields.ExpiresAt = &expires
+		}
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("endpoint = ?", input.Endpoint).First(&sub).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			sub, created = fields, true
+			if err := tx.Create(&sub).Error; err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		default:
+			// Сообщения прежнему владельцу устройства больше не отправляются
+			if sub.UserID != userID {
+				if err := cancelMessages(tx, sub.ID, "подписка перешла к другому пользователю"); err != nil {
+					return err
+				}
+			}
+			if err := tx.Model(&sub).Select("user_id", "p256dh", "auth", "device_name", "user_agent", "expires_at", "updated_at").
+				Updates(&fields).Error; err != nil {
+				return err
+			}
+			if err := tx.First(&sub, sub.ID).Error; err != nil {
+				return err
+			}
+		}
+		if err := s.evictOldest(tx, userID); err != nil {
+			return err
+		}
+
+		var diff audit.Diff
+		if count == 0 && created && !profile.NotificationPreferences.WebPushEnabled() {
+			prefs := profile.NotificationPreferences.WithWebPush()
+			diff = audit.DiffOf(profile.NotificationPreferences, prefs)
+			if err := tx.Model(&profile).UpdateColumn("notification_preferences", prefs).Error; err != nil {
+				return err
+			}
+		}
+		return audit.Record(tx, audit.Entry{
+			Actor:        actor,
+			Action:       audit.ActionPushSubscribed,
+			TargetUserID: userID,
+			Diff:         diff,
+			Details:      map[string]any{"subscription_id": sub.ID, "device_name": sub.DeviceName},
+		})
+	})
+	return sub, created, err
+}
+
+// evictOldest удаляет подписки пользователя сверх WEBPUSH_MAX_SUBSCRIPTIONS,
+// начиная с тех, что дольше всего не обновлялись и не получали сообщений.
+func (s *Store) evictOldest(tx *gorm.DB, userID string) error {
+	var extra []int64
+	if err := tx.Model(&models.PushSubscription{}).Where("user_id = ?", userID).
+		Order("GREATEST(updated_at, last_success_at) DESC, id DESC").Offset(s.cfg.MaxSubscriptions).Pluck("id", &extra).Error; err != nil {
+		return err
+	}
+	for _, id := range extra {
+		if err := deleteSubscription(tx, id, "подписка вытеснена более новой"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// List возвращает подписки пользователя от новых к старым.
+func (s *Store) List(ctx context.Context, userID string) ([]models.PushSubscription, error) {
+	subs := []models.PushSubscription{}
+	err := s.session(ctx).Where("user_id = ?", userID).Order("created_at DESC, id DESC").Find(&subs).Error
+	return subs, err
+}
+
+// Revoke удаляет подписку пользователя вместе с неотправленными сообщениями на неё.
+func (s *Store) Revoke(ctx context.Context, actor audit.Actor, userID string, id int64) error {
+	return s.session(ctx).Transaction(func(tx *gorm.DB) error {
+		var sub models.PushSubscription
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND user_id = ?", id, userID).First(&sub).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if err := deleteSubscription(tx, id, "пользователь отписал устройство"); err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Entry{
+			Actor:        actor,
+			Action:       audit.ActionPushRevoked,
+			TargetUserID: userID,
+			Details:      map[string]any{"subscription_id": sub.ID, "device_name": sub.DeviceName},
+		})
+	})
+}
+
+// Enqueue ставит push о событии event на все действующие подписки получателя.
+// Вызывается в транзакции изменения, как mail.Queue.Enqueue. Повтор с тем же
+// dedupKey на подписку пропускается. Возвращает true, если поставлено хотя бы одно сообщение.
+func (s *Store) Enqueue(tx *gorm.DB, recipientID, event string, payload json.RawMessage, dedupKey *string) (bool, error) {
+	var raw any
+	if len(payload) > 0 {
+		raw = string(payload)
+	}
+	var ids []int64
+	err := tx.Raw(`INSERT INTO push_messages (subscription_id, recipient_id, type, payload, dedup_key, status, next_attempt_at, created_at)
+		SELECT id, user_id, ?, ?, ?, ?, now(), now() FROM push_subscriptions
+		WHERE user_id = ? AND (expires_at IS NULL OR expires_at > now())
+		ON CONFLICT (subscription_id, dedup_key) WHERE dedup_key IS NOT NULL DO NOTHING
+		RETURNING id`,
+		event, raw, dedupKey, models.PushPending, recipientID).Scan(&ids).Error
+	return len(ids) > 0, err
+}
+
+// Claim забирает на отправку до limit сообщений, срок которых наступил. Как и в очереди
+// писем, забранные сообщения получают статус sending до истечения аренды, а SKIP LOCKED
+// не даёт двум экземплярам сервиса забрать одно сообщение.
+func (s *Store) Claim(ctx context.Context, limit int) ([]models.PushMessage, error) {
+	lease := time.Duration(s.cfg.BatchSize+1) * s.cfg.SendTimeout
+	var claimed []models.PushMessage
+	err := s.session(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status IN ? AND next_attempt_at <= now()", []string{models.PushPending, models.PushSending}).
+			Order("next_attempt_at").Limit(limit).Find(&claimed).Error; err != nil {
+			return err
+		}
+		if len(claimed) == 0 {
+			return nil
+		}
+		ids := make([]int64, 0, len(claimed))
+		for _, m := range claimed {
+			ids = append(ids, m.ID)
+		}
+		return tx.Model(&models.PushMessage{}).Where("id IN ?", ids).Updates(map[string]any{
+			"status":          models.PushSending,
+			"attempts":        gorm.Expr("attempts + 1"),
+			"next_attempt_at": gorm.Expr("now() + make_interval(secs => ?)", lease.Seconds()),
+		}).Error
+	})
+	for i := range claimed {
+		claimed[i].Status = models.PushSending
+		claimed[i].Attempts++
+	}
+	return claimed, err
+}
+
+// markSent отмечает сообщение отправленным, а подписку — рабочей.
+func (s *Store) markSent(ctx context.Context, m models.PushMessage) error {
+	return s.session(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.PushSubscription{}).Where("id = ?", m.SubscriptionID).
+			UpdateColumn("last_success_at", gorm.Expr("now()")).Error; err != nil {
+			return err
+		}
+		return finish(tx, m.ID, map[string]any{"status": models.PushSent, "sent_at": gorm.Expr("now()"), "last_error": ""})
+	})
+}
+
+// markRetry возвращает сообщение в очередь через delay после временной ошибки.
+func (s *Store) markRetry(ctx context.Context, id int64, delay time.Duration, reason string) error {
+	return finish(s.session(ctx), id, map[string]any{
+		"status":          models.PushPending,
+		"next_attempt_at": gorm.Expr("now() + make_interval(secs => ?)", delay.Seconds()),
+		"last_error":      reason,
+	})
+}
+
+// markDeferred возвращает сообщение в очередь до момента until без учёта попытки,
+// например до конца тихих часов получателя.
+func (s *Store) markDeferred(ctx context.Context, id int64, until time.Time) error {
+	return finish(s.session(ctx), id, map[string]any{
+		"status":          models.PushPending,
+		"attempts":        gorm.Expr("GREATEST(attempts - 1, 0)"),
+		"next_attempt_at": until,
+	})
+}
+
+// markDone завершает сообщение без отправки: status — failed или cancelled.
+func (s *Store) markDone(ctx context.Context, id int64, status, reason string) error {
+	return finish(s.session(ctx), id, map[string]any{"status": status, "last_error": reason})
+}
+
+// prune удаляет подписку, которую push-сервис больше не знает, и завершает сообщение m.
+func (s *Store) prune(ctx context.Context, m models.PushMessage, reason string) error {
+	return s.session(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := deleteSubscription(tx, m.SubscriptionID, reason); err != nil {
+			return err
+		}
+		return finish(tx, m.ID, map[string]any{"status": models.PushFailed, "last_error": reason})
+	})
+}
+
+func finish(tx *gorm.DB, id int64, updates map[string]any) error {
+	return tx.Model(&models.PushMessage{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// deleteSubscription удаляет подписку и отменяет ожидающие сообщения на неё.
+func deleteSubscription(tx *gorm.DB, id int64, reason string) error {
+	if err := tx.Delete(&models.PushSubscription{}, id).Error; err != nil {
+		return err
+	}
+	return cancelMessages(tx, id, reason)
+}
+
+// cancelMessages отменяет ожидающие сообщения на подписку.
+func cancelMessages(tx *gorm.DB, subscriptionID int64, reason string) error {
+	return tx.Model(&models.PushMessage{}).
+		Where("subscription_id = ? AND status = ?", subscriptionID, models.PushPending).
+		Updates(map[string]any{"status": models.PushCancelled, "last_error": reason}).Error
+}
+
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
@@ -0,0 +1,99 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/monst/story-craft/services/user-profile-service/audit"
+	"github.com/monst/story-craft/services/user-profile-service/config"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"github.com/monst/story-craft/services/user-profile-service/testutil"
+	"gorm.io/gorm"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(testutil.RunWithPostgres(m))
+}
+
+func TestValidate(t *testing.T) {
+	store := NewStore(nil, config.WebPushConfig{AllowedHosts: []string{"fcm.googleapis.com", "*.notify.windows.com"}})
+	p256dh, auth := newBrowser(t).subscriptionKeys()
+	keys := models.PushSubscriptionKeys{P256dh: p256dh, Auth: auth}
+	endpoints := map[string]error{
+		"https://fcm.googleapis.com/fcm/send/abc":          nil,
+		"https://FCM.googleapis.com/fcm/send/abc":          nil,
+		"https://wns2-par02p.notify.windows.com/w/?token=": nil,
+		"http://fcm.googleapis.com/fcm/send/abc":           ErrInvalidEndpoint,
+		"https://notify.windows.com/w/":                    ErrInvalidEndpoint,
+		"https://fcm.googleapis.com.evil.io/send":          ErrInvalidEndpoint,
+		"https://user@fcm.googleapis.com/send":             ErrInvalidEndpoint,
+		"https://169.254.169.254/latest/meta-data":         ErrInvalidEndpoint,
+	}
+	for endpoint, want := range endpoints {
+		if err := store.Validate(models.PushSubscriptionInput{Endpoint: endpoint, Keys: keys}); !errors.Is(err, want) {
+			t.Errorf("%s: ожидали %v, получили %v", endpoint, want, err)
+		}
+	}
+	keys.Auth = "AAAA"
+	if err := store.Validate(models.PushSubscriptionInput{Endpoint: "https://fcm.googleapis.com/x", Keys: keys}); !errors.Is(err, ErrInvalidKeys) {
+		t.Errorf("ожидали ErrInvalidKeys, получили %v", err)
+	}
+}
+
+func TestSubscribeAndRevoke(t *testing.T) {
+	db := testutil.NewDB(t)
+	fx := testutil.LoadFixtures(t, db)
+	vapid := testVAPID(t)
+	server := startFakePush(t, vapid)
+	store, _ := newTestWorker(t, db, server, vapid)
+	ctx := context.Background()
+
+	// Первая подписка включает push для важных событий
+	sub := subscribeDevice(t, store, server, fx.Alice.UserID, "/shared")
+	var alice models.Profile
+	db.First(&alice, "user_id = ?", fx.Alice.UserID)
+	if !alice.NotificationPreferences.Allows(models.EventProposalWon, models.ChannelWebPush) ||
+		alice.NotificationPreferences.Allows(models.EventNewChapter, models.ChannelWebPush) {
+		t.Errorf("неверные настройки push после первой подписки: %+v", alice.NotificationPreferences.Events)
+	}
+	if _, err := store.Enqueue(db, fx.Alice.UserID, models.EventProposalWon, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// На том же устройстве вошёл Bob: подписка переходит к нему, сообщения Alice отменяются
+	var existing models.PushSubscription
+	db.First(&existing, sub.ID)
+	again, created, err := store.Subscribe(ctx, audit.Actor{ID: fx.Bob.UserID}, fx.Bob.UserID, models.PushSubscriptionInput{
+		Endpoint:   existing.Endpoint,
+		Keys:       models.PushSubscriptionKeys{P256dh: existing.P256dh, Auth: existing.Auth},
+		DeviceName: "Общий планшет",
+	}, "test")
+	if err != nil || created || again.ID != sub.ID || again.UserID != fx.Bob.UserID {
+		t.Fatalf("ожидали обновление той же подписки: %+v, %v, %v", again, created, err)
+	}
+	if m := messageStatuses(t, db)[sub.ID]; m.Status != models.PushCancelled {
+		t.Errorf("сообщение прежнему владельцу: ожидали cancelled, получили %s", m.Status)
+	}
+	if subs, _ := store.List(ctx, fx.Alice.UserID); len(subs) != 0 {
+		t.Errorf("у Alice не должно остаться подписок: %+v", subs)
+	}
+
+	if _, _, err := store.Subscribe(ctx, audit.Actor{}, fx.Deleted.UserID, models.PushSubscriptionInput{
+		Endpoint: existing.Endpoint, Keys: models.PushSubscriptionKeys{P256dh: existing.P256dh, Auth: existing.Auth},
+	}, ""); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("удалённый профиль: ожидали ErrRecordNotFound, получили %v", err)
+	}
+	if err := store.Revoke(ctx, audit.Actor{ID: fx.Alice.UserID}, fx.Alice.UserID, sub.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("чужая подписка: ожидали ErrNotFound, получили %v", err)
+	}
+	if err := store.Revoke(ctx, audit.Actor{ID: fx.Bob.UserID}, fx.Bob.UserID, sub.ID); err != nil {
+		t.Fatal(err)
+	}
+	var entries []models.AuditEntry
+	db.Where("action LIKE 'push.%'").Order("id").Find(&entries)
+	if len(entries) != 3 || entries[2].Action != audit.ActionPushRevoked {
+		t.Errorf("ожидали две подписки и отписку в журнале, получили %+v", entries)
+	}
+}
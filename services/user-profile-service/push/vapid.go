@@ -0,0 +1,108 @@
+// Package push отправляет уведомления в браузеры через Web Push: хранит подписки
+// устройств, шифрует сообщения по RFC 8291, подписывает запросы ключом VAPID (RFC 8292)
+// и доставляет их из очереди, удаляя подписки, которые push-сервис больше не знает.
+package push
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrInvalidVAPIDKey — закрытый ключ VAPID не является ключом P-256 в base64url.
+var ErrInvalidVAPIDKey = errors.New("неверный закрытый ключ VAPID")
+
+// vapidTokenTTL — срок подписи запроса к push-сервису; RFC 8292 ограничивает его сутками.
+const vapidTokenTTL = 12 * time.Hour
+
+// VAPID — ключ сервера приложения, которым подписываются запросы к push-сервисам.
+// Браузер принимает сообщения только с подписью ключа, указанного при подписке.
+type VAPID struct {
+	key     *ecdsa.PrivateKey
+	public  []byte
+	subject string
+}
+
+// NewVAPID разбирает закрытый ключ в base64url (32 байта скаляра P-256).
+// subject — контакт владельца сервиса для push-сервисов: mailto: или https:.
+func NewVAPID(privateKey, subject string) (*VAPID, error) {
+	raw, err := decodeBase64(privateKey)
+	if err != nil {
+		return nil, ErrInvalidVAPIDKey
+	}
+	priv, err := ecdh.P256().NewPrivateKey(raw)
+	if err != nil {
+		return nil, ErrInvalidVAPIDKey
+	}
+	public := priv.PublicKey().Bytes()
+	key := &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(public[1:33]),
+			Y:     new(big.Int).SetBytes(public[33:]),
+		},
+		D: new(big.Int).SetBytes(raw),
+	}
+	return &VAPID{key: key, public: public, subject: subject}, nil
+}
+
+// GenerateKeys создаёт новую пару ключей VAPID в base64url: закрытый — для
+// WEBPUSH_VAPID_PRIVATE_KEY, открытый — applicationServerKey для браузеров.
+func GenerateKeys() (privateKey, publicKey string, err error) {
+	priv, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(priv.Bytes()),
+		base64.RawURLEncoding.EncodeToString(priv.PublicKey().Bytes()), nil
+}
+
+// PublicKey возвращает открытый ключ в несжатом виде в base64url — applicationServerKey
+// для pushManager.subscribe в браузере.
+func (v *VAPID) PublicKey() string {
+	return base64.RawURLEncoding.EncodeToString(v.public)
+}
+
+// Authorization возвращает заголовок Authorization для запроса на endpoint:
+// схема vapid с JWT ES256, выданным для источника endpoint и действующим до now + vapidTokenTTL.
+func (v *VAPID) Authorization(endpoint string, now time.Time) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"typ":"JWT","alg":"ES256"}`))
+	claims, err := json.Marshal(map[string]any{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": now.Add(vapidTokenTTL).Unix(),
+		"sub": v.subject,
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	r, s, err := ecdsa.Sign(rand.Reader, v.key, digest[:])
+	if err != nil {
+		return "", err
+	}
+	// Подпись JWS ES256 — r и s по 32 байта, а не DER
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	token := unsigned + "." + base64.RawURLEncoding.EncodeToString(sig)
+	return "vapid t=" + token + ", k=" + v.PublicKey(), nil
+}
+
+// decodeBase64 разбирает base64url с дополнением и без: браузеры и библиотеки выдают ключи по-разному.
+func decodeBase64(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
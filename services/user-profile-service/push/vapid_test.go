@@ -0,0 +1,54 @@
+package push
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVAPIDAuthorization(t *testing.T) {
+	vapid := testVAPID(t)
+	server := &fakePushService{vapidKey: vapid.PublicKey()}
+	auth, err := vapid.Authorization("https://push.example.com/send/abc?x=1", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("POST", "https://push.example.com/send/abc", nil)
+	req.Header.Set("Authorization", auth)
+	if err := server.checkVAPID(req); err != nil {
+		t.Errorf("push-сервис должен принять подпись: %v", err)
+	}
+
+	// Подпись выдана для источника endpoint и не подходит другому push-сервису
+	req = httptest.NewRequest("POST", "https://other.example.com/send/abc", nil)
+	req.Header.Set("Authorization", auth)
+	if err := server.checkVAPID(req); err == nil {
+		t.Error("подпись для другого источника не должна приниматься")
+	}
+	// Подпись чужим ключом
+	other, _ := vapid.Authorization("https://push.example.com/", time.Now())
+	server.vapidKey = testVAPID(t).PublicKey()
+	req.Header.Set("Authorization", strings.Replace(other, "k="+vapid.PublicKey(), "k="+server.vapidKey, 1))
+	req.Host = "push.example.com"
+	if err := server.checkVAPID(req); err == nil {
+		t.Error("подпись чужим ключом не должна приниматься")
+	}
+}
+
+func TestNewVAPID(t *testing.T) {
+	privateKey, publicKey, err := GenerateKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	vapid, err := NewVAPID(privateKey+"=", "mailto:support@story-craft.io")
+	if err != nil || vapid.PublicKey() != publicKey {
+		t.Fatalf("открытый ключ должен выводиться из закрытого: %v", err)
+	}
+	for _, key := range []string{"", "!!!", "AAAA", strings.Repeat("A", 43)} {
+		if _, err := NewVAPID(key, "mailto:a@b.c"); !errors.Is(err, ErrInvalidVAPIDKey) {
+			t.Errorf("%q: ожидали ErrInvalidVAPIDKey, получили %v", key, err)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package push
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/monst/story-craft/services/user-profile-service/config"
+	"github.com/monst/story-craft/services/user-profile-service/metrics"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+)
+
+// Результаты обработки сообщения для метрик.
+const (
+	ResultSent      = "sent"
+	ResultRetry     = "retry"
+	ResultFailed    = "failed"
+	ResultCancelled = "cancelled"
+	ResultDeferred  = "deferred"
+	// ResultPruned — push-сервис не знает подписку, она удалена.
+	ResultPruned = "pruned"
+)
+
+// Message — расшифрованное содержимое push, которое получает service worker приложения.
+type Message struct {
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// urgentEvents — события, о которых push-сервис доставляет сразу, даже в режиме экономии батареи.
+var urgentEvents = map[string]bool{models.EventVotingOpened: true, models.EventProposalWon: true}
+
+// Worker отправляет push-сообщения из очереди.
+type Worker struct {
+	store   *Store
+	sender  *Sender
+	cfg     config.WebPushConfig
+	metrics *metrics.Metrics
+}
+
+func NewWorker(store *Store, sender *Sender, cfg config.WebPushConfig, m *metrics.Metrics) *Worker {
+	return &Worker{store: store, sender: sender, cfg: cfg, metrics: m}
+}
+
+// Run — фоновая задача для lifecycle.Manager.Go: раз в WEBPUSH_POLL_INTERVAL
+// она отправляет готовые сообщения, пока очередь не опустеет.
+func (w *Worker) Run(ctx context.Context) error {
+	ticker := time.NewTicker(w.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			for {
+				n, err := w.ProcessBatch(ctx)
+				if err != nil && ctx.Err() == nil {
+					slog.Warn("Не удалось обработать очередь push-сообщений", slog.Any("error", err))
+				}
+				if err != nil || n < w.cfg.BatchSize {
+					break
+				}
+			}
+		}
+	}
+}
+
+// ProcessBatch забирает из очереди готовые сообщения и отправляет их.
+// Возвращает число обработанных сообщений.
+func (w *Worker) ProcessBatch(ctx context.Context) (int, error) {
+	claimed, err := w.store.Claim(ctx, w.cfg.BatchSize)
+	if err != nil || len(claimed) == 0 {
+		return 0, err
+	}
+
+	var subIDs []int64
+	var userIDs []string
+	for _, m := range claimed {
+		subIDs = append(subIDs, m.SubscriptionID)
+		userIDs = append(userIDs, m.RecipientID)
+	}
+	var subs []models.PushSubscription
+	if err := w.store.session(ctx).Where("id IN ?", subIDs).Find(&subs).Error; err != nil {
+		return 0, err
+	}
+	var profiles []models.Profile
+	if err := w.store.session(ctx).Select("user_id", "notification_preferences").
+		Where("user_id IN ?", userIDs).Find(&profiles).Error; err != nil {
+		return 0, err
+	}
+	bySub := make(map[int64]*models.PushSubscription, len(subs))
+	for i := range subs {
+		bySub[subs[i].ID] = &subs[i]
+	}
+	byUser := make(map[string]*models.Profile, len(profiles))
+	for i := range profiles {
+		byUser[profiles[i].UserID] = &profiles[i]
+	}
+
+	for _, m := range claimed {
+		if err := w.deliver(ctx, m, bySub[m.SubscriptionID], byUser[m.RecipientID]); err != nil {
+			return len(claimed), err
+		}
+	}
+	return len(claimed), nil
+}
+
+// deliver отправляет сообщение на подписку и сохраняет результат.
+// Возвращает только ошибки сохранения результата в очереди.
+func (w *Worker) deliver(ctx context.Context, m models.PushMessage, sub *models.PushSubscription, profile *models.Profile) error {
+	cancel := func(reason string) error {
+		w.metrics.Push(m.Type, ResultCancelled)
+		return w.store.markDone(ctx, m.ID, models.PushCancelled, reason)
+	}
+	fail := func(reason string) error {
+		w.metrics.Push(m.Type, ResultFailed)
+		return w.store.markDone(ctx, m.ID, models.PushFailed, reason)
+	}
+	prune := func(reason string) error {
+		w.metrics.Push(m.Type, ResultPruned)
+		return w.store.prune(ctx, m, reason)
+	}
+
+	// Подписку могли удалить или передать другому пользователю, а получатель — удалить профиль
+	// или выключить push, пока сообщение ждало отправки
+	if sub == nil || sub.UserID != m.RecipientID {
+		return cancel("подписка удалена")
+	}
+	if profile == nil {
+		return cancel("профиль удалён")
+	}
+	now := time.Now()
+	if sub.ExpiresAt != nil && !sub.ExpiresAt.After(now) {
+		return prune("срок подписки истёк")
+	}
+	prefs := profile.NotificationPreferences
+	if !prefs.Allows(m.Type, models.ChannelWebPush) {
+		return cancel("push выключен в настройках уведомлений")
+	}
+	if prefs.QuietHours.Active(now) {
+		w.metrics.Push(m.Type, ResultDeferred)
+		return w.store.markDeferred(ctx, m.ID, prefs.QuietHours.Ends(now))
+	}
+
+	keys, err := ParseKeys(sub.P256dh, sub.Auth)
+	if err != nil {
+		return prune(err.Error())
+	}
+	payload, err := json.Marshal(Message{Type: m.Type, Payload: m.Payload})
+	if err != nil {
+		return fail(err.Error())
+	}
+	// Данные, не помещающиеся в сообщение, приложение загрузит из входящих
+	if len(payload) > MaxPayload {
+		payload, _ = json.Marshal(Message{Type: m.Type})
+	}
+	urgency := "normal"
+	if urgentEvents[m.Type] {
+		urgency = "high"
+	}
+
+	sendCtx, cancelSend := context.WithTimeout(ctx, w.cfg.SendTimeout)
+	err = w.sender.Send(sendCtx, sub.Endpoint, keys, payload, urgency)
+	cancelSend()
+	var statusErr *StatusError
+	switch {
+	case err == nil:
+		w.metrics.Push(m.Type, ResultSent)
+		return w.store.markSent(ctx, m)
+	case ctx.Err() != nil:
+		// Сервис останавливается: сообщение вернётся в очередь по истечении аренды
+		return nil
+	case errors.Is(err, ErrSubscriptionGone):
+		return prune(err.Error())
+	case m.Attempts >= w.cfg.MaxAttempts:
+		return fail(err.Error())
+	case errors.As(err, &statusErr) && !statusErr.Temporary():
+		return fail(err.Error())
+	default:
+		delay := w.backoff(m.Attempts)
+		if statusErr != nil && statusErr.RetryAfter > delay {
+			delay = min(statusErr.RetryAfter, w.cfg.MaxRetryBackoff)
+		}
+		w.metrics.Push(m.Type, ResultRetry)
+		return w.store.markRetry(ctx, m.ID, delay, err.Error())
+	}
+}
+
+// backoff — пауза перед повтором после attempt неудачных попыток: удваивается от
+// WEBPUSH_RETRY_BACKOFF до WEBPUSH_MAX_RETRY_BACKOFF.
+func (w *Worker) backoff(attempt int) time.Duration {
+	delay := w.cfg.RetryBackoff
+	for i := 1; i < attempt && delay < w.cfg.MaxRetryBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, w.cfg.MaxRetryBackoff)
+}
@@ -0,0 +1,186 @@
+package push
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/monst/story-craft/services/user-profile-service/audit"
+	"github.com/monst/story-craft/services/user-profile-service/config"
+	"github.com/monst/story-craft/services/user-profile-service/metrics"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"github.com/monst/story-craft/services/user-profile-service/testutil"
+	"gorm.io/gorm"
+)
+
+func testVAPID(t *testing.T) *VAPID {
+	t.Helper()
+	privateKey, _, err := GenerateKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	vapid, err := NewVAPID(privateKey, "mailto:support@story-craft.io")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return vapid
+}
+
+func testWebPushConfig() config.WebPushConfig {
+	return config.WebPushConfig{
+		Enabled:          true,
+		AllowedHosts:     []string{"127.0.0.1"},
+		MaxSubscriptions: 2,
+		TTL:              time.Hour,
+		SendTimeout:      5 * time.Second,
+		PollInterval:     time.Second,
+		BatchSize:        20,
+		MaxAttempts:      2,
+		RetryBackoff:     time.Minute,
+		MaxRetryBackoff:  time.Hour,
+	}
+}
+
+func newTestWorker(t *testing.T, db *gorm.DB, server *fakePushService, vapid *VAPID) (*Store, *Worker) {
+	t.Helper()
+	cfg := testWebPushConfig()
+	store := NewStore(db, cfg)
+	return store, NewWorker(store, NewSender(server.Client(), vapid, cfg.TTL), cfg, metrics.New())
+}
+
+// subscribeDevice подписывает пользователя на поддельном push-сервисе по пути path.
+func subscribeDevice(t *testing.T, store *Store, server *fakePushService, userID, path string) models.PushSubscription {
+	t.Helper()
+	endpoint, p256dh, auth := server.subscribe(t, path)
+	sub, _, err := store.Subscribe(context.Background(), audit.Actor{ID: userID}, userID, models.PushSubscriptionInput{
+		Endpoint: endpoint,
+		Keys:     models.PushSubscriptionKeys{P256dh: p256dh, Auth: auth},
+	}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sub
+}
+
+func messageStatuses(t *testing.T, db *gorm.DB) map[int64]models.PushMessage {
+	t.Helper()
+	var messages []models.PushMessage
+	if err := db.Find(&messages).Error; err != nil {
+		t.Fatal(err)
+	}
+	bySub := map[int64]models.PushMessage{}
+	for _, m := range messages {
+		bySub[m.SubscriptionID] = m
+	}
+	return bySub
+}
+
+func TestWorkerDeliversEncryptedPush(t *testing.T) {
+	db := testutil.NewDB(t)
+	fx := testutil.LoadFixtures(t, db)
+	vapid := testVAPID(t)
+	server := startFakePush(t, vapid)
+	store, worker := newTestWorker(t, db, server, vapid)
+	ctx := context.Background()
+
+	laptop := subscribeDevice(t, store, server, fx.Alice.UserID, "/laptop")
+	phone := subscribeDevice(t, store, server, fx.Alice.UserID, "/phone")
+	gone := subscribeDevice(t, store, server, fx.Alice.UserID, "/gone")
+	server.setStatus("/gone", http.StatusGone)
+	// Подписок больше WEBPUSH_MAX_SUBSCRIPTIONS: самая старая вытеснена
+	if err := db.First(&models.PushSubscription{}, laptop.ID).Error; err == nil {
+		t.Fatal("самая старая подписка должна быть вытеснена")
+	}
+
+	key := "story-1:won"
+	payload := json.RawMessage(`{"story_id":"story-1","story_title":"Туманный берег"}`)
+	for _, want := range []bool{true, false} {
+		queued, err := store.Enqueue(db, fx.Alice.UserID, models.EventProposalWon, payload, &key)
+		if err != nil || queued != want {
+			t.Fatalf("ожидали queued=%v, получили %v, %v", want, queued, err)
+		}
+	}
+	n, err := worker.ProcessBatch(ctx)
+	if err != nil || n != 2 {
+		t.Fatalf("ожидали по сообщению на 2 подписки, получили %d, %v", n, err)
+	}
+
+	got := server.messages()
+	if len(got) != 1 || got[0].Path != "/phone" || got[0].TTL != "3600" || got[0].Urgency != "high" {
+		t.Fatalf("ожидали одно сообщение на телефон, получили %+v", got)
+	}
+	var msg Message
+	if err := json.Unmarshal(got[0].Payload, &msg); err != nil || msg.Type != models.EventProposalWon || string(msg.Payload) != string(payload) {
+		t.Errorf("неверное содержимое push: %s, %v", got[0].Payload, err)
+	}
+
+	// Подписка, которую push-сервис не знает, удаляется вместе с очередью на неё
+	statuses := messageStatuses(t, db)
+	if statuses[phone.ID].Status != models.PushSent || statuses[gone.ID].Status != models.PushFailed {
+		t.Errorf("неверные статусы сообщений: %+v", statuses)
+	}
+	if err := db.First(&models.PushSubscription{}, gone.ID).Error; err == nil {
+		t.Error("подписка с ответом 410 должна быть удалена")
+	}
+	var sent models.PushSubscription
+	db.First(&sent, phone.ID)
+	if sent.LastSuccessAt == nil {
+		t.Error("время последней доставки не сохранено")
+	}
+}
+
+func TestWorkerRetriesAndCancels(t *testing.T) {
+	db := testutil.NewDB(t)
+	fx := testutil.LoadFixtures(t, db)
+	vapid := testVAPID(t)
+	server := startFakePush(t, vapid)
+	store, worker := newTestWorker(t, db, server, vapid)
+	ctx := context.Background()
+
+	busy := subscribeDevice(t, store, server, fx.Alice.UserID, "/busy")
+	bob := subscribeDevice(t, store, server, fx.Bob.UserID, "/bob")
+	server.setStatus("/busy", http.StatusServiceUnavailable)
+	for _, id := range []string{fx.Alice.UserID, fx.Bob.UserID} {
+		if _, err := store.Enqueue(db, id, models.EventVotingOpened, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Bob выключил push, пока сообщение ждало отправки
+	prefs := models.DefaultNotificationPreferences()
+	db.Model(&models.Profile{}).Where("user_id = ?", fx.Bob.UserID).Update("notification_preferences", prefs)
+	if _, err := worker.ProcessBatch(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses := messageStatuses(t, db)
+	if m := statuses[busy.ID]; m.Status != models.PushPending || m.Attempts != 1 || !m.NextAttemptAt.After(time.Now().Add(30*time.Second)) {
+		t.Errorf("сообщение должно ждать повтора: %+v", m)
+	}
+	if m := statuses[bob.ID]; m.Status != models.PushCancelled {
+		t.Errorf("сообщение Bob: ожидали cancelled, получили %+v", m)
+	}
+
+	// После WEBPUSH_MAX_ATTEMPTS сообщение больше не повторяется, подписка остаётся
+	db.Model(&models.PushMessage{}).Where("subscription_id = ?", busy.ID).Update("next_attempt_at", time.Now().Add(-time.Second))
+	if _, err := worker.ProcessBatch(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if m := messageStatuses(t, db)[busy.ID]; m.Status != models.PushFailed {
+		t.Errorf("ожидали failed после последней попытки, получили %s", m.Status)
+	}
+	if err := db.First(&models.PushSubscription{}, busy.ID).Error; err != nil {
+		t.Errorf("временная ошибка не должна удалять подписку: %v", err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	w := &Worker{cfg: config.WebPushConfig{RetryBackoff: time.Minute, MaxRetryBackoff: 5 * time.Minute}}
+	want := []time.Duration{time.Minute, time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute}
+	for attempt, d := range want {
+		if got := w.backoff(attempt); got != d {
+			t.Errorf("попытка %d: ожидали %s, получили %s", attempt, d, got)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval — как часто MemoryStore удаляет корзины, которые уже наполнились.
+const sweepInterval = time.Minute
+
+// MemoryStore хранит корзины в памяти процесса. Подходит для одного экземпляра сервиса.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	limit   Limit
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket), now: time.Now}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updated: now}
+		s.buckets[key] = b
+	}
+	b.tokens = refill(b.tokens, now.Sub(b.updated), limit)
+	b.updated = now
+	b.limit = limit
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	return newResult(allowed, b.tokens, limit), nil
+}
+
+// sweep удаляет полные корзины: их состояние совпадает с новой корзиной,
+// поэтому память не растёт от разовых клиентов.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		if refill(b.tokens, now.Sub(b.updated), b.limit) >= float64(b.limit.Burst) {
+			delete(s.buckets, key)
+		}
+	}
+}
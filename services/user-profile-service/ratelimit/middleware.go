@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/monst/story-craft/services/user-profile-service/logging"
+	"github.com/monst/story-craft/services/user-profile-service/metrics"
+	"github.com/monst/story-craft/services/user-profile-service/middleware"
+)
+
+// Limiter применяет лимиты к группам маршрутов. У каждого пользователя своя корзина
+// в каждой группе, анонимные запросы считаются по IP-адресу. Пользователь берётся из
+// неподписанного x-user-object, поэтому запросы пользователей ещё и делят общую корзину
+// своего IP-адреса в ipFactor раз больше: смена userId в каждом запросе не даёт
+// обойти ограничение.
+type Limiter struct {
+	store    Store
+	limits   map[string]Limit
+	ipFactor int
+	metrics  *metrics.Metrics
+}
+
+// New создаёт ограничитель с лимитами по имени группы маршрутов. ipFactor — во сколько
+// раз общая корзина пользователей одного IP-адреса больше корзины одного пользователя.
+func New(store Store, limits map[string]Limit, ipFactor int, m *metrics.Metrics) *Limiter {
+	return &Limiter{store: store, limits: limits, ipFactor: max(1, ipFactor), metrics: m}
+}
+
+// Middleware ограничивает частоту запросов к маршрутам группы group и отдаёт
+// заголовки RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset и RateLimit-Policy.
+// При превышении отвечает 429 с Retry-After. Если хранилище недоступно,
+// запрос пропускается: недоступность Redis не должна останавливать сервис.
+// У nil-ограничителя и группы без лимита middleware ничего не делает.
+func (l *Limiter) Middleware(group string) gin.HandlerFunc {
+	if l == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+	limit, ok := l.limits[group]
+	if !ok || limit.Rate <= 0 || limit.Burst <= 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+	shared := Limit{Rate: limit.Rate * float64(l.ipFactor), Burst: limit.Burst * l.ipFactor}
+
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		res, applied, err := l.take(c, group, limit, shared)
+		if err != nil {
+			logging.FromContext(ctx).Warn("Ограничение частоты недоступно, запрос пропущен",
+				slog.String("group", group), slog.Any("error", err))
+			c.Next()
+			return
+		}
+
+		h := c.Writer.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(applied.Burst))
+		h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+		h.Set("RateLimit-Policy", strconv.Itoa(applied.Burst)+";w="+strconv.Itoa(ceilSeconds(applied.Window())))
+
+		if !res.Allowed {
+			l.metrics.RateLimited(group)
+			h.Set("Retry-After", strconv.Itoa(max(1, ceilSeconds(res.RetryAfter))))
+			middleware.AbortWithError(c, http.StatusTooManyRequests, "Слишком много запросов, повторите позже")
+			return
+		}
+		c.Next()
+	}
+}
+
+// take берёт токен из корзин запроса. Анонимный запрос расходует корзину своего IP-адреса,
+// запрос пользователя — общую корзину пользователей его IP-адреса и корзину пользователя.
+// Возвращается итог корзины, которая отклонила запрос, а если прошли обе — корзины,
+// в которой осталось меньше токенов, вместе с её лимитом для заголовков.
+func (l *Limiter) take(c *gin.Context, group string, limit, shared Limit) (Result, Limit, error) {
+	ctx := c.Request.Context()
+	p, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		res, err := l.store.Take(ctx, group+":ip:"+c.ClientIP(), limit)
+		return res, limit, err
+	}
+
+	ipRes, err := l.store.Take(ctx, group+":users-ip:"+c.ClientIP(), shared)
+	if err != nil || !ipRes.Allowed {
+		return ipRes, shared, err
+	}
+	userRes, err := l.store.Take(ctx, group+":user:"+p.UserID, limit)
+	if err != nil || !userRes.Allowed || userRes.Remaining <= ipRes.Remaining {
+		return userRes, limit, err
+	}
+	return ipRes, shared, nil
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,61 @@
+// Package ratelimit ограничивает частоту запросов по алгоритму token bucket.
+// Корзины хранятся в памяти процесса или в Redis, если экземпляров сервиса несколько.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limit описывает корзину: Burst запросов подряд, затем Rate запросов в секунду.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Window — время, за которое пустая корзина наполняется полностью.
+func (l Limit) Window() time.Duration {
+	return durationOf(float64(l.Burst) / l.Rate)
+}
+
+// Result — итог попытки взять токен из корзины.
+type Result struct {
+	Allowed   bool
+	Remaining int
+	// RetryAfter — через сколько появится следующий токен, если запрос отклонён.
+	RetryAfter time.Duration
+	// Reset — через сколько корзина снова будет полной.
+	Reset time.Duration
+}
+
+// Store хранит состояние корзин. Take атомарно пополняет корзину key
+// с момента прошлого обращения и пытается взять из неё один токен.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// refill возвращает число токенов в корзине спустя elapsed после прошлого обращения.
+func refill(tokens float64, elapsed time.Duration, limit Limit) float64 {
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	return math.Min(float64(limit.Burst), tokens+elapsed.Seconds()*limit.Rate)
+}
+
+// newResult считает заголовки ответа по числу токенов, оставшихся после попытки.
+func newResult(allowed bool, tokens float64, limit Limit) Result {
+	res := Result{
+		Allowed:   allowed,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     durationOf((float64(limit.Burst) - tokens) / limit.Rate),
+	}
+	if !allowed {
+		res.RetryAfter = durationOf((1 - tokens) / limit.Rate)
+	}
+	return res
+}
+
+func durationOf(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
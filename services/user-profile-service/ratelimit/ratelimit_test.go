@@ -0,0 +1,247 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/monst/story-craft/services/user-profile-service/metrics"
+	"github.com/monst/story-craft/services/user-profile-service/middleware"
+	"github.com/redis/go-redis/v9"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// fakeClock — управляемое время для обоих хранилищ.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newMemoryStore(clock *fakeClock) Store {
+	s := NewMemoryStore()
+	s.now = clock.now
+	return s
+}
+
+func newRedisStore(t *testing.T, clock *fakeClock) Store {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	s := NewRedisStore(client, "test:")
+	s.now = clock.now
+	return s
+}
+
+func TestStores(t *testing.T) {
+	stores := map[string]func(*testing.T, *fakeClock) Store{
+		"memory": func(_ *testing.T, c *fakeClock) Store { return newMemoryStore(c) },
+		"redis":  newRedisStore,
+	}
+	limit := Limit{Rate: 2, Burst: 3}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			clock := &fakeClock{t: time.Unix(1_700_000_000, 0)}
+			store := newStore(t, clock)
+			ctx := context.Background()
+
+			for i := 2; i >= 0; i-- {
+				res, err := store.Take(ctx, "k", limit)
+				if err != nil {
+					t.Fatalf("Take: %v", err)
+				}
+				if !res.Allowed || res.Remaining != i {
+					t.Fatalf("ожидали разрешение с остатком %d, получили %+v", i, res)
+				}
+			}
+
+			res, err := store.Take(ctx, "k", limit)
+			if err != nil {
+				t.Fatalf("Take: %v", err)
+			}
+			if res.Allowed || res.RetryAfter != 500*time.Millisecond || res.Reset != 1500*time.Millisecond {
+				t.Fatalf("корзина пуста: ожидали отказ с RetryAfter 500ms и Reset 1.5s, получили %+v", res)
+			}
+
+			// Другой ключ — другая корзина
+			if res, _ := store.Take(ctx, "other", limit); !res.Allowed {
+				t.Fatalf("корзины разных ключей не должны влиять друг на друга: %+v", res)
+			}
+
+			clock.advance(500 * time.Millisecond)
+			if res, _ := store.Take(ctx, "k", limit); !res.Allowed || res.Remaining != 0 {
+				t.Fatalf("через 500ms должен появиться один токен: %+v", res)
+			}
+
+			clock.advance(time.Hour)
+			if res, _ := store.Take(ctx, "k", limit); !res.Allowed || res.Remaining != 2 {
+				t.Fatalf("корзина не должна наполняться сверх Burst: %+v", res)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreSweepsFullBuckets(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1_700_000_000, 0)}
+	s := NewMemoryStore()
+	s.now = clock.now
+	limit := Limit{Rate: 1, Burst: 1}
+
+	s.Take(context.Background(), "a", limit)
+	clock.advance(2 * sweepInterval)
+	s.Take(context.Background(), "b", limit)
+
+	if _, ok := s.buckets["a"]; ok {
+		t.Error("наполнившаяся корзина должна удаляться")
+	}
+	if _, ok := s.buckets["b"]; !ok {
+		t.Error("активная корзина не должна удаляться")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1_700_000_000, 0)}
+	limiter := New(newMemoryStore(clock), map[string]Limit{"write": {Rate: 0.5, Burst: 2}}, 10, metrics.New())
+
+	r := gin.New()
+	r.Use(middleware.Authenticate())
+	r.PATCH("/profiles/:user_id", limiter.Middleware("write"), func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/profiles/:user_id", limiter.Middleware("read"), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	do := func(method, userID, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/profiles/42", nil)
+		req.RemoteAddr = ip + ":1234"
+		if userID != "" {
+			req.Header.Set(middleware.UserObjectHeader, `{"userId":"`+userID+`"}`)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := do(http.MethodPatch, "alice", "10.0.0.1"); w.Code != http.StatusOK {
+			t.Fatalf("запрос %d: ожидали 200, получили %d", i+1, w.Code)
+		}
+	}
+
+	w := do(http.MethodPatch, "alice", "10.0.0.1")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("ожидали 429, получили %d", w.Code)
+	}
+	want := map[string]string{
+		"RateLimit-Limit":     "2",
+		"RateLimit-Remaining": "0",
+		"RateLimit-Reset":     "4",
+		"RateLimit-Policy":    "2;w=4",
+		"Retry-After":         "2",
+	}
+	for header, value := range want {
+		if got := w.Header().Get(header); got != value {
+			t.Errorf("%s = %q, ожидалось %q", header, got, value)
+		}
+	}
+
+	// Тот же IP, но другой пользователь — своя корзина
+	if w := do(http.MethodPatch, "bob", "10.0.0.1"); w.Code != http.StatusOK {
+		t.Errorf("лимит alice не должен касаться bob, получили %d", w.Code)
+	}
+
+	// Анонимные запросы считаются по IP
+	for i := 0; i < 2; i++ {
+		do(http.MethodPatch, "", "10.0.0.2")
+	}
+	if w := do(http.MethodPatch, "", "10.0.0.2"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("анонимный клиент должен ограничиваться по IP, получили %d", w.Code)
+	}
+	if w := do(http.MethodPatch, "", "10.0.0.3"); w.Code != http.StatusOK {
+		t.Errorf("другой IP — другая корзина, получили %d", w.Code)
+	}
+
+	// Для группы без лимита заголовки не выставляются
+	if w := do(http.MethodGet, "alice", "10.0.0.1"); w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "" {
+		t.Errorf("группа без лимита не должна ограничиваться: %d %v", w.Code, w.Header())
+	}
+
+	clock.advance(2 * time.Second)
+	w = do(http.MethodPatch, "alice", "10.0.0.1")
+	if w.Code != http.StatusOK {
+		t.Fatalf("после Retry-After запрос должен пройти, получили %d", w.Code)
+	}
+	if got, _ := strconv.Atoi(w.Header().Get("RateLimit-Remaining")); got != 0 {
+		t.Errorf("RateLimit-Remaining = %d, ожидалось 0", got)
+	}
+}
+
+func TestMiddlewareLimitsRotatingUsersByIP(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1_700_000_000, 0)}
+	limiter := New(newMemoryStore(clock), map[string]Limit{"write": {Rate: 0.5, Burst: 2}}, 3, metrics.New())
+
+	r := gin.New()
+	r.Use(middleware.Authenticate())
+	r.PATCH("/profiles/:user_id", limiter.Middleware("write"), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	do := func(userID, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, "/profiles/42", nil)
+		req.RemoteAddr = ip + ":1234"
+		req.Header.Set(middleware.UserObjectHeader, `{"userId":"`+userID+`"}`)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Новый userId в каждом запросе не даёт новой корзины: общая корзина IP — 2×3 запроса
+	for i := 0; i < 6; i++ {
+		if w := do("user-"+strconv.Itoa(i), "10.0.0.1"); w.Code != http.StatusOK {
+			t.Fatalf("запрос %d: ожидали 200, получили %d", i+1, w.Code)
+		}
+	}
+	w := do("user-6", "10.0.0.1")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("перебор userId с одного IP должен ограничиваться, получили %d", w.Code)
+	}
+	if got := w.Header().Get("RateLimit-Limit"); got != "6" {
+		t.Errorf("RateLimit-Limit = %q, ожидалась общая корзина IP 6", got)
+	}
+	if w := do("user-7", "10.0.0.2"); w.Code != http.StatusOK {
+		t.Errorf("другой IP — другая общая корзина, получили %d", w.Code)
+	}
+}
+
+func TestMiddlewareFailsOpen(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	defer client.Close()
+	limiter := New(NewRedisStore(client, "test:"), map[string]Limit{"read": {Rate: 1, Burst: 1}}, 10, metrics.New())
+	mr.Close()
+
+	r := gin.New()
+	r.GET("/", limiter.Middleware("read"), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("при недоступном Redis запрос должен пропускаться, получили %d", w.Code)
+	}
+}
+
+func TestNilLimiter(t *testing.T) {
+	var limiter *Limiter
+	r := gin.New()
+	r.GET("/", limiter.Middleware("read"), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("выключенное ограничение не должно мешать запросам, получили %d", w.Code)
+	}
+}
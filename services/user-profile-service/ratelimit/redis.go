@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// takeScript пополняет корзину и берёт токен за одно обращение к Redis, чтобы
+// параллельные запросы с разных экземпляров сервиса не теряли обновления.
+// Ключ живёт, пока корзина не наполнится, затем Redis удаляет его сам.
+var takeScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = burst
+	ts = now
+end
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) / 1000 * rate)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil((burst - tokens) / rate * 1000) + 1000)
+return {allowed, tostring(tokens)}
+`)
+
+// RedisStore хранит корзины в Redis, общем для всех экземпляров сервиса.
+type RedisStore struct {
+	client redis.Scripter
+	prefix string
+	now    func() time.Time
+}
+
+// NewRedisStore создаёт хранилище; prefix отделяет ключи сервиса от остальных данных в Redis.
+func NewRedisStore(client redis.Scripter, prefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix, now: time.Now}
+}
+
+func (s *RedisStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	reply, err := takeScript.Run(ctx, s.client, []string{s.prefix + key},
+		limit.Rate, limit.Burst, s.now().UnixMilli()).Slice()
+	if err != nil {
+		return Result{}, err
+	}
+
+	allowed, _ := reply[0].(int64)
+	raw, _ := reply[1].(string)
+	tokens, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return Result{}, err
+	}
+	return newResult(allowed == 1, tokens, limit), nil
+}
@@ -0,0 +1,73 @@
+// Package realtime доставляет пользователю события по SSE и WebSocket: новые уведомления
+// и изменения его профиля. События публикуются через PostgreSQL NOTIFY внутри транзакции
+// изменения, поэтому их получают все экземпляры сервиса и только после фиксации данных.
+package realtime
+
+import (
+	"encoding/json"
+
+	"gorm.io/gorm"
+)
+
+// Channel — канал LISTEN/NOTIFY, через который экземпляры сервиса обмениваются событиями.
+const Channel = "user_profile_events"
+
+// Типы событий.
+const (
+	// EventNotification — новое уведомление во входящих, data — models.Notification.
+	EventNotification = "notification"
+	// EventProfile — изменение профиля пользователя, data — ProfileChange.
+	EventProfile = "profile"
+	// EventResync — часть событий могла потеряться: клиенту нужно перечитать данные.
+	EventResync = "resync"
+	// EventHeartbeat отправляется по WebSocket, чтобы соединение не закрывали прокси.
+	EventHeartbeat = "heartbeat"
+)
+
+// maxPayload — предел полезной нагрузки NOTIFY с запасом от 8000 байт PostgreSQL.
+// Больше событие уходит без data, и клиент перечитывает данные сам.
+const maxPayload = 7000
+
+// Event — событие для пользователя UserID. ID берётся из последовательности в БД,
+// поэтому одинаков на всех экземплярах и подходит для Last-Event-ID. Порядок событий
+// задаёт фиксация транзакций, а не ID: событие с меньшим ID может прийти позже.
+type Event struct {
+	ID     int64           `json:"id"`
+	UserID string          `json:"user_id,omitempty"`
+	Type   string          `json:"type"`
+	Data   json.RawMessage `json:"data,omitempty"`
+	// Truncated — data не поместились в NOTIFY и не переданы.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// ProfileChange — данные события EventProfile: действие из журнала изменений и профиль
+// после изменения (nil после удаления).
+type ProfileChange struct {
+	Action  string `json:"action"`
+	Profile any    `json:"profile,omitempty"`
+}
+
+// Publish отправляет событие пользователю userID. Вызывается внутри транзакции изменения:
+// PostgreSQL доставит NOTIFY только после её фиксации, а при откате событие пропадёт.
+func Publish(tx *gorm.DB, userID, eventType string, data any) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	truncated := len(raw) > maxPayload
+	if truncated {
+		raw = []byte("null")
+	}
+	return tx.Exec(`SELECT pg_notify(?, json_build_object(
+			'id', nextval('realtime_event_id_seq'),
+			'user_id', ?::text,
+			'type', ?::text,
+			'data', ?::json,
+			'truncated', ?::boolean)::text)`,
+		Channel, userID, eventType, string(raw), truncated).Error
+}
+
+// Migrate создаёт последовательность идентификаторов событий.
+func Migrate(db *gorm.DB) error {
+	return db.Exec(`CREATE SEQUENCE IF NOT EXISTS realtime_event_id_seq`).Error
+}
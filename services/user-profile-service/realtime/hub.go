@@ -0,0 +1,200 @@
+package realtime
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrTooManyConnections — у пользователя уже открыто максимальное число потоков событий.
+var ErrTooManyConnections = errors.New("слишком много открытых соединений")
+
+// ErrClosed — сервис останавливается и новые потоки не открываются.
+var ErrClosed = errors.New("хаб событий закрыт")
+
+// sendBuffer — сколько событий может ждать отправки одному клиенту. Клиент, который
+// не успевает читать, отключается и при переподключении получает пропущенное из буфера повтора.
+const sendBuffer = 64
+
+// Hub раздаёт события подписчикам этого экземпляра и хранит последние события
+// для повтора по Last-Event-ID. Буфер повтора общий для всех пользователей и ограничен по размеру.
+//
+// Идентификаторы событий выдаются в транзакциях, а NOTIFY приходят в порядке их фиксации,
+// поэтому событие 10 может прийти после события 11. Буфер хранит события в порядке
+// прихода, и повтор отдаёт всё, что пришло после события Last-Event-ID, а не события
+// с большим идентификатором.
+type Hub struct {
+	mu         sync.Mutex
+	subs       map[string]map[*Subscription]struct{}
+	replay     []Event
+	head       int
+	maxPerUser int
+	// started — после подключения к БД пришло хотя бы одно событие.
+	started bool
+	// evicted — последнее вытесненное из буфера событие (-1 — вытеснений не было):
+	// всё пришедшее после него в буфере есть.
+	evicted int64
+	closed  bool
+}
+
+// NewHub создаёт хаб с буфером повтора на replaySize событий и ограничением maxPerUser
+// одновременных подписок на пользователя. Ограничение действует на одном экземпляре:
+// при N экземплярах пользователь может открыть до N×maxPerUser потоков.
+func NewHub(replaySize, maxPerUser int) *Hub {
+	return &Hub{
+		subs:       make(map[string]map[*Subscription]struct{}),
+		replay:     make([]Event, 0, replaySize),
+		maxPerUser: maxPerUser,
+		evicted:    -1,
+	}
+}
+
+// Subscription — поток событий одного клиента.
+type Subscription struct {
+	hub    *Hub
+	userID string
+	events chan Event
+	closed bool
+}
+
+// Events возвращает канал событий. Канал закрывается, если клиент не успевает читать
+// или подписка закрыта.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Close отменяет подписку. Повторный вызов ничего не делает.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.removeLocked(s)
+}
+
+// Subscribe подписывает пользователя на события. Если resume, возвращает события, пришедшие
+// после события lastEventID, из буфера повтора; если этого события в буфере нет и оно не
+// последнее вытесненное, часть событий могла потеряться, и вместо них возвращается одно
+// событие EventResync.
+func (h *Hub) Subscribe(userID string, lastEventID int64, resume bool) (*Subscription, []Event, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil, nil, ErrClosed
+	}
+	if h.maxPerUser > 0 && len(h.subs[userID]) >= h.maxPerUser {
+		return nil, nil, ErrTooManyConnections
+	}
+	s := &Subscription{hub: h, userID: userID, events: make(chan Event, sendBuffer)}
+	if h.subs[userID] == nil {
+		h.subs[userID] = make(map[*Subscription]struct{})
+	}
+	h.subs[userID][s] = struct{}{}
+
+	if !resume {
+		return s, nil, nil
+	}
+	if !h.started {
+		return s, []Event{{Type: EventResync}}, nil
+	}
+	// Ищем событие lastEventID с конца: клиент обычно отстаёт ненамного
+	from := -1
+	if lastEventID == h.evicted {
+		from = 0
+	}
+	for i := len(h.replay) - 1; i >= 0; i-- {
+		if h.replay[(h.head+i)%len(h.replay)].ID == lastEventID {
+			from = i + 1
+			break
+		}
+	}
+	if from < 0 {
+		return s, []Event{{Type: EventResync}}, nil
+	}
+	var missed []Event
+	for i := from; i < len(h.replay); i++ {
+		if e := h.replay[(h.head+i)%len(h.replay)]; e.UserID == userID {
+			missed = append(missed, e)
+		}
+	}
+	return s, missed, nil
+}
+
+// Dispatch сохраняет событие в буфере повтора и отправляет его подписчикам получателя.
+func (h *Hub) Dispatch(e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.started = true
+	switch {
+	case cap(h.replay) == 0:
+		h.evicted = e.ID
+	case len(h.replay) < cap(h.replay):
+		h.replay = append(h.replay, e)
+	default:
+		// Вытесняется самое старое событие: повтор до него уже неполный
+		h.evicted = h.replay[h.head].ID
+		h.replay[h.head] = e
+		h.head = (h.head + 1) % len(h.replay)
+	}
+
+	for s := range h.subs[e.UserID] {
+		h.sendLocked(s, e)
+	}
+}
+
+// Reset вызывается после переподключения к БД: события за время разрыва могли потеряться,
+// поэтому подключённые клиенты получают EventResync, а буфер повтора очищается.
+func (h *Hub) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.replay = h.replay[:0]
+	h.head = 0
+	h.started = false
+	h.evicted = -1
+	for _, subs := range h.subs {
+		for s := range subs {
+			h.sendLocked(s, Event{Type: EventResync})
+		}
+	}
+}
+
+// Close закрывает все подписки и запрещает новые. Вызывается при остановке сервера:
+// иначе открытые потоки не дали бы HTTP-серверу завершиться за отведённое время.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for _, subs := range h.subs {
+		for s := range subs {
+			h.removeLocked(s)
+		}
+	}
+}
+
+// Connections возвращает число открытых подписок пользователя.
+func (h *Hub) Connections(userID string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs[userID])
+}
+
+func (h *Hub) sendLocked(s *Subscription, e Event) {
+	select {
+	case s.events <- e:
+	default:
+		h.removeLocked(s)
+	}
+}
+
+func (h *Hub) removeLocked(s *Subscription) {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.events)
+	delete(h.subs[s.userID], s)
+	if len(h.subs[s.userID]) == 0 {
+		delete(h.subs, s.userID)
+	}
+}
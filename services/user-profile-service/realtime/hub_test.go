@@ -0,0 +1,185 @@
+package realtime
+
+import (
+	"errors"
+	"testing"
+)
+
+func receive(t *testing.T, s *Subscription) Event {
+	t.Helper()
+	select {
+	case e, ok := <-s.Events():
+		if !ok {
+			t.Fatal("канал подписки закрыт")
+		}
+		return e
+	default:
+		t.Fatal("нет события в канале подписки")
+	}
+	return Event{}
+}
+
+func TestHubDispatch(t *testing.T) {
+	h := NewHub(10, 5)
+	alice, _, _ := h.Subscribe("alice", 0, false)
+	bob, _, _ := h.Subscribe("bob", 0, false)
+
+	h.Dispatch(Event{ID: 1, UserID: "alice", Type: EventNotification})
+	if e := receive(t, alice); e.ID != 1 {
+		t.Errorf("ожидали событие 1, получили %+v", e)
+	}
+	select {
+	case e := <-bob.Events():
+		t.Errorf("чужое событие дошло до bob: %+v", e)
+	default:
+	}
+}
+
+func TestHubReplay(t *testing.T) {
+	h := NewHub(3, 5)
+
+	// До первого события экземпляр не знает, что пропустил
+	_, replay, _ := h.Subscribe("alice", 5, true)
+	if len(replay) != 1 || replay[0].Type != EventResync {
+		t.Fatalf("ожидали resync до первого события, получили %+v", replay)
+	}
+
+	for id := int64(10); id <= 12; id++ {
+		h.Dispatch(Event{ID: id, UserID: "alice", Type: EventNotification})
+	}
+	h.Dispatch(Event{ID: 13, UserID: "bob", Type: EventNotification})
+
+	cases := []struct {
+		name   string
+		lastID int64
+		want   []int64
+		resync bool
+	}{
+		{name: "пропущенные события есть в буфере", lastID: 10, want: []int64{11, 12}},
+		{name: "пропущенного нет", lastID: 13},
+		{name: "событие 10 уже вытеснено", lastID: 9, resync: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, replay, err := h.Subscribe("alice", tc.lastID, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+			if tc.resync {
+				if len(replay) != 1 || replay[0].Type != EventResync {
+					t.Fatalf("ожидали resync, получили %+v", replay)
+				}
+				return
+			}
+			if len(replay) != len(tc.want) {
+				t.Fatalf("ожидали %v, получили %+v", tc.want, replay)
+			}
+			for i, id := range tc.want {
+				if replay[i].ID != id {
+					t.Errorf("ожидали %v, получили %+v", tc.want, replay)
+				}
+			}
+		})
+	}
+}
+
+func TestHubReplayFollowsCommitOrder(t *testing.T) {
+	h := NewHub(10, 5)
+
+	// Транзакция с событием 10 зафиксирована позже транзакции с событием 11
+	h.Dispatch(Event{ID: 11, UserID: "alice", Type: EventNotification})
+	h.Dispatch(Event{ID: 10, UserID: "alice", Type: EventProfile})
+	h.Dispatch(Event{ID: 12, UserID: "alice", Type: EventNotification})
+
+	cases := []struct {
+		name   string
+		lastID int64
+		want   []int64
+	}{
+		{name: "после 11 пришло событие 10", lastID: 11, want: []int64{10, 12}},
+		{name: "после 10 осталось 12", lastID: 10, want: []int64{12}},
+		{name: "всё получено", lastID: 12},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, replay, err := h.Subscribe("alice", tc.lastID, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+			if len(replay) != len(tc.want) {
+				t.Fatalf("ожидали %v, получили %+v", tc.want, replay)
+			}
+			for i, id := range tc.want {
+				if replay[i].ID != id {
+					t.Errorf("ожидали %v, получили %+v", tc.want, replay)
+				}
+			}
+		})
+	}
+
+	// Событие, которого экземпляр не видел, не даёт гарантий о пропущенном
+	if _, replay, _ := h.Subscribe("alice", 9, true); len(replay) != 1 || replay[0].Type != EventResync {
+		t.Errorf("ожидали resync для неизвестного события, получили %+v", replay)
+	}
+}
+
+func TestHubConnectionLimit(t *testing.T) {
+	h := NewHub(0, 2)
+	first, _, _ := h.Subscribe("alice", 0, false)
+	h.Subscribe("alice", 0, false)
+	if _, _, err := h.Subscribe("alice", 0, false); !errors.Is(err, ErrTooManyConnections) {
+		t.Fatalf("ожидали ErrTooManyConnections, получили %v", err)
+	}
+
+	first.Close()
+	first.Close()
+	if h.Connections("alice") != 1 {
+		t.Fatalf("ожидали 1 подписку, получили %d", h.Connections("alice"))
+	}
+	if _, _, err := h.Subscribe("alice", 0, false); err != nil {
+		t.Fatalf("после закрытия подписки ожидали успех, получили %v", err)
+	}
+}
+
+func TestHubDropsSlowSubscriber(t *testing.T) {
+	h := NewHub(0, 5)
+	s, _, _ := h.Subscribe("alice", 0, false)
+	for id := int64(1); id <= sendBuffer+1; id++ {
+		h.Dispatch(Event{ID: id, UserID: "alice", Type: EventNotification})
+	}
+	if h.Connections("alice") != 0 {
+		t.Fatal("медленный подписчик не отключён")
+	}
+	n := 0
+	for range s.Events() {
+		n++
+	}
+	if n != sendBuffer {
+		t.Errorf("ожидали %d событий до отключения, получили %d", sendBuffer, n)
+	}
+}
+
+func TestHubResetAndClose(t *testing.T) {
+	h := NewHub(10, 5)
+	s, _, _ := h.Subscribe("alice", 0, false)
+	h.Dispatch(Event{ID: 1, UserID: "alice", Type: EventNotification})
+	receive(t, s)
+
+	h.Reset()
+	if e := receive(t, s); e.Type != EventResync {
+		t.Errorf("ожидали resync после Reset, получили %+v", e)
+	}
+	if _, replay, _ := h.Subscribe("bob", 1, true); len(replay) != 1 || replay[0].Type != EventResync {
+		t.Errorf("после Reset буфер повтора не должен использоваться, получили %+v", replay)
+	}
+
+	h.Close()
+	if _, open := <-s.Events(); open {
+		t.Error("после Close канал подписки должен быть закрыт")
+	}
+	if _, _, err := h.Subscribe("alice", 0, false); !errors.Is(err, ErrClosed) {
+		t.Errorf("ожидали ErrClosed, получили %v", err)
+	}
+}
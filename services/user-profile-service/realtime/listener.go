@@ -0,0 +1,78 @@
+package realtime
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// maxReconnectDelay ограничивает паузу между попытками восстановить LISTEN.
+const maxReconnectDelay = 30 * time.Second
+
+// Listen возвращает фоновую задачу для lifecycle.Manager.Go: она держит одно соединение
+// из пула с LISTEN на Channel и передаёт события в hub. После разрыва соединения задача
+// переподключается, а клиенты получают EventResync.
+func Listen(db *sql.DB, hub *Hub) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		delay := time.Second
+		for {
+			started := time.Now()
+			err := listen(ctx, db, hub)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if time.Since(started) > maxReconnectDelay {
+				delay = time.Second
+			}
+			slog.Warn("Соединение LISTEN для событий потеряно, переподключение",
+				slog.Any("error", err), slog.Duration("delay", delay))
+			hub.Reset()
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+			delay = min(delay*2, maxReconnectDelay)
+		}
+	}
+}
+
+func listen(ctx context.Context, db *sql.DB, hub *Hub) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		sc, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("LISTEN поддерживается только драйвером pgx, получен %T", driverConn)
+		}
+		pc := sc.Conn()
+		if _, err := pc.Exec(ctx, "LISTEN "+Channel); err != nil {
+			return err
+		}
+		// Соединение после ожидания уведомлений не возвращается в пул: на нём остаётся
+		// LISTEN, а при отмене контекста pgx закрывает его
+		for {
+			n, err := pc.WaitForNotification(ctx)
+			if err != nil {
+				return fmt.Errorf("%w: %w", driver.ErrBadConn, err)
+			}
+			var e Event
+			if err := json.Unmarshal([]byte(n.Payload), &e); err != nil {
+				slog.Warn("Некорректное событие в канале LISTEN", slog.Any("error", err))
+				continue
+			}
+			hub.Dispatch(e)
+		}
+	})
+}
@@ -0,0 +1,220 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/monst/story-craft/services/user-profile-service/audit"
+	"github.com/monst/story-craft/services/user-profile-service/config"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"github.com/monst/story-craft/services/user-profile-service/push"
+	"github.com/monst/story-craft/services/user-profile-service/testutil"
+)
+
+func TestAdminRequiresAdminRole(t *testing.T) {
+	db := testutil.NewDB(t)
+	fx := testutil.LoadFixtures(t, db)
+	r := newTestRouter(db)
+
+	path := "/admin/profiles/" + fx.Bob.UserID + "/role"
+	body := gin.H{"role": "ADMIN"}
+
+	if w := doRequest(t, r, http.MethodPut, path, body, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("без пользователя: ожидали 401, получили %d", w.Code)
+	}
+	if w := doRequest(t, r, http.MethodPut, path, body, userHeader(fx.Alice.UserID)); w.Code != http.StatusForbidden {
+		t.Errorf("обычный пользователь: ожидали 403, получили %d", w.Code)
+	}
+
+	// Роль из заголовка не учитывается, только из профиля
+	forged := map[string]string{"x-user-object": `{"userId":"` + fx.Alice.UserID + `","role":"ADMIN"}`}
+	if w := doRequest(t, r, http.MethodPut, path, body, forged); w.Code != http.StatusForbidden {
+		t.Errorf("роль из заголовка: ожидали 403, получили %d", w.Code)
+	}
+}
+
+func TestAdminChangeRole(t *testing.T) {
+	db := testutil.NewDB(t)
+	fx := testutil.LoadFixtures(t, db)
+	r := newTestRouter(db)
+	admin := userHeader(fx.Admin.UserID)
+
+	w := doRequest(t, r, http.MethodPut, "/admin/profiles/"+fx.Bob.UserID+"/role", gin.H{"role": "author", "reason": "первая история"}, admin)
+	if w.Code != http.StatusOK {
+		t.Fatalf("ожидали 200, получили %d: %s", w.Code, w.Body.String())
+	}
+	if got := decodeProfile(t, w).Role; got != models.RoleAuthor {
+		t.Errorf("роль должна стать AUTHOR, получили %q", got)
+	}
+
+	if w := doRequest(t, r, http.MethodPut, "/admin/profiles/"+fx.Bob.UserID+"/role", gin.H{"role": "MODERATOR"}, admin); w.Code != http.StatusBadRequest {
+		t.Errorf("неизвестная роль: ожидали 400, получили %d", w.Code)
+	}
+	if w := doRequest(t, r, http.MethodPut, "/admin/profiles/"+fx.Admin.UserID+"/role", gin.H{"role": "USER"}, admin); w.Code != http.StatusBadRequest {
+		t.Errorf("смена собственной роли: ожидали 400, получили %d", w.Code)
+	}
+
+	var entries []models.AuditEntry
+	db.Where("target_user_id = ?", fx.Bob.UserID).Find(&entries)
+	if len(entries) != 1 || entries[0].Action != audit.ActionRoleChanged || entries[0].ActorID != fx.Admin.UserID {
+		t.Fatalf("ожидали одну запись role.changed от администратора, получили %+v", entries)
+	}
+	var details map[string]string
+	json.Unmarshal(entries[0].Details, &details)
+	if details["from"] != models.RoleUser || details["to"] != models.RoleAuthor {
+		t.Errorf("в журнале должны быть старая и новая роль: %s", entries[0].Details)
+	}
+}
+
+func TestUpdateProfileCannotChangeRole(t *testing.T) {
+	db := testutil.NewDB(t)
+	fx := testutil.LoadFixtures(t, db)
+	r := newTestRouter(db)
+
+	w := doRequest(t, r, http.MethodPatch, "/profiles/"+fx.Alice.UserID, gin.H{
+		"userId": fx.Alice.UserID, "email": fx.Alice.Email, "role": "ADMIN",
+	}, userHeader(fx.Alice.UserID))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("смена роли через PATCH: ожидали 403, получили %d", w.Code)
+	}
+
+	w = doRequest(t, r, http.MethodPost, "/profiles/", gin.H{
+		"email": "new@example.com", "username": "newbie", "role": "admin",
+	}, userHeader("00000000-0000-0000-0000-000000000042"))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("создание с ролью ADMIN: ожидали 403, получили %d", w.Code)
+	}
+}
+
+func TestAdminSuspendAndRestore(t *testing.T) {
+	db := testutil.NewDB(t)
+	fx := testutil.LoadFixtures(t, db)
+	r := newTestRouter(db)
+	admin := userHeader(fx.Admin.UserID)
+	base := "/admin/profiles/" + fx.Alice.UserID
+
+	if w := doRequest(t, r, http.MethodPost, base+"/suspend", gin.H{"reason": "спам"}, admin); w.Code != http.StatusBadRequest {
+		t.Errorf("блокировка без срока: ожидали 400, получили %d", w.Code)
+	}
+	if w := doRequest(t, r, http.MethodPost, base+"/suspend", gin.H{"until": time.Now().Add(time.Hour)}, admin); w.Code != http.StatusBadRequest {
+		t.Errorf("блокировка без причины: ожидали 400, получили %d", w.Code)
+	}
+
+	w := doRequest(t, r, http.MethodPost, base+"/suspend", gin.H{"reason": "спам", "until": time.Now().Add(time.Hour)}, admin)
+	if w.Code != http.StatusOK {
+		t.Fatalf("ожидали 200, получили %d: %s", w.Code, w.Body.String())
+	}
+	if p := decodeProfile(t, w); p.Status != models.StatusSuspended || p.StatusReason != "спам" || p.StatusUntil == nil {
+		t.Errorf("профиль должен быть заблокирован: %+v", p)
+	}
+	if p := decodeProfile(t, doRequest(t, r, http.MethodGet, "/profiles/"+fx.Alice.UserID, nil, userHeader(fx.Bob.UserID))); p.StatusReason != "" {
+		t.Errorf("причина блокировки видна только владельцу: %q", p.StatusReason)
+	}
+
+	if w := doRequest(t, r, http.MethodPatch, "/profiles/"+fx.Alice.UserID, gin.H{
+		"userId": fx.Alice.UserID, "email": fx.Alice.Email, "bio": "снова спам",
+	}, userHeader(fx.Alice.UserID)); w.Code != http.StatusForbidden {
+		t.Errorf("заблокированный профиль нельзя менять: ожидали 403, получили %d", w.Code)
+	}
+
+	w = doRequest(t, r, http.MethodPost, base+"/restore", gin.H{"reason": "апелляция"}, admin)
+	if p := decodeProfile(t, w); w.Code != http.StatusOK || p.Status != models.StatusActive || p.StatusUntil != nil {
+		t.Errorf("блокировка должна сниматься: %d %+v", w.Code, p)
+	}
+
+	w = doRequest(t, r, http.MethodPost, base+"/ban", gin.H{"reason": "повторный спам"}, admin)
+	if p := decodeProfile(t, w); w.Code != http.StatusOK || p.Status != models.StatusBanned || p.StatusUntil != nil {
+		t.Errorf("бан без срока должен быть бессрочным: %d %+v", w.Code, p)
+	}
+
+	var count int64
+	db.Model(&models.AuditEntry{}).Where("target_user_id = ?", fx.Alice.UserID).Count(&count)
+	if count != 3 {
+		t.Errorf("ожидали 3 записи в журнале, получили %d", count)
+	}
+}
+
+func TestRestrictedProfileCannotMutate(t *testing.T) {
+	db := testutil.NewDB(t)
+	fx := testutil.LoadFixtures(t, db)
+	privateKey, _, err := push.GenerateKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Default()
+	cfg.WebPush.Enabled = true
+	cfg.WebPush.VAPIDPrivateKey = privateKey
+	r := SetupRouter(Dependencies{DB: db, Config: cfg})
+	bob := userHeader(fx.Bob.UserID)
+	report := gin.H{"category": "spam"}
+	subscription := browserSubscription(t, "https://fcm.googleapis.com/fcm/send/bob-phone")
+
+	w := doRequest(t, r, http.MethodPost, "/admin/profiles/"+fx.Bob.UserID+"/suspend",
+		gin.H{"reason": "спам", "until": time.Now().Add(time.Hour)}, userHeader(fx.Admin.UserID))
+	if w.Code != http.StatusOK {
+		t.Fatalf("блокировка: ожидали 200, получили %d: %s", w.Code, w.Body.String())
+	}
+
+	cases := []struct {
+		name, method, path string
+		body               any
+	}{
+		{"жалоба", http.MethodPost, "/profiles/" + fx.Alice.UserID + "/reports", report},
+		{"push-подписка", http.MethodPost, "/profiles/" + fx.Bob.UserID + "/push-subscriptions", subscription},
+		{"настройки уведомлений", http.MethodPut, "/profiles/" + fx.Bob.UserID + "/notification-preferences", gin.H{}},
+		{"удаление профиля", http.MethodDelete, "/profiles/" + fx.Bob.UserID, nil},
+	}
+	for _, tc := range cases {
+		if w := doRequest(t, r, tc.method, tc.path, tc.body, bob); w.Code != http.StatusForbidden {
+			t.Errorf("%s: ожидали 403 для заблокированного профиля, получили %d: %s", tc.name, w.Code, w.Body.String())
+		}
+	}
+	var reports int64
+	db.Model(&models.Report{}).Where("reporter_id = ?", fx.Bob.UserID).Count(&reports)
+	if reports != 0 {
+		t.Errorf("жалоба заблокированного пользователя не должна сохраниться, получили %d", reports)
+	}
+
+	// После снятия блокировки изменения снова доступны
+	if w := doRequest(t, r, http.MethodPost, "/admin/profiles/"+fx.Bob.UserID+"/restore", gin.H{"reason": "апелляция"}, userHeader(fx.Admin.UserID)); w.Code != http.StatusOK {
+		t.Fatalf("снятие блокировки: ожидали 200, получили %d", w.Code)
+	}
+	if w := doRequest(t, r, http.MethodPost, "/profiles/"+fx.Alice.UserID+"/reports", report, bob); w.Code != http.StatusCreated {
+		t.Errorf("жалоба: ожидали 201, получили %d: %s", w.Code, w.Body.String())
+	}
+	if w := doRequest(t, r, http.MethodPost, "/profiles/"+fx.Bob.UserID+"/push-subscriptions", subscription, bob); w.Code != http.StatusCreated {
+		t.Errorf("push-подписка: ожидали 201, получили %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestAdminResetFieldsAndFlags(t *testing.T) {
+	db := testutil.NewDB(t)
+	fx := testutil.LoadFixtures(t, db)
+	r := newTestRouter(db)
+	admin := userHeader(fx.Admin.UserID)
+
+	if w := doRequest(t, r, http.MethodPost, "/admin/profiles/"+fx.Alice.UserID+"/reset", gin.H{"fields": []string{"email"}, "reason": "x"}, admin); w.Code != http.StatusBadRequest {
+		t.Errorf("email сбрасывать нельзя: ожидали 400, получили %d", w.Code)
+	}
+	w := doRequest(t, r, http.MethodPost, "/admin/profiles/"+fx.Alice.UserID+"/reset", gin.H{"fields": []string{"display_name", "bio"}, "reason": "оскорбления"}, admin)
+	if p := decodeProfile(t, w); w.Code != http.StatusOK || p.DisplayName != "" || p.Bio != "" {
+		t.Errorf("поля должны быть сброшены: %d %+v", w.Code, p)
+	}
+
+	db.Model(&models.Profile{}).Where("user_id = ?", fx.Bob.UserID).Updates(map[string]any{"flagged_at": time.Now(), "flag_reason": "жалобы"})
+	w = doRequest(t, r, http.MethodGet, "/admin/profiles/flagged", nil, admin)
+	var flagged []models.Profile
+	json.Unmarshal(w.Body.Bytes(), &flagged)
+	if w.Code != http.StatusOK || len(flagged) != 1 || flagged[0].UserID != fx.Bob.UserID {
+		t.Fatalf("ожидали только bob в списке отмеченных: %d %s", w.Code, w.Body.String())
+	}
+
+	doRequest(t, r, http.MethodDelete, "/admin/profiles/"+fx.Bob.UserID+"/flag", nil, admin)
+	w = doRequest(t, r, http.MethodGet, "/admin/profiles/flagged", nil, admin)
+	if w.Body.String() != "[]" {
+		t.Errorf("после снятия отметки список должен быть пуст: %s", w.Body.String())
+	}
+}
@@ -0,0 +1,145 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/monst/story-craft/services/user-profile-service/audit"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"github.com/monst/story-craft/services/user-profile-service/testutil"
+)
+
+func TestProfileMutationsAreAudited(t *testing.T) {
+	db := testutil.NewDB(t)
+	fx := testutil.LoadFixtures(t, db)
+	r := newTestRouter(db)
+	alice := userHeader(fx.Alice.UserID)
+	alice["X-Request-ID"] = "req-audit-1"
+
+	w := doRequest(t, r, http.MethodPatch, "/profiles/"+fx.Alice.UserID, gin.H{
+		"userId": fx.Alice.UserID, "email": fx.Alice.Email, "bio": "Пишу фэнтези",
+	}, alice)
+	if w.Code != http.StatusOK {
+		t.Fatalf("обновление: ожидали 200, получили %d: %s", w.Code, w.Body.String())
+	}
+	// Повтор без изменений не создаёт запись
+	doRequest(t, r, http.MethodPatch, "/profiles/"+fx.Alice.UserID, gin.H{
+		"userId": fx.Alice.UserID, "email": fx.Alice.Email, "bio": "Пишу фэнтези",
+	}, alice)
+	if w := doRequest(t, r, http.MethodDelete, "/profiles/"+fx.Bob.UserID, nil, userHeader(fx.Bob.UserID)); w.Code != http.StatusNoContent {
+		t.Fatalf("удаление: ожидали 204, получили %d", w.Code)
+	}
+
+	var entries []models.AuditEntry
+	db.Order("id").Find(&entries)
+	if len(entries) != 2 {
+		t.Fatalf("ожидали 2 записи, получили %+v", entries)
+	}
+
+	updated := entries[0]
+	if updated.Action != audit.ActionProfileUpdated || updated.ActorID != fx.Alice.UserID || updated.TargetUserID != fx.Alice.UserID {
+		t.Errorf("неверная запись об обновлении: %+v", updated)
+	}
+	if updated.RequestID != "req-audit-1" || updated.IP == "" {
+		t.Errorf("в записи должны быть request_id и ip: %+v", updated)
+	}
+	var diff audit.Diff
+	json.Unmarshal(updated.Diff, &diff)
+	if len(diff) != 1 || diff["bio"].To != "Пишу фэнтези" {
+		t.Errorf("в разнице должно быть только поле bio: %s", updated.Diff)
+	}
+
+	deleted := entries[1]
+	if deleted.Action != audit.ActionProfileDeleted || deleted.PrevHash != updated.Hash {
+		t.Errorf("запись об удалении должна ссылаться на хеш предыдущей: %+v", deleted)
+	}
+}
+
+func TestAuditLogIsAppendOnly(t *testing.T) {
+	db := testutil.NewDB(t)
+	fx := testutil.LoadFixtures(t, db)
+	r := newTestRouter(db)
+	admin := userHeader(fx.Admin.UserID)
+
+	doRequest(t, r, http.MethodPut, "/admin/profiles/"+fx.Bob.UserID+"/role", gin.H{"role": "AUTHOR"}, admin)
+	doRequest(t, r, http.MethodPut, "/admin/profiles/"+fx.Alice.UserID+"/role", gin.H{"role": "AUTHOR"}, admin)
+
+	if err := db.Exec(`UPDATE audit_log SET reason = 'подмена'`).Error; err == nil {
+		t.Error("UPDATE журнала должен быть запрещён")
+	}
+	if err := db.Exec(`DELETE FROM audit_log`).Error; err == nil {
+		t.Error("DELETE из журнала должен быть запрещён")
+	}
+
+	if w := doRequest(t, r, http.MethodGet, "/admin/audit/verify", nil, admin); w.Code != http.StatusOK {
+		t.Fatalf("цепочка цела: ожидали 200, получили %d: %s", w.Code, w.Body.String())
+	}
+
+	// Владелец таблицы может отключить триггер, но подмену выдаст цепочка хешей
+	db.Exec(`ALTER TABLE audit_log DISABLE TRIGGER audit_log_no_update`)
+	var first models.AuditEntry
+	db.Order("id").First(&first)
+	db.Exec(`UPDATE audit_log SET target_user_id = ? WHERE id = ?`, fx.Admin.UserID, first.ID)
+	db.Exec(`ALTER TABLE audit_log ENABLE TRIGGER audit_log_no_update`)
+
+	w := doRequest(t, r, http.MethodGet, "/admin/audit/verify", nil, admin)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("подмена записи: ожидали 409, получили %d", w.Code)
+	}
+	var res audit.VerifyResult
+	json.Unmarshal(w.Body.Bytes(), &res)
+	if res.BrokenAt != first.ID {
+		t.Errorf("ожидали нарушение на записи %d, получили %+v", first.ID, res)
+	}
+}
+
+func TestListAuditLog(t *testing.T) {
+	db := testutil.NewDB(t)
+	fx := testutil.LoadFixtures(t, db)
+	r := newTestRouter(db)
+	admin := userHeader(fx.Admin.UserID)
+
+	doRequest(t, r, http.MethodPut, "/admin/profiles/"+fx.Bob.UserID+"/role", gin.H{"role": "AUTHOR"}, admin)
+	doRequest(t, r, http.MethodPatch, "/profiles/"+fx.Alice.UserID, gin.H{
+		"userId": fx.Alice.UserID, "email": fx.Alice.Email, "bio": "новое",
+	}, userHeader(fx.Alice.UserID))
+	doRequest(t, r, http.MethodPut, "/admin/profiles/"+fx.Alice.UserID+"/role", gin.H{"role": "AUTHOR"}, admin)
+
+	list := func(query string) []models.AuditEntry {
+		t.Helper()
+		w := doRequest(t, r, http.MethodGet, "/admin/audit"+query, nil, admin)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: ожидали 200, получили %d: %s", query, w.Code, w.Body.String())
+		}
+		var entries []models.AuditEntry
+		json.Unmarshal(w.Body.Bytes(), &entries)
+		return entries
+	}
+
+	if got := list(""); len(got) != 3 || got[0].ID < got[2].ID {
+		t.Errorf("ожидали 3 записи от новых к старым, получили %+v", got)
+	}
+	if got := list("?actor_id=" + fx.Admin.UserID); len(got) != 2 {
+		t.Errorf("фильтр по actor_id: ожидали 2, получили %d", len(got))
+	}
+	if got := list("?target_user_id=" + fx.Alice.UserID + "&action=" + audit.ActionProfileUpdated); len(got) != 1 {
+		t.Errorf("фильтр по target_user_id и action: ожидали 1, получили %d", len(got))
+	}
+	if got := list("?from=2100-01-01T00:00:00Z"); len(got) != 0 {
+		t.Errorf("фильтр по времени: ожидали 0, получили %d", len(got))
+	}
+	page := list("?limit=2")
+	if rest := list("?before_id=" + strconv.FormatUint(page[1].ID, 10)); len(rest) != 1 {
+		t.Errorf("вторая страница: ожидали 1, получили %d", len(rest))
+	}
+
+	if w := doRequest(t, r, http.MethodGet, "/admin/audit?from=вчера", nil, admin); w.Code != http.StatusBadRequest {
+		t.Errorf("неверный from: ожидали 400, получили %d", w.Code)
+	}
+	if w := doRequest(t, r, http.MethodGet, "/admin/audit", nil, userHeader(fx.Alice.UserID)); w.Code != http.StatusForbidden {
+		t.Errorf("обычный пользователь: ожидали 403, получили %d", w.Code)
+	}
+}
@@ -0,0 +1,48 @@
+package router
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"github.com/monst/story-craft/services/user-profile-service/testutil"
+)
+
+func TestUpdateBioMarkdown(t *testing.T) {
+	db := testutil.NewDB(t)
+	fx := testutil.LoadFixtures(t, db)
+	r := newTestRouter(db)
+	path := "/profiles/" + fx.Alice.UserID
+
+	w := doRequest(t, r, http.MethodPatch, path, gin.H{
+		"userId": fx.Alice.UserID, "email": fx.Alice.Email,
+		"bio": "Пишу **фэнтези**. <script>alert(1)</script> [Мой сайт](https://alice.example.com) ![кот](https://example.com/cat.png)",
+	}, userHeader(fx.Alice.UserID))
+	if w.Code != http.StatusOK {
+		t.Fatalf("ожидали 200, получили %d: %s", w.Code, w.Body.String())
+	}
+	got := decodeProfile(t, w)
+	if !strings.HasPrefix(got.Bio, "Пишу **фэнтези**.") {
+		t.Errorf("исходный текст описания должен сохраниться: %q", got.Bio)
+	}
+	if !strings.Contains(got.BioHTML, "<strong>фэнтези</strong>") || !strings.Contains(got.BioHTML, `rel="nofollow noreferrer noopener"`) {
+		t.Errorf("ожидали отрисованный HTML со ссылкой: %q", got.BioHTML)
+	}
+	if strings.Contains(got.BioHTML, "<script") || strings.Contains(got.BioHTML, "<img") {
+		t.Errorf("скрипты и изображения не должны попадать в HTML: %q", got.BioHTML)
+	}
+
+	// Ссылки описания не задерживаются проверкой текста с настройками по умолчанию
+	other := decodeProfile(t, doRequest(t, r, http.MethodGet, path, nil, userHeader(fx.Bob.UserID)))
+	if !strings.Contains(other.BioHTML, `href="https://alice.example.com"`) || other.HeldFields != nil {
+		t.Errorf("описание со ссылкой должно быть видно другим: %+v", other)
+	}
+
+	// HTML описания, скрытого модератором, не виден другим
+	db.Model(&models.Profile{}).Where("user_id = ?", fx.Alice.UserID).Update("hidden_fields", models.StringList{"bio"})
+	if other := decodeProfile(t, doRequest(t, r, http.MethodGet, path, nil, userHeader(fx.Bob.UserID))); other.Bio != "" || other.BioHTML != "" {
+		t.Errorf("скрытое описание не должно быть видно: %+v", other)
+	}
+}
@@ -0,0 +1,81 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/monst/story-craft/services/user-profile-service/audit"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"github.com/monst/story-craft/services/user-profile-service/testutil"
+)
+
+func TestDigestSettings(t *testing.T) {
+	db := testutil.NewDB(t)
+	fx := testutil.LoadFixtures(t, db)
+	r := newTestRouter(db)
+	path := "/profiles/" + fx.Alice.UserID + "/digest"
+	alice := userHeader(fx.Alice.UserID)
+
+	decode := func(body []byte) models.DigestSettings {
+		t.Helper()
+		var s models.DigestSettings
+		if err := json.Unmarshal(body, &s); err != nil {
+			t.Fatalf("не удалось разобрать расписание: %v: %s", err, body)
+		}
+		return s
+	}
+
+	w := doRequest(t, r, http.MethodGet, path, nil, alice)
+	if w.Code != http.StatusOK {
+		t.Fatalf("ожидали 200, получили %d: %s", w.Code, w.Body.String())
+	}
+	if s := decode(w.Body.Bytes()); s.Frequency != models.DigestOff || s.NextSendAt != nil {
+		t.Errorf("ожидали выключенный дайджест по умолчанию: %+v", s)
+	}
+
+	body := gin.H{"frequency": "weekly", "channel": "in_app", "send_hour": 8, "weekday": 5, "time_zone": "Europe/Moscow"}
+	w = doRequest(t, r, http.MethodPatch, path, body, alice)
+	if w.Code != http.StatusOK {
+		t.Fatalf("ожидали 200, получили %d: %s", w.Code, w.Body.String())
+	}
+	if s := decode(w.Body.Bytes()); s.Frequency != models.DigestWeekly || s.NextSendAt == nil {
+		t.Errorf("дайджест должен быть запланирован: %+v", s)
+	}
+
+	// Непереданные поля сохраняются
+	w = doRequest(t, r, http.MethodPatch, path, gin.H{"send_hour": 20}, alice)
+	if s := decode(w.Body.Bytes()); w.Code != http.StatusOK || s.Channel != models.ChannelInApp || s.Weekday != 5 || s.SendHour != 20 {
+		t.Errorf("изменение затёрло настройки: %d %+v", w.Code, s)
+	}
+
+	var entries []models.AuditEntry
+	db.Where("action = ? AND target_user_id = ?", audit.ActionDigestUpdated, fx.Alice.UserID).Find(&entries)
+	if len(entries) != 2 {
+		t.Errorf("ожидали две записи в журнале, получили %d", len(entries))
+	}
+
+	cases := []struct {
+		name    string
+		method  string
+		path    string
+		body    gin.H
+		headers map[string]string
+		want    int
+	}{
+		{"без пользователя", http.MethodGet, path, nil, nil, http.StatusUnauthorized},
+		{"чужое расписание", http.MethodGet, path, nil, userHeader(fx.Bob.UserID), http.StatusForbidden},
+		{"изменение чужого расписания", http.MethodPatch, path, body, userHeader(fx.Bob.UserID), http.StatusForbidden},
+		{"неверная частота", http.MethodPatch, path, gin.H{"frequency": "hourly"}, alice, http.StatusBadRequest},
+		{"неверный канал", http.MethodPatch, path, gin.H{"channel": "web_push"}, alice, http.StatusBadRequest},
+		{"неверный час", http.MethodPatch, path, gin.H{"send_hour": 24}, alice, http.StatusBadRequest},
+		{"неизвестный часовой пояс", http.MethodPatch, path, gin.H{"time_zone": "Mars/Olympus"}, alice, http.StatusBadRequest},
+		{"удалённый профиль", http.MethodGet, "/profiles/" + fx.Deleted.UserID + "/digest", nil, userHeader(fx.Deleted.UserID), http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		if w := doRequest(t, r, tc.method, tc.path, tc.body, tc.headers); w.Code != tc.want {
+			t.Errorf("%s: ожидали %d, получили %d: %s", tc.name, tc.want, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/monst/story-craft/services/user-profile-service/config"
+	"github.com/monst/story-craft/services/user-profile-service/mail"
+	"github.com/monst/story-craft/services/user-profile-service/middleware"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"github.com/monst/story-craft/services/user-profile-service/testutil"
+)
+
+func TestEmailFeedback(t *testing.T) {
+	db := testutil.NewDB(t)
+	fx := testutil.LoadFixtures(t, db)
+	cfg := config.Default()
+	cfg.Notifications.IngestToken = testIngestToken
+	cfg.Email.Enabled = true
+	cfg.Email.SigningKey = "email-secret"
+	r := SetupRouter(Dependencies{DB: db, Config: cfg})
+	internal := map[string]string{middleware.InternalTokenHeader: testIngestToken}
+
+	// Письмо о событии ставится вместе с уведомлением, приветствие — при создании профиля
+	body := gin.H{"type": models.EventProposalWon, "recipient_ids": []string{fx.Alice.UserID}, "dedup_key": "won-1"}
+	if w := doRequest(t, r, http.MethodPost, "/internal/notifications", body, internal); w.Code != http.StatusOK {
+		t.Fatalf("ожидали 200, получили %d: %s", w.Code, w.Body.String())
+	}
+	newUser := uuid.NewString()
+	if w := doRequest(t, r, http.MethodPost, "/profiles/", gin.H{"userId": newUser, "username": "carol", "email": "carol@example.com"}, userHeader(newUser)); w.Code != http.StatusCreated {
+		t.Fatalf("ожидали 201, получили %d: %s", w.Code, w.Body.String())
+	}
+	var queued []models.EmailMessage
+	db.Order("id").Find(&queued)
+	if len(queued) != 2 || queued[0].Template != mail.TemplateProposalWon || queued[1].Template != mail.TemplateWelcome || queued[1].RecipientID != newUser {
+		t.Fatalf("неверная очередь писем: %+v", queued)
+	}
+
+	// Отписка по ссылке от всех писем отменяет ожидающее письмо
+	if w := doRequest(t, r, http.MethodPost, "/email/unsubscribe?token=broken", nil, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("неверная ссылка: ожидали 400, получили %d", w.Code)
+	}
+	token := mail.UnsubscribeToken(cfg.Email.SigningKey, fx.Alice.UserID, mail.ScopeAll)
+	for range 2 {
+		if w := doRequest(t, r, http.MethodPost, "/email/unsubscribe?token="+token, nil, nil); w.Code != http.StatusOK {
+			t.Fatalf("ожидали 200, получили %d: %s", w.Code, w.Body.String())
+		}
+	}
+	var alice models.Profile
+	db.First(&alice, "user_id = ?", fx.Alice.UserID)
+	if alice.EmailUnsubscribedAt == nil {
+		t.Error("отписка не сохранена в профиле")
+	}
+	var won models.EmailMessage
+	db.First(&won, queued[0].ID)
+	if won.Status != models.EmailCancelled {
+		t.Errorf("ожидали cancelled, получили %s", won.Status)
+	}
+	var entries int64
+	db.Model(&models.AuditEntry{}).Where("action = ? AND target_user_id = ?", "email.unsubscribed", fx.Alice.UserID).Count(&entries)
+	if entries != 1 {
+		t.Errorf("ожидали одну запись журнала об отписке, получили %d", entries)
+	}
+
+	// Включение писем в настройках отменяет отписку
+	prefs := models.DefaultNotificationPreferences()
+	if w := doRequest(t, r, http.MethodPut, "/profiles/"+fx.Alice.UserID+"/notification-preferences", prefs, userHeader(fx.Alice.UserID)); w.Code != http.StatusOK {
+		t.Fatalf("ожидали 200, получили %d: %s", w.Code, w.Body.String())
+	}
+	alice = models.Profile{}
+	db.First(&alice, "user_id = ?", fx.Alice.UserID)
+	if alice.EmailUnsubscribedAt != nil {
+		t.Error("отписка должна сниматься при включении писем")
+	}
+
+	// Жёсткий отказ останавливает письма до подтверждения нового адреса
+	bounce := gin.H{"address": "bob@example.com", "type": models.BounceHard, "reason": "550 No such user"}
+	if w := doRequest(t, r, http.MethodPost, "/internal/email/bounces", bounce, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("без токена: ожидали 401, получили %d", w.Code)
+	}
+	if w := doRequest(t, r, http.MethodPost, "/internal/email/bounces", bounce, internal); w.Code != http.StatusOK {
+		t.Fatalf("ожидали 200, получили %d: %s", w.Code, w.Body.String())
+	}
+	if w := doRequest(t, r, http.MethodPost, "/internal/email/bounces", gin.H{"address": "nobody@example.com", "type": models.BounceHard}, internal); w.Code != http.StatusNotFound {
+		t.Errorf("неизвестный адрес: ожидали 404, получили %d", w.Code)
+	}
+	if w := doRequest(t, r, http.MethodPost, "/internal/email/bounces", gin.H{"address": "bob@example.com", "type": "spam"}, internal); w.Code != http.StatusBadRequest {
+		t.Errorf("неизвестный вид отказа: ожидали 400, получили %d", w.Code)
+	}
+	var bob models.Profile
+	db.First(&bob, "user_id = ?", fx.Bob.UserID)
+	if bob.EmailBouncedAt == nil {
+		t.Fatal("отказ не сохранён в профиле")
+	}
+	if w := doRequest(t, r, http.MethodPatch, "/profiles/"+fx.Bob.UserID, gin.H{"email": "bob@new.example.com"}, userHeader(fx.Bob.UserID)); w.Code != http.StatusOK {
+		t.Fatalf("ожидали 200, получили %d: %s", w.Code, w.Body.String())
+	}
+	var change models.EmailChange
+	db.Where("user_id = ?", fx.Bob.UserID).First(&change)
+	token = mail.ConfirmToken(cfg.Email.SigningKey, change.ID, change.ExpiresAt)
+	if w := doRequest(t, r, http.MethodPost, "/email/confirm?token="+token, nil, nil); w.Code != http.StatusOK {
+		t.Fatalf("ожидали 200, получили %d: %s", w.Code, w.Body.String())
+	}
+	bob = models.Profile{}
+	db.First(&bob, "user_id = ?", fx.Bob.UserID)
+	if bob.EmailBouncedAt != nil {
+		t.Error("отказ должен сбрасываться при смене адреса")
+	}
+}
+
+func TestEmailChangeConfirmation(t *testing.T) {
+	db := testutil.NewDB(t)
+	fx := testutil.LoadFixtures(t, db)
+	cfg := config.Default()
+	cfg.Notifications.IngestToken = testIngestToken
+	cfg.Email.Enabled = true
+	cfg.Email.SigningKey = "email-secret"
+	r := SetupRouter(Dependencies{DB: db, Config: cfg})
+	alice := userHeader(fx.Alice.UserID)
+	path := "/profiles/" + fx.Alice.UserID
+
+	// Новый адрес ждёт подтверждения, в профиле остаётся прежний
+	if w := doRequest(t, r, http.MethodPatch, path, gin.H{"email": "bob@example.com"}, alice); w.Code != http.StatusConflict {
+		t.Errorf("занятый адрес: ожидали 409, получили %d", w.Code)
+	}
+	w := doRequest(t, r, http.MethodPatch, path, gin.H{"email": "alice@new.example.com"}, alice)
+	profile := decodeProfile(t, w)
+	if w.Code != http.StatusOK || profile.Email != "alice@example.com" || profile.PendingEmail != "alice@new.example.com" {
+		t.Fatalf("ожидали ожидающий подтверждения адрес, получили %d: %s", w.Code, w.Body.String())
+	}
+	if other := decodeProfile(t, doRequest(t, r, http.MethodGet, path, nil, userHeader(fx.Bob.UserID))); other.PendingEmail != "" {
+		t.Error("новый адрес виден только владельцу")
+	}
+	var first models.EmailChange
+	db.Where("user_id = ?", fx.Alice.UserID).First(&first)
+
+	// Повторный запрос заменяет прежнюю ссылку
+	doRequest(t, r, http.MethodPatch, path, gin.H{"email": "alice@other.example.com"}, alice)
+	var second models.EmailChange
+	db.Where("user_id = ? AND id <> ?", fx.Alice.UserID, first.ID).First(&second)
+	if w := doRequest(t, r, http.MethodPost, "/email/confirm?token="+mail.ConfirmToken(cfg.Email.SigningKey, first.ID, first.ExpiresAt), nil, nil); w.Code != http.StatusConflict {
+		t.Errorf("заменённая ссылка: ожидали 409, получили %d", w.Code)
+	}
+	if w := doRequest(t, r, http.MethodPost, "/email/confirm?token="+mail.RevertToken(cfg.Email.SigningKey, second.ID, second.ExpiresAt), nil, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("ссылка отмены вместо подтверждения: ожидали 400, получили %d", w.Code)
+	}
+
+	confirm := "/email/confirm?token=" + mail.ConfirmToken(cfg.Email.SigningKey, second.ID, second.ExpiresAt)
+	w = doRequest(t, r, http.MethodPost, confirm, nil, nil)
+	profile = decodeProfile(t, w)
+	if w.Code != http.StatusOK || profile.Email != "alice@other.example.com" || profile.EmailVerifiedAt == nil || profile.PendingEmail != "" {
+		t.Fatalf("адрес не подтверждён: %d %s", w.Code, w.Body.String())
+	}
+	if w := doRequest(t, r, http.MethodPost, confirm, nil, nil); w.Code != http.StatusConflict {
+		t.Errorf("повторное подтверждение: ожидали 409, получили %d", w.Code)
+	}
+
+	// Другие сервисы видят подтверждение через внутренний API
+	internal := map[string]string{middleware.InternalTokenHeader: testIngestToken}
+	var status models.EmailStatus
+	w = doRequest(t, r, http.MethodGet, "/internal/profiles/"+fx.Alice.UserID+"/email", nil, internal)
+	json.Unmarshal(w.Body.Bytes(), &status)
+	if w.Code != http.StatusOK || !status.Verified || status.Email != "alice@other.example.com" {
+		t.Errorf("неверный статус адреса: %d %s", w.Code, w.Body.String())
+	}
+
+	// На прежний адрес ушло письмо со ссылкой отмены, она возвращает адрес один раз
+	var notice models.EmailMessage
+	if err := db.Where("recipient_id = ? AND template = ?", fx.Alice.UserID, mail.TemplateEmailChanged).First(&notice).Error; err != nil {
+		t.Fatalf("нет письма на прежний адрес: %v", err)
+	}
+	db.First(&second, second.ID)
+	revert := "/email/revert?token=" + mail.RevertToken(cfg.Email.SigningKey, second.ID, *second.RevertExpiresAt)
+	w = doRequest(t, r, http.MethodPost, revert, nil, nil)
+	profile = decodeProfile(t, w)
+	if w.Code != http.StatusOK || profile.Email != "alice@example.com" || profile.EmailVerifiedAt == nil {
+		t.Fatalf("адрес не возвращён: %d %s", w.Code, w.Body.String())
+	}
+	if w := doRequest(t, r, http.MethodPost, revert, nil, nil); w.Code != http.StatusConflict {
+		t.Errorf("повторная отмена: ожидали 409, получили %d", w.Code)
+	}
+	var entries int64
+	db.Model(&models.AuditEntry{}).Where("target_user_id = ? AND action IN ?", fx.Alice.UserID, []string{"email.changed", "email.reverted"}).Count(&entries)
+	if entries != 2 {
+		t.Errorf("ожидали записи журнала о смене и отмене, получили %d", entries)
+	}
+
+	// Подтверждение текущего адреса по запросу владельца
+	if w := doRequest(t, r, http.MethodPost, "/profiles/"+fx.Bob.UserID+"/email/verify", nil, alice); w.Code != http.StatusForbidden {
+		t.Errorf("чужой профиль: ожидали 403, получили %d", w.Code)
+	}
+	if w := doRequest(t, r, http.MethodPost, "/profiles/"+fx.Bob.UserID+"/email/verify", nil, userHeader(fx.Bob.UserID)); w.Code != http.StatusAccepted {
+		t.Fatalf("ожидали 202, получили %d: %s", w.Code, w.Body.String())
+	}
+	var verification models.EmailChange
+	db.Where("user_id = ?", fx.Bob.UserID).First(&verification)
+	if verification.NewEmail != "bob@example.com" {
+		t.Fatalf("ожидали подтверждение текущего адреса, получили %+v", verification)
+	}
+	doRequest(t, r, http.MethodPost, "/email/confirm?token="+mail.ConfirmToken(cfg.Email.SigningKey, verification.ID, verification.ExpiresAt), nil, nil)
+	if w := doRequest(t, r, http.MethodPost, "/profiles/"+fx.Bob.UserID+"/email/verify", nil, userHeader(fx.Bob.UserID)); w.Code != http.StatusConflict {
+		t.Errorf("подтверждённый адрес: ожидали 409, получили %d", w.Code)
+	}
+}
+
+func TestEmailChangeWithoutDelivery(t *testing.T) {
+	db := testutil.NewDB(t)
+	fx := testutil.LoadFixtures(t, db)
+	r := newTestRouter(db)
+
+	// Без отправки писем адрес меняется сразу, но остаётся неподтверждённым
+	db.Model(&fx.Alice).Update("email_verified_at", time.Now())
+	profile := decodeProfile(t, doRequest(t, r, http.MethodPatch, "/profiles/"+fx.Alice.UserID, gin.H{"email": "alice@new.example.com"}, userHeader(fx.Alice.UserID)))
+	if profile.Email != "alice@new.example.com" || profile.EmailVerifiedAt != nil {
+		t.Errorf("ожидали неподтверждённый новый адрес, получили %+v", profile)
+	}
+}
+
+func TestEmailRoutesDisabled(t *testing.T) {
+	r := newTestRouter(testutil.NewDB(t))
+	if w := doRequest(t, r, http.MethodPost, "/email/unsubscribe?token=x", nil, nil); w.Code != http.StatusNotFound {
+		t.Errorf("при выключенных письмах ожидали 404, получили %d", w.Code)
+	}
+}
@@ -0,0 +1,78 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/monst/story-craft/services/user-profile-service/audit"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"github.com/monst/story-craft/services/user-profile-service/testutil"
+)
+
+func TestAdminJobs(t *testing.T) {
+	db := testutil.NewDB(t)
+	fx := testutil.LoadFixtures(t, db)
+	r := newTestRouter(db)
+	admin := userHeader(fx.Admin.UserID)
+
+	finished := time.Now().UTC()
+	failed := models.Job{Kind: "digest.send", Status: models.JobFailed, Attempts: 5, MaxAttempts: 5, LastError: "timeout", FinishedAt: &finished}
+	done := models.Job{Kind: "jobs.cleanup", Status: models.JobDone, Attempts: 1, MaxAttempts: 5, FinishedAt: &finished}
+	for _, job := range []*models.Job{&failed, &done} {
+		if err := db.Create(job).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	failedPath := "/admin/jobs/" + strconv.FormatInt(failed.ID, 10)
+
+	w := doRequest(t, r, http.MethodGet, "/admin/jobs?status=failed", nil, admin)
+	var list []models.Job
+	if err := json.Unmarshal(w.Body.Bytes(), &list); w.Code != http.StatusOK || err != nil {
+		t.Fatalf("ожидали 200, получили %d: %s", w.Code, w.Body.String())
+	}
+	if len(list) != 1 || list[0].ID != failed.ID || list[0].LastError != "timeout" {
+		t.Errorf("ожидали одну задачу с ошибкой: %+v", list)
+	}
+
+	w = doRequest(t, r, http.MethodPost, failedPath+"/retry", nil, admin)
+	if w.Code != http.StatusOK {
+		t.Fatalf("повтор: ожидали 200, получили %d: %s", w.Code, w.Body.String())
+	}
+	w = doRequest(t, r, http.MethodGet, failedPath, nil, admin)
+	var job models.Job
+	json.Unmarshal(w.Body.Bytes(), &job)
+	if job.Status != models.JobPending || job.Attempts != 0 {
+		t.Errorf("задача должна вернуться в очередь: %+v", job)
+	}
+
+	var entries []models.AuditEntry
+	db.Where("action = ? AND actor_id = ?", audit.ActionJobRetried, fx.Admin.UserID).Find(&entries)
+	if len(entries) != 1 {
+		t.Errorf("ожидали одну запись в журнале, получили %d", len(entries))
+	}
+
+	cases := []struct {
+		name    string
+		method  string
+		path    string
+		headers map[string]string
+		want    int
+	}{
+		{"обычный пользователь", http.MethodGet, "/admin/jobs", userHeader(fx.Alice.UserID), http.StatusForbidden},
+		{"повтор обычным пользователем", http.MethodPost, failedPath + "/retry", userHeader(fx.Alice.UserID), http.StatusForbidden},
+		{"неизвестный статус", http.MethodGet, "/admin/jobs?status=lost", admin, http.StatusBadRequest},
+		{"неверный limit", http.MethodGet, "/admin/jobs?limit=0", admin, http.StatusBadRequest},
+		{"неверный идентификатор", http.MethodGet, "/admin/jobs/abc", admin, http.StatusBadRequest},
+		{"нет задачи", http.MethodGet, "/admin/jobs/999999", admin, http.StatusNotFound},
+		{"повтор задачи в очереди", http.MethodPost, failedPath + "/retry", admin, http.StatusConflict},
+		{"повтор выполненной задачи", http.MethodPost, "/admin/jobs/" + strconv.FormatInt(done.ID, 10) + "/retry", admin, http.StatusConflict},
+	}
+	for _, tc := range cases {
+		if w := doRequest(t, r, tc.method, tc.path, nil, tc.headers); w.Code != tc.want {
+			t.Errorf("%s: ожидали %d, получили %d: %s", tc.name, tc.want, w.Code, w.Body.String())
+		}
+	}
+}
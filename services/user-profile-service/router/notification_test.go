@@ -0,0 +1,97 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/monst/story-craft/services/user-profile-service/config"
+	"github.com/monst/story-craft/services/user-profile-service/middleware"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"github.com/monst/story-craft/services/user-profile-service/testutil"
+)
+
+const testIngestToken = "test-ingest-token"
+
+func TestNotificationInbox(t *testing.T) {
+	db := testutil.NewDB(t)
+	fx := testutil.LoadFixtures(t, db)
+	cfg := config.Default()
+	cfg.Notifications.IngestToken = testIngestToken
+	r := SetupRouter(Dependencies{DB: db, Config: cfg})
+	internal := map[string]string{middleware.InternalTokenHeader: testIngestToken}
+	alice := userHeader(fx.Alice.UserID)
+
+	for i := range 3 {
+		body := gin.H{
+			"type":          models.EventVotingOpened,
+			"recipient_ids": []string{fx.Alice.UserID, fx.Bob.UserID},
+			"payload":       gin.H{"story_id": strconv.Itoa(i)},
+			"dedup_key":     "voting-" + strconv.Itoa(i),
+		}
+		if w := doRequest(t, r, http.MethodPost, "/internal/notifications", body, internal); w.Code != http.StatusOK {
+			t.Fatalf("ожидали 200, получили %d: %s", w.Code, w.Body.String())
+		}
+	}
+
+	// Постраничная выдача по курсору
+	var page models.NotificationPage
+	w := doRequest(t, r, http.MethodGet, "/notifications?limit=2", nil, alice)
+	if w.Code != http.StatusOK {
+		t.Fatalf("ожидали 200, получили %d: %s", w.Code, w.Body.String())
+	}
+	json.Unmarshal(w.Body.Bytes(), &page)
+	if len(page.Items) != 2 || page.NextCursor == "" || page.UnreadCount != 3 {
+		t.Fatalf("неверная первая страница: %+v", page)
+	}
+	newest := page.Items[0].ID
+	w = doRequest(t, r, http.MethodGet, "/notifications?limit=2&cursor="+page.NextCursor, nil, alice)
+	page = models.NotificationPage{}
+	json.Unmarshal(w.Body.Bytes(), &page)
+	if len(page.Items) != 1 || page.NextCursor != "" {
+		t.Fatalf("неверная последняя страница: %+v", page)
+	}
+
+	path := "/notifications/" + strconv.FormatInt(newest, 10) + "/read"
+	if w := doRequest(t, r, http.MethodPost, path, nil, userHeader(fx.Bob.UserID)); w.Code != http.StatusNotFound {
+		t.Errorf("чужое уведомление: ожидали 404, получили %d", w.Code)
+	}
+	if w := doRequest(t, r, http.MethodPost, path, nil, alice); w.Code != http.StatusOK {
+		t.Errorf("ожидали 200, получили %d: %s", w.Code, w.Body.String())
+	}
+	w = doRequest(t, r, http.MethodGet, "/notifications/unread-count", nil, alice)
+	var count map[string]int64
+	json.Unmarshal(w.Body.Bytes(), &count)
+	if count["unread_count"] != 2 {
+		t.Errorf("ожидали 2 непрочитанных, получили %s", w.Body.String())
+	}
+
+	w = doRequest(t, r, http.MethodPost, "/notifications/read-all", nil, alice)
+	json.Unmarshal(w.Body.Bytes(), &count)
+	if w.Code != http.StatusOK || count["updated"] != 2 {
+		t.Errorf("ожидали 2 отмеченных, получили %d: %s", w.Code, w.Body.String())
+	}
+
+	cases := []struct {
+		name    string
+		method  string
+		path    string
+		body    any
+		headers map[string]string
+		want    int
+	}{
+		{"входящие без пользователя", http.MethodGet, "/notifications", nil, nil, http.StatusUnauthorized},
+		{"неверный курсор", http.MethodGet, "/notifications?cursor=abc", nil, alice, http.StatusBadRequest},
+		{"без внутреннего токена", http.MethodPost, "/internal/notifications", gin.H{"type": models.EventNewFollower, "recipient_ids": []string{fx.Alice.UserID}}, alice, http.StatusUnauthorized},
+		{"неизвестный тип", http.MethodPost, "/internal/notifications", gin.H{"type": "chapter_liked", "recipient_ids": []string{fx.Alice.UserID}}, internal, http.StatusBadRequest},
+		{"неверный получатель", http.MethodPost, "/internal/notifications", gin.H{"type": models.EventNewFollower, "recipient_ids": []string{"alice"}}, internal, http.StatusBadRequest},
+		{"слишком долгий срок", http.MethodPost, "/internal/notifications", gin.H{"type": models.EventNewFollower, "recipient_ids": []string{fx.Alice.UserID}, "ttl": "100000h"}, internal, http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		if w := doRequest(t, r, tc.method, tc.path, tc.body, tc.headers); w.Code != tc.want {
+			t.Errorf("%s: ожидали %d, получили %d: %s", tc.name, tc.want, w.Code, w.Body.String())
+		}
+	}
+}
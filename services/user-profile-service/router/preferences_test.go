@@ -0,0 +1,88 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/monst/story-craft/services/user-profile-service/audit"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"github.com/monst/story-craft/services/user-profile-service/testutil"
+)
+
+func decodePreferences(t *testing.T, body []byte) models.NotificationPreferences {
+	t.Helper()
+	var prefs models.NotificationPreferences
+	if err := json.Unmarshal(body, &prefs); err != nil {
+		t.Fatalf("не удалось разобрать настройки: %v: %s", err, body)
+	}
+	return prefs
+}
+
+func TestNotificationPreferences(t *testing.T) {
+	db := testutil.NewDB(t)
+	fx := testutil.LoadFixtures(t, db)
+	r := newTestRouter(db)
+	path := "/profiles/" + fx.Alice.UserID + "/notification-preferences"
+	alice := userHeader(fx.Alice.UserID)
+
+	w := doRequest(t, r, http.MethodGet, path, nil, alice)
+	if w.Code != http.StatusOK {
+		t.Fatalf("ожидали 200, получили %d: %s", w.Code, w.Body.String())
+	}
+	if prefs := decodePreferences(t, w.Body.Bytes()); prefs.Version != models.PreferencesVersion {
+		t.Errorf("ожидали настройки по умолчанию: %+v", prefs)
+	}
+
+	body := gin.H{
+		"events":      gin.H{"new_chapter": gin.H{"in_app": true, "web_push": true}},
+		"quiet_hours": gin.H{"enabled": true, "start": "23:00", "end": "07:30", "time_zone": "Europe/Moscow"},
+	}
+	w = doRequest(t, r, http.MethodPut, path, body, alice)
+	if w.Code != http.StatusOK {
+		t.Fatalf("ожидали 200, получили %d: %s", w.Code, w.Body.String())
+	}
+	w = doRequest(t, r, http.MethodGet, path, nil, alice)
+	prefs := decodePreferences(t, w.Body.Bytes())
+	if !prefs.Allows(models.EventNewChapter, models.ChannelWebPush) || prefs.QuietHours.TimeZone != "Europe/Moscow" {
+		t.Errorf("настройки не сохранились: %+v", prefs)
+	}
+	if !prefs.Allows(models.EventProposalWon, models.ChannelEmail) {
+		t.Errorf("событие без настроек должно получить значения по умолчанию: %+v", prefs.Events)
+	}
+
+	// Настройки не попадают в публичный профиль
+	w = doRequest(t, r, http.MethodGet, "/profiles/"+fx.Alice.UserID, nil, userHeader(fx.Bob.UserID))
+	var public map[string]any
+	json.Unmarshal(w.Body.Bytes(), &public)
+	if _, ok := public["notification_preferences"]; ok {
+		t.Errorf("настройки уведомлений видны в профиле: %s", w.Body.String())
+	}
+
+	var entries []models.AuditEntry
+	db.Where("action = ? AND target_user_id = ?", audit.ActionPreferencesUpdated, fx.Alice.UserID).Find(&entries)
+	if len(entries) != 1 {
+		t.Errorf("ожидали одну запись в журнале, получили %d", len(entries))
+	}
+
+	cases := []struct {
+		name    string
+		method  string
+		body    gin.H
+		headers map[string]string
+		want    int
+	}{
+		{"без пользователя", http.MethodGet, nil, nil, http.StatusUnauthorized},
+		{"чужие настройки", http.MethodGet, nil, userHeader(fx.Bob.UserID), http.StatusForbidden},
+		{"изменение чужих настроек", http.MethodPut, body, userHeader(fx.Bob.UserID), http.StatusForbidden},
+		{"неизвестный часовой пояс", http.MethodPut, gin.H{"quiet_hours": gin.H{"time_zone": "Mars/Olympus"}}, alice, http.StatusBadRequest},
+		{"неизвестное событие", http.MethodPut, gin.H{"events": gin.H{"chapter_liked": gin.H{"email": true}}}, alice, http.StatusBadRequest},
+		{"неверное время", http.MethodPut, gin.H{"quiet_hours": gin.H{"start": "25:00"}}, alice, http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		if w := doRequest(t, r, tc.method, path, tc.body, tc.headers); w.Code != tc.want {
+			t.Errorf("%s: ожидали %d, получили %d: %s", tc.name, tc.want, w.Code, w.Body.String())
+		}
+	}
+}
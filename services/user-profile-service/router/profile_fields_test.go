@@ -0,0 +1,89 @@
+package router
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"github.com/monst/story-craft/services/user-profile-service/testutil"
+)
+
+func TestUpdateExtendedFields(t *testing.T) {
+	db := testutil.NewDB(t)
+	fx := testutil.LoadFixtures(t, db)
+	r := newTestRouter(db)
+	alice := userHeader(fx.Alice.UserID)
+	path := "/profiles/" + fx.Alice.UserID
+
+	w := doRequest(t, r, http.MethodPatch, path, gin.H{
+		"userId": fx.Alice.UserID, "email": fx.Alice.Email,
+		"links":           []string{"https://github.com/alice", "https://alice-writes.example.com"},
+		"location":        "  Казань ",
+		"pronouns":        "она/её",
+		"languages":       []string{"ru", "en-us"},
+		"favoriteGenres":  []string{"фэнтези", "Киберпанк"},
+		"fieldVisibility": gin.H{"location": "private", "links": "registered"},
+	}, alice)
+	if w.Code != http.StatusOK {
+		t.Fatalf("ожидали 200, получили %d: %s", w.Code, w.Body.String())
+	}
+	got := decodeProfile(t, w)
+	if len(got.Links) != 2 || got.Links[0].Type != "github" || got.Links[1].Type != models.LinkWebsite {
+		t.Errorf("ожидали ссылки с типами, получили %+v", got.Links)
+	}
+	if got.Location != "Казань" || got.Pronouns != "она/её" {
+		t.Errorf("получили location %q, pronouns %q", got.Location, got.Pronouns)
+	}
+	if got.Languages[1] != "en-US" || got.FavoriteGenres[0] != "Фэнтези" {
+		t.Errorf("теги языков и жанры должны приводиться к каноническому виду: %v, %v", got.Languages, got.FavoriteGenres)
+	}
+
+	// Поля, не переданные в запросе, не меняются
+	w = doRequest(t, r, http.MethodPatch, path, gin.H{"userId": fx.Alice.UserID, "email": fx.Alice.Email, "bio": "Пишу фэнтези"}, alice)
+	if got := decodeProfile(t, w); got.Location != "Казань" || len(got.Links) != 2 {
+		t.Errorf("расширенные поля не должны сбрасываться: %+v", got)
+	}
+
+	bob := decodeProfile(t, doRequest(t, r, http.MethodGet, path, nil, userHeader(fx.Bob.UserID)))
+	if bob.Location != "" || len(bob.Links) != 2 || bob.Pronouns != "она/её" || bob.FieldVisibility != nil {
+		t.Errorf("Bob видит всё, кроме закрытых полей: %+v", bob)
+	}
+	// Видимость чужих полей не меняется: закрытое местоположение остаётся закрытым
+	w = doRequest(t, r, http.MethodPatch, path, gin.H{
+		"userId": fx.Alice.UserID, "email": fx.Alice.Email, "fieldVisibility": gin.H{"location": "public"},
+	}, userHeader(fx.Bob.UserID))
+	if w.Code != http.StatusForbidden || strings.Contains(w.Body.String(), "Казань") {
+		t.Errorf("чужой профиль: ожидали 403 без данных профиля, получили %d: %s", w.Code, w.Body.String())
+	}
+	if bob := decodeProfile(t, doRequest(t, r, http.MethodGet, path, nil, userHeader(fx.Bob.UserID))); bob.Location != "" {
+		t.Errorf("местоположение должно остаться закрытым: %q", bob.Location)
+	}
+	guest := decodeProfile(t, doRequest(t, r, http.MethodGet, path, nil, nil))
+	if guest.Links != nil || len(guest.FavoriteGenres) != 2 {
+		t.Errorf("гость не видит поля для зарегистрированных: %+v", guest)
+	}
+
+	for name, body := range map[string]gin.H{
+		"ссылка":    {"links": []string{"javascript:alert(1)"}},
+		"много":     {"links": []string{"https://a.example", "https://b.example", "https://c.example", "https://d.example", "https://e.example", "https://f.example"}},
+		"язык":      {"languages": []string{"эльфийский"}},
+		"жанр":      {"favoriteGenres": []string{"Космоопера"}},
+		"видимость": {"fieldVisibility": gin.H{"bio": "private"}},
+		"длина":     {"pronouns": strings.Repeat("я", 51)},
+	} {
+		body["userId"], body["email"] = fx.Alice.UserID, fx.Alice.Email
+		if w := doRequest(t, r, http.MethodPatch, path, body, alice); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: ожидали 400, получили %d: %s", name, w.Code, w.Body.String())
+		}
+	}
+
+	// Пустые значения очищают поля
+	w = doRequest(t, r, http.MethodPatch, path, gin.H{
+		"userId": fx.Alice.UserID, "email": fx.Alice.Email, "links": []string{}, "location": "",
+	}, alice)
+	if got := decodeProfile(t, w); got.Links != nil || got.Location != "" || got.Pronouns == "" {
+		t.Errorf("ожидали очищенные ссылки и местоположение: %+v", got)
+	}
+}
@@ -0,0 +1,144 @@
+package router
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/monst/story-craft/services/user-profile-service/config"
+	"github.com/monst/story-craft/services/user-profile-service/middleware"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"github.com/monst/story-craft/services/user-profile-service/push"
+	"github.com/monst/story-craft/services/user-profile-service/testutil"
+)
+
+// browserSubscription возвращает подписку браузера с настоящими ключами шифрования.
+func browserSubscription(t *testing.T, endpoint string) models.PushSubscriptionInput {
+	t.Helper()
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := make([]byte, 16)
+	rand.Read(auth)
+	return models.PushSubscriptionInput{
+		Endpoint: endpoint,
+		Keys: models.PushSubscriptionKeys{
+			P256dh: base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes()),
+			Auth:   base64.RawURLEncoding.EncodeToString(auth),
+		},
+		DeviceName: "Firefox на ноутбуке",
+	}
+}
+
+func TestPushSubscriptions(t *testing.T) {
+	db := testutil.NewDB(t)
+	fx := testutil.LoadFixtures(t, db)
+	privateKey, publicKey, err := push.GenerateKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Default()
+	cfg.Notifications.IngestToken = testIngestToken
+	cfg.WebPush.Enabled = true
+	cfg.WebPush.VAPIDPrivateKey = privateKey
+	r := SetupRouter(Dependencies{DB: db, Config: cfg})
+	path := "/profiles/" + fx.Alice.UserID + "/push-subscriptions"
+	alice := userHeader(fx.Alice.UserID)
+
+	w := doRequest(t, r, http.MethodGet, "/push/vapid-public-key", nil, nil)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), publicKey) {
+		t.Fatalf("ожидали открытый ключ, получили %d: %s", w.Code, w.Body.String())
+	}
+
+	input := browserSubscription(t, "https://fcm.googleapis.com/fcm/send/alice-laptop")
+	if w := doRequest(t, r, http.MethodPost, path, input, userHeader(fx.Bob.UserID)); w.Code != http.StatusForbidden {
+		t.Errorf("чужой профиль: ожидали 403, получили %d", w.Code)
+	}
+	invalid := map[string]models.PushSubscriptionInput{
+		"не push-сервис": browserSubscription(t, "https://internal.example.com/hook"),
+		"без https":      browserSubscription(t, "http://fcm.googleapis.com/fcm/send/x"),
+		"неверные ключи": {Endpoint: input.Endpoint, Keys: models.PushSubscriptionKeys{P256dh: "AAAA", Auth: "AAAA"}},
+		"без ключей":     {Endpoint: input.Endpoint},
+	}
+	for name, body := range invalid {
+		if w := doRequest(t, r, http.MethodPost, path, body, alice); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: ожидали 400, получили %d: %s", name, w.Code, w.Body.String())
+		}
+	}
+	for _, want := range []int{http.StatusCreated, http.StatusOK} {
+		if w := doRequest(t, r, http.MethodPost, path, input, alice); w.Code != want {
+			t.Fatalf("ожидали %d, получили %d: %s", want, w.Code, w.Body.String())
+		}
+	}
+
+	w = doRequest(t, r, http.MethodGet, path, nil, alice)
+	var subs []models.PushSubscription
+	if err := json.Unmarshal(w.Body.Bytes(), &subs); err != nil || len(subs) != 1 || subs[0].DeviceName != input.DeviceName {
+		t.Fatalf("ожидали одну подписку, получили %d: %s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), input.Keys.Auth) {
+		t.Error("ключи шифрования подписки не должны отдаваться наружу")
+	}
+
+	// Первая подписка включила push о победе: уведомление ставит сообщение на устройство
+	body := gin.H{"type": models.EventProposalWon, "recipient_ids": []string{fx.Alice.UserID}, "dedup_key": "won-1"}
+	internal := map[string]string{middleware.InternalTokenHeader: testIngestToken}
+	if w := doRequest(t, r, http.MethodPost, "/internal/notifications", body, internal); w.Code != http.StatusOK {
+		t.Fatalf("ожидали 200, получили %d: %s", w.Code, w.Body.String())
+	}
+	var queued []models.PushMessage
+	db.Find(&queued)
+	if len(queued) != 1 || queued[0].SubscriptionID != subs[0].ID || queued[0].Type != models.EventProposalWon {
+		t.Fatalf("неверная очередь push: %+v", queued)
+	}
+
+	revoke := path + "/" + strconv.FormatInt(subs[0].ID, 10)
+	if w := doRequest(t, r, http.MethodDelete, path+"/abc", nil, alice); w.Code != http.StatusBadRequest {
+		t.Errorf("неверный идентификатор: ожидали 400, получили %d", w.Code)
+	}
+	if w := doRequest(t, r, http.MethodDelete, revoke, nil, alice); w.Code != http.StatusNoContent {
+		t.Fatalf("ожидали 204, получили %d: %s", w.Code, w.Body.String())
+	}
+	if w := doRequest(t, r, http.MethodDelete, revoke, nil, alice); w.Code != http.StatusNotFound {
+		t.Errorf("повторная отписка: ожидали 404, получили %d", w.Code)
+	}
+	var message models.PushMessage
+	db.First(&message, queued[0].ID)
+	if message.Status != models.PushCancelled {
+		t.Errorf("сообщение на отписанное устройство: ожидали cancelled, получили %s", message.Status)
+	}
+
+	// Удаление профиля удаляет подписки его устройств
+	if w := doRequest(t, r, http.MethodPost, path, browserSubscription(t, "https://fcm.googleapis.com/fcm/send/alice-phone"), alice); w.Code != http.StatusCreated {
+		t.Fatalf("ожидали 201, получили %d: %s", w.Code, w.Body.String())
+	}
+	if w := doRequest(t, r, http.MethodDelete, "/profiles/"+fx.Alice.UserID, nil, alice); w.Code != http.StatusNoContent {
+		t.Fatalf("ожидали 204, получили %d: %s", w.Code, w.Body.String())
+	}
+	var left int64
+	db.Model(&models.PushSubscription{}).Count(&left)
+	if left != 0 {
+		t.Errorf("после удаления профиля осталось подписок: %d", left)
+	}
+}
+
+func TestPushDisabled(t *testing.T) {
+	db := testutil.NewDB(t)
+	fx := testutil.LoadFixtures(t, db)
+	r := newTestRouter(db)
+
+	if w := doRequest(t, r, http.MethodGet, "/push/vapid-public-key", nil, nil); w.Code != http.StatusNotFound {
+		t.Errorf("без Web Push: ожидали 404, получили %d", w.Code)
+	}
+	path := "/profiles/" + fx.Alice.UserID + "/push-subscriptions"
+	if w := doRequest(t, r, http.MethodPost, path, browserSubscription(t, "https://fcm.googleapis.com/fcm/send/x"), userHeader(fx.Alice.UserID)); w.Code != http.StatusNotFound {
+		t.Errorf("без Web Push: ожидали 404, получили %d", w.Code)
+	}
+}
@@ -0,0 +1,180 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"github.com/monst/story-craft/services/user-profile-service/testutil"
+)
+
+func decodeReport(t *testing.T, body []byte) models.Report {
+	t.Helper()
+	var report models.Report
+	if err := json.Unmarshal(body, &report); err != nil {
+		t.Fatalf("не удалось разобрать жалобу: %v: %s", err, body)
+	}
+	return report
+}
+
+func TestCreateReport(t *testing.T) {
+	db := testutil.NewDB(t)
+	fx := testutil.LoadFixtures(t, db)
+	r := newTestRouter(db)
+	path := "/profiles/" + fx.Alice.UserID + "/reports"
+	body := gin.H{"category": "spam", "fields": []string{"display_name"}, "comment": "реклама"}
+
+	w := doRequest(t, r, http.MethodPost, path, body, userHeader(fx.Bob.UserID))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("ожидали 201, получили %d: %s", w.Code, w.Body.String())
+	}
+	report := decodeReport(t, w.Body.Bytes())
+	if report.Status != models.ReportOpen || report.Category != models.ReportSpam {
+		t.Errorf("неверная жалоба: %+v", report)
+	}
+	var snapshot map[string]string
+	json.Unmarshal(report.Snapshot, &snapshot)
+	if snapshot["display_name"] != "Alice" {
+		t.Errorf("в жалобе должно сохраниться значение поля: %s", report.Snapshot)
+	}
+
+	// Повторная жалоба того же пользователя не создаёт новую
+	w = doRequest(t, r, http.MethodPost, path, gin.H{"category": "OTHER"}, userHeader(fx.Bob.UserID))
+	if w.Code != http.StatusOK || decodeReport(t, w.Body.Bytes()).ID != report.ID {
+		t.Errorf("дубликат: ожидали 200 с той же жалобой, получили %d: %s", w.Code, w.Body.String())
+	}
+
+	var profile models.Profile
+	db.Where("user_id = ?", fx.Alice.UserID).First(&profile)
+	if profile.FlaggedAt == nil || profile.FlagReason != models.ReportSpam {
+		t.Errorf("жалоба должна отметить профиль для проверки: %+v", profile)
+	}
+
+	cases := []struct {
+		name    string
+		path    string
+		body    gin.H
+		headers map[string]string
+		want    int
+	}{
+		{"без пользователя", path, body, nil, http.StatusUnauthorized},
+		{"на себя", "/profiles/" + fx.Bob.UserID + "/reports", body, userHeader(fx.Bob.UserID), http.StatusBadRequest},
+		{"неизвестная категория", path, gin.H{"category": "BORING"}, userHeader(fx.Admin.UserID), http.StatusBadRequest},
+		{"неизвестное поле", path, gin.H{"category": "SPAM", "fields": []string{"email"}}, userHeader(fx.Admin.UserID), http.StatusBadRequest},
+		{"нет профиля", "/profiles/00000000-0000-0000-0000-000000000000/reports", body, userHeader(fx.Bob.UserID), http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		if w := doRequest(t, r, http.MethodPost, tc.path, tc.body, tc.headers); w.Code != tc.want {
+			t.Errorf("%s: ожидали %d, получили %d: %s", tc.name, tc.want, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestModerationQueue(t *testing.T) {
+	db := testutil.NewDB(t)
+	fx := testutil.LoadFixtures(t, db)
+	r := newTestRouter(db)
+	admin := userHeader(fx.Admin.UserID)
+
+	doRequest(t, r, http.MethodPatch, "/profiles/"+fx.Alice.UserID, gin.H{
+		"userId": fx.Alice.UserID, "email": fx.Alice.Email, "bio": "спам-ссылка",
+	}, userHeader(fx.Alice.UserID))
+	w := doRequest(t, r, http.MethodPost, "/profiles/"+fx.Alice.UserID+"/reports", gin.H{"category": "SPAM", "fields": []string{"bio"}}, userHeader(fx.Bob.UserID))
+	report := decodeReport(t, w.Body.Bytes())
+	reportPath := "/admin/reports/" + strconv.FormatUint(report.ID, 10)
+
+	w = doRequest(t, r, http.MethodGet, "/admin/reports", nil, admin)
+	var queue []models.Report
+	json.Unmarshal(w.Body.Bytes(), &queue)
+	if len(queue) != 1 || queue[0].ID != report.ID {
+		t.Fatalf("в очереди должна быть одна жалоба, получили %s", w.Body.String())
+	}
+
+	if w := doRequest(t, r, http.MethodPost, reportPath+"/triage", nil, admin); w.Code != http.StatusOK || decodeReport(t, w.Body.Bytes()).Status != models.ReportTriaged {
+		t.Fatalf("triage: ожидали 200 и TRIAGED, получили %d: %s", w.Code, w.Body.String())
+	}
+	if w := doRequest(t, r, http.MethodPost, reportPath+"/triage", nil, admin); w.Code != http.StatusConflict {
+		t.Errorf("повторный triage: ожидали 409, получили %d", w.Code)
+	}
+
+	w = doRequest(t, r, http.MethodPost, reportPath+"/action", gin.H{"outcome": "Описание скрыто", "hide_fields": []string{"bio"}}, admin)
+	if w.Code != http.StatusOK {
+		t.Fatalf("action: ожидали 200, получили %d: %s", w.Code, w.Body.String())
+	}
+	if got := decodeReport(t, w.Body.Bytes()); got.Status != models.ReportActioned || got.ResolvedAt == nil {
+		t.Errorf("жалоба должна быть закрыта как ACTIONED: %+v", got)
+	}
+	if w := doRequest(t, r, http.MethodPost, reportPath+"/dismiss", gin.H{"outcome": "нет"}, admin); w.Code != http.StatusConflict {
+		t.Errorf("повторное решение: ожидали 409, получили %d", w.Code)
+	}
+
+	// Скрытое поле видит только владелец, отметка о проверке снята
+	if got := decodeProfile(t, doRequest(t, r, http.MethodGet, "/profiles/"+fx.Alice.UserID, nil, userHeader(fx.Bob.UserID))); got.Bio != "" || got.HiddenFields != nil || got.FlaggedAt != nil {
+		t.Errorf("другие не должны видеть скрытое описание и список скрытых полей: %+v", got)
+	}
+	if got := decodeProfile(t, doRequest(t, r, http.MethodGet, "/profiles/"+fx.Alice.UserID, nil, userHeader(fx.Alice.UserID))); got.Bio != "спам-ссылка" || !got.HiddenFields.Contains("bio") {
+		t.Errorf("владелец должен видеть своё описание: %+v", got)
+	}
+
+	// Автор жалобы узнаёт решение, но не модератора
+	w = doRequest(t, r, http.MethodGet, "/reports", nil, userHeader(fx.Bob.UserID))
+	var mine []models.Report
+	json.Unmarshal(w.Body.Bytes(), &mine)
+	if len(mine) != 1 || mine[0].Outcome != "Описание скрыто" || mine[0].ModeratorID != "" {
+		t.Errorf("автор жалобы должен видеть решение без модератора: %s", w.Body.String())
+	}
+	w = doRequest(t, r, http.MethodGet, "/notifications", nil, userHeader(fx.Bob.UserID))
+	var inbox models.NotificationPage
+	json.Unmarshal(w.Body.Bytes(), &inbox)
+	if len(inbox.Items) != 1 || inbox.Items[0].Type != models.EventReportResolved {
+		t.Fatalf("автор жалобы должен получить уведомление о решении: %s", w.Body.String())
+	}
+	var resolution models.ReportResolution
+	json.Unmarshal(inbox.Items[0].Payload, &resolution)
+	if resolution.ReportID != report.ID || resolution.Status != models.ReportActioned || resolution.Outcome != "Описание скрыто" {
+		t.Errorf("неверное уведомление о решении: %s", inbox.Items[0].Payload)
+	}
+
+	// Исправленное описание снова видно
+	doRequest(t, r, http.MethodPatch, "/profiles/"+fx.Alice.UserID, gin.H{
+		"userId": fx.Alice.UserID, "email": fx.Alice.Email, "bio": "Пишу рассказы",
+	}, userHeader(fx.Alice.UserID))
+	if got := decodeProfile(t, doRequest(t, r, http.MethodGet, "/profiles/"+fx.Alice.UserID, nil, userHeader(fx.Bob.UserID))); got.Bio != "Пишу рассказы" {
+		t.Errorf("исправленное поле должно стать видимым: %+v", got)
+	}
+}
+
+func TestDismissReport(t *testing.T) {
+	db := testutil.NewDB(t)
+	fx := testutil.LoadFixtures(t, db)
+	r := newTestRouter(db)
+
+	w := doRequest(t, r, http.MethodPost, "/profiles/"+fx.Alice.UserID+"/reports", gin.H{"category": "OTHER"}, userHeader(fx.Bob.UserID))
+	report := decodeReport(t, w.Body.Bytes())
+	reportPath := "/admin/reports/" + strconv.FormatUint(report.ID, 10)
+
+	if w := doRequest(t, r, http.MethodPost, reportPath+"/dismiss", gin.H{}, userHeader(fx.Admin.UserID)); w.Code != http.StatusBadRequest {
+		t.Errorf("без outcome: ожидали 400, получили %d", w.Code)
+	}
+	if w := doRequest(t, r, http.MethodPost, reportPath+"/dismiss", gin.H{"outcome": "Нарушений нет"}, userHeader(fx.Alice.UserID)); w.Code != http.StatusForbidden {
+		t.Errorf("не администратор: ожидали 403, получили %d", w.Code)
+	}
+	w = doRequest(t, r, http.MethodPost, reportPath+"/dismiss", gin.H{"outcome": "Нарушений нет"}, userHeader(fx.Admin.UserID))
+	if w.Code != http.StatusOK || decodeReport(t, w.Body.Bytes()).Status != models.ReportDismissed {
+		t.Fatalf("ожидали 200 и DISMISSED, получили %d: %s", w.Code, w.Body.String())
+	}
+	w = doRequest(t, r, http.MethodGet, "/notifications", nil, userHeader(fx.Bob.UserID))
+	var inbox models.NotificationPage
+	json.Unmarshal(w.Body.Bytes(), &inbox)
+	if len(inbox.Items) != 1 || inbox.Items[0].Type != models.EventReportResolved {
+		t.Errorf("автор жалобы должен получить уведомление об отклонении: %s", w.Body.String())
+	}
+
+	// После решения можно пожаловаться снова
+	if w := doRequest(t, r, http.MethodPost, "/profiles/"+fx.Alice.UserID+"/reports", gin.H{"category": "SPAM"}, userHeader(fx.Bob.UserID)); w.Code != http.StatusCreated {
+		t.Errorf("новая жалоба после решения: ожидали 201, получили %d", w.Code)
+	}
+}
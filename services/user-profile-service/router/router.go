@@ -1,8 +1,29 @@
 package router
 
 import (
-	"github.com/monst/story-craft/services/user-profile-service/handlers"
+	"log/slog"
+	"time"
+
+	"github.com/monst/story-craft/services/user-profile-service/config"
+	"github.com/monst/story-craft/services/user-profile-service/digest"
 	_ "github.com/monst/story-craft/services/user-profile-service/docs" // Импортируем Swagger документацию
+	"github.com/monst/story-craft/services/user-profile-service/genres"
+	"github.com/monst/story-craft/services/user-profile-service/handlers"
+	"github.com/monst/story-craft/services/user-profile-service/health"
+	"github.com/monst/story-craft/services/user-profile-service/idempotency"
+	"github.com/monst/story-craft/services/user-profile-service/jobs"
+	"github.com/monst/story-craft/services/user-profile-service/lifecycle"
+	"github.com/monst/story-craft/services/user-profile-service/logging"
+	"github.com/monst/story-craft/services/user-profile-service/mail"
+	"github.com/monst/story-craft/services/user-profile-service/metrics"
+	"github.com/monst/story-craft/services/user-profile-service/middleware"
+	"github.com/monst/story-craft/services/user-profile-service/notifications"
+	"github.com/monst/story-craft/services/user-profile-service/push"
+	"github.com/monst/story-craft/services/user-profile-service/ratelimit"
+	"github.com/monst/story-craft/services/user-profile-service/realtime"
+	"github.com/monst/story-craft/services/user-profile-service/screening"
+	"github.com/monst/story-craft/services/user-profile-service/tracing"
+	"github.com/monst/story-craft/services/user-profile-service/utils"
 
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
@@ -32,36 +53,217 @@ import (
 // @scheme bearer
 // @bearerFormat JWT
 
-func SetupRouter(db *gorm.DB) *gin.Engine {
-	r := gin.Default()
+// Dependencies — зависимости роутера, создаваемые при запуске сервиса.
+type Dependencies struct {
+	DB     *gorm.DB
+	Config *config.Config
+	// Lifecycle — менеджер фоновых задач; если задан, их состояние входит в проверку готовности.
+	Lifecycle *lifecycle.Manager
+	// Metrics — метрики Prometheus; если не заданы, создаются новые.
+	Metrics *metrics.Metrics
+	// Logger — базовый логгер запросов; если не задан, используется slog.Default().
+	Logger *slog.Logger
+	// RateLimitStore — хранилище корзин ограничения частоты; если не задано, используется память процесса.
+	RateLimitStore ratelimit.Store
+	// Classifier — внешний классификатор текста профилей; если не задан, работают только встроенные правила.
+	Classifier screening.Classifier
+	// Hub — раздача событий клиентам; события в него передаёт realtime.Listen.
+	// Если не задан, создаётся пустой хаб, и потоки событий получают только heartbeat.
+	Hub *realtime.Hub
+	// Genres — справочник жанров, обновляемый из story-service; если не задан, используются жанры из конфигурации.
+	Genres *genres.Catalog
+}
 
-	// Настройка CORS для API
-	r.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, DELETE")
+func SetupRouter(deps Dependencies) *gin.Engine {
+	db, cfg := deps.DB, deps.Config
+	m := deps.Metrics
+	if m == nil {
+		m = metrics.New()
+	}
+	logger := deps.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
+	r := gin.New()
+	if err := r.SetTrustedProxies(cfg.HTTP.TrustedProxies); err != nil {
+		logger.Error("Неверный список доверенных прокси", slog.Any("error", err))
+	}
+	// Логгер запроса создаётся после спана, чтобы в записи попал trace_id;
+	// Recovery идёт после AccessLog, чтобы паника попала в лог как ответ 500
+	r.Use(tracing.Middleware(), logging.RequestID(logger), logging.AccessLog(), gin.Recovery())
+	r.Use(m.Middleware(), middleware.Authenticate())
+
+	// Настройка CORS для API: политика и переопределения для маршрутов задаются в конфигурации
+	r.Use(middleware.CORS(cfg.CORS))
+
+	// Ограничение частоты: чтение и изменение профилей считаются в разных корзинах
+	var limiter *ratelimit.Limiter
+	if cfg.RateLimit.Enabled {
+		store := deps.RateLimitStore
+		if store == nil {
+			store = ratelimit.NewMemoryStore()
 		}
-		c.Next()
-	})
+		limiter = ratelimit.New(store, map[string]ratelimit.Limit{
+			"profiles_read":  {Rate: cfg.RateLimit.ReadRate, Burst: cfg.RateLimit.ReadBurst},
+			"profiles_write": {Rate: cfg.RateLimit.WriteRate, Burst: cfg.RateLimit.WriteBurst},
+		}, cfg.RateLimit.IPFactor, m)
+	}
+	readLimit, writeLimit := limiter.Middleware("profiles_read"), limiter.Middleware("profiles_write")
+
+	// Изменения из /profiles и /admin одинаково направляют последующие чтения в основную БД
+	recentWrites := utils.NewRecentWrites(cfg.Database.ReadYourWritesWindow)
+
+	// Проверка текста профилей на мат, ссылки и спам при создании и изменении
+	screener, err := screening.New(cfg.Screening, deps.Classifier, m)
+	if err != nil {
+		logger.Error("Неверная конфигурация проверки текста, проверка отключена", slog.Any("error", err))
+	}
+
+	reportHandler := handlers.NewReportHandler(db, recentWrites, m, cfg.Notifications.TTL)
+
+	// Письма ставятся в очередь в транзакциях изменений, отправляет их фоновая задача
+	var emailQueue *mail.Queue
+	if cfg.Email.Enabled {
+		emailQueue = mail.NewQueue(db, cfg.Email)
+	}
+	// Push ставятся в очередь так же; ключ VAPID уже проверен при загрузке конфигурации
+	var pushStore *push.Store
+	var vapid *push.VAPID
+	if cfg.WebPush.Enabled {
+		if vapid, err = push.NewVAPID(cfg.WebPush.VAPIDPrivateKey, cfg.WebPush.Subject); err != nil {
+			logger.Error("Неверный ключ VAPID, Web Push отключён", slog.Any("error", err))
+		} else {
+			pushStore = push.NewStore(db, cfg.WebPush)
+		}
+	}
 
 	// Группа API для работы с профилями
-	profiles := r.Group("/profiles")
+	// Любимые жанры выбираются из справочника story-service; без него — из PROFILE_GENRES
+	catalog := deps.Genres
+	if catalog == nil {
+		catalog = genres.NewCatalog(cfg.Profile)
+	}
+	profileHandler := handlers.NewProfileHandler(db, recentWrites, m, screener, emailQueue, cfg.Profile, catalog)
+	// Заблокированный или забаненный пользователь ничего не меняет от своего имени
+	active := handlers.NewRestrictionGuard(db).RequireActive
+	profiles := r.Group("/profiles", middleware.QueryDeadline(cfg.Database.QueryTimeout))
 	{
-		profileHandler := handlers.NewProfileHandler(db)
-		profiles.POST("/", profileHandler.CreateProfile)
-		profiles.GET("/:user_id", profileHandler.GetProfile)
-		profiles.PATCH("/:user_id", profileHandler.UpdateProfile)
-		profiles.DELETE("/:user_id", profileHandler.DeleteProfile)
+		// Повтор создания после таймаута с тем же Idempotency-Key получает исходный ответ вместо 409
+		idempotent := idempotency.Middleware(idempotency.NewDBStore(db), idempotency.Options{
+			TTL:         cfg.Idempotency.TTL,
+			LockTimeout: cfg.Idempotency.LockTimeout,
+		})
+		profiles.POST("/", writeLimit, idempotent, profileHandler.CreateProfile)
+		profiles.GET("/", readLimit, profileHandler.ListProfiles)
+		profiles.GET("/:user_id", readLimit, profileHandler.GetProfile)
+		profiles.PATCH("/:user_id", writeLimit, active, profileHandler.UpdateProfile)
+		profiles.DELETE("/:user_id", writeLimit, active, profileHandler.DeleteProfile)
+		profiles.POST("/:user_id/reports", writeLimit, active, reportHandler.CreateReport)
+		profiles.GET("/:user_id/notification-preferences", readLimit, profileHandler.GetNotificationPreferences)
+		profiles.PUT("/:user_id/notification-preferences", writeLimit, active, profileHandler.UpdateNotificationPreferences)
 	}
 
+	// Расписание дайджеста уведомлений; сводки собирает задача по расписанию
+	digestHandler := handlers.NewDigestHandler(digest.NewStore(db))
+	profiles.GET("/:user_id/digest", readLimit, digestHandler.GetDigestSettings)
+	profiles.PATCH("/:user_id/digest", writeLimit, active, digestHandler.UpdateDigestSettings)
+	r.GET("/reports", middleware.QueryDeadline(cfg.Database.QueryTimeout), readLimit, reportHandler.ListMyReports)
+
+	// Входящие уведомления текущего пользователя
+	notificationHandler := handlers.NewNotificationHandler(notifications.NewStore(db, emailQueue, pushStore), cfg.Notifications, m)
+	inbox := r.Group("/notifications", middleware.QueryDeadline(cfg.Database.QueryTimeout))
+	{
+		inbox.GET("", readLimit, notificationHandler.ListNotifications)
+		inbox.GET("/unread-count", readLimit, notificationHandler.UnreadCount)
+		inbox.POST("/read-all", writeLimit, active, notificationHandler.MarkAllRead)
+		inbox.POST("/:id/read", writeLimit, active, notificationHandler.MarkRead)
+	}
+
+	// Отписка по ссылке из письма: пользователь не входит в приложение, ссылка подписана
+	// Подтверждение и отмена смены адреса тоже идут по подписанным ссылкам из писем
+	var emailHandler *handlers.EmailHandler
+	if emailQueue != nil {
+		emailHandler = handlers.NewEmailHandler(emailQueue, cfg.Email, recentWrites)
+		email := r.Group("/email", middleware.QueryDeadline(cfg.Database.QueryTimeout), writeLimit)
+		email.POST("/unsubscribe", emailHandler.Unsubscribe)
+		email.POST("/confirm", emailHandler.ConfirmEmail)
+		email.POST("/revert", emailHandler.RevertEmail)
+		profiles.POST("/:user_id/email/verify", writeLimit, active, emailHandler.RequestVerification)
+	}
+
+	// Подписки браузеров на push по устройствам и ключ для pushManager.subscribe
+	if pushStore != nil {
+		pushHandler := handlers.NewPushHandler(pushStore, vapid, recentWrites)
+		r.GET("/push/vapid-public-key", pushHandler.GetVAPIDPublicKey)
+		profiles.POST("/:user_id/push-subscriptions", writeLimit, active, pushHandler.Subscribe)
+		profiles.GET("/:user_id/push-subscriptions", readLimit, pushHandler.ListSubscriptions)
+		profiles.DELETE("/:user_id/push-subscriptions/:id", writeLimit, active, pushHandler.RevokeSubscription)
+	}
+
+	// Потоки событий: новые уведомления и изменения профиля без опроса
+	hub := deps.Hub
+	if hub == nil {
+		hub = realtime.NewHub(cfg.Realtime.ReplayBuffer, cfg.Realtime.MaxConnectionsPerUser)
+	}
+	streamHandler := handlers.NewStreamHandler(hub, cfg.Realtime, middleware.OriginChecker(cfg.CORS, "/events"), m)
+	r.GET("/events", streamHandler.Stream)
+	if cfg.Realtime.WebSocket {
+		r.GET("/events/ws", streamHandler.WebSocket)
+	}
+
+	// Внутренний API для других сервисов: без ограничения частоты, по общему секрету
+	internal := r.Group("/internal", middleware.QueryDeadline(cfg.Database.QueryTimeout), middleware.RequireInternalToken(cfg.Notifications.IngestToken))
+	{
+		internal.POST("/notifications", notificationHandler.IngestNotifications)
+		internal.GET("/profiles/:user_id/email", profileHandler.GetEmailStatus)
+		if emailHandler != nil {
+			internal.POST("/email/bounces", emailHandler.RecordBounce)
+		}
+	}
+
+	// Модерация, управление ролями и журнал изменений: только для ADMIN
+	adminHandler := handlers.NewAdminHandler(db, recentWrites, m)
+	admin := r.Group("/admin", middleware.QueryDeadline(cfg.Database.QueryTimeout), adminHandler.RequireAdmin)
+	{
+		admin.GET("/profiles/flagged", readLimit, adminHandler.ListFlaggedProfiles)
+		admin.PUT("/profiles/:user_id/role", writeLimit, adminHandler.ChangeRole)
+		admin.POST("/profiles/:user_id/suspend", writeLimit, adminHandler.SuspendProfile)
+		admin.POST("/profiles/:user_id/ban", writeLimit, adminHandler.BanProfile)
+		admin.POST("/profiles/:user_id/restore", writeLimit, adminHandler.RestoreProfile)
+		admin.POST("/profiles/:user_id/reset", writeLimit, adminHandler.ResetProfileFields)
+		admin.DELETE("/profiles/:user_id/flag", writeLimit, adminHandler.ResolveFlag)
+		admin.GET("/reports", readLimit, reportHandler.ListReports)
+		admin.POST("/reports/:id/triage", writeLimit, reportHandler.TriageReport)
+		admin.POST("/reports/:id/action", writeLimit, reportHandler.ActionReport)
+		admin.POST("/reports/:id/dismiss", writeLimit, reportHandler.DismissReport)
+		admin.GET("/audit", readLimit, adminHandler.ListAuditLog)
+		admin.GET("/audit/verify", readLimit, adminHandler.VerifyAuditLog)
+
+		// Очередь фоновых задач: просмотр и повтор задач с ошибкой
+		jobHandler := handlers.NewJobHandler(jobs.NewQueue(db, cfg.Jobs))
+		admin.GET("/jobs", readLimit, jobHandler.ListJobs)
+		admin.GET("/jobs/:id", readLimit, jobHandler.GetJob)
+		admin.POST("/jobs/:id/retry", writeLimit, jobHandler.RetryJob)
+	}
+
+	// Проверки живости и готовности для healthcheck в docker-compose и оркестратора
+	checker := health.NewChecker(2*time.Second, 2*time.Second)
+	checker.Add("database", health.Database(db))
+	checker.Add("migrations", health.Migrations(db))
+	if deps.Lifecycle != nil {
+		checker.Add("workers", health.Workers(deps.Lifecycle))
+	}
+	healthHandler := handlers.NewHealthHandler(checker)
+	r.GET("/health/live", healthHandler.Live)
+	r.GET("/health/ready", healthHandler.Ready)
+
+	// Метрики Prometheus
+	r.GET("/metrics", gin.WrapH(m.Handler()))
+
 	// Роут для Swagger UI
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
-	
+
 	// Роут для JSON схемы Swagger
 	r.GET("/schema", func(c *gin.Context) {
 		c.Redirect(301, "/swagger/doc.json")
@@ -0,0 +1,344 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/monst/story-craft/services/user-profile-service/config"
+	"github.com/monst/story-craft/services/user-profile-service/health"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"github.com/monst/story-craft/services/user-profile-service/testutil"
+	"gorm.io/gorm"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	os.Exit(testutil.RunWithPostgres(m))
+}
+
+func newTestRouter(db *gorm.DB) *gin.Engine {
+	return SetupRouter(Dependencies{DB: db, Config: config.Default()})
+}
+
+func doRequest(t *testing.T, r http.Handler, method, path string, body any, headers map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatalf("не удалось закодировать тело запроса: %v", err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func userHeader(userID string) map[string]string {
+	return map[string]string{"x-user-object": `{"userId":"` + userID + `"}`}
+}
+
+func decodeProfile(t *testing.T, w *httptest.ResponseRecorder) models.Profile {
+	t.Helper()
+	var p models.Profile
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatalf("не удалось разобрать ответ %q: %v", w.Body.String(), err)
+	}
+	return p
+}
+
+func TestCreateProfile(t *testing.T) {
+	db := testutil.NewDB(t)
+	r := newTestRouter(db)
+	userID := uuid.NewString()
+
+	w := doRequest(t, r, http.MethodPost, "/profiles/", gin.H{
+		"userId":   userID,
+		"email":    "writer@example.com",
+		"username": "writer",
+	}, userHeader(userID))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("ожидали 201, получили %d: %s", w.Code, w.Body.String())
+	}
+	created := decodeProfile(t, w)
+	if created.ID == uuid.Nil {
+		t.Error("id профиля должен генерироваться базой через uuid_generate_v4")
+	}
+	if created.Role != models.RoleUser {
+		t.Errorf("роль по умолчанию: ожидали USER, получили %q", created.Role)
+	}
+
+	w = doRequest(t, r, http.MethodPost, "/profiles/", gin.H{
+		"userId":   userID,
+		"email":    "other@example.com",
+		"username": "other",
+	}, userHeader(userID))
+	if w.Code != http.StatusConflict {
+		t.Fatalf("повторное создание: ожидали 409, получили %d", w.Code)
+	}
+}
+
+func TestCreateProfileIdempotentRetry(t *testing.T) {
+	db := testutil.NewDB(t)
+	r := newTestRouter(db)
+	userID := uuid.NewString()
+	headers := userHeader(userID)
+	headers["Idempotency-Key"] = uuid.NewString()
+	body := gin.H{"userId": userID, "email": "retry@example.com", "username": "retry"}
+
+	first := doRequest(t, r, http.MethodPost, "/profiles/", body, headers)
+	retry := doRequest(t, r, http.MethodPost, "/profiles/", body, headers)
+	if first.Code != http.StatusCreated || retry.Code != http.StatusCreated {
+		t.Fatalf("повтор с тем же ключом должен вернуть 201, получили %d и %d: %s", first.Code, retry.Code, retry.Body.String())
+	}
+	if retry.Body.String() != first.Body.String() || retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("повтор должен вернуть исходный ответ: %s", retry.Body.String())
+	}
+
+	body["username"] = "other"
+	if w := doRequest(t, r, http.MethodPost, "/profiles/", body, headers); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("тот же ключ с другим телом: ожидали 422, получили %d", w.Code)
+	}
+}
+
+func TestCreateProfileConflicts(t *testing.T) {
+	db := testutil.NewDB(t)
+	fx := testutil.LoadFixtures(t, db)
+	r := newTestRouter(db)
+
+	tests := []struct {
+		name string
+		body gin.H
+	}{
+		{"занятый username", gin.H{"email": "new@example.com", "username": fx.Alice.Username}},
+		{"занятый email", gin.H{"email": fx.Bob.Email, "username": "newcomer"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID := uuid.NewString()
+			tt.body["userId"] = userID
+			w := doRequest(t, r, http.MethodPost, "/profiles/", tt.body, userHeader(userID))
+			if w.Code != http.StatusConflict {
+				t.Fatalf("ожидали 409, получили %d: %s", w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestCreateProfileValidation(t *testing.T) {
+	db := testutil.NewDB(t)
+	r := newTestRouter(db)
+
+	w := doRequest(t, r, http.MethodPost, "/profiles/", gin.H{
+		"userId":   "not-a-uuid",
+		"email":    "x@example.com",
+		"username": "x",
+	}, userHeader("not-a-uuid"))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("неверный user_id: ожидали 400, получили %d", w.Code)
+	}
+
+	w = doRequest(t, r, http.MethodPost, "/profiles/", gin.H{"email": "x@example.com"}, map[string]string{
+		"x-user-object": `{}`,
+	})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("без userId в заголовке: ожидали 400, получили %d", w.Code)
+	}
+}
+
+func TestGetProfile(t *testing.T) {
+	db := testutil.NewDB(t)
+	fx := testutil.LoadFixtures(t, db)
+	r := newTestRouter(db)
+
+	w := doRequest(t, r, http.MethodGet, "/profiles/"+fx.Alice.UserID, nil, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("ожидали 200, получили %d", w.Code)
+	}
+	if got := decodeProfile(t, w); got.Username != "alice" {
+		t.Errorf("ожидали alice, получили %q", got.Username)
+	}
+
+	w = doRequest(t, r, http.MethodGet, "/profiles/"+fx.Deleted.UserID, nil, nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("удалённый профиль: ожидали 404, получили %d", w.Code)
+	}
+
+	w = doRequest(t, r, http.MethodGet, "/profiles/"+uuid.NewString(), nil, map[string]string{
+		"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	})
+	if w.Code != http.StatusNotFound {
+		t.Errorf("несуществующий профиль: ожидали 404, получили %d", w.Code)
+	}
+	var errBody map[string]string
+	json.Unmarshal(w.Body.Bytes(), &errBody)
+	if errBody["trace_id"] != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("ответ с ошибкой должен содержать trace_id из traceparent: %s", w.Body.String())
+	}
+}
+
+func TestListProfiles(t *testing.T) {
+	db := testutil.NewDB(t)
+	fx := testutil.LoadFixtures(t, db)
+	r := newTestRouter(db)
+
+	w := doRequest(t, r, http.MethodGet, "/profiles/?user_ids="+fx.Alice.UserID+","+fx.Bob.UserID+","+fx.Deleted.UserID, nil, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("пакетная выборка: ожидали 200, получили %d: %s", w.Code, w.Body.String())
+	}
+	var batch []models.Profile
+	json.Unmarshal(w.Body.Bytes(), &batch)
+	if len(batch) != 2 {
+		t.Errorf("ожидали alice и bob без удалённого профиля, получили %d профилей", len(batch))
+	}
+
+	w = doRequest(t, r, http.MethodGet, "/profiles/?q=AL", nil, nil)
+	var found []models.Profile
+	json.Unmarshal(w.Body.Bytes(), &found)
+	if w.Code != http.StatusOK || len(found) != 1 || found[0].Username != "alice" {
+		t.Errorf("поиск по префиксу: код %d, результат %+v", w.Code, found)
+	}
+
+	w = doRequest(t, r, http.MethodGet, "/profiles/?q=%25", nil, nil)
+	json.Unmarshal(w.Body.Bytes(), &found)
+	if len(found) != 0 {
+		t.Errorf("символ %% должен экранироваться, найдено %d профилей", len(found))
+	}
+
+	for _, path := range []string{"/profiles/", "/profiles/?user_ids=bad-id", "/profiles/?q=a&limit=1000"} {
+		if w := doRequest(t, r, http.MethodGet, path, nil, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: ожидали 400, получили %d", path, w.Code)
+		}
+	}
+}
+
+func TestUpdateProfile(t *testing.T) {
+	db := testutil.NewDB(t)
+	fx := testutil.LoadFixtures(t, db)
+	r := newTestRouter(db)
+
+	alice := userHeader(fx.Alice.UserID)
+	path := "/profiles/" + fx.Alice.UserID
+
+	w := doRequest(t, r, http.MethodPatch, path, gin.H{
+		"email":       fx.Alice.Email,
+		"bio":         "Пишу фэнтези",
+		"displayName": "Алиса",
+	}, alice)
+	if w.Code != http.StatusOK {
+		t.Fatalf("ожидали 200, получили %d: %s", w.Code, w.Body.String())
+	}
+	got := decodeProfile(t, w)
+	if got.Bio != "Пишу фэнтези" || got.DisplayName != "Алиса" {
+		t.Errorf("поля не обновились: %+v", got)
+	}
+
+	// Без отправки писем новый адрес нечем подтвердить
+	w = doRequest(t, r, http.MethodPatch, path, gin.H{"email": "alice@new.example.com"}, alice)
+	if w.Code != http.StatusConflict {
+		t.Errorf("смена email без писем: ожидали 409, получили %d", w.Code)
+	}
+	var stored models.Profile
+	db.Where("user_id = ?", fx.Alice.UserID).First(&stored)
+	if stored.Email != fx.Alice.Email {
+		t.Errorf("адрес не должен меняться без подтверждения: %q", stored.Email)
+	}
+
+	// Чужой профиль менять нельзя: иначе можно подставить свой адрес и забрать аккаунт
+	for name, headers := range map[string]map[string]string{"аноним": nil, "другой пользователь": userHeader(fx.Bob.UserID)} {
+		want := http.StatusForbidden
+		if headers == nil {
+			want = http.StatusUnauthorized
+		}
+		w = doRequest(t, r, http.MethodPatch, path, gin.H{"email": fx.Alice.Email, "bio": "взлом"}, headers)
+		if w.Code != want {
+			t.Errorf("%s: ожидали %d, получили %d", name, want, w.Code)
+		}
+	}
+	db.Where("user_id = ?", fx.Alice.UserID).First(&stored)
+	if stored.Bio != "Пишу фэнтези" {
+		t.Errorf("чужое изменение не должно сохраниться: %q", stored.Bio)
+	}
+
+	missing := uuid.NewString()
+	w = doRequest(t, r, http.MethodPatch, "/profiles/"+missing, gin.H{
+		"email": "nobody@example.com",
+	}, userHeader(missing))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("несуществующий профиль: ожидали 404, получили %d", w.Code)
+	}
+}
+
+func TestDeleteProfile(t *testing.T) {
+	db := testutil.NewDB(t)
+	fx := testutil.LoadFixtures(t, db)
+	r := newTestRouter(db)
+
+	if w := doRequest(t, r, http.MethodDelete, "/profiles/"+fx.Bob.UserID, nil, userHeader(fx.Alice.UserID)); w.Code != http.StatusForbidden {
+		t.Errorf("чужой профиль: ожидали 403, получили %d", w.Code)
+	}
+	if w := doRequest(t, r, http.MethodDelete, "/profiles/"+fx.Bob.UserID, nil, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("без авторизации: ожидали 401, получили %d", w.Code)
+	}
+
+	w := doRequest(t, r, http.MethodDelete, "/profiles/"+fx.Bob.UserID, nil, userHeader(fx.Bob.UserID))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("ожидали 204, получили %d", w.Code)
+	}
+
+	w = doRequest(t, r, http.MethodGet, "/profiles/"+fx.Bob.UserID, nil, nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("после удаления: ожидали 404, получили %d", w.Code)
+	}
+
+	var count int64
+	db.Unscoped().Model(&models.Profile{}).Where("user_id = ?", fx.Bob.UserID).Count(&count)
+	if count != 1 {
+		t.Errorf("удаление должно быть мягким, записей в таблице: %d", count)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	db := testutil.NewDB(t)
+	r := newTestRouter(db)
+
+	if w := doRequest(t, r, http.MethodGet, "/health/live", nil, nil); w.Code != http.StatusOK {
+		t.Fatalf("live: ожидали 200, получили %d", w.Code)
+	}
+
+	w := doRequest(t, r, http.MethodGet, "/health/ready", nil, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("ready: ожидали 200, получили %d: %s", w.Code, w.Body.String())
+	}
+	var report health.Report
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"database", "migrations"} {
+		if report.Checks[name].Status != health.StatusOK {
+			t.Errorf("проверка %s: %+v", name, report.Checks[name])
+		}
+	}
+}
+
+func TestReadyFailsWithoutDatabase(t *testing.T) {
+	db := testutil.NewDB(t)
+	r := newTestRouter(db)
+	sqlDB, _ := db.DB()
+	sqlDB.Close()
+
+	w := doRequest(t, r, http.MethodGet, "/health/ready", nil, nil)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("ожидали 503, получили %d: %s", w.Code, w.Body.String())
+	}
+}
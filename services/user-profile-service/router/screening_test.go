@@ -0,0 +1,79 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/monst/story-craft/services/user-profile-service/handlers"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"github.com/monst/story-craft/services/user-profile-service/testutil"
+)
+
+func TestCreateProfileRejectsProfanity(t *testing.T) {
+	db := testutil.NewDB(t)
+	r := newTestRouter(db)
+	userID := uuid.NewString()
+
+	w := doRequest(t, r, http.MethodPost, "/profiles", gin.H{
+		"userId": userID, "username": "xуйло", "email": "new@example.com",
+	}, userHeader(userID))
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("ожидали 422, получили %d: %s", w.Code, w.Body.String())
+	}
+	var body struct {
+		Violations []handlers.Violation `json:"violations"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if len(body.Violations) != 1 || body.Violations[0].Field != "username" || body.Violations[0].Rules[0] != "profanity" {
+		t.Errorf("ожидали нарушение profanity в username: %s", w.Body.String())
+	}
+}
+
+func TestUpdateProfileScreening(t *testing.T) {
+	db := testutil.NewDB(t)
+	fx := testutil.LoadFixtures(t, db)
+	r := newTestRouter(db)
+	alice := userHeader(fx.Alice.UserID)
+	path := "/profiles/" + fx.Alice.UserID
+
+	// Повторы символов маскируются, реклама мессенджера задерживает описание до проверки модератором
+	w := doRequest(t, r, http.MethodPatch, path, gin.H{
+		"userId": fx.Alice.UserID, "email": fx.Alice.Email,
+		"displayName": "Алиса!!!!!!!!", "bio": "Пишите в телеграм, там новые рассказы",
+	}, alice)
+	if w.Code != http.StatusOK {
+		t.Fatalf("ожидали 200, получили %d: %s", w.Code, w.Body.String())
+	}
+	got := decodeProfile(t, w)
+	if got.DisplayName != "Алиса!!!!" {
+		t.Errorf("повтор должен быть сокращён, получили %q", got.DisplayName)
+	}
+	if !got.HeldFields.Contains("bio") || got.FlaggedAt == nil {
+		t.Errorf("описание должно ждать модератора: %+v", got)
+	}
+
+	if other := decodeProfile(t, doRequest(t, r, http.MethodGet, path, nil, userHeader(fx.Bob.UserID))); other.Bio != "" || other.HeldFields != nil || other.FlagReason != "" {
+		t.Errorf("задержанное описание и отметка проверки не должны быть видны другим: %+v", other)
+	}
+
+	// Модератор одобряет: отметка снята, описание видно всем
+	if w := doRequest(t, r, http.MethodDelete, "/admin/profiles/"+fx.Alice.UserID+"/flag", nil, userHeader(fx.Admin.UserID)); w.Code != http.StatusOK {
+		t.Fatalf("снятие отметки: ожидали 200, получили %d", w.Code)
+	}
+	if other := decodeProfile(t, doRequest(t, r, http.MethodGet, path, nil, userHeader(fx.Bob.UserID))); other.Bio != "Пишите в телеграм, там новые рассказы" {
+		t.Errorf("одобренное описание должно быть видно: %q", other.Bio)
+	}
+
+	w = doRequest(t, r, http.MethodPatch, path, gin.H{"userId": fx.Alice.UserID, "email": fx.Alice.Email, "bio": "иди на хуй"}, alice)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("мат: ожидали 422, получили %d", w.Code)
+	}
+	var profile models.Profile
+	db.Where("user_id = ?", fx.Alice.UserID).First(&profile)
+	if profile.Bio != "Пишите в телеграм, там новые рассказы" {
+		t.Errorf("отклонённое изменение не должно сохраниться: %q", profile.Bio)
+	}
+}
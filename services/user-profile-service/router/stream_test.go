@@ -0,0 +1,224 @@
+package router
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/monst/story-craft/services/user-profile-service/config"
+	"github.com/monst/story-craft/services/user-profile-service/middleware"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"github.com/monst/story-craft/services/user-profile-service/realtime"
+	"github.com/monst/story-craft/services/user-profile-service/testutil"
+	"golang.org/x/net/websocket"
+	"gorm.io/gorm"
+)
+
+// startListener запускает realtime.Listen и ждёт, пока LISTEN начнёт получать события.
+func startListener(t *testing.T, db *gorm.DB, hub *realtime.Hub) {
+	t.Helper()
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		realtime.Listen(sqlDB, hub)(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	probe, _, _ := hub.Subscribe("listener-probe", 0, false)
+	defer probe.Close()
+	deadline := time.After(10 * time.Second)
+	for {
+		if err := realtime.Publish(db, "listener-probe", realtime.EventHeartbeat, nil); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-probe.Events():
+			return
+		case <-time.After(100 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("LISTEN не начал получать события")
+		}
+	}
+}
+
+type sseEvent struct {
+	id, event, data string
+}
+
+// readSSE читает события потока, пропуская служебные строки и пинги.
+func readSSE(t *testing.T, events <-chan sseEvent, want string) sseEvent {
+	t.Helper()
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				t.Fatalf("поток закрыт до события %s", want)
+			}
+			if e.event == want {
+				return e
+			}
+		case <-timeout:
+			t.Fatalf("не дождались события %s", want)
+		}
+	}
+}
+
+func openSSE(t *testing.T, url string, headers map[string]string) <-chan sseEvent {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		t.Fatalf("ожидали поток событий, получили %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	events := make(chan sseEvent, 16)
+	go func() {
+		defer close(events)
+		defer resp.Body.Close()
+		var e sseEvent
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+				if e.event != "" {
+					events <- e
+				}
+				e = sseEvent{}
+			case strings.HasPrefix(line, "id:"):
+				e.id = strings.TrimPrefix(line, "id:")
+			case strings.HasPrefix(line, "event:"):
+				e.event = strings.TrimPrefix(line, "event:")
+			case strings.HasPrefix(line, "data:"):
+				e.data = strings.TrimPrefix(line, "data:")
+			}
+		}
+	}()
+	return events
+}
+
+func TestEventStream(t *testing.T) {
+	db := testutil.NewDB(t)
+	fx := testutil.LoadFixtures(t, db)
+	cfg := config.Default()
+	cfg.Notifications.IngestToken = testIngestToken
+	hub := realtime.NewHub(cfg.Realtime.ReplayBuffer, cfg.Realtime.MaxConnectionsPerUser)
+	startListener(t, db, hub)
+	r := SetupRouter(Dependencies{DB: db, Config: cfg, Hub: hub})
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+
+	alice := userHeader(fx.Alice.UserID)
+	events := openSSE(t, srv.URL+"/events", alice)
+
+	w := doRequest(t, r, http.MethodPatch, "/profiles/"+fx.Alice.UserID, gin.H{
+		"email": fx.Alice.Email,
+		"bio":   "Пишу фэнтези",
+	}, alice)
+	if w.Code != http.StatusOK {
+		t.Fatalf("ожидали 200, получили %d: %s", w.Code, w.Body.String())
+	}
+	profile := readSSE(t, events, realtime.EventProfile)
+	if !strings.Contains(profile.data, "Пишу фэнтези") || profile.id == "" {
+		t.Errorf("неверное событие профиля: %+v", profile)
+	}
+
+	internal := map[string]string{middleware.InternalTokenHeader: testIngestToken}
+	body := gin.H{"type": models.EventNewFollower, "recipient_ids": []string{fx.Alice.UserID}, "payload": gin.H{"follower_id": fx.Bob.UserID}}
+	if w := doRequest(t, r, http.MethodPost, "/internal/notifications", body, internal); w.Code != http.StatusOK {
+		t.Fatalf("ожидали 200, получили %d: %s", w.Code, w.Body.String())
+	}
+	note := readSSE(t, events, realtime.EventNotification)
+	if !strings.Contains(note.data, fx.Bob.UserID) {
+		t.Errorf("неверное событие уведомления: %+v", note)
+	}
+
+	// После переподключения с Last-Event-ID приходит пропущенное уведомление
+	resumed := openSSE(t, srv.URL+"/events", map[string]string{
+		"x-user-object": alice["x-user-object"],
+		"Last-Event-ID": profile.id,
+	})
+	if replayed := readSSE(t, resumed, realtime.EventNotification); replayed.id != note.id {
+		t.Errorf("ожидали повтор события %s, получили %+v", note.id, replayed)
+	}
+
+	cases := []struct {
+		name    string
+		headers map[string]string
+		want    int
+	}{
+		{name: "без авторизации", want: http.StatusUnauthorized},
+		{name: "неверный Last-Event-ID", headers: map[string]string{"x-user-object": alice["x-user-object"], "Last-Event-ID": "abc"}, want: http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if w := doRequest(t, r, http.MethodGet, "/events", nil, tc.headers); w.Code != tc.want {
+				t.Errorf("ожидали %d, получили %d: %s", tc.want, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestEventWebSocket(t *testing.T) {
+	db := testutil.NewDB(t)
+	fx := testutil.LoadFixtures(t, db)
+	cfg := config.Default()
+	cfg.Realtime.WebSocket = true
+	cfg.CORS.AllowedOrigins = []string{"https://story-craft.io"}
+	hub := realtime.NewHub(cfg.Realtime.ReplayBuffer, cfg.Realtime.MaxConnectionsPerUser)
+	startListener(t, db, hub)
+	srv := httptest.NewServer(SetupRouter(Dependencies{DB: db, Config: cfg, Hub: hub}))
+	t.Cleanup(srv.Close)
+
+	dial := func(origin string) (*websocket.Conn, error) {
+		wsCfg, err := websocket.NewConfig("ws"+strings.TrimPrefix(srv.URL, "http")+"/events/ws", origin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wsCfg.Header.Set("x-user-object", userHeader(fx.Alice.UserID)["x-user-object"])
+		return websocket.DialConfig(wsCfg)
+	}
+
+	if _, err := dial("https://evil.example"); err == nil {
+		t.Fatal("соединение с чужого origin должно отклоняться")
+	}
+
+	ws, err := dial("https://story-craft.io")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	if err := realtime.Publish(db, fx.Alice.UserID, realtime.EventProfile, realtime.ProfileChange{Action: "profile.updated"}); err != nil {
+		t.Fatal(err)
+	}
+	ws.SetReadDeadline(time.Now().Add(10 * time.Second))
+	var e realtime.Event
+	if err := websocket.JSON.Receive(ws, &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Type != realtime.EventProfile || e.ID == 0 || e.UserID != "" {
+		t.Errorf("неверное событие: %+v", e)
+	}
+}
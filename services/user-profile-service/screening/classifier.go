@@ -0,0 +1,61 @@
+package screening
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Classification — ответ внешнего классификатора.
+type Classification struct {
+	Flagged bool    `json:"flagged"`
+	Label   string  `json:"label"`
+	Score   float64 `json:"score"`
+}
+
+// Classifier — внешняя проверка текста, например ML-модель токсичности.
+// Действие при срабатывании задаётся в конфигурации, как и для встроенных правил.
+type Classifier interface {
+	Classify(ctx context.Context, field, text string) (Classification, error)
+}
+
+// HTTPClassifier отправляет текст POST-запросом {"field": ..., "text": ...} и ожидает
+// в ответ JSON Classification.
+type HTTPClassifier struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPClassifier создаёт классификатор с ограничением времени ответа timeout.
+func NewHTTPClassifier(url string, timeout time.Duration) *HTTPClassifier {
+	return &HTTPClassifier{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+func (c *HTTPClassifier) Classify(ctx context.Context, field, text string) (Classification, error) {
+	var result Classification
+	body, err := json.Marshal(map[string]string{"field": field, "text": text})
+	if err != nil {
+		return result, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return result, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return result, fmt.Errorf("классификатор ответил %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return result, fmt.Errorf("не удалось разобрать ответ классификатора: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,230 @@
+package screening
+
+import (
+	"bufio"
+	"embed"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//go:embed wordlists/*.txt
+var wordlists embed.FS
+
+// wordPattern — строка словаря: точное слово, начало слова (stem*) или часть слова (*root*).
+type wordPattern struct {
+	word           string
+	prefix, suffix bool
+}
+
+func (p wordPattern) matches(token string) bool {
+	switch {
+	case p.prefix && p.suffix:
+		return strings.Contains(token, p.word)
+	case p.suffix:
+		return strings.HasPrefix(token, p.word)
+	default:
+		return token == p.word
+	}
+}
+
+func parseWord(line string) (wordPattern, bool) {
+	line = strings.ToLower(strings.TrimSpace(line))
+	if line == "" || strings.HasPrefix(line, "#") {
+		return wordPattern{}, false
+	}
+	p := wordPattern{word: line}
+	if strings.HasPrefix(p.word, "*") {
+		p.prefix, p.word = true, p.word[1:]
+	}
+	if strings.HasSuffix(p.word, "*") {
+		p.suffix, p.word = true, p.word[:len(p.word)-1]
+	}
+	// «*root» без звёздочки в конце читаем как «*root*»: окончания в словаре не перечисляются
+	if p.prefix {
+		p.suffix = true
+	}
+	return p, p.word != ""
+}
+
+// ProfanityRule ищет слова из встроенных словарей ru и en и дополнительных слов из конфигурации.
+type ProfanityRule struct {
+	patterns []wordPattern
+}
+
+// NewProfanityRule загружает встроенные словари и добавляет extra в том же формате.
+func NewProfanityRule(extra []string) *ProfanityRule {
+	r := &ProfanityRule{}
+	for _, name := range []string{"wordlists/ru.txt", "wordlists/en.txt"} {
+		f, err := wordlists.Open(name)
+		if err != nil {
+			panic(fmt.Sprintf("screening: словарь %s не встроен: %v", name, err))
+		}
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			if p, ok := parseWord(sc.Text()); ok {
+				r.patterns = append(r.patterns, p)
+			}
+		}
+		f.Close()
+	}
+	for _, line := range extra {
+		if p, ok := parseWord(line); ok {
+			r.patterns = append(r.patterns, p)
+		}
+	}
+	return r
+}
+
+func (r *ProfanityRule) Name() string { return "profanity" }
+
+func (r *ProfanityRule) Match(text string) []Match {
+	var matches []Match
+	for _, tok := range tokenize(text) {
+		normalized := normalizeToken(tok.text)
+		for _, p := range r.patterns {
+			if p.matches(normalized) {
+				matches = append(matches, Match{Start: tok.start, End: tok.end, Replacement: strings.Repeat("*", utf8.RuneCountInString(tok.text))})
+				break
+			}
+		}
+	}
+	return matches
+}
+
+type token struct {
+	text       string
+	start, end int
+}
+
+// tokenize делит текст на слова. Цифры и @ $ считаются частью слова, чтобы ловить замены вроде «sh1t».
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range text {
+		inWord := unicode.IsLetter(r) || unicode.IsDigit(r) || r == '@' || r == '$'
+		switch {
+		case inWord && start < 0:
+			start = i
+		case !inWord && start >= 0:
+			tokens = append(tokens, token{text: text[start:i], start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{text: text[start:], start: start, end: len(text)})
+	}
+	return tokens
+}
+
+// Замены для слов с кириллицей: латинские буквы и цифры, похожие на русские буквы.
+var toCyrillic = strings.NewReplacer(
+	"a", "а", "b", "в", "c", "с", "e", "е", "h", "н", "k", "к", "m", "м", "o", "о",
+	"p", "р", "t", "т", "x", "х", "y", "у", "u", "и",
+	"0", "о", "3", "з", "4", "ч", "6", "б", "@", "а",
+)
+
+// Замены для слов на латинице.
+var leet = strings.NewReplacer(
+	"0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s",
+)
+
+// normalizeToken приводит слово к нижнему регистру и раскрывает замены символов.
+func normalizeToken(s string) string {
+	s = strings.ToLower(s)
+	for _, r := range s {
+		if unicode.Is(unicode.Cyrillic, r) {
+			return toCyrillic.Replace(s)
+		}
+	}
+	return leet.Replace(s)
+}
+
+// RegexpRule ищет совпадения с регулярными выражениями и заменяет их на replacement.
+type RegexpRule struct {
+	name        string
+	patterns    []*regexp.Regexp
+	replacement string
+}
+
+func (r *RegexpRule) Name() string { return r.name }
+
+func (r *RegexpRule) Match(text string) []Match {
+	var matches []Match
+	for _, re := range r.patterns {
+		for _, loc := range re.FindAllStringIndex(text, -1) {
+			matches = append(matches, Match{Start: loc[0], End: loc[1], Replacement: r.replacement})
+		}
+	}
+	return matches
+}
+
+// urlPatterns ловят ссылки со схемой, www и голые домены в популярных зонах.
+var urlPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+`),
+	regexp.MustCompile(`(?i)\b[a-z0-9][a-z0-9-]*(?:\.[a-z0-9-]+)*\.(?:com|net|org|ru|su|io|me|info|biz|xyz|top|club|site|online|store|link|click|ly|gg|cc|tk)\b(?:/\S*)?`),
+	regexp.MustCompile(`(?i)[а-яё0-9][а-яё0-9-]*\.рф(?:/\S*)?`),
+}
+
+// NewURLRule создаёт правило для ссылок.
+func NewURLRule() *RegexpRule {
+	return &RegexpRule{name: "urls", patterns: urlPatterns, replacement: "[ссылка скрыта]"}
+}
+
+// spamPatterns — типичные признаки рекламы: азартные игры, заработок, приглашения
+// написать в мессенджер, телефоны и адреса почты.
+var spamPatterns = []string{
+	`(?i)казино|casino|букмекер|ставки на спорт|viagra|виагр`,
+	`(?i)заработ\p{L}*\s+от\s+\d+|доход\p{L}*\s+от\s+\d+|earn\s+\$?\d+|make\s+money|free\s+money|крипт\p{L}*\s+инвест\p{L}*|crypto\s+invest\p{L}*`,
+	`(?i)пиш(?:и|ите)\s+(?:в|мне\s+в)\s+(?:лс|личку|телег\p{L}*|тг|whatsapp|ватсап\p{L}*|вотсап\p{L}*)|\b(?:telegram|whatsapp|viber)\s*:`,
+	`\+\d[\d\-\s()]{8,}\d|\b[78][\s\-(]*9\d{2}[\s\-)]*\d{3}[\s\-]?\d{2}[\s\-]?\d{2}\b`,
+	`[\p{L}\d._%+-]+@[\p{L}\d-]+\.[\p{L}\d.-]+`,
+}
+
+// NewSpamRule создаёт правило для спама; extra дополняет встроенные выражения.
+func NewSpamRule(extra []string) (*RegexpRule, error) {
+	r := &RegexpRule{name: "spam", replacement: "***"}
+	for _, p := range append(spamPatterns[:len(spamPatterns):len(spamPatterns)], extra...) {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("неверное выражение для спама %q: %w", p, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// RepeatedCharsRule находит один и тот же символ подряд больше max раз («ааааа», «!!!!!!»).
+// Цифры и пробелы не учитываются. При маскировании повтор сокращается до max символов.
+type RepeatedCharsRule struct {
+	max int
+}
+
+func NewRepeatedCharsRule(max int) *RepeatedCharsRule {
+	return &RepeatedCharsRule{max: max}
+}
+
+func (r *RepeatedCharsRule) Name() string { return "repeated_chars" }
+
+func (r *RepeatedCharsRule) Match(text string) []Match {
+	var matches []Match
+	var prev rune
+	runStart, runLen := 0, 0
+	flush := func(end int) {
+		if runLen > r.max {
+			matches = append(matches, Match{Start: runStart, End: end, Replacement: strings.Repeat(string(prev), r.max)})
+		}
+	}
+	for i, c := range text {
+		if c == prev && !unicode.IsDigit(c) && !unicode.IsSpace(c) {
+			runLen++
+			continue
+		}
+		flush(i)
+		prev, runStart, runLen = c, i, 1
+	}
+	flush(len(text))
+	return matches
+}
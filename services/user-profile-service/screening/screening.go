@@ -0,0 +1,240 @@
+// Package screening проверяет текст профиля перед сохранением: мат, ссылки, спам,
+// повторяющиеся символы и, если подключён, внешний классификатор. Для каждого правила
+// в конфигурации задаётся действие: пропустить, замаскировать, отправить на проверку или отклонить.
+package screening
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+
+	"github.com/monst/story-craft/services/user-profile-service/config"
+	"github.com/monst/story-craft/services/user-profile-service/logging"
+	"github.com/monst/story-craft/services/user-profile-service/metrics"
+)
+
+// Action — что делать с текстом, нарушившим правило. Действия упорядочены по строгости.
+type Action string
+
+const (
+	// ActionOff отключает правило.
+	ActionOff Action = "off"
+	// ActionAllow — нарушений нет.
+	ActionAllow Action = "allow"
+	// ActionRedact маскирует найденный фрагмент и сохраняет текст.
+	ActionRedact Action = "redact"
+	// ActionHold сохраняет текст, но показывает его только владельцу до проверки модератором.
+	ActionHold Action = "hold"
+	// ActionReject отклоняет изменение.
+	ActionReject Action = "reject"
+)
+
+var severity = map[Action]int{ActionOff: 0, ActionAllow: 0, ActionRedact: 1, ActionHold: 2, ActionReject: 3}
+
+// ParseAction проверяет название действия из конфигурации.
+func ParseAction(s string) (Action, error) {
+	a := Action(s)
+	if _, ok := severity[a]; !ok || a == ActionAllow {
+		return "", fmt.Errorf("неизвестное действие %q, ожидалось off, redact, hold или reject", s)
+	}
+	return a, nil
+}
+
+// Match — найденный правилом фрагмент текста: байтовые границы и замена для маскирования.
+type Match struct {
+	Start, End  int
+	Replacement string
+}
+
+// Rule — правило проверки текста.
+type Rule interface {
+	Name() string
+	Match(text string) []Match
+}
+
+// FieldVerdict — итог проверки одного поля.
+type FieldVerdict struct {
+	Action Action
+	// Rules — сработавшие правила.
+	Rules []string
+	// Text — текст после маскирования; совпадает с исходным, если маскировать было нечего.
+	Text string
+}
+
+// Verdict — итог проверки всех полей. Action — самое строгое действие среди полей.
+type Verdict struct {
+	Action Action
+	Fields map[string]FieldVerdict
+}
+
+// FieldsWith возвращает поля, для которых итоговое действие равно a, по алфавиту.
+func (v Verdict) FieldsWith(a Action) []string {
+	var fields []string
+	for name, fv := range v.Fields {
+		if fv.Action == a {
+			fields = append(fields, name)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// RulesFor возвращает сработавшие правила для полей с действием a.
+func (v Verdict) RulesFor(a Action) []string {
+	var rules []string
+	for _, name := range v.FieldsWith(a) {
+		for _, rule := range v.Fields[name].Rules {
+			if !slices.Contains(rules, rule) {
+				rules = append(rules, rule)
+			}
+		}
+	}
+	return rules
+}
+
+type configuredRule struct {
+	rule   Rule
+	action Action
+	// fields — поля, к которым применяется правило; nil — ко всем, пустой список — ни к одному.
+	fields []string
+}
+
+func (r configuredRule) appliesTo(field string) bool {
+	return r.fields == nil || slices.Contains(r.fields, field)
+}
+
+// Screener применяет правила к полям профиля. Нулевой *Screener пропускает любой текст.
+type Screener struct {
+	rules            []configuredRule
+	classifier       Classifier
+	classifierAction Action
+	metrics          *metrics.Metrics
+}
+
+// New собирает правила из конфигурации. classifier может быть nil.
+func New(cfg config.ScreeningConfig, classifier Classifier, m *metrics.Metrics) (*Screener, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	s := &Screener{classifier: classifier, metrics: m}
+
+	spam, err := NewSpamRule(cfg.SpamPatterns)
+	if err != nil {
+		return nil, err
+	}
+	rules := []struct {
+		rule   Rule
+		action string
+		fields []string
+	}{
+		{NewProfanityRule(cfg.ExtraWords), cfg.ProfanityAction, nil},
+		{NewURLRule(), cfg.URLAction, append([]string{}, cfg.URLFields...)},
+		{spam, cfg.SpamAction, nil},
+		{NewRepeatedCharsRule(cfg.MaxRepeat), cfg.RepeatedCharsAction, nil},
+	}
+	for _, r := range rules {
+		action, err := ParseAction(r.action)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", r.rule.Name(), err)
+		}
+		if action != ActionOff {
+			s.rules = append(s.rules, configuredRule{rule: r.rule, action: action, fields: r.fields})
+		}
+	}
+
+	if s.classifierAction, err = ParseAction(cfg.ClassifierAction); err != nil {
+		return nil, fmt.Errorf("classifier: %w", err)
+	}
+	if s.classifierAction == ActionRedact {
+		return nil, fmt.Errorf("classifier: действие redact не поддерживается, классификатор не указывает фрагмент")
+	}
+	return s, nil
+}
+
+// Screen проверяет поля (имя поля → текст). Пустые поля пропускаются.
+// Ошибка классификатора не останавливает проверку: встроенные правила продолжают работать.
+func (s *Screener) Screen(ctx context.Context, fields map[string]string) Verdict {
+	verdict := Verdict{Action: ActionAllow, Fields: make(map[string]FieldVerdict, len(fields))}
+	if s == nil {
+		return verdict
+	}
+
+	for name, text := range fields {
+		if text == "" {
+			continue
+		}
+		fv := FieldVerdict{Action: ActionAllow, Text: text}
+		var redactions []Match
+		for _, r := range s.rules {
+			if !r.appliesTo(name) {
+				continue
+			}
+			matches := r.rule.Match(text)
+			if len(matches) == 0 {
+				continue
+			}
+			fv.add(r.rule.Name(), r.action)
+			s.count(r.rule.Name(), r.action)
+			if r.action == ActionRedact {
+				redactions = append(redactions, matches...)
+			}
+		}
+		fv.Text = redact(text, redactions)
+
+		if s.classifier != nil && s.classifierAction != ActionOff {
+			flagged, err := s.classifier.Classify(ctx, name, fv.Text)
+			if err != nil {
+				logging.FromContext(ctx).WarnContext(ctx, "Внешний классификатор недоступен", slog.Any("error", err))
+			} else if flagged.Flagged {
+				fv.add("classifier:"+flagged.Label, s.classifierAction)
+				s.count("classifier", s.classifierAction)
+			}
+		}
+
+		if fv.Action != ActionAllow {
+			verdict.Fields[name] = fv
+			if severity[fv.Action] > severity[verdict.Action] {
+				verdict.Action = fv.Action
+			}
+		}
+	}
+	return verdict
+}
+
+func (s *Screener) count(rule string, action Action) {
+	if s.metrics != nil {
+		s.metrics.ScreeningFinding(rule, string(action))
+	}
+}
+
+func (fv *FieldVerdict) add(rule string, action Action) {
+	fv.Rules = append(fv.Rules, rule)
+	if severity[action] > severity[fv.Action] {
+		fv.Action = action
+	}
+}
+
+// redact заменяет найденные фрагменты. Пересекающиеся фрагменты объединяются,
+// замена берётся у первого из них.
+func redact(text string, matches []Match) string {
+	if len(matches) == 0 {
+		return text
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Start < matches[j].Start })
+	var out []byte
+	pos := 0
+	for _, m := range matches {
+		if m.Start < pos {
+			if m.End > pos {
+				pos = m.End
+			}
+			continue
+		}
+		out = append(out, text[pos:m.Start]...)
+		out = append(out, m.Replacement...)
+		pos = m.End
+	}
+	return string(append(out, text[pos:]...))
+}
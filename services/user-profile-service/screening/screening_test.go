@@ -0,0 +1,173 @@
+package screening
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/monst/story-craft/services/user-profile-service/config"
+)
+
+func newScreener(t *testing.T, classifier Classifier, mutate func(*config.ScreeningConfig)) *Screener {
+	t.Helper()
+	cfg := config.Default().Screening
+	if mutate != nil {
+		mutate(&cfg)
+	}
+	s, err := New(cfg, classifier, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return s
+}
+
+func TestProfanityRule(t *testing.T) {
+	rule := NewProfanityRule([]string{"редиска*"})
+	cases := map[string]bool{
+		"Привет, я пишу фэнтези":      false,
+		"ребалансировка и скупщик":    false,
+		"Shitake mushrooms":           false,
+		"ты сука":                     true,
+		"СУКА!":                       true,
+		"пиздец":                      true,
+		"xуйня":                       true, // латинская x
+		"what the fuck":               true,
+		"sh1t happens":                true,
+		"motherfucker":                true,
+		"нехорошая редиска":           true,
+		"Блядь":                       true,
+		"бляха-муха без мата":         false,
+		"classic assassin literature": false,
+	}
+	for text, want := range cases {
+		if got := len(rule.Match(text)) > 0; got != want {
+			t.Errorf("%q: ожидали %v, получили %v", text, want, got)
+		}
+	}
+}
+
+func TestRulesRedact(t *testing.T) {
+	s := newScreener(t, nil, func(c *config.ScreeningConfig) {
+		c.ProfanityAction, c.URLAction, c.SpamAction, c.RepeatedCharsAction = "redact", "redact", "redact", "redact"
+	})
+	cases := map[string]string{
+		"ну ты сука":                           "ну ты ****",
+		"мой сайт https://spam.example/x":      "мой сайт [ссылка скрыта]",
+		"заходи на shop.ru/sale":               "заходи на [ссылка скрыта]",
+		"пишите в телеграм":                    "***",
+		"звоните +7 (999) 123-45-67":           "звоните ***",
+		"Урааааааа!!!!!!!":                     "Ураааа!!!!",
+		"родился 01.01.2000, пишу с 2010 года": "родился 01.01.2000, пишу с 2010 года",
+	}
+	for in, want := range cases {
+		v := s.Screen(context.Background(), map[string]string{"display_name": in})
+		got := in
+		if fv, ok := v.Fields["display_name"]; ok {
+			got = fv.Text
+		}
+		if got != want {
+			t.Errorf("%q: ожидали %q, получили %q", in, want, got)
+		}
+	}
+}
+
+func TestScreenActions(t *testing.T) {
+	s := newScreener(t, nil, nil)
+	v := s.Screen(context.Background(), map[string]string{
+		"username":     "writer",
+		"display_name": "Вася ааааааа",
+		"bio":          "Пишите в телеграм, ссылка: t.me/channel",
+	})
+	if v.Action != ActionHold {
+		t.Fatalf("ожидали hold, получили %s", v.Action)
+	}
+	if got := v.FieldsWith(ActionHold); !reflect.DeepEqual(got, []string{"bio"}) {
+		t.Errorf("задержано должно быть только описание: %v", got)
+	}
+	if fv := v.Fields["display_name"]; fv.Action != ActionRedact || fv.Text != "Вася аааа" {
+		t.Errorf("повтор должен быть сокращён: %+v", fv)
+	}
+	if _, ok := v.Fields["username"]; ok {
+		t.Error("чистое поле не должно попадать в итог")
+	}
+
+	// Ссылки ищутся только в полях из URLFields: ссылки описания входят в его разметку
+	v = s.Screen(context.Background(), map[string]string{
+		"display_name": "Алиса alice.example.com",
+		"bio":          "Мой сайт: [alice.example.com](https://alice.example.com)",
+	})
+	if got := v.FieldsWith(ActionHold); !reflect.DeepEqual(got, []string{"display_name"}) {
+		t.Errorf("ссылка задерживает только отображаемое имя: %v", got)
+	}
+
+	if v := s.Screen(context.Background(), map[string]string{"bio": "иди на хуй"}); v.Action != ActionReject {
+		t.Errorf("мат по умолчанию отклоняется, получили %s", v.Action)
+	}
+
+	var disabled *Screener
+	if v := disabled.Screen(context.Background(), map[string]string{"bio": "сука"}); v.Action != ActionAllow {
+		t.Errorf("выключенная проверка пропускает всё, получили %s", v.Action)
+	}
+}
+
+type fakeClassifier struct {
+	result Classification
+	err    error
+	calls  int
+}
+
+func (f *fakeClassifier) Classify(context.Context, string, string) (Classification, error) {
+	f.calls++
+	return f.result, f.err
+}
+
+func TestClassifierHook(t *testing.T) {
+	fc := &fakeClassifier{result: Classification{Flagged: true, Label: "toxic", Score: 0.97}}
+	s := newScreener(t, fc, func(c *config.ScreeningConfig) { c.ClassifierAction = "reject" })
+
+	v := s.Screen(context.Background(), map[string]string{"bio": "вежливый текст"})
+	if v.Action != ActionReject || !reflect.DeepEqual(v.Fields["bio"].Rules, []string{"classifier:toxic"}) {
+		t.Errorf("ожидали отказ по классификатору: %+v", v)
+	}
+
+	// Недоступный классификатор не мешает сохранению
+	fc.err = errors.New("timeout")
+	if v := s.Screen(context.Background(), map[string]string{"bio": "вежливый текст"}); v.Action != ActionAllow {
+		t.Errorf("ошибка классификатора не должна блокировать текст: %s", v.Action)
+	}
+
+	if _, err := New(config.ScreeningConfig{Enabled: true, ProfanityAction: "off", URLAction: "off", SpamAction: "off", RepeatedCharsAction: "off", ClassifierAction: "redact", MaxRepeat: 4}, fc, nil); err == nil {
+		t.Error("redact для классификатора не поддерживается")
+	}
+}
+
+func TestHTTPClassifier(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		json.NewDecoder(r.Body).Decode(&req)
+		json.NewEncoder(w).Encode(Classification{Flagged: req["text"] == "плохо", Label: "insult", Score: 0.9})
+	}))
+	defer srv.Close()
+
+	c := NewHTTPClassifier(srv.URL, time.Second)
+	got, err := c.Classify(context.Background(), "bio", "плохо")
+	if err != nil || !got.Flagged || got.Label != "insult" {
+		t.Errorf("ожидали срабатывание, получили %+v, %v", got, err)
+	}
+	if got, err := c.Classify(context.Background(), "bio", "хорошо"); err != nil || got.Flagged {
+		t.Errorf("ожидали чистый текст, получили %+v, %v", got, err)
+	}
+
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer broken.Close()
+	if _, err := NewHTTPClassifier(broken.URL, time.Second).Classify(context.Background(), "bio", "x"); err == nil {
+		t.Error("ожидали ошибку при ответе 503")
+	}
+}
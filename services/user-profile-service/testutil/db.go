@@ -0,0 +1,85 @@
+package testutil
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/monst/story-craft/services/user-profile-service/config"
+	"github.com/monst/story-craft/services/user-profile-service/utils"
+	"gorm.io/gorm"
+)
+
+// NewDB возвращает подключение к отдельной схеме с применёнными миграциями.
+// Схема удаляется после завершения теста, поэтому тесты не видят данных друг друга
+// и могут выполняться параллельно.
+func NewDB(t testing.TB) *gorm.DB {
+	t.Helper()
+	requirePostgres(t)
+
+	schema := "test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+
+	admin, err := open(baseURL.String())
+	if err != nil {
+		t.Fatalf("не удалось подключиться к тестовому PostgreSQL: %v", err)
+	}
+	if err := admin.Exec(fmt.Sprintf(`CREATE SCHEMA %q`, schema)).Error; err != nil {
+		closeDB(admin)
+		t.Fatalf("не удалось создать схему %s: %v", schema, err)
+	}
+
+	u := *baseURL
+	q := u.Query()
+	q.Set("search_path", schema+",public")
+	u.RawQuery = q.Encode()
+
+	db, err := open(u.String())
+	if err != nil {
+		t.Fatalf("не удалось подключиться к схеме %s: %v", schema, err)
+	}
+
+	t.Cleanup(func() {
+		closeDB(db)
+		admin.Exec(fmt.Sprintf(`DROP SCHEMA IF EXISTS %q CASCADE`, schema))
+		closeDB(admin)
+	})
+
+	if err := utils.Migrate(db); err != nil {
+		t.Fatalf("не удалось применить миграции: %v", err)
+	}
+
+	return db
+}
+
+// CreateDatabase создаёт пустую базу данных без миграций и возвращает настройки
+// подключения к ней. Нужна для проверки кода, который сам готовит схему, например utils.SetupDatabase.
+func CreateDatabase(t testing.TB) config.DatabaseConfig {
+	t.Helper()
+	requirePostgres(t)
+
+	name := "test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+
+	admin, err := open(baseURL.String())
+	if err != nil {
+		t.Fatalf("не удалось подключиться к тестовому PostgreSQL: %v", err)
+	}
+	if err := admin.Exec(fmt.Sprintf(`CREATE DATABASE %q`, name)).Error; err != nil {
+		closeDB(admin)
+		t.Fatalf("не удалось создать базу %s: %v", name, err)
+	}
+	t.Cleanup(func() {
+		admin.Exec(fmt.Sprintf(`DROP DATABASE IF EXISTS %q WITH (FORCE)`, name))
+		closeDB(admin)
+	})
+
+	password, _ := baseURL.User.Password()
+	return config.DatabaseConfig{
+		Host:     baseURL.Hostname(),
+		Port:     baseURL.Port(),
+		User:     baseURL.User.Username(),
+		Password: password,
+		Name:     name,
+		SSLMode:  "disable",
+	}
+}
@@ -0,0 +1,73 @@
+package testutil
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"gorm.io/gorm"
+)
+
+// ProfileOption изменяет профиль, собираемый фабрикой.
+type ProfileOption func(*models.Profile)
+
+func WithUserID(userID string) ProfileOption {
+	return func(p *models.Profile) { p.UserID = userID }
+}
+
+func WithUsername(username string) ProfileOption {
+	return func(p *models.Profile) { p.Username = username }
+}
+
+func WithEmail(email string) ProfileOption {
+	return func(p *models.Profile) { p.Email = email }
+}
+
+func WithRole(role string) ProfileOption {
+	return func(p *models.Profile) { p.Role = role }
+}
+
+func WithDisplayName(name string) ProfileOption {
+	return func(p *models.Profile) { p.DisplayName = name }
+}
+
+func WithBio(bio string) ProfileOption {
+	return func(p *models.Profile) { p.Bio = bio }
+}
+
+// ProfileFactory собирает валидные профили с уникальными user_id, username и email.
+type ProfileFactory struct {
+	db  *gorm.DB
+	seq atomic.Int64
+}
+
+func NewProfileFactory(db *gorm.DB) *ProfileFactory {
+	return &ProfileFactory{db: db}
+}
+
+// Build возвращает профиль без сохранения в базу.
+func (f *ProfileFactory) Build(opts ...ProfileOption) models.Profile {
+	n := f.seq.Add(1)
+	profile := models.Profile{
+		UserID:   uuid.NewString(),
+		Username: fmt.Sprintf("user%d", n),
+		Email:    fmt.Sprintf("user%d@example.com", n),
+		Role:     models.RoleUser,
+	}
+	for _, opt := range opts {
+		opt(&profile)
+	}
+	return profile
+}
+
+// Create сохраняет профиль в базу и завершает тест при ошибке.
+func (f *ProfileFactory) Create(t testing.TB, opts ...ProfileOption) models.Profile {
+	t.Helper()
+	profile := f.Build(opts...)
+	if err := f.db.Create(&profile).Error; err != nil {
+		t.Fatalf("не удалось создать профиль %s: %v", profile.Username, err)
+	}
+	return profile
+}
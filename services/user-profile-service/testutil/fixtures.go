@@ -0,0 +1,34 @@
+package testutil
+
+import (
+	"testing"
+
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"gorm.io/gorm"
+)
+
+// Fixtures — стандартный набор профилей для сценарных тестов.
+type Fixtures struct {
+	Alice   models.Profile
+	Bob     models.Profile
+	Admin   models.Profile
+	Deleted models.Profile
+}
+
+// LoadFixtures наполняет базу стандартным набором профилей.
+// Профиль Deleted удалён мягко и не должен находиться обычными запросами.
+func LoadFixtures(t testing.TB, db *gorm.DB) Fixtures {
+	t.Helper()
+	f := NewProfileFactory(db)
+
+	fx := Fixtures{
+		Alice:   f.Create(t, WithUsername("alice"), WithEmail("alice@example.com"), WithDisplayName("Alice")),
+		Bob:     f.Create(t, WithUsername("bob"), WithEmail("bob@example.com"), WithDisplayName("Bob")),
+		Admin:   f.Create(t, WithUsername("admin"), WithEmail("admin@example.com"), WithRole(models.RoleAdmin)),
+		Deleted: f.Create(t, WithUsername("ghost"), WithEmail("ghost@example.com")),
+	}
+	if err := db.Delete(&fx.Deleted).Error; err != nil {
+		t.Fatalf("не удалось удалить профиль ghost: %v", err)
+	}
+	return fx
+}
@@ -0,0 +1,179 @@
+// Package testutil содержит обвязку для интеграционных тестов: временный PostgreSQL,
+// изолированные схемы для каждого теста и фабрики моделей.
+package testutil
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	embeddedpostgres "github.com/fergusstrange/embedded-postgres"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Переменные окружения, управляющие обвязкой:
+//   - TEST_DATABASE_URL — использовать уже запущенный PostgreSQL вместо временного;
+//   - TEST_PG_BINARIES — каталог локально установленного PostgreSQL (с подкаталогом bin).
+//     Если не задан, ищем pg_ctl в PATH, а при его отсутствии скачиваем бинарники embedded-postgres;
+//   - TEST_SKIP_POSTGRES=1 — пропустить тесты с БД, если PostgreSQL не запустился.
+//     Без него недоступный PostgreSQL проваливает тесты пакета.
+const (
+	envDatabaseURL  = "TEST_DATABASE_URL"
+	envPgBinaries   = "TEST_PG_BINARIES"
+	envSkipPostgres = "TEST_SKIP_POSTGRES"
+)
+
+var (
+	// baseURL — строка подключения к базе, в которой создаются схемы тестов.
+	baseURL *url.URL
+	// startErr — причина, по которой PostgreSQL недоступен; тесты с БД в этом случае
+	// пропускаются, если это разрешено TEST_SKIP_POSTGRES.
+	startErr error
+)
+
+// RunWithPostgres поднимает временный PostgreSQL, выполняет тесты пакета и останавливает базу.
+// Если PostgreSQL не запустился, тесты не выполняются и код возврата ненулевой,
+// кроме случая TEST_SKIP_POSTGRES=1. Вызывается из TestMain:
+//
+//	func TestMain(m *testing.M) { os.Exit(testutil.RunWithPostgres(m)) }
+func RunWithPostgres(m *testing.M) int {
+	stop, err := startPostgres()
+	if err != nil {
+		if os.Getenv(envSkipPostgres) != "1" {
+			log.Printf("PostgreSQL для тестов недоступен: %v (задайте %s или %s, либо %s=1, чтобы пропустить тесты с БД)",
+				err, envDatabaseURL, envPgBinaries, envSkipPostgres)
+			return 1
+		}
+		startErr = err
+		log.Printf("PostgreSQL для тестов недоступен, интеграционные тесты будут пропущены (%s=1): %v", envSkipPostgres, err)
+	} else {
+		defer func() {
+			if err := stop(); err != nil {
+				log.Printf("Не удалось остановить тестовый PostgreSQL: %v", err)
+			}
+		}()
+	}
+
+	return m.Run()
+}
+
+func startPostgres() (func() error, error) {
+	if raw := os.Getenv(envDatabaseURL); raw != "" {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("неверный %s: %w", envDatabaseURL, err)
+		}
+		baseURL = u
+		return func() error { return nil }, prepareBase()
+	}
+
+	port, err := freePort()
+	if err != nil {
+		return nil, err
+	}
+	runtimeDir, err := os.MkdirTemp("", "profile-service-pg-")
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := embeddedpostgres.DefaultConfig().
+		Port(uint32(port)).
+		Database("profiles_test").
+		Username("postgres").
+		Password("postgres").
+		RuntimePath(runtimeDir).
+		StartTimeout(time.Minute).
+		Logger(io.Discard)
+	if binaries := localBinaries(); binaries != "" {
+		cfg = cfg.BinariesPath(binaries)
+	}
+
+	pg := embeddedpostgres.NewDatabase(cfg)
+	if err := pg.Start(); err != nil {
+		os.RemoveAll(runtimeDir)
+		return nil, err
+	}
+
+	baseURL = &url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword("postgres", "postgres"),
+		Host:     fmt.Sprintf("localhost:%d", port),
+		Path:     "/profiles_test",
+		RawQuery: "sslmode=disable",
+	}
+	stop := func() error {
+		defer os.RemoveAll(runtimeDir)
+		return pg.Stop()
+	}
+	if err := prepareBase(); err != nil {
+		stop()
+		return nil, err
+	}
+	return stop, nil
+}
+
+// prepareBase создаёт расширения в схеме public, чтобы они были доступны во всех тестовых схемах.
+func prepareBase() error {
+	db, err := open(baseURL.String())
+	if err != nil {
+		return err
+	}
+	defer closeDB(db)
+
+	return db.Exec(`CREATE EXTENSION IF NOT EXISTS "uuid-ossp";`).Error
+}
+
+// localBinaries возвращает каталог установленного PostgreSQL, если он есть.
+func localBinaries() string {
+	if dir := os.Getenv(envPgBinaries); dir != "" {
+		return dir
+	}
+	pgCtl, err := exec.LookPath("pg_ctl")
+	if err != nil {
+		return ""
+	}
+	if resolved, err := filepath.EvalSymlinks(pgCtl); err == nil {
+		pgCtl = resolved
+	}
+	return filepath.Dir(filepath.Dir(pgCtl))
+}
+
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+func open(dsn string) (*gorm.DB, error) {
+	return gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+}
+
+func closeDB(db *gorm.DB) {
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+}
+
+// requirePostgres пропускает тест, если PostgreSQL не удалось запустить и пропуск
+// разрешён TEST_SKIP_POSTGRES, и проваливает тест из пакета без RunWithPostgres.
+func requirePostgres(t testing.TB) {
+	t.Helper()
+	if baseURL == nil {
+		if startErr == nil {
+			t.Fatal("PostgreSQL не запущен: добавьте testutil.RunWithPostgres в TestMain пакета")
+		}
+		t.Skipf("PostgreSQL недоступен: %v", startErr)
+	}
+}
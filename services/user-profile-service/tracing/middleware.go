@@ -0,0 +1,66 @@
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// TraceIDKey — ключ gin-контекста с идентификатором трейса, его читает логгер запросов.
+	TraceIDKey = "trace_id"
+	// TraceIDHeader — заголовок ответа с идентификатором трейса.
+	TraceIDHeader = "X-Trace-Id"
+)
+
+// Middleware продолжает трейс из заголовка traceparent (его передаёт API Gateway)
+// или начинает новый и оборачивает обработку запроса в серверный спан.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+
+		route := c.FullPath()
+		spanName := c.Request.Method + " " + route
+		if route == "" {
+			spanName = c.Request.Method
+		}
+
+		ctx, span := Tracer().Start(ctx, spanName,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(c.Request.Method),
+				semconv.URLPath(c.Request.URL.Path),
+				semconv.ClientAddress(c.ClientIP()),
+				semconv.UserAgentOriginal(c.Request.UserAgent()),
+			),
+		)
+		defer span.End()
+		if route != "" {
+			span.SetAttributes(semconv.HTTPRoute(route))
+		}
+
+		if traceID := TraceID(ctx); traceID != "" {
+			c.Set(TraceIDKey, traceID)
+			c.Header(TraceIDHeader, traceID)
+		}
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		for _, err := range c.Errors {
+			span.RecordError(err.Err, trace.WithAttributes(attribute.String("gin.error.type", fmt.Sprint(err.Type))))
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func setupRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { tp.Shutdown(context.Background()) })
+	return recorder
+}
+
+func attr(attrs []attribute.KeyValue, key string) string {
+	for _, kv := range attrs {
+		if string(kv.Key) == key {
+			return kv.Value.Emit()
+		}
+	}
+	return ""
+}
+
+func TestMiddlewareContinuesIncomingTrace(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := setupRecorder(t)
+
+	var handlerTraceID string
+	r := gin.New()
+	r.Use(Middleware())
+	r.GET("/profiles/:user_id", func(c *gin.Context) {
+		handlerTraceID = TraceID(c.Request.Context())
+		c.Status(http.StatusInternalServerError)
+	})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/profiles/42", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if handlerTraceID != traceID {
+		t.Errorf("обработчик должен видеть входящий trace_id, получили %q", handlerTraceID)
+	}
+	if got := w.Header().Get(TraceIDHeader); got != traceID {
+		t.Errorf("заголовок %s: %q", TraceIDHeader, got)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("ожидали один спан, получили %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "GET /profiles/:user_id" {
+		t.Errorf("имя спана должно содержать шаблон маршрута, получили %q", span.Name())
+	}
+	if span.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("родитель спана: %s", span.Parent().SpanID())
+	}
+	if got := attr(span.Attributes(), "http.response.status_code"); got != "500" {
+		t.Errorf("код ответа в атрибутах: %q", got)
+	}
+	if span.Status().Code.String() != "Error" {
+		t.Errorf("ответ 500 должен помечать спан ошибкой, статус %v", span.Status())
+	}
+}
+
+func TestGormPluginRecordsStatement(t *testing.T) {
+	recorder := setupRecorder(t)
+
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:                 true,
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Use(GormPlugin()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, parent := Tracer().Start(context.Background(), "parent")
+	var row struct{ ID int }
+	db.WithContext(ctx).Table("user_profiles").Where("user_id = ?", "42").First(&row)
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("ожидали спан запроса и родительский спан, получили %d", len(spans))
+	}
+	query := spans[0]
+	if query.Name() != "db.query user_profiles" {
+		t.Errorf("имя спана: %q", query.Name())
+	}
+	if query.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Error("спан запроса должен быть дочерним для спана обработчика")
+	}
+	if got := attr(query.Attributes(), "db.query.text"); got == "" {
+		t.Error("в спане нет текста запроса")
+	}
+}
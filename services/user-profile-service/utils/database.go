@@ -1,35 +1,113 @@
 package utils
 
 import (
-	"fmt"
-	"log"
-	"os"
+	"log/slog"
+
+	"github.com/monst/story-craft/services/user-profile-service/audit"
+	"github.com/monst/story-craft/services/user-profile-service/config"
+	"github.com/monst/story-craft/services/user-profile-service/logging"
 	"github.com/monst/story-craft/services/user-profile-service/models"
+	"github.com/monst/story-craft/services/user-profile-service/realtime"
 
-	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
 )
 
-func SetupDatabase() (*gorm.DB, error) {
-	if err := godotenv.Load(); err != nil {
-		log.Println("Не удалось загрузить файл .env, используем переменные окружения системы")
+func SetupDatabase(cfg config.DatabaseConfig) (*gorm.DB, error) {
+	db, err := gorm.Open(postgres.Open(cfg.DSN()), &gorm.Config{
+		Logger: logging.NewGormLogger(slog.Default(), cfg.SlowQueryThreshold),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Чтения уходят на реплики, запись и явно помеченные запросы (dbresolver.Write) — в основную БД
+	if replicas := cfg.ReplicaDSNs(); len(replicas) > 0 {
+		dialectors := make([]gorm.Dialector, 0, len(replicas))
+		for _, dsn := range replicas {
+			dialectors = append(dialectors, postgres.Open(dsn))
+		}
+		resolver := dbresolver.Register(dbresolver.Config{
+			Replicas:          dialectors,
+			Policy:            dbresolver.RandomPolicy{},
+			TraceResolverMode: true,
+		}).
+			SetMaxOpenConns(cfg.MaxOpenConns).
+			SetMaxIdleConns(cfg.MaxIdleConns).
+			SetConnMaxLifetime(cfg.ConnMaxLifetime).
+			SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+		if err := db.Use(resolver); err != nil {
+			return nil, err
+		}
 	}
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s", os.Getenv("DB_HOST"), os.Getenv("DB_USER"), os.Getenv("DB_PASSWORD"), os.Getenv("DB_NAME"), os.Getenv("DB_PORT"))
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+
+	sqlDB, err := db.DB()
 	if err != nil {
 		return nil, err
 	}
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	if err := Migrate(db); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
 
+// Migrate подготавливает схему базы данных: включает расширения и применяет миграции моделей.
+// Используется как при старте сервиса, так и в интеграционных тестах.
+func Migrate(db *gorm.DB) error {
 	// enable uuid extension for generating UUIDs
 	if err := db.Exec(`CREATE EXTENSION IF NOT EXISTS "uuid-ossp";`).Error; err != nil {
-		return nil, err
+		return err
 	}
 
 	// Миграция схемы
-	if err := db.AutoMigrate(&models.Profile{}); err != nil {
-		return nil, err
+	if err := db.AutoMigrate(&models.Profile{}, &models.IdempotencyKey{}, &models.Report{}, &models.Notification{}, &models.EmailMessage{}, &models.EmailChange{}, &models.PushSubscription{}, &models.PushMessage{}, &models.DigestSettings{}, &models.Job{}); err != nil {
+		return err
+	}
+	// Идентификаторы событий для Last-Event-ID общие для всех экземпляров
+	if err := realtime.Migrate(db); err != nil {
+		return err
+	}
+	// Журнал изменений мигрируется отдельно: ему нужны триггеры, запрещающие правку записей
+	if err := audit.Migrate(db); err != nil {
+		return err
 	}
 
-	return db, nil
+	// Роли хранятся в верхнем регистре, как в auth-service и story-service
+	if err := db.Exec(`UPDATE user_profiles SET role = UPPER(role) WHERE role <> UPPER(role)`).Error; err != nil {
+		return err
+	}
+
+	if err := migrateNotificationPreferences(db); err != nil {
+		return err
+	}
+
+	return recordSchemaVersion(db)
+}
+
+// migrateNotificationPreferences переписывает сохранённые настройки уведомлений старых версий
+// в текущий формат. Чтение тоже приводит документ к текущей версии, но после миграции
+// хранимые данные можно разбирать и за пределами сервиса, не зная истории формата.
+func migrateNotificationPreferences(db *gorm.DB) error {
+	var profiles []models.Profile
+	err := db.Unscoped().Select("id", "notification_preferences").
+		Where("notification_preferences IS NOT NULL").
+		Where("COALESCE((notification_preferences->>'version')::int, 0) < ?", models.PreferencesVersion).
+		FindInBatches(&profiles, 500, func(tx *gorm.DB, _ int) error {
+			for _, p := range profiles {
+				// Scan уже привёл документ к текущей версии, осталось сохранить
+				if err := db.Unscoped().Model(&models.Profile{}).Where("id = ?", p.ID).
+					UpdateColumn("notification_preferences", p.NotificationPreferences).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+	return err
 }
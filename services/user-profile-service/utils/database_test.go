@@ -0,0 +1,59 @@
+package utils_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/monst/story-craft/services/user-profile-service/models"
+	"github.com/monst/story-craft/services/user-profile-service/testutil"
+	"github.com/monst/story-craft/services/user-profile-service/utils"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(testutil.RunWithPostgres(m))
+}
+
+func TestSetupDatabase(t *testing.T) {
+	db, err := utils.SetupDatabase(testutil.CreateDatabase(t))
+	if err != nil {
+		t.Fatalf("SetupDatabase: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	defer sqlDB.Close()
+
+	var extensions int64
+	db.Raw(`SELECT count(*) FROM pg_extension WHERE extname = 'uuid-ossp'`).Scan(&extensions)
+	if extensions != 1 {
+		t.Fatal("расширение uuid-ossp не установлено")
+	}
+
+	first := models.Profile{UserID: uuid.NewString(), Username: "first", Email: "first@example.com"}
+	if err := db.Create(&first).Error; err != nil {
+		t.Fatalf("не удалось создать профиль: %v", err)
+	}
+	if first.ID == uuid.Nil {
+		t.Error("id должен заполняться значением по умолчанию uuid_generate_v4()")
+	}
+
+	tests := []struct {
+		name    string
+		profile models.Profile
+	}{
+		{"user_id", models.Profile{UserID: first.UserID, Username: "u1", Email: "u1@example.com"}},
+		{"username", models.Profile{UserID: uuid.NewString(), Username: first.Username, Email: "u2@example.com"}},
+		{"email", models.Profile{UserID: uuid.NewString(), Username: "u3", Email: first.Email}},
+	}
+	for _, tt := range tests {
+		t.Run("уникальность "+tt.name, func(t *testing.T) {
+			if err := db.Create(&tt.profile).Error; err == nil {
+				t.Fatalf("ожидали нарушение уникальности по %s", tt.name)
+			}
+		})
+	}
+
+	// Повторный запуск миграций на уже подготовленной базе не должен падать.
+	if err := utils.Migrate(db); err != nil {
+		t.Fatalf("повторная миграция: %v", err)
+	}
+}
@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SchemaVersion — версия схемы, которую ожидает текущая сборка. Увеличивается
+// при каждом изменении моделей, которые мигрирует Migrate.
+const SchemaVersion = 16
+
+// SchemaMigration — запись о применённой версии схемы.
+type SchemaMigration struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false"`
+	AppliedAt time.Time `gorm:"not null;default:now()"`
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// recordSchemaVersion отмечает, что схема приведена к SchemaVersion.
+func recordSchemaVersion(db *gorm.DB) error {
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return err
+	}
+	return db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&SchemaMigration{Version: SchemaVersion, AppliedAt: time.Now()}).Error
+}
+
+// CurrentSchemaVersion возвращает последнюю применённую к базе версию схемы.
+func CurrentSchemaVersion(ctx context.Context, db *gorm.DB) (int, error) {
+	var version int
+	err := db.WithContext(ctx).Model(&SchemaMigration{}).Select("COALESCE(MAX(version), 0)").Scan(&version).Error
+	return version, err
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// RecentWrites помнит профили, изменённые в последние window, чтобы их чтения
+// шли в основную БД, а не в реплику, которая могла ещё не получить изменения.
+// Состояние хранится в памяти процесса: при нескольких экземплярах сервиса гарантия
+// действует, только если балансировщик направляет запросы пользователя на один
+// экземпляр (sticky routing). Иначе чтение после записи на другом экземпляре
+// может прийти из отстающей реплики.
+type RecentWrites struct {
+	window time.Duration
+	now    func() time.Time
+
+	mu    sync.Mutex
+	until map[string]time.Time
+}
+
+func NewRecentWrites(window time.Duration) *RecentWrites {
+	return &RecentWrites{window: window, now: time.Now, until: make(map[string]time.Time)}
+}
+
+// Mark отмечает, что профиль key только что изменён.
+func (r *RecentWrites) Mark(key string) {
+	if r == nil || r.window <= 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	// Чистим просроченные записи, чтобы карта не росла бесконечно
+	if len(r.until) >= 1024 {
+		for k, t := range r.until {
+			if now.After(t) {
+				delete(r.until, k)
+			}
+		}
+	}
+	r.until[key] = now.Add(r.window)
+}
+
+// Active сообщает, изменялся ли хотя бы один из профилей keys в пределах окна.
+func (r *RecentWrites) Active(keys ...string) bool {
+	if r == nil || r.window <= 0 {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	for _, key := range keys {
+		if t, ok := r.until[key]; ok {
+			if now.Before(t) {
+				return true
+			}
+			delete(r.until, key)
+		}
+	}
+	return false
+}
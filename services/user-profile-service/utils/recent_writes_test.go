@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecentWrites(t *testing.T) {
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	rw := NewRecentWrites(5 * time.Second)
+	rw.now = func() time.Time { return now }
+
+	if rw.Active("alice") {
+		t.Fatal("профиль без изменений не должен читаться из основной БД")
+	}
+
+	rw.Mark("alice")
+	if !rw.Active("alice") || !rw.Active("bob", "alice") {
+		t.Fatal("сразу после изменения профиль должен читаться из основной БД")
+	}
+	if rw.Active("bob") {
+		t.Fatal("изменение alice не должно влиять на bob")
+	}
+
+	now = now.Add(6 * time.Second)
+	if rw.Active("alice") {
+		t.Fatal("после окна чтения должны вернуться на реплику")
+	}
+}
+
+func TestRecentWritesDisabled(t *testing.T) {
+	rw := NewRecentWrites(0)
+	rw.Mark("alice")
+	if rw.Active("alice") {
+		t.Fatal("при нулевом окне отслеживание выключено")
+	}
+
+	var nilRW *RecentWrites
+	nilRW.Mark("alice")
+	if nilRW.Active("alice") {
+		t.Fatal("nil RecentWrites должен быть безопасным")
+	}
+}